@@ -1,7 +1,6 @@
 package cache
 
 import (
-	"strings"
 	"sync"
 	"time"
 
@@ -10,14 +9,14 @@ import (
 )
 
 type Key struct {
-	Name  string // lower-cased, no trailing dot
+	Name  string // packet.CanonicalName form
 	Type  uint16
 	Class uint16
 }
 
 func KeyOf(q *packet.DNSQuestion) Key {
 	return Key{
-		Name:  strings.ToLower(strings.TrimSuffix(q.Name, ".")),
+		Name:  packet.CanonicalName(q.Name),
 		Type:  uint16(q.Type),
 		Class: uint16(q.Class),
 	}
@@ -60,7 +59,7 @@ func (c *Cache) Get(k Key) (*packet.DNSPacket, bool) {
 		delete(c.items, k)
 		return nil, false
 	}
-	return cloneForReuse(e.resp), true
+	return e.resp.Clone(), true
 }
 
 func (c *Cache) Put(k Key, resp *packet.DNSPacket) {
@@ -77,11 +76,35 @@ func (c *Cache) Put(k Key, resp *packet.DNSPacket) {
 		c.evictOne(k)
 	}
 	c.items[k] = entry{
-		resp:      cloneForStore(resp),
+		resp:      resp.Clone(),
 		expiresAt: c.now().Add(ttl),
 	}
 }
 
+// Remove drops every entry for name, whatever its type and class.
+func (c *Cache) Remove(name string) {
+	name = packet.CanonicalName(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.items {
+		if k.Name == name {
+			delete(c.items, k)
+		}
+	}
+}
+
+// RemoveZone drops every entry at or below origin, for when a whole zone
+// has been replaced.
+func (c *Cache) RemoveZone(origin string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.items {
+		if packet.IsSubDomain(origin, k.Name) {
+			delete(c.items, k)
+		}
+	}
+}
+
 func (c *Cache) Len() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -89,7 +112,7 @@ func (c *Cache) Len() int {
 }
 
 func (c *Cache) computeTTL(resp *packet.DNSPacket) time.Duration {
-	if resp.Header.RCode == 3 || len(resp.Answers) == 0 {
+	if resp.RCode() == packet.DNSRCodeNXDomain || len(resp.Answers) == 0 {
 		return c.negTTL
 	}
 	min := uint32(0)
@@ -125,52 +148,6 @@ func (c *Cache) evictOne(skip Key) {
 	}
 }
 
-func cloneForStore(p *packet.DNSPacket) *packet.DNSPacket {
-	h := *p.Header
-	return &packet.DNSPacket{
-		Header:      &h,
-		Questions:   p.Questions,
-		Answers:     p.Answers,
-		Authorities: p.Authorities,
-		Additionals: p.Additionals,
-	}
-}
-
-func cloneForReuse(p *packet.DNSPacket) *packet.DNSPacket {
-	h := *p.Header
-	return &packet.DNSPacket{
-		Header:      &h,
-		Questions:   p.Questions,
-		Answers:     p.Answers,
-		Authorities: p.Authorities,
-		Additionals: p.Additionals,
-	}
-}
-
 func recordTTL(r packet.DNSResource) uint32 {
-	switch x := r.(type) {
-	case *packet.DNSResourceRecordA:
-		return x.TTL
-	case *packet.DNSResourceRecordAAAA:
-		return x.TTL
-	case *packet.DNSResourceRecordCNAME:
-		return x.TTL
-	case *packet.DNSResourceRecordMX:
-		return x.TTL
-	case *packet.DNSResourceRecordNS:
-		return x.TTL
-	case *packet.DNSResourceRecordTXT:
-		return x.TTL
-	case *packet.DNSResourceRecordPTR:
-		return x.TTL
-	case *packet.DNSResourceRecordSOA:
-		return x.TTL
-	case *packet.DNSResourceRecordSRV:
-		return x.TTL
-	case *packet.DNSResourceRecordEDNS:
-		return x.TTL
-	case *packet.DNSResourceRecordUnknown:
-		return x.TTL
-	}
-	return 0
+	return r.GetHeader().TTL
 }
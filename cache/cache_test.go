@@ -162,3 +162,49 @@ func TestHeaderIsolation(t *testing.T) {
 		t.Error("cache entry header leaked between Get calls")
 	}
 }
+
+func TestRecordIsolation(t *testing.T) {
+	c, _ := newTestCache(t, time.Second, time.Hour, time.Minute, 100)
+	k := keyForA("example.com")
+	resp := newAResponse("example.com", 300)
+	c.Put(k, resp)
+	resp.Answers[0].(*packet.DNSResourceRecordA).Address = "5.6.7.8"
+
+	got1, _ := c.Get(k)
+	got1.Answers[0].GetHeader().TTL = 1
+	got1.Answers = append(got1.Answers[:0], got1.Answers[0])
+
+	got2, _ := c.Get(k)
+	a := got2.Answers[0].(*packet.DNSResourceRecordA)
+	if a.Address != "1.2.3.4" || a.TTL != 300 {
+		t.Errorf("cache entry record leaked: %s ttl=%d", a.Address, a.TTL)
+	}
+}
+
+func TestRemoveZone(t *testing.T) {
+	c, _ := newTestCache(t, time.Second, time.Hour, time.Minute, 100)
+	for _, name := range []string{"example.com", "a.example.com", "notexample.com"} {
+		c.Put(keyForA(name), newAResponse(name, 300))
+	}
+
+	c.RemoveZone("Example.COM.")
+	for name, cached := range map[string]bool{"example.com": false, "a.example.com": false, "notexample.com": true} {
+		if _, ok := c.Get(keyForA(name)); ok != cached {
+			t.Errorf("%s: cached=%v, want %v", name, ok, cached)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	c, _ := newTestCache(t, time.Second, time.Hour, time.Minute, 100)
+	c.Put(keyForA("a.example.com"), newAResponse("a.example.com", 300))
+	c.Put(keyForA("b.example.com"), newAResponse("b.example.com", 300))
+
+	c.Remove("A.Example.com.")
+	if _, ok := c.Get(keyForA("a.example.com")); ok {
+		t.Error("removed name still cached")
+	}
+	if _, ok := c.Get(keyForA("b.example.com")); !ok {
+		t.Error("other names should stay cached")
+	}
+}
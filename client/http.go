@@ -5,22 +5,27 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/lsongdev/dns-go/packet"
 )
 
 // HTTPClient is a DNS over HTTPS (DoH) client (RFC 8484).
-// Supports both GET and POST methods.
+// Supports both GET and POST methods, and the JSON API of Google Public
+// DNS and Cloudflare.
 // Uses HTTP/2 which is required by most DoH servers.
 type HTTPClient struct {
 	Server  string
 	Timeout time.Duration
 	UsePost bool // Use POST method instead of GET
+	JSON    bool // Use the application/dns-json API instead of RFC 8484
 }
 
 // NewHTTPClient creates a new DoH client.
@@ -43,6 +48,17 @@ func NewHTTPClientPost(server string) *HTTPClient {
 	}
 }
 
+// NewHTTPClientJSON creates a client for the JSON API of Google Public DNS
+// and Cloudflare.
+// server should be a full URL like "https://dns.google/resolve"
+func NewHTTPClientJSON(server string) *HTTPClient {
+	return &HTTPClient{
+		Server:  server,
+		Timeout: 5 * time.Second,
+		JSON:    true,
+	}
+}
+
 // createHTTPClient creates an HTTP client with HTTP/2 support.
 func createHTTPClient(timeout time.Duration) *http.Client {
 	// Create transport with HTTP/2 support
@@ -66,8 +82,13 @@ func createHTTPClient(timeout time.Duration) *http.Client {
 
 // Query sends a DNS query and returns the response.
 func (c *HTTPClient) Query(query *packet.DNSPacket) (res *packet.DNSPacket, err error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if c.JSON {
+		return c.queryJSON(query)
+	}
 	queryData := query.Bytes()
-	httpClient := createHTTPClient(c.Timeout)
 
 	var req *http.Request
 	if c.UsePost {
@@ -88,13 +109,66 @@ func (c *HTTPClient) Query(query *packet.DNSPacket) (res *packet.DNSPacket, err
 	}
 
 	req.Header.Set("Accept", "application/dns-message")
+	body, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return packet.FromBytes(body)
+}
+
+// queryJSON asks the JSON API for the first question of query, passing on
+// its CD bit, DO bit and client subnet, and rebuilds the answer as a
+// packet.
+func (c *HTTPClient) queryJSON(query *packet.DNSPacket) (*packet.DNSPacket, error) {
+	if len(query.Questions) == 0 {
+		return nil, fmt.Errorf("JSON API queries need a question")
+	}
+	q := query.Questions[0]
+	params := url.Values{}
+	params.Set("name", q.Name)
+	params.Set("type", strconv.Itoa(int(q.Type)))
+	if query.Header.CheckingDisabled() {
+		params.Set("cd", "1")
+	}
+	if opt := query.EDNS(); opt != nil {
+		if opt.GetDNSSECOK() {
+			params.Set("do", "1")
+		}
+		if ecs, err := opt.ClientSubnet(); err == nil && ecs != nil {
+			params.Set("edns_client_subnet", fmt.Sprintf("%s/%d", ecs.Address, ecs.SourcePrefix))
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, c.Server+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-json")
+	body, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var out packet.DNSJSONResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("invalid JSON answer: %v", err)
+	}
+	res, err := out.Packet()
+	if err != nil {
+		return nil, err
+	}
+	res.Header.ID = query.Header.ID
+	return res, nil
+}
+
+// do sends req and returns the body of a 200 answer.
+func (c *HTTPClient) do(req *http.Request) ([]byte, error) {
 	req.Header.Set("User-Agent", "dns-go")
 
 	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
 	defer cancel()
 	req = req.WithContext(ctx)
 
-	resp, err := httpClient.Do(req)
+	resp, err := createHTTPClient(c.Timeout).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -103,13 +177,7 @@ func (c *HTTPClient) Query(query *packet.DNSPacket) (res *packet.DNSPacket, err
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("DoH server returned status %d", resp.StatusCode)
 	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	return packet.FromBytes(body)
+	return io.ReadAll(resp.Body)
 }
 
 func (c *HTTPClient) Close() error {
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/binary"
 	"fmt"
@@ -17,6 +18,9 @@ import (
 type TCPClient struct {
 	Server  string
 	Timeout time.Duration
+	// TSIG, when set, signs every query with the key and requires the
+	// response to carry a valid signature from it (RFC 8945).
+	TSIG *packet.TSIGKey
 
 	mu        sync.Mutex
 	conn      net.Conn
@@ -56,6 +60,19 @@ func NewTLSClientWithConfig(server string, tlsConfig *tls.Config) *TCPClient {
 
 // Query sends a DNS query and returns the response.
 func (c *TCPClient) Query(req *packet.DNSPacket) (res *packet.DNSPacket, err error) {
+	var requestMAC []byte
+	if c.TSIG != nil {
+		req = req.Clone()
+		rr, err := req.SignTSIG(*c.TSIG, nil)
+		if err != nil {
+			return nil, err
+		}
+		requestMAC = rr.MAC
+	}
+	var data bytes.Buffer
+	if err := req.PackTo(&data, true); err != nil {
+		return nil, err
+	}
 	conn, err := c.getConn()
 	if err != nil {
 		return nil, err
@@ -66,33 +83,22 @@ func (c *TCPClient) Query(req *packet.DNSPacket) (res *packet.DNSPacket, err err
 		return nil, err
 	}
 
-	// Encode the query with 2-byte length prefix
-	queryData := req.Bytes()
-	lengthBuf := make([]byte, 2)
-	binary.BigEndian.PutUint16(lengthBuf, uint16(len(queryData)))
-
-	// Write length prefix + query data
-	_, err = conn.Write(append(lengthBuf, queryData...))
-	if err != nil {
+	if err := writeMsg(conn, data.Bytes()); err != nil {
 		c.closeConn()
 		return nil, err
 	}
-
-	// Read 2-byte length prefix
-	lengthBuf = make([]byte, 2)
-	_, err = io.ReadFull(conn, lengthBuf)
+	buf, err := readMsg(conn)
 	if err != nil {
 		c.closeConn()
 		return nil, err
 	}
-	msgLen := binary.BigEndian.Uint16(lengthBuf)
 
-	// Read response data
-	buf := make([]byte, msgLen)
-	_, err = io.ReadFull(conn, buf)
-	if err != nil {
-		c.closeConn()
-		return nil, err
+	if c.TSIG != nil {
+		keyring := packet.TSIGKeyring{}
+		keyring.Add(*c.TSIG)
+		if _, err := packet.VerifyTSIG(buf, keyring, requestMAC); err != nil {
+			return nil, err
+		}
 	}
 
 	res, err = packet.FromBytes(buf)
@@ -100,13 +106,90 @@ func (c *TCPClient) Query(req *packet.DNSPacket) (res *packet.DNSPacket, err err
 		return nil, err
 	}
 
-	if res.Header.RCode != 0 {
-		return nil, fmt.Errorf("query failed: %v", res.Header.RCode)
+	if res.RCode() != packet.DNSRCodeNoError {
+		return nil, fmt.Errorf("query failed: %v", res.RCode())
 	}
 
 	return res, nil
 }
 
+// Transfer fetches zone with AXFR (RFC 5936) and returns its records in
+// the order the server sent them, starting with the SOA. The SOA that
+// repeats at the end of the transfer is not included. With TSIG set the
+// request is signed and every message of the answer must be covered by a
+// signature.
+func (c *TCPClient) Transfer(zone string) ([]packet.DNSResource, error) {
+	req := packet.NewPacket()
+	req.AddQuestion(&packet.DNSQuestion{Name: zone, Type: packet.DNSTypeAXFR, Class: packet.DNSClassIN})
+	var verifier *packet.TSIGVerifier
+	if c.TSIG != nil {
+		rr, err := req.SignTSIG(*c.TSIG, nil)
+		if err != nil {
+			return nil, err
+		}
+		keyring := packet.TSIGKeyring{}
+		keyring.Add(*c.TSIG)
+		verifier = packet.NewTSIGVerifier(keyring, rr.MAC)
+	}
+	var data bytes.Buffer
+	if err := req.PackTo(&data, true); err != nil {
+		return nil, err
+	}
+	conn, err := c.getConn()
+	if err != nil {
+		return nil, err
+	}
+	// the transfer leaves the connection in no state to reuse
+	defer c.closeConn()
+	if err := conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return nil, err
+	}
+	if err := writeMsg(conn, data.Bytes()); err != nil {
+		return nil, err
+	}
+
+	var records []packet.DNSResource
+	for {
+		// Timeout applies to each message, not the whole transfer
+		if err := conn.SetReadDeadline(time.Now().Add(c.Timeout)); err != nil {
+			return nil, err
+		}
+		buf, err := readMsg(conn)
+		if err != nil {
+			return nil, err
+		}
+		if verifier != nil {
+			if _, err := verifier.Verify(buf); err != nil {
+				return nil, err
+			}
+		}
+		res, err := packet.FromBytes(buf)
+		if err != nil {
+			return nil, err
+		}
+		if res.Header.ID != req.Header.ID {
+			return nil, fmt.Errorf("transfer %s: response ID %d does not match %d", zone, res.Header.ID, req.Header.ID)
+		}
+		if res.RCode() != packet.DNSRCodeNoError {
+			return nil, fmt.Errorf("transfer %s failed: %v", zone, res.RCode())
+		}
+		for _, rr := range res.Answers {
+			if len(records) == 0 && rr.GetType() != packet.DNSTypeSOA {
+				return nil, fmt.Errorf("transfer %s: first record is %s, not SOA", zone, rr.GetType())
+			}
+			if len(records) > 0 && rr.GetType() == packet.DNSTypeSOA {
+				if verifier != nil {
+					if err := verifier.Done(); err != nil {
+						return nil, err
+					}
+				}
+				return records, nil
+			}
+			records = append(records, rr)
+		}
+	}
+}
+
 // Close closes the underlying connection.
 func (c *TCPClient) Close() error {
 	return c.closeConn()
@@ -152,3 +235,25 @@ func (c *TCPClient) closeConn() error {
 	}
 	return nil
 }
+
+// writeMsg writes data with its 2-byte length prefix.
+func writeMsg(conn net.Conn, data []byte) error {
+	msg := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(msg, uint16(len(data)))
+	copy(msg[2:], data)
+	_, err := conn.Write(msg)
+	return err
+}
+
+// readMsg reads one length-prefixed message.
+func readMsg(conn net.Conn) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
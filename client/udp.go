@@ -25,6 +25,9 @@ func NewUDPClient(server string) *UDPClient {
 }
 
 func (client *UDPClient) Query(req *packet.DNSPacket) (res *packet.DNSPacket, err error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
 	conn, err := client.getConn()
 	if err != nil {
 		return nil, err
@@ -53,8 +56,8 @@ func (client *UDPClient) Query(req *packet.DNSPacket) (res *packet.DNSPacket, er
 		return nil, err
 	}
 
-	if res.Header.RCode != 0 {
-		return nil, fmt.Errorf("query failed: %v", res.Header.RCode)
+	if res.RCode() != packet.DNSRCodeNoError {
+		return nil, fmt.Errorf("query failed: %v", res.RCode())
 	}
 
 	return res, nil
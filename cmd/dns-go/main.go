@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
-	"os"
+	"net"
+	"net/http"
 	"os/signal"
 	"syscall"
 
@@ -28,37 +32,70 @@ func main() {
 	}
 	defer handler.Close()
 
-	errCh := make(chan error, len(cfg.Listens))
-	for _, l := range cfg.Listens {
-		l := l
-		go func() {
-			log.Printf("listen %-4s %s", l.Type, l.Addr)
-			errCh <- listen(l, handler)
-		}()
+	keyring, err := pipeline.TSIGKeyring(cfg.TSIG)
+	if err != nil {
+		log.Fatalf("tsig: %v", err)
 	}
 
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	srv := &server.Server{
+		MaxUDPSize:     cfg.MaxUDPSize,
+		TCPIdleTimeout: cfg.TCPIdleTimeout.Duration(),
+		TCPReadTimeout: cfg.TCPReadTimeout.Duration(),
+		MaxTCPQueries:  cfg.MaxTCPQueries,
+		MaxTCPConns:    cfg.MaxTCPConns,
+	}
+	for _, l := range cfg.Listens {
+		h := &server.TSIGHandler{Handler: handler, Keyring: keyring, Require: l.RequireTSIG}
+		addr, err := listen(srv, l, h)
+		if err != nil {
+			log.Fatalf("listen %s %s: %v", l.Type, l.Addr, err)
+		}
+		log.Printf("listen %-4s %s", l.Type, addr)
+	}
 
-	select {
-	case err := <-errCh:
+	// on SIGINT or SIGTERM, Serve stops accepting queries and waits for
+	// the ones in flight before returning
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := srv.Serve(ctx); !errors.Is(err, server.ErrServerClosed) {
 		log.Fatalf("listener error: %v", err)
-	case sig := <-sigCh:
-		log.Printf("received %v, shutting down", sig)
 	}
+	log.Printf("shut down")
 }
 
-func listen(l config.ListenSpec, h server.DNSHandler) error {
+func listen(srv *server.Server, l config.ListenSpec, h server.DNSHandler) (net.Addr, error) {
 	switch l.Type {
 	case "udp":
-		return server.ListenUDP(l.Addr, h)
+		return srv.ListenUDP(l.Addr, h)
 	case "tcp":
-		return server.ListenTCP(l.Addr, h)
+		return srv.ListenTCP(l.Addr, h)
 	case "tls", "dot":
-		return server.ListenTLS(l.Addr, l.CertFile, l.KeyFile, h)
+		cfg, err := tlsConfig(l)
+		if err != nil {
+			return nil, err
+		}
+		return srv.ListenTLS(l.Addr, cfg, h)
 	case "doh", "http":
-		return server.ListenHTTP(l.Addr, h)
+		mux := http.NewServeMux()
+		mux.Handle(l.Path, &server.DoHHandler{Handler: h})
+		mux.Handle(l.JSONPath, &server.JSONHandler{Handler: h})
+		if l.CertFile == "" {
+			return srv.ListenHTTP(l.Addr, mux)
+		}
+		cfg, err := tlsConfig(l)
+		if err != nil {
+			return nil, err
+		}
+		return srv.ListenHTTPS(l.Addr, cfg, mux)
 	default:
-		return fmt.Errorf("unknown listen type: %s", l.Type)
+		return nil, fmt.Errorf("unknown listen type: %s", l.Type)
+	}
+}
+
+func tlsConfig(l config.ListenSpec) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(l.CertFile, l.KeyFile)
+	if err != nil {
+		return nil, err
 	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
 }
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net"
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -26,18 +29,37 @@ func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
 }
 
 type Config struct {
-	Listens []ListenSpec `yaml:"listens"`
-	Cache   CacheSpec    `yaml:"cache"`
-	Domains []DomainSpec `yaml:"domains"`
-	Proxy   ProxySpec    `yaml:"proxy"`
-	Filters FiltersSpec  `yaml:"filters"`
+	Listens []ListenSpec  `yaml:"listens"`
+	Cache   CacheSpec     `yaml:"cache"`
+	Domains []DomainSpec  `yaml:"domains"`
+	Proxy   ProxySpec     `yaml:"proxy"`
+	Filters FiltersSpec   `yaml:"filters"`
+	TSIG    []TSIGKeySpec `yaml:"tsig_keys"`
+	Policy  PolicySpec    `yaml:"policy"`
+
+	// MaxUDPSize caps UDP responses, whatever size the client
+	// advertises; longer ones are truncated. Zero means 1232 bytes.
+	MaxUDPSize int `yaml:"max_udp_size"`
+
+	// TCP and DoT connections are closed after TCPIdleTimeout without a
+	// query; TCPReadTimeout bounds reading the rest of a query and writing
+	// each response. A connection has at most MaxTCPQueries queries in
+	// hand, and at most MaxTCPConns connections are open at once. Zero
+	// means the server's default (10s, 5s, 16 and 512).
+	TCPIdleTimeout Duration `yaml:"tcp_idle_timeout"`
+	TCPReadTimeout Duration `yaml:"tcp_read_timeout"`
+	MaxTCPQueries  int      `yaml:"max_tcp_queries"`
+	MaxTCPConns    int      `yaml:"max_tcp_conns"`
 }
 
 type ListenSpec struct {
-	Type     string `yaml:"type"`
-	Addr     string `yaml:"addr"`
-	CertFile string `yaml:"cert_file"`
-	KeyFile  string `yaml:"key_file"`
+	Type        string `yaml:"type"`
+	Addr        string `yaml:"addr"`
+	CertFile    string `yaml:"cert_file"`
+	KeyFile     string `yaml:"key_file"`
+	RequireTSIG bool   `yaml:"require_tsig"` // refuse requests not signed with a tsig_keys entry
+	Path        string `yaml:"path"`         // doh: where queries are served, default /dns-query
+	JSONPath    string `yaml:"json_path"`    // doh: where the dns-json API is served, default /resolve
 }
 
 type CacheSpec struct {
@@ -48,10 +70,40 @@ type CacheSpec struct {
 	MaxEntries  int      `yaml:"max_entries"`
 }
 
+// PolicySpec relaxes how requests that aren't a plain query are answered.
+// The zero value follows the RFCs: NOTIMP for opcodes other than QUERY,
+// UPDATE and NOTIFY, FORMERR unless a query has exactly one question, and
+// BADVERS for EDNS versions above 0.
+type PolicySpec struct {
+	DropUnknownOpcodes bool `yaml:"drop_unknown_opcodes"` // leave them unanswered instead
+	AllowMultiQuestion bool `yaml:"allow_multi_question"` // answer the first question, ignore the rest
+	IgnoreEDNSVersion  bool `yaml:"ignore_edns_version"`  // answer any version as if it were 0
+}
+
 type DomainSpec struct {
 	Domain   string   `yaml:"domain"`
 	Records  []string `yaml:"records"`
 	ZoneFile string   `yaml:"zone_file"`
+	// AllowUpdate lists who may change the zone with DNS UPDATE: tsig_keys
+	// names and client IPs or CIDRs. Updates are refused when it's empty,
+	// and a secondary zone (primary) can't have one.
+	AllowUpdate []string `yaml:"allow_update"`
+	// Primary (host:port) makes the domain a secondary zone, transferred
+	// with AXFR at startup, on NOTIFY and at the SOA refresh interval.
+	Primary string `yaml:"primary"`
+	// AllowNotify lists who may send NOTIFY, in the form of allow_update.
+	// It defaults to the primary's address.
+	AllowNotify []string `yaml:"allow_notify"`
+	// Notify lists secondaries (host:port) to send NOTIFY to whenever the
+	// zone changes.
+	Notify []string `yaml:"notify"`
+	// AllowTransfer lists who may copy the zone with AXFR, in the form of
+	// allow_update. It defaults to the addresses in notify and tsig_key.
+	AllowTransfer []string `yaml:"allow_transfer"`
+	// TSIGKey names the tsig_keys entry shared with the zone's peers. It
+	// signs the transfers from primary and the NOTIFY sent to notify, and
+	// NOTIFY received for the zone must then be signed with it.
+	TSIGKey string `yaml:"tsig_key"`
 }
 
 type ProxySpec struct {
@@ -80,6 +132,18 @@ type ListSpec struct {
 	Refresh Duration `yaml:"refresh"`
 }
 
+// TSIGKeySpec is a shared TSIG key, written as in BIND's key statement.
+type TSIGKeySpec struct {
+	Name      string `yaml:"name"`
+	Algorithm string `yaml:"algorithm"` // hmac-sha256 (default) or hmac-sha512
+	Secret    string `yaml:"secret"`    // base64
+}
+
+// DecodeSecret returns the key's secret bytes.
+func (k TSIGKeySpec) DecodeSecret() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(k.Secret)
+}
+
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -116,6 +180,20 @@ func (c *Config) applyDefaults() {
 	if c.Proxy.Strategy == "" {
 		c.Proxy.Strategy = "failover"
 	}
+	for i := range c.Listens {
+		l := &c.Listens[i]
+		if (l.Type == "doh" || l.Type == "http") && l.Path == "" {
+			l.Path = "/dns-query"
+		}
+		if (l.Type == "doh" || l.Type == "http") && l.JSONPath == "" {
+			l.JSONPath = "/resolve"
+		}
+	}
+	for i := range c.TSIG {
+		if c.TSIG[i].Algorithm == "" {
+			c.TSIG[i].Algorithm = "hmac-sha256"
+		}
+	}
 	for i := range c.Proxy.Upstreams {
 		u := &c.Proxy.Upstreams[i]
 		if u.Type == "doh" && u.Method == "" {
@@ -143,6 +221,81 @@ func (c *Config) Validate() error {
 		if (l.Type == "tls" || l.Type == "dot") && (l.CertFile == "" || l.KeyFile == "") {
 			return fmt.Errorf("listens[%d]: type %q requires cert_file and key_file", i, l.Type)
 		}
+		if (l.Type == "doh" || l.Type == "http") && (l.CertFile == "") != (l.KeyFile == "") {
+			return fmt.Errorf("listens[%d]: cert_file and key_file go together", i)
+		}
+		if l.Path != "" && !strings.HasPrefix(l.Path, "/") {
+			return fmt.Errorf("listens[%d]: path %q must start with /", i, l.Path)
+		}
+		if l.JSONPath != "" && !strings.HasPrefix(l.JSONPath, "/") {
+			return fmt.Errorf("listens[%d]: json_path %q must start with /", i, l.JSONPath)
+		}
+		if l.JSONPath != "" && l.JSONPath == l.Path {
+			return fmt.Errorf("listens[%d]: path and json_path are both %q", i, l.Path)
+		}
+		if l.RequireTSIG && len(c.TSIG) == 0 {
+			return fmt.Errorf("listens[%d]: require_tsig needs at least one tsig_keys entry", i)
+		}
+	}
+	if c.MaxUDPSize != 0 && (c.MaxUDPSize < 512 || c.MaxUDPSize > 65535) {
+		return fmt.Errorf("max_udp_size %d out of range (want 512-65535)", c.MaxUDPSize)
+	}
+	if c.TCPIdleTimeout < 0 || c.TCPReadTimeout < 0 {
+		return fmt.Errorf("tcp_idle_timeout and tcp_read_timeout can't be negative")
+	}
+	if c.MaxTCPQueries < 0 || c.MaxTCPConns < 0 {
+		return fmt.Errorf("max_tcp_queries and max_tcp_conns can't be negative")
+	}
+	names := make(map[string]bool, len(c.TSIG))
+	for i, k := range c.TSIG {
+		if k.Name == "" {
+			return fmt.Errorf("tsig_keys[%d]: name required", i)
+		}
+		name := strings.ToLower(strings.TrimSuffix(k.Name, "."))
+		if names[name] {
+			return fmt.Errorf("tsig_keys[%d]: duplicate key %q", i, k.Name)
+		}
+		names[name] = true
+		switch strings.ToLower(strings.TrimSuffix(k.Algorithm, ".")) {
+		case "hmac-sha256", "hmac-sha512":
+		default:
+			return fmt.Errorf("tsig_keys[%d]: algorithm %q not supported (want hmac-sha256 or hmac-sha512)", i, k.Algorithm)
+		}
+		if secret, err := k.DecodeSecret(); err != nil || len(secret) == 0 {
+			return fmt.Errorf("tsig_keys[%d]: secret must be non-empty base64", i)
+		}
+	}
+	for i, d := range c.Domains {
+		if err := validatePeers(d.AllowUpdate, names); err != nil {
+			return fmt.Errorf("domains[%d]: allow_update %w", i, err)
+		}
+		if err := validatePeers(d.AllowNotify, names); err != nil {
+			return fmt.Errorf("domains[%d]: allow_notify %w", i, err)
+		}
+		if err := validatePeers(d.AllowTransfer, names); err != nil {
+			return fmt.Errorf("domains[%d]: allow_transfer %w", i, err)
+		}
+		if d.TSIGKey != "" && !names[strings.ToLower(strings.TrimSuffix(d.TSIGKey, "."))] {
+			return fmt.Errorf("domains[%d]: tsig_key %q is not in tsig_keys", i, d.TSIGKey)
+		}
+		if d.Primary != "" {
+			if len(d.Records) > 0 || d.ZoneFile != "" {
+				return fmt.Errorf("domains[%d]: primary can't be combined with records or zone_file", i)
+			}
+			if len(d.AllowUpdate) > 0 {
+				return fmt.Errorf("domains[%d]: primary can't be combined with allow_update", i)
+			}
+			if _, _, err := net.SplitHostPort(d.Primary); err != nil {
+				return fmt.Errorf("domains[%d]: primary %q must be host:port", i, d.Primary)
+			}
+		} else if len(d.AllowNotify) > 0 {
+			return fmt.Errorf("domains[%d]: allow_notify requires primary", i)
+		}
+		for _, addr := range d.Notify {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				return fmt.Errorf("domains[%d]: notify %q must be host:port", i, addr)
+			}
+		}
 	}
 	if c.Proxy.Strategy != "failover" {
 		return fmt.Errorf("proxy.strategy %q not supported (only 'failover' in v1)", c.Proxy.Strategy)
@@ -172,3 +325,20 @@ func (c *Config) Validate() error {
 	}
 	return nil
 }
+
+// validatePeers checks an allow_update or allow_notify list: each entry is
+// an IP, a CIDR or the name of a tsig_keys entry.
+func validatePeers(entries []string, keys map[string]bool) error {
+	for _, who := range entries {
+		if net.ParseIP(who) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(who); err == nil {
+			continue
+		}
+		if !keys[strings.ToLower(strings.TrimSuffix(who, "."))] {
+			return fmt.Errorf("entry %q is neither an address nor a tsig_keys name", who)
+		}
+	}
+	return nil
+}
@@ -89,6 +89,12 @@ filters:
 	if cfg.Cache.MinTTL.Duration() != 30*time.Second {
 		t.Errorf("MinTTL: got %v", cfg.Cache.MinTTL.Duration())
 	}
+	if cfg.Listens[0].Path != "/dns-query" || cfg.Listens[1].Path != "" {
+		t.Errorf("default doh path not applied: %+v", cfg.Listens)
+	}
+	if cfg.Listens[0].JSONPath != "/resolve" || cfg.Listens[1].JSONPath != "" {
+		t.Errorf("default json path not applied: %+v", cfg.Listens)
+	}
 	if len(cfg.Domains) != 2 || cfg.Domains[1].ZoneFile != "./zones/test.zone" {
 		t.Errorf("domains parse mismatch: %+v", cfg.Domains)
 	}
@@ -135,6 +141,66 @@ proxy:
 `,
 			wantErr: "unknown type",
 		},
+		{
+			name: "doh cert without key",
+			src: `
+listens:
+  - type: doh
+    addr: ":443"
+    cert_file: cert.pem
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: "cert_file and key_file",
+		},
+		{
+			name: "relative doh path",
+			src: `
+listens:
+  - type: doh
+    addr: ":443"
+    path: dns-query
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: "must start with /",
+		},
+		{
+			name: "json path clashes with doh path",
+			src: `
+listens:
+  - type: doh
+    addr: ":443"
+    json_path: /dns-query
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: "path and json_path",
+		},
+		{
+			name: "max_udp_size too small",
+			src: `
+listens:
+  - type: udp
+    addr: ":53"
+max_udp_size: 256
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: "max_udp_size",
+		},
+		{
+			name: "negative max_tcp_conns",
+			src: `
+listens:
+  - type: tcp
+    addr: ":53"
+max_tcp_conns: -1
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: "max_tcp_conns",
+		},
 		{
 			name: "unknown upstream type",
 			src: `
@@ -186,6 +252,162 @@ proxy:
 `,
 			wantErr: "not supported",
 		},
+		{
+			name: "require_tsig without keys",
+			src: `
+listens:
+  - type: tcp
+    addr: ":5353"
+    require_tsig: true
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: "require_tsig needs at least one tsig_keys entry",
+		},
+		{
+			name: "tsig bad algorithm",
+			src: `
+listens:
+  - type: tcp
+    addr: ":5353"
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+tsig_keys:
+  - name: xfr-key
+    algorithm: hmac-md5
+    secret: c2VjcmV0
+`,
+			wantErr: "tsig_keys[0]: algorithm",
+		},
+		{
+			name: "tsig bad secret",
+			src: `
+listens:
+  - type: tcp
+    addr: ":5353"
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+tsig_keys:
+  - name: xfr-key
+    secret: "not base64!"
+`,
+			wantErr: "secret must be non-empty base64",
+		},
+		{
+			name: "tsig duplicate key",
+			src: `
+listens:
+  - type: tcp
+    addr: ":5353"
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+tsig_keys:
+  - {name: xfr-key, secret: c2VjcmV0}
+  - {name: XFR-key., secret: c2VjcmV0}
+`,
+			wantErr: "duplicate key",
+		},
+		{
+			name: "allow_update unknown key",
+			src: `
+listens:
+  - type: udp
+    addr: ":5353"
+domains:
+  - domain: home.lan
+    allow_update: [10.0.0.0/8, dhcp-key]
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: `allow_update entry "dhcp-key"`,
+		},
+		{
+			name: "primary with records",
+			src: `
+listens:
+  - type: udp
+    addr: ":5353"
+domains:
+  - domain: home.lan
+    primary: "10.0.0.1:53"
+    records: ["@ IN A 10.0.0.2"]
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: "primary can't be combined",
+		},
+		{
+			name: "primary with allow_update",
+			src: `
+listens:
+  - type: udp
+    addr: ":5353"
+domains:
+  - domain: home.lan
+    primary: "10.0.0.1:53"
+    allow_update: [10.0.0.0/8]
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: "primary can't be combined with allow_update",
+		},
+		{
+			name: "allow_notify without primary",
+			src: `
+listens:
+  - type: udp
+    addr: ":5353"
+domains:
+  - domain: home.lan
+    allow_notify: [10.0.0.1]
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: "allow_notify requires primary",
+		},
+		{
+			name: "allow_transfer unknown key",
+			src: `
+listens:
+  - type: udp
+    addr: ":5353"
+domains:
+  - domain: home.lan
+    allow_transfer: [xfr-key]
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: `allow_transfer entry "xfr-key"`,
+		},
+		{
+			name: "unknown tsig_key",
+			src: `
+listens:
+  - type: udp
+    addr: ":5353"
+domains:
+  - domain: home.lan
+    primary: "10.0.0.1:53"
+    tsig_key: xfr-key
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: `tsig_key "xfr-key" is not in tsig_keys`,
+		},
+		{
+			name: "notify without port",
+			src: `
+listens:
+  - type: udp
+    addr: ":5353"
+domains:
+  - domain: home.lan
+    notify: [10.0.0.3]
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`,
+			wantErr: "must be host:port",
+		},
 		{
 			name: "bad duration",
 			src: `
@@ -232,6 +454,116 @@ proxy:
 	}
 }
 
+func TestParseTSIGKeys(t *testing.T) {
+	src := `
+listens:
+  - type: tcp
+    addr: ":5353"
+    require_tsig: true
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+domains:
+  - domain: home.lan
+    allow_update: [update-key., 192.168.1.10, "fd00::/8"]
+    tsig_key: XFR-key.
+tsig_keys:
+  - name: xfr-key
+    secret: c2VjcmV0
+  - name: update-key
+    algorithm: hmac-sha512
+    secret: c2VjcmV0
+`
+	cfg, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if !cfg.Listens[0].RequireTSIG {
+		t.Error("require_tsig not parsed")
+	}
+	if len(cfg.TSIG) != 2 || cfg.TSIG[0].Algorithm != "hmac-sha256" || cfg.TSIG[1].Algorithm != "hmac-sha512" {
+		t.Errorf("unexpected keys %+v", cfg.TSIG)
+	}
+	if secret, err := cfg.TSIG[0].DecodeSecret(); err != nil || string(secret) != "secret" {
+		t.Errorf("DecodeSecret = %q, %v", secret, err)
+	}
+	if got := cfg.Domains[0].AllowUpdate; len(got) != 3 || got[0] != "update-key." {
+		t.Errorf("allow_update = %v", got)
+	}
+	if got := cfg.Domains[0].TSIGKey; got != "XFR-key." {
+		t.Errorf("tsig_key = %q", got)
+	}
+}
+
+func TestParseSecondaryZone(t *testing.T) {
+	src := `
+listens:
+  - type: udp
+    addr: ":5353"
+domains:
+  - domain: home.lan
+    primary: "10.0.0.1:53"
+    allow_notify: [10.0.0.0/24]
+    notify: ["10.0.0.3:53", "[fd00::3]:53"]
+    allow_transfer: [10.0.0.0/24]
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+`
+	cfg, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	d := cfg.Domains[0]
+	if d.Primary != "10.0.0.1:53" || len(d.AllowNotify) != 1 || len(d.Notify) != 2 || len(d.AllowTransfer) != 1 {
+		t.Errorf("unexpected domain %+v", d)
+	}
+}
+
+func TestParseServerLimits(t *testing.T) {
+	src := `
+listens:
+  - type: tcp
+    addr: ":5353"
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+max_udp_size: 1400
+tcp_idle_timeout: 30s
+tcp_read_timeout: 2s
+max_tcp_queries: 8
+max_tcp_conns: 100
+`
+	cfg, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if cfg.MaxUDPSize != 1400 || cfg.TCPIdleTimeout.Duration() != 30*time.Second || cfg.TCPReadTimeout.Duration() != 2*time.Second {
+		t.Errorf("unexpected limits %+v", cfg)
+	}
+	if cfg.MaxTCPQueries != 8 || cfg.MaxTCPConns != 100 {
+		t.Errorf("max_tcp_queries %d, max_tcp_conns %d", cfg.MaxTCPQueries, cfg.MaxTCPConns)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	src := `
+listens:
+  - type: udp
+    addr: ":5353"
+proxy:
+  upstreams: [{type: udp, addr: "1.1.1.1:53"}]
+policy:
+  drop_unknown_opcodes: true
+  allow_multi_question: true
+`
+	cfg, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	p := cfg.Policy
+	if !p.DropUnknownOpcodes || !p.AllowMultiQuestion || p.IgnoreEDNSVersion {
+		t.Errorf("unexpected policy %+v", p)
+	}
+}
+
 func TestLoadRepoConfig(t *testing.T) {
 	cfg, err := Load("../config.yaml")
 	if err != nil {
@@ -88,7 +88,7 @@ func (h *RelayHandler) HandleQuery(conn *server.PackConn) {
 	start := time.Now()
 
 	if *verbose {
-		log.Printf("[%s] Query: %s %s", conn.RemoteAddr, question.Name, typeName(question.Type))
+		log.Printf("[%s] Query: %s %s", conn.RemoteAddr, packet.DisplayName(question.Name), question.Type)
 	}
 
 	// Forward query to upstream
@@ -96,7 +96,7 @@ func (h *RelayHandler) HandleQuery(conn *server.PackConn) {
 	if err != nil {
 		log.Printf("[%s] Upstream error: %v (%v)", conn.RemoteAddr, err, time.Since(start))
 		// Return SERVFAIL to client
-		h.writeError(conn, conn.Request, 2) // SERVFAIL
+		h.writeError(conn, conn.Request, packet.DNSRCodeServFail)
 		return
 	}
 
@@ -124,27 +124,7 @@ func (h *RelayHandler) HandleQuery(conn *server.PackConn) {
 
 	// Log response
 	if *verbose {
-		log.Printf("[%s] Response: %d answers, %d authorities, %d additionals (%v)",
-			conn.RemoteAddr, len(res.Answers), len(res.Authorities), len(res.Additionals), time.Since(start))
-
-		if len(res.Answers) > 0 {
-			log.Println("  Answers:")
-			for _, record := range res.Answers {
-				printRecord(record)
-			}
-		}
-		if len(res.Authorities) > 0 {
-			log.Println("  Authorities:")
-			for _, record := range res.Authorities {
-				printRecord(record)
-			}
-		}
-		if len(res.Additionals) > 0 {
-			log.Println("  Additionals:")
-			for _, record := range res.Additionals {
-				printRecord(record)
-			}
-		}
+		log.Printf("[%s] Response (%v):\n%s", conn.RemoteAddr, time.Since(start), res)
 	}
 
 	// Write response
@@ -153,64 +133,12 @@ func (h *RelayHandler) HandleQuery(conn *server.PackConn) {
 	}
 }
 
-func (h *RelayHandler) writeError(conn *server.PackConn, req *packet.DNSPacket, rcode uint8) {
+func (h *RelayHandler) writeError(conn *server.PackConn, req *packet.DNSPacket, rcode packet.DNSRCode) {
 	res := packet.NewPacketFromRequest(req)
-	res.Header.RCode = rcode
+	res.SetRCode(rcode)
 	conn.WriteResponse(res)
 }
 
-func typeName(t packet.DNSType) string {
-	switch t {
-	case packet.DNSTypeA:
-		return "A"
-	case packet.DNSTypeAAAA:
-		return "AAAA"
-	case packet.DNSTypeCNAME:
-		return "CNAME"
-	case packet.DNSTypeMX:
-		return "MX"
-	case packet.DNSTypeNS:
-		return "NS"
-	case packet.DNSTypeTXT:
-		return "TXT"
-	case packet.DNSTypePTR:
-		return "PTR"
-	case packet.DNSTypeSOA:
-		return "SOA"
-	case packet.DNSTypeSRV:
-		return "SRV"
-	default:
-		return string(rune(t))
-	}
-}
-
-func printRecord(record packet.DNSResource) {
-	switch r := record.(type) {
-	case *packet.DNSResourceRecordA:
-		log.Printf("  A: %s -> %s", r.Name, r.Address)
-	case *packet.DNSResourceRecordAAAA:
-		log.Printf("  AAAA: %s -> %s", r.Name, r.Address)
-	case *packet.DNSResourceRecordCNAME:
-		log.Printf("  CNAME: %s -> %s", r.Name, r.Domain)
-	case *packet.DNSResourceRecordMX:
-		log.Printf("  MX: %s (pref=%d) -> %s", r.Name, r.Preference, r.Exchange)
-	case *packet.DNSResourceRecordNS:
-		log.Printf("  NS: %s -> %s", r.Name, r.NameServer)
-	case *packet.DNSResourceRecordTXT:
-		log.Printf("  TXT: %s -> %s", r.Name, r.Content)
-	case *packet.DNSResourceRecordPTR:
-		log.Printf("  PTR: %s -> %s", r.Name, r.PtrDomainName)
-	case *packet.DNSResourceRecordSOA:
-		log.Printf("  SOA: %s -> %s %s (serial=%d)", r.Name, r.MName, r.RName, r.Serial)
-	case *packet.DNSResourceRecordEDNS:
-		log.Printf("  EDNS: UDPSize=%d, DO=%v, Options=%d", r.UDPSize, r.GetDNSSECOK(), len(r.Options))
-	case *packet.DNSResourceRecordSRV:
-		log.Printf("  SRV: %s (priority=%d, weight=%d, port=%d) -> %s", r.Name, r.Priority, r.Weight, r.Port, r.Target)
-	default:
-		log.Printf("  Unknown: type=%d, record=%+v", record.GetType(), r)
-	}
-}
-
 func main() {
 	flag.Parse()
 
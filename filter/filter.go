@@ -7,6 +7,8 @@ import (
 	"os"
 	"strings"
 	"sync"
+
+	"github.com/lsongdev/dns-go/packet"
 )
 
 type Action int
@@ -69,7 +71,10 @@ func (f *Filter) AddRule(line string) error {
 	}
 
 	if strings.HasPrefix(rule, "||") {
-		domain := strings.TrimRight(rule[2:], "^|/")
+		domain, err := packet.IDNAToASCII(strings.TrimRight(rule[2:], "^|/"))
+		if err != nil {
+			return fmt.Errorf("filter: malformed rule %q: %w", line, err)
+		}
 		domain = strings.ToLower(domain)
 		if domain == "" {
 			return fmt.Errorf("filter: malformed rule %q", line)
@@ -94,6 +99,9 @@ func (f *Filter) AddRule(line string) error {
 		return nil
 	}
 
+	if ascii, err := packet.IDNAToASCII(rule); err == nil {
+		rule = ascii
+	}
 	if validDomain(strings.ToLower(rule)) {
 		f.mu.Lock()
 		defer f.mu.Unlock()
@@ -162,7 +170,12 @@ func (f *Filter) AddListFile(path string) error {
 }
 
 func (f *Filter) Decide(qname string) Action {
-	qname = strings.ToLower(strings.TrimSuffix(qname, "."))
+	// names off the wire are already ASCII; this only converts Unicode
+	// names handed in directly
+	if ascii, err := packet.IDNAToASCII(qname); err == nil {
+		qname = ascii
+	}
+	qname = packet.CanonicalName(qname)
 	if qname == "" {
 		return Pass
 	}
@@ -196,7 +209,7 @@ func (f *Filter) Decide(qname string) Action {
 }
 
 func suffixes(name string) []string {
-	parts := strings.Split(name, ".")
+	parts := packet.SplitName(name)
 	out := make([]string, 0, len(parts))
 	for i := 0; i < len(parts); i++ {
 		out = append(out, strings.Join(parts[i:], "."))
@@ -219,3 +219,28 @@ func TestWildcardSkipped(t *testing.T) {
 		t.Errorf("wildcard rule should not match anything, got %v", got)
 	}
 }
+
+func TestUnicodeRules(t *testing.T) {
+	f := New()
+	mustAdd(t, f,
+		"||例子.中国^",
+		"bücher.example",
+	)
+
+	cases := map[string]Action{
+		"xn--fsqu00a.xn--fiqs8s":     Block,
+		"www.xn--fsqu00a.xn--fiqs8s": Block,
+		"WWW.例子.中国":                  Block,
+		"xn--bcher-kva.example":      Block,
+		"www.xn--bcher-kva.example":  Pass,
+		"xn--fiqs8s":                 Pass,
+	}
+	for name, want := range cases {
+		if got := f.Decide(name); got != want {
+			t.Errorf("Decide(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if err := f.AddRule("||bad name.com^"); err == nil {
+		t.Error("expected error for a rule with a disallowed character")
+	}
+}
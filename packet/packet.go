@@ -3,6 +3,7 @@ package packet
 import (
 	"bytes"
 	"fmt"
+	"strings"
 )
 
 // DNS contains data from a single Domain Name Service packet.
@@ -39,7 +40,36 @@ func NewPacketFromRequest(request *DNSPacket) *DNSPacket {
 	}
 }
 
-// DecodeFromBytes decodes the slice into the DNS struct.
+// Message sections, as reported by ParseError.
+const (
+	SectionHeader     = "header"
+	SectionQuestion   = "question"
+	SectionAnswer     = "answer"
+	SectionAuthority  = "authority"
+	SectionAdditional = "additional"
+)
+
+// ParseError reports which part of a message failed to decode. Index is the
+// position of the offending entry within Section (0 for the header).
+type ParseError struct {
+	Section string
+	Index   int
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	if e.Section == SectionHeader {
+		return fmt.Sprintf("error decoding DNS header: %v", e.Err)
+	}
+	return fmt.Sprintf("error decoding DNS %s #%d: %v", e.Section, e.Index, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// FromBytes decodes the slice into the DNS struct. Malformed input is
+// reported as a *ParseError.
 func FromBytes(data []byte) (d *DNSPacket, err error) {
 	d = &DNSPacket{}
 	// Create a reader with the data
@@ -47,13 +77,13 @@ func FromBytes(data []byte) (d *DNSPacket, err error) {
 	// Decode the DNS header
 	d.Header = &DNSHeader{}
 	if err := d.Header.Parse(reader); err != nil {
-		return nil, fmt.Errorf("error decoding DNS header: %v", err)
+		return nil, &ParseError{Section: SectionHeader, Err: err}
 	}
 	// Decode questions
 	for i := 0; i < int(d.Header.QDCount); i++ {
 		question := &DNSQuestion{}
 		if err := question.Parse(reader); err != nil {
-			return nil, fmt.Errorf("error decoding DNS question: %v", err)
+			return nil, &ParseError{Section: SectionQuestion, Index: i, Err: err}
 		}
 		d.Questions = append(d.Questions, question)
 	}
@@ -61,7 +91,7 @@ func FromBytes(data []byte) (d *DNSPacket, err error) {
 	for i := 0; i < int(d.Header.ANCount); i++ {
 		answer, err := ParseResource(reader)
 		if err != nil {
-			return nil, fmt.Errorf("error decoding DNS answer: %v", err)
+			return nil, &ParseError{Section: SectionAnswer, Index: i, Err: err}
 		}
 		d.Answers = append(d.Answers, answer)
 	}
@@ -69,7 +99,7 @@ func FromBytes(data []byte) (d *DNSPacket, err error) {
 	for i := 0; i < int(d.Header.NSCount); i++ {
 		authority, err := ParseResource(reader)
 		if err != nil {
-			return nil, fmt.Errorf("error decoding DNS authority: %v", err)
+			return nil, &ParseError{Section: SectionAuthority, Index: i, Err: err}
 		}
 		d.Authorities = append(d.Authorities, authority)
 	}
@@ -77,36 +107,164 @@ func FromBytes(data []byte) (d *DNSPacket, err error) {
 	for i := 0; i < int(d.Header.ARCount); i++ {
 		additional, err := ParseResource(reader)
 		if err != nil {
-			return nil, fmt.Errorf("error decoding DNS additional: %v", err)
+			return nil, &ParseError{Section: SectionAdditional, Index: i, Err: err}
 		}
 		d.Additionals = append(d.Additionals, additional)
 	}
 	return d, err
 }
 
+// Bytes encodes the packet with name compression enabled. It returns nil
+// if a name can't be encoded; use Pack or PackTo to learn why.
 func (packet *DNSPacket) Bytes() []byte {
+	data, _ := packet.Pack(true)
+	return data
+}
+
+// Pack encodes the packet. When compress is true, owner names, question
+// names and the names inside compressible RDATA are replaced by pointers to
+// earlier occurrences (RFC 1035 §4.1.4); otherwise every name is written
+// out in full. Names are checked with ValidateName as they are written,
+// and the first one that doesn't fit the wire limits fails the encoding.
+func (packet *DNSPacket) Pack(compress bool) ([]byte, error) {
 	var buf bytes.Buffer
+	if err := packet.pack(&buf, compress); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PackTo encodes the packet like Pack, but into buf, which is reset first
+// so compression pointers stay relative to the start of the message.
+// Reusing buf, for instance one from GetBuffer, avoids reallocating the
+// output for every message. buf is left empty if a name can't be encoded.
+func (packet *DNSPacket) PackTo(buf *bytes.Buffer, compress bool) error {
+	buf.Reset()
+	if err := packet.pack(buf, compress); err != nil {
+		buf.Reset()
+		return err
+	}
+	return nil
+}
+
+// Validate checks every name in the packet — question names, owner names
+// and the domain names inside RDATA — with ValidateName.
+func (packet *DNSPacket) Validate() error {
+	for i, q := range packet.Questions {
+		if err := ValidateName(q.Name); err != nil {
+			return fmt.Errorf("%s #%d: %w", SectionQuestion, i, err)
+		}
+	}
+	for _, section := range []struct {
+		name    string
+		records []DNSResource
+	}{
+		{SectionAnswer, packet.Answers},
+		{SectionAuthority, packet.Authorities},
+		{SectionAdditional, packet.Additionals},
+	} {
+		for i, rr := range section.records {
+			if err := validateResource(rr); err != nil {
+				return fmt.Errorf("%s #%d: %w", section.name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (packet *DNSPacket) pack(buf *bytes.Buffer, compress bool) error {
+	var c *Compressor
+	if compress {
+		c = compressorPool.Get().(*Compressor)
+		defer func() {
+			c.Reset()
+			compressorPool.Put(c)
+		}()
+	}
 
 	packet.Header.QDCount = uint16(len(packet.Questions))
 	packet.Header.ANCount = uint16(len(packet.Answers))
 	packet.Header.NSCount = uint16(len(packet.Authorities))
 	packet.Header.ARCount = uint16(len(packet.Additionals))
 
-	buf.Write(packet.Header.Bytes())
+	var header [12]byte
+	packet.Header.encode(header[:])
+	buf.Write(header[:])
+
+	for i, question := range packet.Questions {
+		if err := question.encode(buf, c); err != nil {
+			return fmt.Errorf("%s #%d: %w", SectionQuestion, i, err)
+		}
+	}
+	for _, section := range []struct {
+		name    string
+		records []DNSResource
+	}{
+		{SectionAnswer, packet.Answers},
+		{SectionAuthority, packet.Authorities},
+		{SectionAdditional, packet.Additionals},
+	} {
+		for i, rr := range section.records {
+			if err := encodeResource(buf, rr, c); err != nil {
+				return fmt.Errorf("%s #%d: %w", section.name, i, err)
+			}
+		}
+	}
+	return nil
+}
 
-	for _, question := range packet.Questions {
-		buf.Write(question.Bytes())
+// String renders the packet as dig does: the header and flags, the OPT
+// pseudo-section if an EDNS record is present, then the question, answer,
+// authority and additional sections in presentation format.
+func (packet *DNSPacket) String() string {
+	var b strings.Builder
+	var opt *DNSResourceRecordEDNS
+	var additionals []DNSResource
+	for _, rr := range packet.Additionals {
+		if edns, ok := rr.(*DNSResourceRecordEDNS); ok && opt == nil {
+			opt = edns
+			continue
+		}
+		additionals = append(additionals, rr)
 	}
-	for _, answer := range packet.Answers {
-		buf.Write(answer.Bytes())
+	header := DNSHeader{}
+	if packet.Header != nil {
+		header = *packet.Header
 	}
-	for _, authority := range packet.Authorities {
-		buf.Write(authority.Bytes())
+	header.QDCount = uint16(len(packet.Questions))
+	header.ANCount = uint16(len(packet.Answers))
+	header.NSCount = uint16(len(packet.Authorities))
+	header.ARCount = uint16(len(packet.Additionals))
+	b.WriteString(header.format(packet.RCode()))
+	b.WriteString("\n")
+	if opt != nil {
+		b.WriteString("\n;; OPT PSEUDOSECTION:\n")
+		b.WriteString(opt.String())
+		b.WriteString("\n")
 	}
-	for _, additional := range packet.Additionals {
-		buf.Write(additional.Bytes())
+	if len(packet.Questions) > 0 {
+		b.WriteString("\n;; QUESTION SECTION:\n")
+		for _, q := range packet.Questions {
+			b.WriteString(";" + q.String() + "\n")
+		}
+	}
+	for _, section := range []struct {
+		title   string
+		records []DNSResource
+	}{
+		{"ANSWER", packet.Answers},
+		{"AUTHORITY", packet.Authorities},
+		{"ADDITIONAL", additionals},
+	} {
+		if len(section.records) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n;; %s SECTION:\n", section.title)
+		for _, rr := range section.records {
+			fmt.Fprintf(&b, "%v\n", rr)
+		}
 	}
-	return buf.Bytes()
+	return b.String()
 }
 
 func (p *DNSPacket) AddQuestion(question *DNSQuestion) {
@@ -222,6 +380,49 @@ func (p *DNSPacket) AddAdditionalEDNS(udpSize uint16, extRCode uint8, version ui
 	})
 }
 
+// EDNS returns the packet's OPT record, or nil if it has none.
+func (p *DNSPacket) EDNS() *DNSResourceRecordEDNS {
+	for _, rr := range p.Additionals {
+		if opt, ok := rr.(*DNSResourceRecordEDNS); ok {
+			return opt
+		}
+	}
+	return nil
+}
+
+// RCode returns the full 12-bit response code: the header's 4 bits
+// combined with the extended RCODE of the OPT record, if any.
+func (p *DNSPacket) RCode() DNSRCode {
+	var rcode DNSRCode
+	if p.Header != nil {
+		rcode = DNSRCode(p.Header.RCode & 0x0F)
+	}
+	if opt := p.EDNS(); opt != nil {
+		rcode |= DNSRCode(opt.ExtRCode) << 4
+	}
+	return rcode
+}
+
+// SetRCode stores rcode across the header and the OPT record. Codes above
+// 15 need an OPT record, so one advertising DefaultEDNSUDPSize is added when
+// the packet has none.
+func (p *DNSPacket) SetRCode(rcode DNSRCode) {
+	if p.Header == nil {
+		p.Header = NewHeader()
+	}
+	p.Header.RCode = uint8(rcode & 0x0F)
+	opt := p.EDNS()
+	if opt == nil {
+		if rcode <= 0x0F {
+			return
+		}
+		opt = NewEDNSRecord(DefaultEDNSUDPSize)
+		p.AddAdditional(opt)
+	}
+	opt.ExtRCode = uint8(rcode >> 4)
+	opt.syncTTL()
+}
+
 func (p *DNSPacket) AddQuestionSRV(domain string) {
 	p.AddQuestion(&DNSQuestion{
 		Name:  domain,
@@ -229,3 +430,11 @@ func (p *DNSPacket) AddQuestionSRV(domain string) {
 		Class: DNSClassIN,
 	})
 }
+
+func (p *DNSPacket) AddQuestionHTTPS(domain string) {
+	p.AddQuestion(&DNSQuestion{
+		Name:  domain,
+		Type:  DNSTypeHTTPS,
+		Class: DNSClassIN,
+	})
+}
@@ -0,0 +1,232 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Cloner is implemented by records that can produce a deep copy of
+// themselves. Every record type in this package implements it.
+type Cloner interface {
+	Clone() DNSResource
+}
+
+// Clone returns a deep copy of the packet: the header, questions and every
+// record are copied, so the result can be modified without affecting p.
+func (p *DNSPacket) Clone() *DNSPacket {
+	if p == nil {
+		return nil
+	}
+	cp := &DNSPacket{
+		Answers:     cloneResources(p.Answers),
+		Authorities: cloneResources(p.Authorities),
+		Additionals: cloneResources(p.Additionals),
+	}
+	if p.Header != nil {
+		header := *p.Header
+		cp.Header = &header
+	}
+	if p.Questions != nil {
+		cp.Questions = make([]*DNSQuestion, len(p.Questions))
+		for i, q := range p.Questions {
+			if q != nil {
+				question := *q
+				cp.Questions[i] = &question
+			}
+		}
+	}
+	return cp
+}
+
+func cloneResources(records []DNSResource) []DNSResource {
+	if records == nil {
+		return nil
+	}
+	out := make([]DNSResource, len(records))
+	for i, rr := range records {
+		out[i] = CloneResource(rr)
+	}
+	return out
+}
+
+// CloneResource returns a deep copy of rr. Records that don't implement
+// Cloner are copied by re-decoding their encoded RDATA.
+func CloneResource(rr DNSResource) DNSResource {
+	if rr == nil {
+		return nil
+	}
+	if c, ok := rr.(Cloner); ok {
+		return c.Clone()
+	}
+	cp, err := DecodeRData(*rr.GetHeader(), rr.Encode())
+	if err != nil {
+		return &DNSResourceRecordUnknown{
+			DNSResourceRecord: *rr.GetHeader(),
+			RData:             rr.Encode(),
+		}
+	}
+	return cp
+}
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// Equal reports whether p and other carry the same message. Names are
+// compared case-insensitively and without regard to a trailing dot, and the
+// records of each section are compared as a set, so RRset order does not
+// matter. Header counts are ignored since they follow from the sections.
+func (p *DNSPacket) Equal(other *DNSPacket) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	if !equalHeader(p.Header, other.Header) {
+		return false
+	}
+	if len(p.Questions) != len(other.Questions) {
+		return false
+	}
+	for i, q := range p.Questions {
+		o := other.Questions[i]
+		if q == nil || o == nil {
+			if q != o {
+				return false
+			}
+			continue
+		}
+		if !EqualName(q.Name, o.Name) || q.Type != o.Type || q.Class != o.Class {
+			return false
+		}
+	}
+	return equalSection(p.Answers, other.Answers) &&
+		equalSection(p.Authorities, other.Authorities) &&
+		equalSection(p.Additionals, other.Additionals)
+}
+
+func equalHeader(a, b *DNSHeader) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	x, y := *a, *b
+	x.QDCount, x.ANCount, x.NSCount, x.ARCount = 0, 0, 0, 0
+	y.QDCount, y.ANCount, y.NSCount, y.ARCount = 0, 0, 0, 0
+	return x == y
+}
+
+func equalSection(a, b []DNSResource) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x, y := sectionKeys(a), sectionKeys(b)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sectionKeys(records []DNSResource) []string {
+	keys := make([]string, len(records))
+	for i, rr := range records {
+		keys[i] = resourceKey(rr)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EqualResource reports whether a and b are the same record, comparing the
+// owner name and any domain names in RDATA case-insensitively.
+func EqualResource(a, b DNSResource) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return resourceKey(a) == resourceKey(b)
+}
+
+// EqualRData reports whether a and b have the same owner name, type and
+// RDATA, ignoring class and TTL. This is how RFC 2136 matches the records
+// of an UPDATE against those of a zone.
+func EqualRData(a, b DNSResource) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ha, hb := a.GetHeader(), b.GetHeader()
+	return ha.Type == hb.Type && EqualName(ha.Name, hb.Name) &&
+		bytes.Equal(canonicalRData(a).Encode(), canonicalRData(b).Encode())
+}
+
+// resourceKey renders rr in a canonical form: lower-cased owner name, type,
+// class, TTL and the hex of its RDATA with embedded names lower-cased.
+func resourceKey(rr DNSResource) string {
+	h := rr.GetHeader()
+	class, ttl := uint32(h.Class), h.TTL
+	if opt, ok := rr.(*DNSResourceRecordEDNS); ok {
+		class = uint32(opt.UDPSize)
+		ttl = uint32(opt.ExtRCode)<<24 | uint32(opt.Version)<<16 | uint32(opt.Flags)
+	}
+	return fmt.Sprintf("%s %d %d %d %s", CanonicalName(h.Name), h.Type, class, ttl,
+		hex.EncodeToString(canonicalRData(rr).Encode()))
+}
+
+// canonicalRData returns rr, or a copy of it with the domain names in its
+// RDATA lower-cased.
+func canonicalRData(rr DNSResource) DNSResource {
+	switch r := rr.(type) {
+	case *DNSResourceRecordCNAME:
+		cp := *r
+		cp.Domain = CanonicalName(r.Domain)
+		return &cp
+	case *DNSResourceRecordNS:
+		cp := *r
+		cp.NameServer = CanonicalName(r.NameServer)
+		return &cp
+	case *DNSResourceRecordPTR:
+		cp := *r
+		cp.PtrDomainName = CanonicalName(r.PtrDomainName)
+		return &cp
+	case *DNSResourceRecordMX:
+		cp := *r
+		cp.Exchange = CanonicalName(r.Exchange)
+		return &cp
+	case *DNSResourceRecordSOA:
+		cp := *r
+		cp.MName = CanonicalName(r.MName)
+		cp.RName = CanonicalName(r.RName)
+		return &cp
+	case *DNSResourceRecordSRV:
+		cp := *r
+		cp.Target = CanonicalName(r.Target)
+		return &cp
+	case *DNSResourceRecordNAPTR:
+		cp := *r
+		cp.Replacement = CanonicalName(r.Replacement)
+		return &cp
+	case *DNSResourceRecordRRSIG:
+		cp := *r
+		cp.SignerName = CanonicalName(r.SignerName)
+		return &cp
+	case *DNSResourceRecordNSEC:
+		cp := *r
+		cp.NextDomain = CanonicalName(r.NextDomain)
+		return &cp
+	case *DNSResourceRecordSVCB:
+		cp := *r
+		cp.Target = CanonicalName(r.Target)
+		return &cp
+	case *DNSResourceRecordHTTPS:
+		cp := *r
+		cp.Target = CanonicalName(r.Target)
+		return &cp
+	case *DNSResourceRecordTSIG:
+		cp := *r
+		cp.Algorithm = CanonicalName(r.Algorithm)
+		return &cp
+	}
+	return rr
+}
@@ -0,0 +1,124 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// maxPointerOffset is the largest message offset a 14-bit compression
+// pointer can address.
+const maxPointerOffset = 0x3FFF
+
+// Compressor remembers the message offset of every domain name suffix
+// written so far, so later occurrences of the same suffix can be replaced
+// by a 2-byte pointer (RFC 1035 §4.1.4). Offsets are relative to the start
+// of the buffer handed to the encode methods, which must therefore hold the
+// whole message from the header onwards.
+//
+// A nil *Compressor is valid and disables compression: names are always
+// written out in full.
+type Compressor struct {
+	offsets map[string]int
+}
+
+// NewCompressor returns an empty compression table for a single message.
+func NewCompressor() *Compressor {
+	return &Compressor{offsets: make(map[string]int)}
+}
+
+// Reset empties the table so it can be used for another message.
+func (c *Compressor) Reset() {
+	for k := range c.offsets {
+		delete(c.offsets, k)
+	}
+}
+
+// CompressibleResource is implemented by records whose RDATA embeds domain
+// names that may be compressed. RFC 3597 §4 restricts this to the types
+// defined in RFC 1035 (NS, CNAME, SOA, PTR, MX); newer types such as SRV
+// must always carry uncompressed names.
+//
+// EncodeCompressed appends the RDATA to msg, which holds the message encoded
+// so far, so pointers emitted through c are message-relative. It fails,
+// writing nothing for the offending name, if a name can't be encoded.
+type CompressibleResource interface {
+	DNSResource
+	EncodeCompressed(msg *bytes.Buffer, c *Compressor) error
+}
+
+// encodeDomainName writes domain to msg as a sequence of labels
+// terminated by the root label, or by a pointer to an earlier copy of its
+// longest already-written suffix. Escapes in domain are resolved into the
+// label bytes. A name ValidateName rejects is not written at all, since
+// its labels would not fit their length bytes.
+func encodeDomainName(msg *bytes.Buffer, domain string, c *Compressor) error {
+	if err := ValidateName(domain); err != nil {
+		return err
+	}
+	domain = trimRoot(domain)
+	for suffix := domain; suffix != ""; {
+		if c != nil {
+			if off, ok := c.offsets[suffix]; ok {
+				msg.Write([]byte{byte(0xC0 | off>>8), byte(off)})
+				return nil
+			}
+			if msg.Len() <= maxPointerOffset {
+				c.offsets[suffix] = msg.Len()
+			}
+		}
+		end := labelEnd(suffix)
+		label, rest := suffix[:end], ""
+		if end < len(suffix) {
+			rest = suffix[end+1:]
+		}
+		if strings.IndexByte(label, '\\') < 0 {
+			msg.WriteByte(byte(len(label)))
+			msg.WriteString(label)
+		} else {
+			var buf [4 * maxLabelLength]byte
+			wire := appendUnescapedLabel(buf[:0], label)
+			msg.WriteByte(byte(len(wire)))
+			msg.Write(wire)
+		}
+		suffix = rest
+	}
+	msg.WriteByte(0x00)
+	return nil
+}
+
+// encodeResource appends rr to msg, compressing the owner name and, for
+// CompressibleResource records, the names inside RDATA. It fails if a name
+// in rr can't be encoded.
+func encodeResource(msg *bytes.Buffer, rr DNSResource, c *Compressor) error {
+	if opt, ok := rr.(*DNSResourceRecordEDNS); ok {
+		opt.syncTTL()
+	}
+	r := rr.GetHeader()
+	if err := encodeDomainName(msg, r.Name, c); err != nil {
+		return err
+	}
+	var fixed [8]byte
+	binary.BigEndian.PutUint16(fixed[0:], uint16(r.Type))
+	binary.BigEndian.PutUint16(fixed[2:], uint16(r.Class))
+	binary.BigEndian.PutUint32(fixed[4:], r.TTL)
+	msg.Write(fixed[:])
+	// RDLENGTH is patched once the RDATA has been written
+	lengthAt := msg.Len()
+	msg.Write([]byte{0, 0})
+	if cr, ok := rr.(CompressibleResource); ok {
+		if err := cr.EncodeCompressed(msg, c); err != nil {
+			return fmt.Errorf("%s RDATA: %w", r.Type, err)
+		}
+	} else {
+		// Encode has no way to report a bad name, so check them first
+		if err := validateRData(rr); err != nil {
+			return err
+		}
+		msg.Write(rr.Encode())
+	}
+	rdLength := msg.Len() - lengthAt - 2
+	binary.BigEndian.PutUint16(msg.Bytes()[lengthAt:], uint16(rdLength))
+	return nil
+}
@@ -0,0 +1,137 @@
+package packet
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// DNS JSON API (application/dns-json), the format of the JSON endpoints of
+// Google Public DNS and Cloudflare. Unlike the RFC 8427 form of
+// MarshalJSON it only describes answers: flags are booleans, records carry
+// their RDATA in presentation format and the OPT record is left out.
+
+// DNSJSONResponse is an answer in the DNS JSON API format.
+type DNSJSONResponse struct {
+	Status     DNSRCode          `json:"Status"`
+	TC         bool              `json:"TC"`
+	RD         bool              `json:"RD"`
+	RA         bool              `json:"RA"`
+	AD         bool              `json:"AD"`
+	CD         bool              `json:"CD"`
+	Question   []DNSJSONQuestion `json:"Question"`
+	Answer     []DNSJSONRecord   `json:"Answer,omitempty"`
+	Authority  []DNSJSONRecord   `json:"Authority,omitempty"`
+	Additional []DNSJSONRecord   `json:"Additional,omitempty"`
+	// EDNSClientSubnet is the client subnet the answer applies to, as
+	// address/prefix, when the query carried one.
+	EDNSClientSubnet string `json:"edns_client_subnet,omitempty"`
+	Comment          string `json:"Comment,omitempty"`
+}
+
+type DNSJSONQuestion struct {
+	Name string  `json:"name"`
+	Type DNSType `json:"type"`
+}
+
+type DNSJSONRecord struct {
+	Name string  `json:"name"`
+	Type DNSType `json:"type"`
+	TTL  uint32  `json:"TTL"`
+	Data string  `json:"data"`
+}
+
+// NewDNSJSONResponse converts res to the DNS JSON API format. Names are
+// fully qualified, as the public resolvers return them.
+func NewDNSJSONResponse(res *DNSPacket) *DNSJSONResponse {
+	h := res.Header
+	if h == nil {
+		h = &DNSHeader{}
+	}
+	out := &DNSJSONResponse{
+		Status:     res.RCode(),
+		TC:         h.TC == 1,
+		RD:         h.RD == 1,
+		RA:         h.RA == 1,
+		AD:         h.AuthenticData(),
+		CD:         h.CheckingDisabled(),
+		Question:   []DNSJSONQuestion{},
+		Answer:     toDNSJSONRecords(res.Answers),
+		Authority:  toDNSJSONRecords(res.Authorities),
+		Additional: toDNSJSONRecords(res.Additionals),
+	}
+	for _, q := range res.Questions {
+		out.Question = append(out.Question, DNSJSONQuestion{Name: fqdn(q.Name), Type: q.Type})
+	}
+	if opt := res.EDNS(); opt != nil {
+		if ecs, err := opt.ClientSubnet(); err == nil && ecs != nil {
+			out.EDNSClientSubnet = fmt.Sprintf("%s/%d", ecs.Address, ecs.SourcePrefix)
+		}
+	}
+	return out
+}
+
+// Packet converts r back to a response message, with names in the form
+// FromBytes gives them. The ID is left zero for the caller to fill in.
+func (r *DNSJSONResponse) Packet() (*DNSPacket, error) {
+	p := &DNSPacket{Header: &DNSHeader{
+		QR: DNSResponse,
+		TC: boolBit(r.TC),
+		RD: boolBit(r.RD),
+		RA: boolBit(r.RA),
+	}}
+	p.Header.SetAuthenticData(r.AD)
+	p.Header.SetCheckingDisabled(r.CD)
+	for _, q := range r.Question {
+		p.AddQuestion(&DNSQuestion{Name: relativeName(q.Name), Type: q.Type, Class: DNSClassIN})
+	}
+	var err error
+	if p.Answers, err = fromDNSJSONRecords(r.Answer, SectionAnswer); err != nil {
+		return nil, err
+	}
+	if p.Authorities, err = fromDNSJSONRecords(r.Authority, SectionAuthority); err != nil {
+		return nil, err
+	}
+	if p.Additionals, err = fromDNSJSONRecords(r.Additional, SectionAdditional); err != nil {
+		return nil, err
+	}
+	p.Header.ANCount = uint16(len(p.Answers))
+	p.Header.NSCount = uint16(len(p.Authorities))
+	p.Header.ARCount = uint16(len(p.Additionals))
+	// Last, so that the OPT an extended rcode needs is not overwritten.
+	p.SetRCode(r.Status)
+	return p, nil
+}
+
+func fromDNSJSONRecords(records []DNSJSONRecord, section string) ([]DNSResource, error) {
+	var out []DNSResource
+	for i, r := range records {
+		h := DNSResourceRecord{Name: relativeName(r.Name), Type: r.Type, Class: DNSClassIN, TTL: r.TTL}
+		rr, err := parsePresentationRData(h, r.Data)
+		if err != nil {
+			return nil, &ParseError{Section: section, Index: i, Err: fmt.Errorf("%s %q: %v", r.Type, r.Data, err)}
+		}
+		out = append(out, rr)
+	}
+	return out, nil
+}
+
+func toDNSJSONRecords(records []DNSResource) []DNSJSONRecord {
+	var out []DNSJSONRecord
+	for _, rr := range records {
+		if rr.GetType() == DNSTypeEDNS {
+			continue
+		}
+		data, ok := rdataPresentation(rr)
+		if !ok {
+			// the generic form of RFC 3597 §5
+			rdata := rr.Encode()
+			data = fmt.Sprintf(`\# %d`, len(rdata))
+			if len(rdata) > 0 {
+				data += " " + hex.EncodeToString(rdata)
+			}
+		}
+		h := rr.GetHeader()
+		out = append(out, DNSJSONRecord{Name: fqdn(h.Name), Type: h.Type, TTL: h.TTL, Data: data})
+	}
+	return out
+}
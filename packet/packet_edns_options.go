@@ -0,0 +1,340 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrEDNSOption is returned when an EDNS option's data doesn't match the
+// layout its code defines.
+var ErrEDNSOption = errors.New("malformed EDNS option")
+
+// EDNS Client Subnet address families (RFC 7871 §6).
+const (
+	ClientSubnetFamilyIPv4 uint16 = 1
+	ClientSubnetFamilyIPv6 uint16 = 2
+)
+
+// EDNSClientSubnet is the decoded form of an EDNS Client Subnet option
+// (RFC 7871). Address holds the full-length address with the bits past
+// SourcePrefix zeroed.
+type EDNSClientSubnet struct {
+	Family       uint16
+	SourcePrefix uint8
+	ScopePrefix  uint8
+	Address      net.IP
+}
+
+// EDNSCookie is the decoded form of a DNS Cookie option (RFC 7873 §4).
+// Server is empty in a client's first query.
+type EDNSCookie struct {
+	Client []byte
+	Server []byte
+}
+
+// EDNSTCPKeepalive is the decoded form of an edns-tcp-keepalive option
+// (RFC 7828 §3.1). Clients send it without a timeout; servers include one.
+type EDNSTCPKeepalive struct {
+	HasTimeout bool
+	Timeout    time.Duration
+}
+
+// EDNSExpire is the decoded form of an EDNS EXPIRE option (RFC 7314).
+// Queries carry it empty; responses carry the zone's remaining expire time.
+type EDNSExpire struct {
+	HasExpire bool
+	Expire    uint32
+}
+
+// EDECode is an Extended DNS Error INFO-CODE (RFC 8914 §4).
+type EDECode uint16
+
+const (
+	EDECodeOther                      EDECode = 0
+	EDECodeUnsupportedDNSKEYAlgorithm EDECode = 1
+	EDECodeUnsupportedDSDigestType    EDECode = 2
+	EDECodeStaleAnswer                EDECode = 3
+	EDECodeForgedAnswer               EDECode = 4
+	EDECodeDNSSECIndeterminate        EDECode = 5
+	EDECodeDNSSECBogus                EDECode = 6
+	EDECodeSignatureExpired           EDECode = 7
+	EDECodeSignatureNotYetValid       EDECode = 8
+	EDECodeDNSKEYMissing              EDECode = 9
+	EDECodeRRSIGsMissing              EDECode = 10
+	EDECodeNoZoneKeyBitSet            EDECode = 11
+	EDECodeNSECMissing                EDECode = 12
+	EDECodeCachedError                EDECode = 13
+	EDECodeNotReady                   EDECode = 14
+	EDECodeBlocked                    EDECode = 15
+	EDECodeCensored                   EDECode = 16
+	EDECodeFiltered                   EDECode = 17
+	EDECodeProhibited                 EDECode = 18
+	EDECodeStaleNXDOMAINAnswer        EDECode = 19
+	EDECodeNotAuthoritative           EDECode = 20
+	EDECodeNotSupported               EDECode = 21
+	EDECodeNoReachableAuthority       EDECode = 22
+	EDECodeNetworkError               EDECode = 23
+	EDECodeInvalidData                EDECode = 24
+)
+
+var edeCodeNames = map[EDECode]string{
+	EDECodeOther:                      "Other",
+	EDECodeUnsupportedDNSKEYAlgorithm: "Unsupported DNSKEY Algorithm",
+	EDECodeUnsupportedDSDigestType:    "Unsupported DS Digest Type",
+	EDECodeStaleAnswer:                "Stale Answer",
+	EDECodeForgedAnswer:               "Forged Answer",
+	EDECodeDNSSECIndeterminate:        "DNSSEC Indeterminate",
+	EDECodeDNSSECBogus:                "DNSSEC Bogus",
+	EDECodeSignatureExpired:           "Signature Expired",
+	EDECodeSignatureNotYetValid:       "Signature Not Yet Valid",
+	EDECodeDNSKEYMissing:              "DNSKEY Missing",
+	EDECodeRRSIGsMissing:              "RRSIGs Missing",
+	EDECodeNoZoneKeyBitSet:            "No Zone Key Bit Set",
+	EDECodeNSECMissing:                "NSEC Missing",
+	EDECodeCachedError:                "Cached Error",
+	EDECodeNotReady:                   "Not Ready",
+	EDECodeBlocked:                    "Blocked",
+	EDECodeCensored:                   "Censored",
+	EDECodeFiltered:                   "Filtered",
+	EDECodeProhibited:                 "Prohibited",
+	EDECodeStaleNXDOMAINAnswer:        "Stale NXDOMAIN Answer",
+	EDECodeNotAuthoritative:           "Not Authoritative",
+	EDECodeNotSupported:               "Not Supported",
+	EDECodeNoReachableAuthority:       "No Reachable Authority",
+	EDECodeNetworkError:               "Network Error",
+	EDECodeInvalidData:                "Invalid Data",
+}
+
+func (c EDECode) String() string {
+	if name, ok := edeCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("EDE%d", uint16(c))
+}
+
+// EDNSExtendedError is the decoded form of an Extended DNS Error option
+// (RFC 8914 §2).
+type EDNSExtendedError struct {
+	InfoCode  EDECode
+	ExtraText string
+}
+
+func (e *EDNSExtendedError) String() string {
+	if e.ExtraText == "" {
+		return fmt.Sprintf("%d (%s)", uint16(e.InfoCode), e.InfoCode)
+	}
+	return fmt.Sprintf("%d (%s): %s", uint16(e.InfoCode), e.InfoCode, e.ExtraText)
+}
+
+// Option returns the data of the first option with the given code.
+func (d *DNSResourceRecordEDNS) Option(code uint16) ([]byte, bool) {
+	for _, option := range d.Options {
+		if option.Code == code {
+			return option.Data, true
+		}
+	}
+	return nil, false
+}
+
+// ClientSubnet decodes the EDNS Client Subnet option. It returns nil and no
+// error when the option is absent.
+func (d *DNSResourceRecordEDNS) ClientSubnet() (*EDNSClientSubnet, error) {
+	data, ok := d.Option(EDNSOptionClientSubnet)
+	if !ok {
+		return nil, nil
+	}
+	return ParseClientSubnet(data)
+}
+
+// Cookie decodes the DNS Cookie option. It returns nil and no error when
+// the option is absent.
+func (d *DNSResourceRecordEDNS) Cookie() (*EDNSCookie, error) {
+	data, ok := d.Option(EDNSOptionCookie)
+	if !ok {
+		return nil, nil
+	}
+	return ParseCookie(data)
+}
+
+// NSID returns the name server identifier (RFC 5001). Queries carry the
+// option empty to ask for it.
+func (d *DNSResourceRecordEDNS) NSID() ([]byte, bool) {
+	return d.Option(EDNSOptionNSID)
+}
+
+// TCPKeepalive decodes the edns-tcp-keepalive option. It returns nil and no
+// error when the option is absent.
+func (d *DNSResourceRecordEDNS) TCPKeepalive() (*EDNSTCPKeepalive, error) {
+	data, ok := d.Option(EDNSOptionTCPKeepalive)
+	if !ok {
+		return nil, nil
+	}
+	return ParseTCPKeepalive(data)
+}
+
+// Expire decodes the EXPIRE option. It returns nil and no error when the
+// option is absent.
+func (d *DNSResourceRecordEDNS) Expire() (*EDNSExpire, error) {
+	data, ok := d.Option(EDNSOptionExpire)
+	if !ok {
+		return nil, nil
+	}
+	return ParseExpire(data)
+}
+
+// Padding returns the length of the Padding option (RFC 7830).
+func (d *DNSResourceRecordEDNS) Padding() (int, bool) {
+	data, ok := d.Option(EDNSOptionPadding)
+	return len(data), ok
+}
+
+// ExtendedErrors decodes every Extended DNS Error option in the record; a
+// response may carry several.
+func (d *DNSResourceRecordEDNS) ExtendedErrors() ([]*EDNSExtendedError, error) {
+	var out []*EDNSExtendedError
+	for _, option := range d.Options {
+		if option.Code != EDNSOptionExtendedError {
+			continue
+		}
+		ede, err := ParseExtendedError(option.Data)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ede)
+	}
+	return out, nil
+}
+
+// ParseClientSubnet decodes the data of an EDNS Client Subnet option. The
+// address must be exactly as long as SourcePrefix requires.
+func ParseClientSubnet(data []byte) (*EDNSClientSubnet, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: client subnet is %d bytes", ErrEDNSOption, len(data))
+	}
+	ecs := &EDNSClientSubnet{
+		Family:       binary.BigEndian.Uint16(data),
+		SourcePrefix: data[2],
+		ScopePrefix:  data[3],
+	}
+	var size int
+	switch ecs.Family {
+	case ClientSubnetFamilyIPv4:
+		size = net.IPv4len
+	case ClientSubnetFamilyIPv6:
+		size = net.IPv6len
+	default:
+		return nil, fmt.Errorf("%w: client subnet family %d", ErrEDNSOption, ecs.Family)
+	}
+	if int(ecs.SourcePrefix) > size*8 || int(ecs.ScopePrefix) > size*8 {
+		return nil, fmt.Errorf("%w: client subnet prefix /%d/%d", ErrEDNSOption, ecs.SourcePrefix, ecs.ScopePrefix)
+	}
+	addr := data[4:]
+	if len(addr) != (int(ecs.SourcePrefix)+7)/8 {
+		return nil, fmt.Errorf("%w: client subnet address is %d bytes for /%d", ErrEDNSOption, len(addr), ecs.SourcePrefix)
+	}
+	ecs.Address = make(net.IP, size)
+	copy(ecs.Address, addr)
+	return ecs, nil
+}
+
+// ParseCookie decodes the data of a DNS Cookie option: an 8-byte client
+// cookie optionally followed by an 8 to 32-byte server cookie.
+func ParseCookie(data []byte) (*EDNSCookie, error) {
+	if len(data) != 8 && (len(data) < 16 || len(data) > 40) {
+		return nil, fmt.Errorf("%w: cookie is %d bytes", ErrEDNSOption, len(data))
+	}
+	cookie := &EDNSCookie{Client: cloneBytes(data[:8])}
+	if len(data) > 8 {
+		cookie.Server = cloneBytes(data[8:])
+	}
+	return cookie, nil
+}
+
+// ParseTCPKeepalive decodes the data of an edns-tcp-keepalive option, whose
+// timeout is in units of 100 milliseconds.
+func ParseTCPKeepalive(data []byte) (*EDNSTCPKeepalive, error) {
+	switch len(data) {
+	case 0:
+		return &EDNSTCPKeepalive{}, nil
+	case 2:
+		timeout := binary.BigEndian.Uint16(data)
+		return &EDNSTCPKeepalive{HasTimeout: true, Timeout: time.Duration(timeout) * 100 * time.Millisecond}, nil
+	}
+	return nil, fmt.Errorf("%w: tcp keepalive is %d bytes", ErrEDNSOption, len(data))
+}
+
+// ParseExpire decodes the data of an EXPIRE option.
+func ParseExpire(data []byte) (*EDNSExpire, error) {
+	switch len(data) {
+	case 0:
+		return &EDNSExpire{}, nil
+	case 4:
+		return &EDNSExpire{HasExpire: true, Expire: binary.BigEndian.Uint32(data)}, nil
+	}
+	return nil, fmt.Errorf("%w: expire is %d bytes", ErrEDNSOption, len(data))
+}
+
+// ParseExtendedError decodes the data of an Extended DNS Error option.
+func ParseExtendedError(data []byte) (*EDNSExtendedError, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("%w: extended error is %d bytes", ErrEDNSOption, len(data))
+	}
+	return &EDNSExtendedError{
+		InfoCode:  EDECode(binary.BigEndian.Uint16(data)),
+		ExtraText: string(bytes.TrimRight(data[2:], "\x00")),
+	}, nil
+}
+
+// AddEDNSOptionNSID adds an NSID option (RFC 5001). Pass nil in a query to
+// request the server's identifier.
+func (d *DNSResourceRecordEDNS) AddEDNSOptionNSID(id []byte) {
+	d.AddEDNSOption(EDNSOptionNSID, cloneBytes(id))
+}
+
+// AddEDNSOptionTCPKeepalive adds an edns-tcp-keepalive option (RFC 7828).
+// A zero timeout adds the empty form clients send.
+func (d *DNSResourceRecordEDNS) AddEDNSOptionTCPKeepalive(timeout time.Duration) {
+	if timeout <= 0 {
+		d.AddEDNSOption(EDNSOptionTCPKeepalive, nil)
+		return
+	}
+	units := timeout / (100 * time.Millisecond)
+	if units > 0xFFFF {
+		units = 0xFFFF
+	}
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, uint16(units))
+	d.AddEDNSOption(EDNSOptionTCPKeepalive, data)
+}
+
+// AddEDNSOptionExtendedError adds an Extended DNS Error option (RFC 8914)
+// with an optional human-readable explanation.
+func (d *DNSResourceRecordEDNS) AddEDNSOptionExtendedError(code EDECode, text string) {
+	data := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(data, uint16(code))
+	d.AddEDNSOption(EDNSOptionExtendedError, append(data, text...))
+}
+
+// optionString renders an option's data for String, decoding the options
+// dig shows in readable form and falling back to hex.
+func optionString(option EDNSOption) (string, bool) {
+	switch option.Code {
+	case EDNSOptionClientSubnet:
+		if ecs, err := ParseClientSubnet(option.Data); err == nil {
+			return fmt.Sprintf("%s/%d/%d", ecs.Address, ecs.SourcePrefix, ecs.ScopePrefix), true
+		}
+	case EDNSOptionExtendedError:
+		if ede, err := ParseExtendedError(option.Data); err == nil {
+			return ede.String(), true
+		}
+	case EDNSOptionTCPKeepalive:
+		if ka, err := ParseTCPKeepalive(option.Data); err == nil && ka.HasTimeout {
+			return ka.Timeout.String(), true
+		}
+	}
+	return "", false
+}
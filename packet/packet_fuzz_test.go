@@ -0,0 +1,49 @@
+package packet
+
+import (
+	"reflect"
+	"testing"
+)
+
+// FuzzFromBytes feeds arbitrary datagrams to the decoder. Whatever it
+// accepts must re-encode without panicking, compressed or not, and Unpack
+// must reach the same verdict and the same message. Seeds live
+// in testdata/fuzz/FuzzFromBytes; run with `go test -fuzz=FuzzFromBytes`.
+func FuzzFromBytes(f *testing.F) {
+	query := NewPacket()
+	query.AddQuestionA("www.example.com")
+	query.AddAdditionalEDNS(4096, 0, 0, true)
+	f.Add(query.Bytes())
+
+	resp := NewPacket()
+	resp.Header.QR = DNSResponse
+	resp.AddQuestionMX("example.com")
+	resp.AddAnswer(&DNSResourceRecordMX{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeMX, Class: DNSClassIN, TTL: 300},
+		Preference:        10,
+		Exchange:          "mail.example.com",
+	})
+	resp.AddAuthority(&DNSResourceRecordSOA{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeSOA, Class: DNSClassIN, TTL: 300},
+		MName:             "ns1.example.com",
+		RName:             "hostmaster.example.com",
+	})
+	f.Add(resp.Bytes())
+	f.Add(mustPack(f, resp, false))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pkt, err := FromBytes(data)
+		var unpacked DNSPacket
+		if uerr := unpacked.Unpack(data); (uerr == nil) != (err == nil) {
+			t.Fatalf("FromBytes error %v, Unpack error %v", err, uerr)
+		}
+		if err != nil {
+			return
+		}
+		if !reflect.DeepEqual(&unpacked, pkt) {
+			t.Fatalf("Unpack disagrees with FromBytes:\n%v\nvs\n%v", &unpacked, pkt)
+		}
+		pkt.Pack(true)
+		pkt.Pack(false)
+	})
+}
@@ -3,7 +3,9 @@ package packet
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"math/rand"
+	"strings"
 )
 
 const (
@@ -96,6 +98,12 @@ func (h *DNSHeader) Parse(reader *bytes.Reader) error {
 
 func (h *DNSHeader) Bytes() []byte {
 	data := make([]byte, 12)
+	h.encode(data)
+	return data
+}
+
+// encode writes the 12-byte header into data.
+func (h *DNSHeader) encode(data []byte) {
 	binary.BigEndian.PutUint16(data[:2], h.ID)
 	data[2] = h.QR<<7 | h.OpCode<<3 | h.AA<<2 | h.TC<<1 | h.RD
 	data[3] = h.RA<<7 | h.Z<<4 | h.RCode
@@ -103,5 +111,64 @@ func (h *DNSHeader) Bytes() []byte {
 	binary.BigEndian.PutUint16(data[6:8], h.ANCount)
 	binary.BigEndian.PutUint16(data[8:10], h.NSCount)
 	binary.BigEndian.PutUint16(data[10:12], h.ARCount)
-	return data
+}
+
+// Bits of the Z field: the reserved bit, then AD and CD (RFC 4035 §3.2).
+const (
+	headerZAD uint8 = 0x02 // authentic data
+	headerZCD uint8 = 0x01 // checking disabled
+)
+
+// AuthenticData reports whether the AD bit is set.
+func (h *DNSHeader) AuthenticData() bool { return h.Z&headerZAD != 0 }
+
+// CheckingDisabled reports whether the CD bit is set.
+func (h *DNSHeader) CheckingDisabled() bool { return h.Z&headerZCD != 0 }
+
+// SetAuthenticData sets or clears the AD bit.
+func (h *DNSHeader) SetAuthenticData(ad bool) { h.setZ(headerZAD, ad) }
+
+// SetCheckingDisabled sets or clears the CD bit.
+func (h *DNSHeader) SetCheckingDisabled(cd bool) { h.setZ(headerZCD, cd) }
+
+func (h *DNSHeader) setZ(bit uint8, set bool) {
+	if set {
+		h.Z |= bit
+	} else {
+		h.Z &^= bit
+	}
+}
+
+// String renders the two header lines of a dig response, e.g.
+//
+//	;; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 4660
+//	;; flags: qr rd ra; QUERY: 1, ANSWER: 1, AUTHORITY: 0, ADDITIONAL: 0
+func (h *DNSHeader) String() string {
+	return h.format(DNSRCode(h.RCode))
+}
+
+// format renders the header with rcode as its status, so a packet can show
+// the extended RCODE it assembles from the header and OPT record.
+func (h *DNSHeader) format(rcode DNSRCode) string {
+	var flags []string
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{h.QR == 1, "qr"},
+		{h.AA == 1, "aa"},
+		{h.TC == 1, "tc"},
+		{h.RD == 1, "rd"},
+		{h.RA == 1, "ra"},
+		{h.Z&headerZAD != 0, "ad"},
+		{h.Z&headerZCD != 0, "cd"},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	return fmt.Sprintf(";; ->>HEADER<<- opcode: %s, status: %s, id: %d\n"+
+		";; flags: %s; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d",
+		DNSOpCode(h.OpCode), rcode, h.ID,
+		strings.Join(flags, " "), h.QDCount, h.ANCount, h.NSCount, h.ARCount)
 }
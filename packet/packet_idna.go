@@ -0,0 +1,303 @@
+package packet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrIDNA is returned for names that can't be converted between their
+// Unicode and ASCII (A-label) forms.
+var ErrIDNA = errors.New("invalid internationalized domain name")
+
+// acePrefix marks a label holding punycode (RFC 5890 §2.3.2.1).
+const acePrefix = "xn--"
+
+// IDNAToASCII converts a Unicode domain name to the ASCII form used on the
+// wire, encoding every non-ASCII label as an "xn--" A-label (RFC 5891
+// §4.4). Labels are lower-cased and fullwidth characters folded before
+// encoding, and the ideographic full stops U+3002, U+FF0E and U+FF61 are
+// accepted as separators. ASCII labels are returned unchanged, so names
+// that are already ASCII pass through as they are. A trailing dot is kept.
+//
+// Only the common mappings of UTS #46 are applied; names needing Unicode
+// normalization should be normalized (NFC) by the caller.
+func IDNAToASCII(name string) (string, error) {
+	if isASCII(name) {
+		return name, nil
+	}
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '。', '．', '｡':
+			return '.'
+		}
+		return r
+	}, name)
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		ascii, err := labelToASCII(label)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q: %v", ErrIDNA, name, err)
+		}
+		labels[i] = ascii
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// IDNAToUnicode converts the A-labels of name back to Unicode. A label
+// that doesn't decode, or whose Unicode form wouldn't encode back to it,
+// is rejected.
+func IDNAToUnicode(name string) (string, error) {
+	if !strings.Contains(strings.ToLower(name), acePrefix) {
+		return name, nil
+	}
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if len(label) < len(acePrefix) || !strings.EqualFold(label[:len(acePrefix)], acePrefix) {
+			continue
+		}
+		runes, err := punycodeDecode(strings.ToLower(label[len(acePrefix):]))
+		if err != nil {
+			return "", fmt.Errorf("%w: %q: %v", ErrIDNA, label, err)
+		}
+		unicodeLabel := string(runes)
+		if ascii, err := labelToASCII(unicodeLabel); err != nil || ascii != strings.ToLower(label) {
+			return "", fmt.Errorf("%w: %q is not a valid A-label", ErrIDNA, label)
+		}
+		labels[i] = unicodeLabel
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// DisplayName returns name with its Unicode form appended in parentheses
+// when it contains A-labels, e.g. "xn--fsqu00a.xn--fiqs8s (例子.中国)", for
+// logs and other human-facing output. Other names are returned as they are.
+func DisplayName(name string) string {
+	ascii, err := IDNAToASCII(name)
+	if err != nil {
+		return name
+	}
+	u, err := IDNAToUnicode(ascii)
+	if err != nil || u == ascii {
+		return name
+	}
+	return ascii + " (" + u + ")"
+}
+
+// labelToASCII maps and validates a single Unicode label and encodes it as
+// an A-label.
+func labelToASCII(label string) (string, error) {
+	label = strings.Map(func(r rune) rune {
+		if r >= '！' && r <= '～' {
+			r -= 0xFEE0 // fullwidth ASCII
+		}
+		return unicode.ToLower(r)
+	}, label)
+	if isASCII(label) {
+		return label, nil
+	}
+	if !utf8.ValidString(label) {
+		return "", errors.New("invalid UTF-8")
+	}
+	if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+		return "", errors.New("label begins or ends with a hyphen")
+	}
+	for i, r := range label {
+		switch {
+		case i == 0 && unicode.IsMark(r):
+			return "", fmt.Errorf("label begins with combining mark %U", r)
+		case r == '-', unicode.IsLetter(r), unicode.IsMark(r), unicode.IsDigit(r):
+		default:
+			return "", fmt.Errorf("disallowed character %U", r)
+		}
+	}
+	encoded, err := punycodeEncode([]rune(label))
+	if err != nil {
+		return "", err
+	}
+	ascii := acePrefix + encoded
+	if len(ascii) > maxLabelLength {
+		return "", fmt.Errorf("A-label %q exceeds %d bytes", ascii, maxLabelLength)
+	}
+	return ascii, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Bootstring parameters for punycode (RFC 3492 §5).
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+	punyMaxInt      = 1<<31 - 1
+)
+
+var errPunycode = errors.New("malformed punycode")
+
+// punycodeEncode implements the encoding procedure of RFC 3492 §6.3.
+func punycodeEncode(input []rune) (string, error) {
+	var out strings.Builder
+	for _, r := range input {
+		if r < punyInitialN {
+			out.WriteRune(r)
+		}
+	}
+	b := out.Len()
+	h := b
+	if b > 0 {
+		out.WriteByte('-')
+	}
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for h < len(input) {
+		m := rune(unicode.MaxRune + 1)
+		for _, r := range input {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		if int(m-n) > (punyMaxInt-delta)/(h+1) {
+			return "", errPunycode
+		}
+		delta += int(m-n) * (h + 1)
+		n = m
+		for _, r := range input {
+			if r < n {
+				if delta++; delta > punyMaxInt {
+					return "", errPunycode
+				}
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				out.WriteByte(punyDigit(t + (q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out.WriteByte(punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return out.String(), nil
+}
+
+// punycodeDecode implements the decoding procedure of RFC 3492 §6.2.
+func punycodeDecode(s string) ([]rune, error) {
+	var output []rune
+	pos := 0
+	if b := strings.LastIndexByte(s, '-'); b > 0 {
+		for i := 0; i < b; i++ {
+			if s[i] >= punyInitialN {
+				return nil, errPunycode
+			}
+			output = append(output, rune(s[i]))
+		}
+		pos = b + 1
+	}
+	n, i, bias := rune(punyInitialN), 0, punyInitialBias
+	for pos < len(s) {
+		oldi, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos >= len(s) {
+				return nil, errPunycode
+			}
+			digit, ok := punyDigitValue(s[pos])
+			pos++
+			if !ok || digit > (punyMaxInt-i)/w {
+				return nil, errPunycode
+			}
+			i += digit * w
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			if w > punyMaxInt/(punyBase-t) {
+				return nil, errPunycode
+			}
+			w *= punyBase - t
+		}
+		count := len(output) + 1
+		bias = punyAdapt(i-oldi, count, oldi == 0)
+		if i/count > unicode.MaxRune-int(n) {
+			return nil, errPunycode
+		}
+		n += rune(i / count)
+		i %= count
+		if n < punyInitialN || !utf8.ValidRune(n) {
+			return nil, errPunycode
+		}
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = n
+		i++
+	}
+	return output, nil
+}
+
+func punyThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punyTMin
+	case k >= bias+punyTMax:
+		return punyTMax
+	}
+	return k - bias
+}
+
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyDigitValue(c byte) (int, bool) {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a'), true
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A'), true
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26, true
+	}
+	return 0, false
+}
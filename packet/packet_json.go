@@ -0,0 +1,329 @@
+package packet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSON representation of DNS messages (RFC 8427).
+//
+// Header fields and flags are individual integer members. A single
+// question is flattened into QNAME/QTYPE/QCLASS, several go to questionRRs.
+// Every resource record carries its RDATA as RDATAHEX, the raw fallback
+// that works for any type, and, for types with a presentation format, an
+// "rdata" member named after the type (rdataA, rdataMX, ...).
+// UnmarshalJSON rebuilds records from RDATAHEX, or from the rdata member
+// for the simple types A, AAAA, CNAME, NS, PTR and MX so hand-written
+// fixtures stay readable.
+
+type jsonMessage struct {
+	ID      uint16 `json:"ID"`
+	QR      uint8  `json:"QR"`
+	Opcode  uint8  `json:"Opcode"`
+	AA      uint8  `json:"AA"`
+	TC      uint8  `json:"TC"`
+	RD      uint8  `json:"RD"`
+	RA      uint8  `json:"RA"`
+	AD      uint8  `json:"AD"`
+	CD      uint8  `json:"CD"`
+	RCODE   uint8  `json:"RCODE"`
+	QDCOUNT uint16 `json:"QDCOUNT"`
+	ANCOUNT uint16 `json:"ANCOUNT"`
+	NSCOUNT uint16 `json:"NSCOUNT"`
+	ARCOUNT uint16 `json:"ARCOUNT"`
+
+	QNAME      string `json:"QNAME,omitempty"`
+	QTYPE      uint16 `json:"QTYPE,omitempty"`
+	QTYPEname  string `json:"QTYPEname,omitempty"`
+	QCLASS     uint16 `json:"QCLASS,omitempty"`
+	QCLASSname string `json:"QCLASSname,omitempty"`
+
+	QuestionRRs   []jsonQuestion `json:"questionRRs,omitempty"`
+	AnswerRRs     []jsonRR       `json:"answerRRs,omitempty"`
+	AuthorityRRs  []jsonRR       `json:"authorityRRs,omitempty"`
+	AdditionalRRs []jsonRR       `json:"additionalRRs,omitempty"`
+}
+
+type jsonQuestion struct {
+	NAME      string `json:"NAME"`
+	TYPE      uint16 `json:"TYPE"`
+	TYPEname  string `json:"TYPEname,omitempty"`
+	CLASS     uint16 `json:"CLASS"`
+	CLASSname string `json:"CLASSname,omitempty"`
+}
+
+// jsonRR is a resource record object. Its rdata member has a name that
+// depends on the type, so it is marshalled by hand.
+type jsonRR struct {
+	NAME      string
+	TYPE      DNSType
+	CLASS     DNSClass
+	TTL       uint32
+	RDATAHEX  string
+	rdataName string // e.g. "rdataMX"; empty when absent
+	rdata     string
+}
+
+func (rr jsonRR) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"NAME":      rr.NAME,
+		"TYPE":      uint16(rr.TYPE),
+		"TYPEname":  rr.TYPE.String(),
+		"CLASS":     uint16(rr.CLASS),
+		"CLASSname": rr.CLASS.String(),
+		"TTL":       rr.TTL,
+		"RDLENGTH":  len(rr.RDATAHEX) / 2,
+		"RDATAHEX":  rr.RDATAHEX,
+	}
+	if rr.rdataName != "" {
+		m[rr.rdataName] = rr.rdata
+	}
+	return json.Marshal(m)
+}
+
+func (rr *jsonRR) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	var typ, class *uint16
+	var typeName, className string
+	for key, dst := range map[string]interface{}{
+		"NAME":      &rr.NAME,
+		"TYPE":      &typ,
+		"TYPEname":  &typeName,
+		"CLASS":     &class,
+		"CLASSname": &className,
+		"TTL":       &rr.TTL,
+		"RDATAHEX":  &rr.RDATAHEX,
+	} {
+		if raw, ok := m[key]; ok {
+			if err := json.Unmarshal(raw, dst); err != nil {
+				return fmt.Errorf("RR member %s: %v", key, err)
+			}
+		}
+	}
+	switch {
+	case typ != nil:
+		rr.TYPE = DNSType(*typ)
+	case typeName != "":
+		t, err := ParseDNSType(typeName)
+		if err != nil {
+			return err
+		}
+		rr.TYPE = t
+	default:
+		return fmt.Errorf("RR %q has no TYPE", rr.NAME)
+	}
+	switch {
+	case class != nil:
+		rr.CLASS = DNSClass(*class)
+	case className != "":
+		c, err := ParseDNSClass(className)
+		if err != nil {
+			return err
+		}
+		rr.CLASS = c
+	default:
+		rr.CLASS = DNSClassIN
+	}
+	rr.rdataName = "rdata" + rr.TYPE.String()
+	if raw, ok := m[rr.rdataName]; ok {
+		if err := json.Unmarshal(raw, &rr.rdata); err != nil {
+			return fmt.Errorf("RR member %s: %v", rr.rdataName, err)
+		}
+	} else {
+		rr.rdataName = ""
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler using the RFC 8427 format.
+func (packet *DNSPacket) MarshalJSON() ([]byte, error) {
+	h := packet.Header
+	if h == nil {
+		h = &DNSHeader{}
+	}
+	msg := jsonMessage{
+		ID:      h.ID,
+		QR:      h.QR,
+		Opcode:  h.OpCode,
+		AA:      h.AA,
+		TC:      h.TC,
+		RD:      h.RD,
+		RA:      h.RA,
+		AD:      boolBit(h.Z&headerZAD != 0),
+		CD:      boolBit(h.Z&headerZCD != 0),
+		RCODE:   h.RCode,
+		QDCOUNT: uint16(len(packet.Questions)),
+		ANCOUNT: uint16(len(packet.Answers)),
+		NSCOUNT: uint16(len(packet.Authorities)),
+		ARCOUNT: uint16(len(packet.Additionals)),
+	}
+	if len(packet.Questions) == 1 {
+		q := packet.Questions[0]
+		msg.QNAME = fqdn(q.Name)
+		msg.QTYPE, msg.QTYPEname = uint16(q.Type), q.Type.String()
+		msg.QCLASS, msg.QCLASSname = uint16(q.Class), q.Class.String()
+	} else {
+		for _, q := range packet.Questions {
+			msg.QuestionRRs = append(msg.QuestionRRs, jsonQuestion{
+				NAME:      fqdn(q.Name),
+				TYPE:      uint16(q.Type),
+				TYPEname:  q.Type.String(),
+				CLASS:     uint16(q.Class),
+				CLASSname: q.Class.String(),
+			})
+		}
+	}
+	msg.AnswerRRs = toJSONRRs(packet.Answers)
+	msg.AuthorityRRs = toJSONRRs(packet.Authorities)
+	msg.AdditionalRRs = toJSONRRs(packet.Additionals)
+	return json.Marshal(msg)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for the RFC 8427 format.
+func (packet *DNSPacket) UnmarshalJSON(data []byte) error {
+	var msg jsonMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	h := &DNSHeader{
+		ID:      msg.ID,
+		QR:      msg.QR & 1,
+		OpCode:  msg.Opcode & 0x0F,
+		AA:      msg.AA & 1,
+		TC:      msg.TC & 1,
+		RD:      msg.RD & 1,
+		RA:      msg.RA & 1,
+		RCode:   msg.RCODE & 0x0F,
+		QDCount: msg.QDCOUNT,
+		ANCount: msg.ANCOUNT,
+		NSCount: msg.NSCOUNT,
+		ARCount: msg.ARCOUNT,
+	}
+	if msg.AD != 0 {
+		h.Z |= headerZAD
+	}
+	if msg.CD != 0 {
+		h.Z |= headerZCD
+	}
+	p := DNSPacket{Header: h}
+	if msg.QNAME != "" {
+		class := DNSClass(msg.QCLASS)
+		if class == 0 {
+			class = DNSClassIN
+		}
+		p.Questions = append(p.Questions, &DNSQuestion{
+			Name:  relativeName(msg.QNAME),
+			Type:  DNSType(msg.QTYPE),
+			Class: class,
+		})
+	}
+	for _, q := range msg.QuestionRRs {
+		p.Questions = append(p.Questions, &DNSQuestion{
+			Name:  relativeName(q.NAME),
+			Type:  DNSType(q.TYPE),
+			Class: DNSClass(q.CLASS),
+		})
+	}
+	var err error
+	if p.Answers, err = fromJSONRRs(msg.AnswerRRs, SectionAnswer); err != nil {
+		return err
+	}
+	if p.Authorities, err = fromJSONRRs(msg.AuthorityRRs, SectionAuthority); err != nil {
+		return err
+	}
+	if p.Additionals, err = fromJSONRRs(msg.AdditionalRRs, SectionAdditional); err != nil {
+		return err
+	}
+	*packet = p
+	return nil
+}
+
+func toJSONRRs(records []DNSResource) []jsonRR {
+	var out []jsonRR
+	for _, rr := range records {
+		if edns, ok := rr.(*DNSResourceRecordEDNS); ok {
+			edns.syncTTL()
+		}
+		h := rr.GetHeader()
+		j := jsonRR{
+			NAME:     fqdn(h.Name),
+			TYPE:     h.Type,
+			CLASS:    h.Class,
+			TTL:      h.TTL,
+			RDATAHEX: strings.ToUpper(hex.EncodeToString(rr.Encode())),
+		}
+		if rdata, ok := rdataPresentation(rr); ok {
+			j.rdataName, j.rdata = "rdata"+h.Type.String(), rdata
+		}
+		out = append(out, j)
+	}
+	return out
+}
+
+// rdataPresentation extracts the RDATA part of a record's String, i.e.
+// everything after the owner, TTL, class and type fields.
+func rdataPresentation(rr DNSResource) (string, bool) {
+	switch rr.(type) {
+	case *DNSResourceRecordEDNS, *DNSResourceRecordUnknown:
+		return "", false
+	}
+	s, ok := rr.(fmt.Stringer)
+	if !ok {
+		return "", false
+	}
+	fields := strings.SplitN(s.String(), "\t", 5)
+	if len(fields) != 5 {
+		return "", false
+	}
+	return fields[4], true
+}
+
+func fromJSONRRs(rrs []jsonRR, section string) ([]DNSResource, error) {
+	var out []DNSResource
+	for i, j := range rrs {
+		rr, err := j.resource()
+		if err != nil {
+			return nil, &ParseError{Section: section, Index: i, Err: err}
+		}
+		out = append(out, rr)
+	}
+	return out, nil
+}
+
+// resource rebuilds the record, preferring RDATAHEX over the rdata member.
+func (j jsonRR) resource() (DNSResource, error) {
+	h := DNSResourceRecord{Name: relativeName(j.NAME), Type: j.TYPE, Class: j.CLASS, TTL: j.TTL}
+	if j.RDATAHEX != "" || j.rdataName == "" {
+		data, err := hex.DecodeString(j.RDATAHEX)
+		if err != nil {
+			return nil, fmt.Errorf("RDATAHEX: %v", err)
+		}
+		return DecodeRData(h, data)
+	}
+	rr, err := parsePresentationRData(h, j.rdata)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", j.rdataName, err)
+	}
+	return rr, nil
+}
+
+// relativeName strips the trailing dot the JSON form carries, matching the
+// names FromBytes produces; the root stays ".".
+func relativeName(name string) string {
+	if name == "." {
+		return name
+	}
+	return trimRoot(name)
+}
+
+func boolBit(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
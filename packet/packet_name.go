@@ -0,0 +1,312 @@
+package packet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Domain names are carried as strings in presentation format (RFC 1035
+// §5.1, RFC 4343 §2.1): labels are separated by '.', and label bytes that
+// would otherwise be ambiguous are escaped. The decoders write '.', '\',
+// '"', '(', ')' and ';' as "\X" and bytes outside printable ASCII, space
+// included, as "\DDD"; the encoders accept both forms for any byte. A name
+// read off the wire therefore re-encodes to exactly the same labels.
+
+// Errors returned by ValidateName.
+var (
+	ErrLabelTooLong = errors.New("label exceeds 63 bytes")
+	ErrEmptyLabel   = errors.New("empty label in domain name")
+	ErrNameEscape   = errors.New("invalid escape in domain name")
+)
+
+// ValidateName checks that name, in presentation format, has well-formed
+// escapes, no empty labels, labels of at most 63 bytes and a wire length of
+// at most 255 bytes. Both "" and "." denote the root.
+func ValidateName(name string) error {
+	name = trimRoot(name)
+	wireLen := 1 // root label
+	for name != "" {
+		end := labelEnd(name)
+		n, err := labelLength(name[:end])
+		switch {
+		case err != nil:
+			return fmt.Errorf("%w: %q", err, name[:end])
+		case n == 0:
+			return ErrEmptyLabel
+		case n > maxLabelLength:
+			return fmt.Errorf("%w: %q", ErrLabelTooLong, name[:end])
+		}
+		wireLen += 1 + n
+		if wireLen > maxNameLength {
+			return ErrNameTooLong
+		}
+		if end == len(name) {
+			break
+		}
+		name = name[end+1:]
+		if name == "" {
+			return ErrEmptyLabel
+		}
+	}
+	return nil
+}
+
+// CanonicalName returns the form of name used to compare names: ASCII
+// letters are lower-cased (RFC 4343 §3), escapes are normalized and the
+// trailing dot is dropped, so the root is "".
+func CanonicalName(name string) string {
+	name = trimRoot(name)
+	if plainName(name) {
+		return asciiLower(name)
+	}
+	var b []byte
+	for name != "" {
+		end := labelEnd(name)
+		label := unescapeLabel(name[:end])
+		for i, c := range label {
+			if c >= 'A' && c <= 'Z' {
+				label[i] = c + 'a' - 'A'
+			}
+		}
+		if len(b) > 0 {
+			b = append(b, '.')
+		}
+		b = appendEscapedLabel(b, label)
+		if end == len(name) {
+			break
+		}
+		name = name[end+1:]
+	}
+	return string(b)
+}
+
+// EqualName reports whether a and b name the same domain, ignoring ASCII
+// case, escaping and a trailing dot.
+func EqualName(a, b string) bool {
+	return CanonicalName(a) == CanonicalName(b)
+}
+
+// IsFQDN reports whether name ends with an unescaped '.'.
+func IsFQDN(name string) bool {
+	if !strings.HasSuffix(name, ".") {
+		return false
+	}
+	// the dot is escaped if an odd number of backslashes precede it
+	slashes := 0
+	for i := len(name) - 2; i >= 0 && name[i] == '\\'; i-- {
+		slashes++
+	}
+	return slashes%2 == 0
+}
+
+// SplitName splits name into its labels at the unescaped dots. Labels keep
+// their escapes; the root has no labels.
+func SplitName(name string) []string {
+	name = trimRoot(name)
+	var labels []string
+	for name != "" {
+		end := labelEnd(name)
+		labels = append(labels, name[:end])
+		if end == len(name) {
+			break
+		}
+		name = name[end+1:]
+	}
+	return labels
+}
+
+// IsSubDomain reports whether child is parent or a name below it. Names are
+// compared as by EqualName.
+func IsSubDomain(parent, child string) bool {
+	p, c := CanonicalName(parent), CanonicalName(child)
+	if p == "" || p == c {
+		return true
+	}
+	if !strings.HasSuffix(c, "."+p) {
+		return false
+	}
+	// "a\.example.com" is not below "example.com"
+	return IsFQDN(c[:len(c)-len(p)])
+}
+
+// validateResource checks the owner name of rr and the domain names in its
+// RDATA.
+func validateResource(rr DNSResource) error {
+	if err := ValidateName(rr.GetHeader().Name); err != nil {
+		return err
+	}
+	return validateRData(rr)
+}
+
+// validateRData checks the domain names in rr's RDATA.
+func validateRData(rr DNSResource) error {
+	var names [2]string
+	for _, name := range rdataNames(names[:0], rr) {
+		if err := ValidateName(name); err != nil {
+			return fmt.Errorf("%s RDATA: %w", rr.GetHeader().Type, err)
+		}
+	}
+	return nil
+}
+
+// rdataNames appends the domain names embedded in rr's RDATA to dst.
+func rdataNames(dst []string, rr DNSResource) []string {
+	switch r := rr.(type) {
+	case *DNSResourceRecordCNAME:
+		return append(dst, r.Domain)
+	case *DNSResourceRecordNS:
+		return append(dst, r.NameServer)
+	case *DNSResourceRecordPTR:
+		return append(dst, r.PtrDomainName)
+	case *DNSResourceRecordMX:
+		return append(dst, r.Exchange)
+	case *DNSResourceRecordSOA:
+		return append(dst, r.MName, r.RName)
+	case *DNSResourceRecordSRV:
+		return append(dst, r.Target)
+	case *DNSResourceRecordNAPTR:
+		return append(dst, r.Replacement)
+	case *DNSResourceRecordRRSIG:
+		return append(dst, r.SignerName)
+	case *DNSResourceRecordNSEC:
+		return append(dst, r.NextDomain)
+	case *DNSResourceRecordSVCB:
+		return append(dst, r.Target)
+	case *DNSResourceRecordHTTPS:
+		return append(dst, r.Target)
+	case *DNSResourceRecordTSIG:
+		return append(dst, r.Algorithm)
+	}
+	return dst
+}
+
+// trimRoot drops the unescaped trailing dot of name, if any.
+func trimRoot(name string) string {
+	if IsFQDN(name) {
+		return name[:len(name)-1]
+	}
+	return name
+}
+
+// labelEnd returns the index of the first unescaped '.' in name, or
+// len(name) if the whole of name is one label.
+func labelEnd(name string) int {
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '\\':
+			i++
+		case '.':
+			return i
+		}
+	}
+	return len(name)
+}
+
+// labelLength returns the number of bytes label occupies on the wire.
+func labelLength(label string) (int, error) {
+	n := 0
+	for i := 0; i < len(label); i++ {
+		if label[i] == '\\' {
+			width, ok := escapeWidth(label[i+1:])
+			if !ok {
+				return 0, ErrNameEscape
+			}
+			i += width
+		}
+		n++
+	}
+	return n, nil
+}
+
+// escapeWidth returns how many bytes after a backslash belong to the
+// escape: three for \DDD and one for \X.
+func escapeWidth(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if !isDigit(s[0]) {
+		return 1, true
+	}
+	if len(s) < 3 || !isDigit(s[1]) || !isDigit(s[2]) {
+		return 0, false
+	}
+	if (int(s[0]-'0')*100 + int(s[1]-'0')*10 + int(s[2]-'0')) > 255 {
+		return 0, false
+	}
+	return 3, true
+}
+
+// appendUnescapedLabel appends the wire bytes of label to dst. Malformed
+// escapes are copied literally; ValidateName reports them.
+func appendUnescapedLabel(dst []byte, label string) []byte {
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if c == '\\' {
+			if width, ok := escapeWidth(label[i+1:]); ok {
+				if width == 3 {
+					d := label[i+1:]
+					c = byte(int(d[0]-'0')*100 + int(d[1]-'0')*10 + int(d[2]-'0'))
+				} else {
+					c = label[i+1]
+				}
+				i += width
+			}
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+func unescapeLabel(label string) []byte {
+	return appendUnescapedLabel(make([]byte, 0, len(label)), label)
+}
+
+// appendEscapedLabel appends the presentation form of the wire label to dst.
+func appendEscapedLabel(dst []byte, label []byte) []byte {
+	for _, c := range label {
+		switch {
+		case c == '.' || c == '\\' || c == '"' || c == '(' || c == ')' || c == ';':
+			dst = append(dst, '\\', c)
+		case c <= ' ' || c > '~':
+			dst = append(dst, '\\', '0'+c/100, '0'+c/10%10, '0'+c%10)
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+// plainName reports whether name is already in canonical escaping: no
+// escapes and no bytes that the decoders would escape.
+func plainName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c == '\\' || c == '"' || c == '(' || c == ')' || c == ';':
+			return false
+		case c <= ' ' || c > '~':
+			return false
+		}
+	}
+	return true
+}
+
+// asciiLower lower-cases the ASCII letters of s, leaving other bytes alone.
+func asciiLower(s string) string {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= 'A' && c <= 'Z' {
+			b := []byte(s)
+			for j := i; j < len(b); j++ {
+				if c := b[j]; c >= 'A' && c <= 'Z' {
+					b[j] = c + 'a' - 'A'
+				}
+			}
+			return string(b)
+		}
+	}
+	return s
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,36 @@
+package packet
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer caps the capacity of buffers returned to the pool, so a
+// single oversized message doesn't pin its memory for the process lifetime.
+const maxPooledBuffer = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+var compressorPool = sync.Pool{
+	New: func() interface{} { return NewCompressor() },
+}
+
+// GetBuffer returns an empty buffer from a shared pool, sized for a typical
+// message after its first use. Pair it with PutBuffer once the encoded
+// bytes are no longer referenced.
+func GetBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// PutBuffer returns buf to the pool. The caller must not use buf, or any
+// slice obtained from buf.Bytes, afterwards.
+func PutBuffer(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(buf)
+}
@@ -0,0 +1,74 @@
+package packet
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Helpers for the presentation format of RFC 1035 §5.1, shared by the
+// String methods of the record types.
+
+// presentation joins the record header and rdata into one zone-file line,
+// with fields separated by tabs as dig prints them.
+func (r *DNSResourceRecord) presentation(rdata string) string {
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s", fqdn(r.Name), r.TTL, r.Class, r.Type, rdata)
+}
+
+// fqdn returns name with its trailing dot.
+func fqdn(name string) string {
+	if IsFQDN(name) {
+		return name
+	}
+	return name + "."
+}
+
+// quoteString renders s as a quoted <character-string>: '"' and '\' are
+// backslash-escaped and bytes outside printable ASCII become \DDD.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c > 0x7e:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// hexString renders binary RDATA fields as upper-case hex, or "-" when
+// empty, as used for NSEC3 salts.
+func hexString(data []byte) string {
+	if len(data) == 0 {
+		return "-"
+	}
+	return strings.ToUpper(hex.EncodeToString(data))
+}
+
+// base32Hex is the unpadded base32hex encoding of NSEC3 hashed owner names
+// (RFC 5155 §3.3).
+var base32Hex = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// sigTime renders an RRSIG timestamp as YYYYMMDDHHmmSS in UTC.
+func sigTime(t uint32) string {
+	return time.Unix(int64(t), 0).UTC().Format("20060102150405")
+}
+
+// typeList renders the types of an NSEC or NSEC3 bitmap.
+func typeList(types []DNSType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,870 @@
+package packet
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parsing of RDATA in the presentation format of RFC 1035 §5.1, as zone
+// files, RFC 8427 JSON and the DNS JSON API write it.
+
+// SplitFields splits a line of presentation format into its fields at
+// blanks outside quotes. Quotes and backslash escapes are kept for
+// ParseRData to resolve.
+func SplitFields(s string) []string {
+	var fields []string
+	current := ""
+	inQuote := false
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch == '"' {
+			inQuote = !inQuote
+			current += s[i : i+1]
+			continue
+		}
+		if ch == '\\' && i+1 < len(s) {
+			// keep the escape; parsers that care (TXT) unescape it
+			current += s[i : i+2]
+			i++
+			continue
+		}
+		if (ch == ' ' || ch == '\t') && !inQuote {
+			if current != "" {
+				fields = append(fields, current)
+				current = ""
+			}
+			continue
+		}
+		current += s[i : i+1]
+	}
+	if current != "" {
+		fields = append(fields, current)
+	}
+	return fields
+}
+
+// ParseRData builds the typed record for header from its RDATA fields in
+// presentation format, as SplitFields returns them. Domain names are
+// taken as written: relative names aren't completed with an origin. The
+// generic form of RFC 3597 §5, "\# length hex", is accepted for any type.
+func ParseRData(header DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) > 0 && rdata[0] == `\#` {
+		return parseGeneric(header, rdata[1:])
+	}
+	h := header
+	switch h.Type {
+	case DNSTypeA:
+		return parseA(h, rdata)
+	case DNSTypeAAAA:
+		return parseAAAA(h, rdata)
+	case DNSTypeCNAME:
+		return parseCNAME(h, rdata)
+	case DNSTypeNS:
+		return parseNS(h, rdata)
+	case DNSTypeMX:
+		return parseMX(h, rdata)
+	case DNSTypeTXT, DNSTypeSPF:
+		return parseTXT(h, rdata)
+	case DNSTypePTR:
+		return parsePTR(h, rdata)
+	case DNSTypeSOA:
+		return parseSOA(h, rdata)
+	case DNSTypeSRV:
+		return parseSRV(h, rdata)
+	case DNSTypeCAA:
+		return parseCAA(h, rdata)
+	case DNSTypeTLSA:
+		return parseTLSA(h, rdata)
+	case DNSTypeSSHFP:
+		return parseSSHFP(h, rdata)
+	case DNSTypeNAPTR:
+		return parseNAPTR(h, rdata)
+	case DNSTypeDNSKEY, DNSTypeCDNSKEY:
+		return parseDNSKEY(h, rdata)
+	case DNSTypeDS, DNSTypeCDS:
+		return parseDS(h, rdata)
+	case DNSTypeRRSIG:
+		return parseRRSIG(h, rdata)
+	case DNSTypeNSEC:
+		return parseNSEC(h, rdata)
+	case DNSTypeNSEC3:
+		return parseNSEC3(h, rdata)
+	case DNSTypeNSEC3PARAM:
+		return parseNSEC3PARAM(h, rdata)
+	case DNSTypeSVCB, DNSTypeHTTPS:
+		return parseSVCB(h, rdata)
+	}
+	return nil, fmt.Errorf("unsupported record type %s", h.Type)
+}
+
+// parsePresentationRData parses data, the RDATA of a record in one
+// string, into the record FromBytes would have made of it: names come
+// without their trailing dot.
+func parsePresentationRData(h DNSResourceRecord, data string) (DNSResource, error) {
+	rr, err := ParseRData(h, SplitFields(data))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeRData(h, rr.Encode())
+}
+
+// parseGeneric parses the RFC 3597 §5 generic RDATA form "\# length hex",
+// where the hex may be split into several fields. Types this package
+// knows are decoded into their typed record; others are kept as
+// DNSResourceRecordUnknown.
+func parseGeneric(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 1 {
+		return nil, fmt.Errorf("\\# requires an RDATA length")
+	}
+	length, err := strconv.ParseUint(rdata[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RDATA length %q: %v", rdata[0], err)
+	}
+	data, err := hex.DecodeString(strings.Join(rdata[1:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RDATA hex: %v", err)
+	}
+	if len(data) != int(length) {
+		return nil, fmt.Errorf("RDATA length %d does not match %d bytes of data", length, len(data))
+	}
+	return DecodeRData(h, data)
+}
+
+// ParseTTL parses a TTL in seconds, optionally followed by one of BIND's
+// units W, D, H or M, as in 1h or 2d.
+func ParseTTL(s string) (uint32, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, fmt.Errorf("empty TTL")
+	}
+	if s[len(s)-1] == 'S' {
+		s = s[:len(s)-1]
+	}
+	multiplier := uint32(1)
+	switch {
+	case strings.HasSuffix(s, "W"):
+		multiplier = 604800
+		s = s[:len(s)-1]
+	case strings.HasSuffix(s, "D"):
+		multiplier = 86400
+		s = s[:len(s)-1]
+	case strings.HasSuffix(s, "H"):
+		multiplier = 3600
+		s = s[:len(s)-1]
+	case strings.HasSuffix(s, "M"):
+		multiplier = 60
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad TTL value %q: %v", s, err)
+	}
+	return uint32(v) * multiplier, nil
+}
+
+func parseA(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 1 {
+		return nil, fmt.Errorf("A record requires an IP address")
+	}
+	ip := net.ParseIP(rdata[0])
+	if ip == nil || ip.To4() == nil {
+		return nil, fmt.Errorf("invalid A record IP %q", rdata[0])
+	}
+	return &DNSResourceRecordA{
+		DNSResourceRecord: h,
+		Address:           rdata[0],
+	}, nil
+}
+
+func parseAAAA(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 1 {
+		return nil, fmt.Errorf("AAAA record requires an IPv6 address")
+	}
+	ip := net.ParseIP(rdata[0])
+	if ip == nil || ip.To16() == nil {
+		return nil, fmt.Errorf("invalid AAAA record IP %q", rdata[0])
+	}
+	return &DNSResourceRecordAAAA{
+		DNSResourceRecord: h,
+		Address:           rdata[0],
+	}, nil
+}
+
+func parseCNAME(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 1 {
+		return nil, fmt.Errorf("CNAME record requires a target domain")
+	}
+	return &DNSResourceRecordCNAME{
+		DNSResourceRecord: h,
+		Domain:            rdata[0],
+	}, nil
+}
+
+func parseNS(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 1 {
+		return nil, fmt.Errorf("NS record requires a nameserver domain")
+	}
+	return &DNSResourceRecordNS{
+		DNSResourceRecord: h,
+		NameServer:        rdata[0],
+	}, nil
+}
+
+func parseMX(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 2 {
+		return nil, fmt.Errorf("MX record requires preference and exchange")
+	}
+	pref, err := strconv.ParseUint(rdata[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MX preference %q: %v", rdata[0], err)
+	}
+	return &DNSResourceRecordMX{
+		DNSResourceRecord: h,
+		Preference:        uint16(pref),
+		Exchange:          rdata[1],
+	}, nil
+}
+
+// parseTXT turns each field into one character-string: quoted fields may
+// contain spaces, and both forms understand the \X and \DDD escapes of
+// RFC 1035 §5.1. Values longer than 255 bytes are split on encode.
+func parseTXT(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 1 {
+		return nil, fmt.Errorf("TXT record requires text content")
+	}
+	texts := make([]string, 0, len(rdata))
+	for _, field := range rdata {
+		text, err := characterString(field)
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, text)
+	}
+	return &DNSResourceRecordTXT{
+		DNSResourceRecord: h,
+		Text:              texts,
+	}, nil
+}
+
+// characterString decodes one <character-string> field, quoted or not.
+func characterString(field string) (string, error) {
+	if strings.HasPrefix(field, "\"") {
+		inner, ok := unquote(field)
+		if !ok {
+			return "", fmt.Errorf("malformed quoted string %s", field)
+		}
+		field = inner
+	}
+	return unescapeString(field)
+}
+
+// unquote strips the surrounding quotes from field, which must close
+// exactly at its last byte. Escapes are left for unescapeString.
+func unquote(field string) (string, bool) {
+	for i := 1; i < len(field); i++ {
+		switch field[i] {
+		case '\\':
+			i++
+		case '"':
+			return field[1:i], i == len(field)-1
+		}
+	}
+	return "", false
+}
+
+// unescapeString resolves the \X (literal X) and \DDD (decimal byte)
+// escapes allowed in zone file character-strings.
+func unescapeString(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("dangling escape in %q", s)
+		}
+		if isDigit(s[i]) {
+			if i+2 >= len(s) || !isDigit(s[i+1]) || !isDigit(s[i+2]) {
+				return "", fmt.Errorf("bad \\DDD escape in %q", s)
+			}
+			v := int(s[i]-'0')*100 + int(s[i+1]-'0')*10 + int(s[i+2]-'0')
+			if v > 255 {
+				return "", fmt.Errorf("\\DDD escape out of range in %q", s)
+			}
+			b.WriteByte(byte(v))
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), nil
+}
+
+func parsePTR(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 1 {
+		return nil, fmt.Errorf("PTR record requires a target domain")
+	}
+	return &DNSResourceRecordPTR{
+		DNSResourceRecord: h,
+		PtrDomainName:     rdata[0],
+	}, nil
+}
+
+func parseSOA(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 7 {
+		return nil, fmt.Errorf("SOA requires MNAME RNAME SERIAL REFRESH RETRY EXPIRE MINIMUM")
+	}
+	serial, err := strconv.ParseUint(rdata[2], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOA serial: %v", err)
+	}
+	refresh, err := strconv.ParseUint(rdata[3], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOA refresh: %v", err)
+	}
+	retry, err := strconv.ParseUint(rdata[4], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOA retry: %v", err)
+	}
+	expire, err := strconv.ParseUint(rdata[5], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOA expire: %v", err)
+	}
+	minimum, err := strconv.ParseUint(rdata[6], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOA minimum: %v", err)
+	}
+	return &DNSResourceRecordSOA{
+		DNSResourceRecord: h,
+		MName:             rdata[0],
+		RName:             rdata[1],
+		Serial:            uint32(serial),
+		Refresh:           uint32(refresh),
+		Retry:             uint32(retry),
+		Expire:            uint32(expire),
+		Minimum:           uint32(minimum),
+	}, nil
+}
+
+func parseSRV(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 4 {
+		return nil, fmt.Errorf("SRV requires priority weight port target")
+	}
+	priority, err := strconv.ParseUint(rdata[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRV priority: %v", err)
+	}
+	weight, err := strconv.ParseUint(rdata[1], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRV weight: %v", err)
+	}
+	port, err := strconv.ParseUint(rdata[2], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRV port: %v", err)
+	}
+	return &DNSResourceRecordSRV{
+		DNSResourceRecord: h,
+		Priority:          uint16(priority),
+		Weight:            uint16(weight),
+		Port:              uint16(port),
+		Target:            rdata[3],
+	}, nil
+}
+
+// parseSVCB parses the SVCB/HTTPS presentation format (RFC 9460 §2.1):
+// SvcPriority TargetName followed by key=value SvcParams.
+func parseSVCB(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 2 {
+		return nil, fmt.Errorf("SVCB requires priority and target")
+	}
+	priority, err := strconv.ParseUint(rdata[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SVCB priority: %v", err)
+	}
+	svcb := DNSResourceRecordSVCB{
+		DNSResourceRecord: h,
+		Priority:          uint16(priority),
+		Target:            rdata[1],
+	}
+	seen := make(map[SvcParamKey]bool)
+	for _, field := range rdata[2:] {
+		key, err := parseSvcParam(&svcb.Params, field)
+		if err != nil {
+			return nil, err
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate SvcParam %s", key)
+		}
+		seen[key] = true
+	}
+	for _, key := range svcb.Params.Mandatory {
+		if key == SvcParamMandatory || !seen[key] {
+			return nil, fmt.Errorf("mandatory key %s is not present", key)
+		}
+	}
+	if h.Type == DNSTypeHTTPS {
+		return &DNSResourceRecordHTTPS{DNSResourceRecordSVCB: svcb}, nil
+	}
+	return &svcb, nil
+}
+
+// parseSvcParam decodes one key[=value] field into params.
+func parseSvcParam(params *SvcParams, field string) (SvcParamKey, error) {
+	keyName, value, hasValue := strings.Cut(field, "=")
+	key, err := ParseSvcParamKey(keyName)
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasPrefix(value, "\"") {
+		inner, ok := unquote(value)
+		if !ok {
+			return 0, fmt.Errorf("malformed quoted value in %s", field)
+		}
+		value = inner
+	}
+	// every registered key except no-default-alpn carries a value;
+	// keyNNNNN may be given bare to mean an empty value
+	if !hasValue && key <= SvcParamIPv6Hint && key != SvcParamNoDefaultALPN {
+		return 0, fmt.Errorf("SvcParam %s requires a value", key)
+	}
+	switch key {
+	case SvcParamMandatory:
+		for _, item := range splitValueList(value) {
+			k, err := ParseSvcParamKey(item)
+			if err != nil {
+				return 0, err
+			}
+			params.Mandatory = append(params.Mandatory, k)
+		}
+	case SvcParamALPN:
+		for _, item := range splitValueList(value) {
+			id, err := unescapeString(item)
+			if err != nil {
+				return 0, err
+			}
+			if id == "" || len(id) > 255 {
+				return 0, fmt.Errorf("invalid alpn-id %q", id)
+			}
+			params.ALPN = append(params.ALPN, id)
+		}
+	case SvcParamNoDefaultALPN:
+		if value != "" {
+			return 0, fmt.Errorf("no-default-alpn takes no value")
+		}
+		params.NoDefaultALPN = true
+	case SvcParamPort:
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid port %q: %v", value, err)
+		}
+		params.Port = uint16(port)
+	case SvcParamIPv4Hint:
+		for _, item := range splitValueList(value) {
+			ip := net.ParseIP(item)
+			if ip == nil || ip.To4() == nil {
+				return 0, fmt.Errorf("invalid ipv4hint %q", item)
+			}
+			params.IPv4Hint = append(params.IPv4Hint, ip.To4())
+		}
+	case SvcParamIPv6Hint:
+		for _, item := range splitValueList(value) {
+			ip := net.ParseIP(item)
+			if ip == nil || ip.To4() != nil {
+				return 0, fmt.Errorf("invalid ipv6hint %q", item)
+			}
+			params.IPv6Hint = append(params.IPv6Hint, ip)
+		}
+	case SvcParamECH:
+		ech, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ech: %v", err)
+		}
+		params.ECH = ech
+	default:
+		raw, err := unescapeString(value)
+		if err != nil {
+			return 0, err
+		}
+		params.Other = append(params.Other, SvcParam{Key: key, Value: []byte(raw)})
+	}
+	return key, nil
+}
+
+// splitValueList splits a comma-separated value list (RFC 9460 Appendix
+// A.1), leaving escaped commas and other escapes in place.
+func splitValueList(s string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// parseCAA parses "flags tag value" (RFC 8659 §4.1.1). The value is a
+// character-string but may exceed 255 bytes, as it is not length-prefixed
+// on the wire.
+func parseCAA(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) != 3 {
+		return nil, fmt.Errorf("CAA requires flags tag value")
+	}
+	flag, err := strconv.ParseUint(rdata[0], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CAA flags: %v", err)
+	}
+	tag := rdata[1]
+	if tag == "" || len(tag) > 255 {
+		return nil, fmt.Errorf("invalid CAA tag %q", tag)
+	}
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		if !isDigit(c) && (c|0x20 < 'a' || c|0x20 > 'z') {
+			return nil, fmt.Errorf("invalid CAA tag %q", tag)
+		}
+	}
+	value, err := characterString(rdata[2])
+	if err != nil {
+		return nil, err
+	}
+	return &DNSResourceRecordCAA{
+		DNSResourceRecord: h,
+		Flag:              uint8(flag),
+		Tag:               tag,
+		Value:             value,
+	}, nil
+}
+
+// parseTLSA parses "usage selector matching-type data" (RFC 6698 §2.2);
+// the hex data may be split into several fields.
+func parseTLSA(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 4 {
+		return nil, fmt.Errorf("TLSA requires usage selector matching-type data")
+	}
+	var fields [3]uint8
+	for i, label := range []string{"usage", "selector", "matching type"} {
+		v, err := strconv.ParseUint(rdata[i], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLSA %s: %v", label, err)
+		}
+		fields[i] = uint8(v)
+	}
+	data, err := hex.DecodeString(strings.Join(rdata[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLSA data: %v", err)
+	}
+	return &DNSResourceRecordTLSA{
+		DNSResourceRecord: h,
+		Usage:             fields[0],
+		Selector:          fields[1],
+		MatchingType:      fields[2],
+		Certificate:       data,
+	}, nil
+}
+
+// parseSSHFP parses "algorithm fp-type fingerprint" (RFC 4255 §3.2).
+func parseSSHFP(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 3 {
+		return nil, fmt.Errorf("SSHFP requires algorithm fp-type fingerprint")
+	}
+	algorithm, err := strconv.ParseUint(rdata[0], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSHFP algorithm: %v", err)
+	}
+	fpType, err := strconv.ParseUint(rdata[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSHFP fingerprint type: %v", err)
+	}
+	fingerprint, err := hex.DecodeString(strings.Join(rdata[2:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSHFP fingerprint: %v", err)
+	}
+	return &DNSResourceRecordSSHFP{
+		DNSResourceRecord: h,
+		Algorithm:         uint8(algorithm),
+		FPType:            uint8(fpType),
+		Fingerprint:       fingerprint,
+	}, nil
+}
+
+// parseNAPTR parses "order preference flags services regexp replacement"
+// (RFC 3403 §4.1), where the middle three are character-strings.
+func parseNAPTR(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) != 6 {
+		return nil, fmt.Errorf("NAPTR requires order preference flags services regexp replacement")
+	}
+	order, err := strconv.ParseUint(rdata[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NAPTR order: %v", err)
+	}
+	pref, err := strconv.ParseUint(rdata[1], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NAPTR preference: %v", err)
+	}
+	var texts [3]string
+	for i, field := range rdata[2:5] {
+		if texts[i], err = characterString(field); err != nil {
+			return nil, err
+		}
+		if len(texts[i]) > 255 {
+			return nil, fmt.Errorf("NAPTR field exceeds 255 bytes")
+		}
+	}
+	return &DNSResourceRecordNAPTR{
+		DNSResourceRecord: h,
+		Order:             uint16(order),
+		Preference:        uint16(pref),
+		Flags:             texts[0],
+		Services:          texts[1],
+		Regexp:            texts[2],
+		Replacement:       rdata[5],
+	}, nil
+}
+
+// parseDNSKEY parses "flags protocol algorithm public-key" (RFC 4034
+// §2.2); the base64 key may be split into several fields.
+func parseDNSKEY(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 4 {
+		return nil, fmt.Errorf("%s requires flags protocol algorithm key", h.Type)
+	}
+	flags, err := strconv.ParseUint(rdata[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s flags: %v", h.Type, err)
+	}
+	protocol, err := strconv.ParseUint(rdata[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s protocol: %v", h.Type, err)
+	}
+	algorithm, err := strconv.ParseUint(rdata[2], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s algorithm: %v", h.Type, err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.Join(rdata[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s public key: %v", h.Type, err)
+	}
+	return &DNSResourceRecordDNSKEY{
+		DNSResourceRecord: h,
+		Flags:             uint16(flags),
+		Protocol:          uint8(protocol),
+		Algorithm:         uint8(algorithm),
+		PublicKey:         key,
+	}, nil
+}
+
+// parseDS parses "key-tag algorithm digest-type digest" (RFC 4034 §5.3);
+// the hex digest may be split into several fields.
+func parseDS(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 4 {
+		return nil, fmt.Errorf("%s requires key-tag algorithm digest-type digest", h.Type)
+	}
+	keyTag, err := strconv.ParseUint(rdata[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s key tag: %v", h.Type, err)
+	}
+	algorithm, err := strconv.ParseUint(rdata[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s algorithm: %v", h.Type, err)
+	}
+	digestType, err := strconv.ParseUint(rdata[2], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s digest type: %v", h.Type, err)
+	}
+	digest, err := hex.DecodeString(strings.Join(rdata[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s digest: %v", h.Type, err)
+	}
+	return &DNSResourceRecordDS{
+		DNSResourceRecord: h,
+		KeyTag:            uint16(keyTag),
+		Algorithm:         uint8(algorithm),
+		DigestType:        uint8(digestType),
+		Digest:            digest,
+	}, nil
+}
+
+// parseRRSIG parses "type-covered algorithm labels original-ttl expiration
+// inception key-tag signer signature" (RFC 4034 §3.2).
+func parseRRSIG(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 9 {
+		return nil, fmt.Errorf("RRSIG requires type algorithm labels ttl expiration inception key-tag signer signature")
+	}
+	covered, err := ParseDNSType(rdata[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid RRSIG type covered: %v", err)
+	}
+	algorithm, err := strconv.ParseUint(rdata[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RRSIG algorithm: %v", err)
+	}
+	labels, err := strconv.ParseUint(rdata[2], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RRSIG labels: %v", err)
+	}
+	originalTTL, err := ParseTTL(rdata[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid RRSIG original TTL: %v", err)
+	}
+	expiration, err := parseSigTime(rdata[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid RRSIG expiration: %v", err)
+	}
+	inception, err := parseSigTime(rdata[5])
+	if err != nil {
+		return nil, fmt.Errorf("invalid RRSIG inception: %v", err)
+	}
+	keyTag, err := strconv.ParseUint(rdata[6], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RRSIG key tag: %v", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.Join(rdata[8:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RRSIG signature: %v", err)
+	}
+	return &DNSResourceRecordRRSIG{
+		DNSResourceRecord: h,
+		TypeCovered:       covered,
+		Algorithm:         uint8(algorithm),
+		Labels:            uint8(labels),
+		OriginalTTL:       originalTTL,
+		Expiration:        expiration,
+		Inception:         inception,
+		KeyTag:            uint16(keyTag),
+		SignerName:        rdata[7],
+		Signature:         signature,
+	}, nil
+}
+
+// parseSigTime accepts an RRSIG timestamp either as YYYYMMDDHHmmSS in UTC
+// or as seconds since the epoch (RFC 4034 §3.2).
+func parseSigTime(s string) (uint32, error) {
+	if len(s) == 14 {
+		t, err := time.Parse("20060102150405", s)
+		if err != nil {
+			return 0, err
+		}
+		// serial number arithmetic: the value wraps modulo 2^32
+		return uint32(t.Unix()), nil
+	}
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(v), nil
+}
+
+// parseNSEC parses "next-domain type..." (RFC 4034 §4.2).
+func parseNSEC(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 1 {
+		return nil, fmt.Errorf("NSEC requires a next domain name")
+	}
+	types, err := parseTypeList(rdata[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid NSEC type list: %v", err)
+	}
+	return &DNSResourceRecordNSEC{
+		DNSResourceRecord: h,
+		NextDomain:        rdata[0],
+		Types:             types,
+	}, nil
+}
+
+// parseNSEC3 parses "algorithm flags iterations salt next-hashed type..."
+// (RFC 5155 §3.3).
+func parseNSEC3(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) < 5 {
+		return nil, fmt.Errorf("NSEC3 requires algorithm flags iterations salt next-hashed")
+	}
+	params, err := parseNSEC3Params(rdata[:4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid NSEC3: %v", err)
+	}
+	next, err := base32Hex.DecodeString(strings.ToUpper(rdata[4]))
+	if err != nil || len(next) == 0 {
+		return nil, fmt.Errorf("invalid NSEC3 next hashed owner %q", rdata[4])
+	}
+	types, err := parseTypeList(rdata[5:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid NSEC3 type list: %v", err)
+	}
+	return &DNSResourceRecordNSEC3{
+		DNSResourceRecord: h,
+		HashAlgorithm:     params.HashAlgorithm,
+		Flags:             params.Flags,
+		Iterations:        params.Iterations,
+		Salt:              params.Salt,
+		NextHashed:        next,
+		Types:             types,
+	}, nil
+}
+
+// parseNSEC3PARAM parses "algorithm flags iterations salt" (RFC 5155 §4.3).
+func parseNSEC3PARAM(h DNSResourceRecord, rdata []string) (DNSResource, error) {
+	if len(rdata) != 4 {
+		return nil, fmt.Errorf("NSEC3PARAM requires algorithm flags iterations salt")
+	}
+	params, err := parseNSEC3Params(rdata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NSEC3PARAM: %v", err)
+	}
+	params.DNSResourceRecord = h
+	return params, nil
+}
+
+// parseNSEC3Params parses the four leading fields shared by NSEC3 and
+// NSEC3PARAM. A salt of "-" means no salt.
+func parseNSEC3Params(fields []string) (*DNSResourceRecordNSEC3PARAM, error) {
+	algorithm, err := strconv.ParseUint(fields[0], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("hash algorithm: %v", err)
+	}
+	flags, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("flags: %v", err)
+	}
+	iterations, err := strconv.ParseUint(fields[2], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("iterations: %v", err)
+	}
+	var salt []byte
+	if fields[3] != "-" {
+		if salt, err = hex.DecodeString(fields[3]); err != nil || len(salt) > 255 {
+			return nil, fmt.Errorf("bad salt %q", fields[3])
+		}
+	}
+	return &DNSResourceRecordNSEC3PARAM{
+		HashAlgorithm: uint8(algorithm),
+		Flags:         uint8(flags),
+		Iterations:    uint16(iterations),
+		Salt:          salt,
+	}, nil
+}
+
+// parseTypeList parses the type mnemonics of an NSEC or NSEC3 bitmap.
+func parseTypeList(fields []string) ([]DNSType, error) {
+	var types []DNSType
+	for _, field := range fields {
+		t, err := ParseDNSType(field)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
@@ -3,9 +3,9 @@ package packet
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"strings"
 )
 
 // Assuming DNSType and DNSClass are defined elsewhere.
@@ -24,13 +24,13 @@ func (q *DNSQuestion) Parse(reader *bytes.Reader) error {
 	q.Name = name
 	// Decode type
 	typeBytes := make([]byte, 2)
-	if _, err := reader.Read(typeBytes); err != nil {
+	if err := readFull(reader, typeBytes); err != nil {
 		return err
 	}
 	q.Type = DNSType(binary.BigEndian.Uint16(typeBytes))
 	// Decode class
 	classBytes := make([]byte, 2)
-	if _, err := reader.Read(classBytes); err != nil {
+	if err := readFull(reader, classBytes); err != nil {
 		return err
 	}
 	q.Class = DNSClass(binary.BigEndian.Uint16(classBytes))
@@ -39,43 +39,61 @@ func (q *DNSQuestion) Parse(reader *bytes.Reader) error {
 
 func (q *DNSQuestion) Bytes() []byte {
 	var buf bytes.Buffer
-	// Encode domain name
-	encodeDomainName(&buf, q.Name, true)
-	// Encode type
-	typeBytes := make([]byte, 2)
-	binary.BigEndian.PutUint16(typeBytes, uint16(q.Type))
-	buf.Write(typeBytes)
-	// Encode class
-	classBytes := make([]byte, 2)
-	binary.BigEndian.PutUint16(classBytes, uint16(q.Class))
-	buf.Write(classBytes)
+	q.encode(&buf, nil)
 	return buf.Bytes()
 }
 
-func encodeDomainName(buf *bytes.Buffer, domain string, addNullTerminator bool) {
-	// Handle root domain specially
-	if domain == "." || domain == "" {
-		if addNullTerminator {
-			buf.WriteByte(0x00)
-		}
-		return
-	}
-	
-	labels := strings.Split(domain, ".")
-	for _, label := range labels {
-		// Write label length
-		buf.WriteByte(byte(len(label)))
-		// Write label content
-		buf.WriteString(label)
-	}
-	if addNullTerminator {
-		buf.WriteByte(0x00)
+// String returns the question in presentation format, as in dig's
+// QUESTION SECTION without the leading ';'.
+func (q *DNSQuestion) String() string {
+	return fmt.Sprintf("%s\t%s\t%s", fqdn(q.Name), q.Class, q.Type)
+}
+
+// encode appends the question to msg, compressing the name through c. It
+// fails if the name can't be encoded.
+func (q *DNSQuestion) encode(msg *bytes.Buffer, c *Compressor) error {
+	// Encode domain name
+	if err := encodeDomainName(msg, q.Name, c); err != nil {
+		return err
 	}
+	// Encode type and class
+	var fixed [4]byte
+	binary.BigEndian.PutUint16(fixed[0:], uint16(q.Type))
+	binary.BigEndian.PutUint16(fixed[2:], uint16(q.Class))
+	msg.Write(fixed[:])
+	return nil
 }
 
+// Limits enforced while decoding names (RFC 1035 §2.3.4, §4.1.4).
+const (
+	maxLabelLength  = 63  // octets in a single label
+	maxNameLength   = 255 // wire length, including length octets and the root label
+	maxPointerJumps = 126 // a 255-byte name has at most 127 labels
+)
+
+// Errors returned by the name decoder for hostile or corrupt input.
+var (
+	ErrNameTooLong      = errors.New("domain name exceeds 255 bytes")
+	ErrPointerLoop      = errors.New("too many compression pointers")
+	ErrPointerForward   = errors.New("compression pointer does not point to an earlier name")
+	ErrLabelTypeUnknown = errors.New("unsupported label type")
+)
+
+// decodeDomainName reads a possibly compressed name at the reader's current
+// position. Pointers are followed iteratively and must point strictly before
+// the start of the labels being read, so every jump moves backwards and
+// loops are impossible; the number of jumps and the total uncompressed
+// length are capped as well. On return the reader sits
+// just past the name as it appeared at the starting position. The name is
+// returned in escaped presentation form (see ValidateName).
 func decodeDomainName(reader *bytes.Reader) (name string, err error) {
-	var parts []string
+	var labels []byte
+	wireLen := 1 // root label
+	jumps := 0
+	resume := int64(-1) // where to continue once the first pointer is taken
+	start := reader.Size() - int64(reader.Len())
 	for {
+		pos := reader.Size() - int64(reader.Len())
 		labelLen, err := reader.ReadByte()
 		if err != nil {
 			return "", fmt.Errorf("error reading label length: %v", err)
@@ -83,47 +101,53 @@ func decodeDomainName(reader *bytes.Reader) (name string, err error) {
 		if labelLen == 0 {
 			break
 		}
-		var part string
-		if labelLen&0xc0 == 0xc0 {
-			part, err = readPointer(reader, labelLen)
+		switch labelLen & 0xc0 {
+		case 0xc0:
+			pointerByte, err := reader.ReadByte()
 			if err != nil {
-				return "", err
+				return "", fmt.Errorf("error reading pointer byte: %v", err)
 			}
-			parts = append(parts, part)
-			break
+			pointer := int64(labelLen&0x3f)<<8 | int64(pointerByte) // 14 bits
+			if pointer >= start {
+				return "", fmt.Errorf("%w: %d at offset %d", ErrPointerForward, pointer, pos)
+			}
+			start = pointer
+			if jumps++; jumps > maxPointerJumps {
+				return "", ErrPointerLoop
+			}
+			if resume < 0 {
+				resume = pos + 2
+			}
+			if _, err := reader.Seek(pointer, io.SeekStart); err != nil {
+				return "", fmt.Errorf("error seeking to pointer position: %v", err)
+			}
+			continue
+		case 0x00:
+		default:
+			return "", fmt.Errorf("%w: %#x", ErrLabelTypeUnknown, labelLen&0xc0)
 		}
 
+		wireLen += 1 + int(labelLen)
+		if wireLen > maxNameLength {
+			return "", ErrNameTooLong
+		}
 		labelBytes := make([]byte, labelLen)
 		if _, err := io.ReadFull(reader, labelBytes); err != nil {
 			return "", fmt.Errorf("error reading label: %v", err)
 		}
-		parts = append(parts, string(labelBytes))
+		if len(labels) > 0 {
+			labels = append(labels, '.')
+		}
+		labels = appendEscapedLabel(labels, labelBytes)
 	}
-	
-	if len(parts) == 0 {
-		return ".", nil // Root domain (used in EDNS OPT records)
+	if resume >= 0 {
+		if _, err := reader.Seek(resume, io.SeekStart); err != nil {
+			return "", fmt.Errorf("error restoring position: %v", err)
+		}
 	}
-	
-	name = strings.Join(parts, ".")
-	return
-}
 
-func readPointer(reader *bytes.Reader, labelLen byte) (name string, err error) {
-	pointerByte, err := reader.ReadByte()
-	if err != nil {
-		return "", fmt.Errorf("error reading pointer byte: %v", err)
-	}
-	pointer := (uint16(labelLen&0x3f) << 8) | uint16(pointerByte) // 14 bits
-	offset, err := reader.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return "", fmt.Errorf("error saving current position: %v", err)
-	}
-	_, err = reader.Seek(int64(pointer), io.SeekStart)
-	if err != nil {
-		return "", fmt.Errorf("error seeking to pointer position: %v", err)
+	if len(labels) == 0 {
+		return ".", nil // Root domain (used in EDNS OPT records)
 	}
-	defer func() {
-		_, _ = reader.Seek(offset, io.SeekStart)
-	}()
-	return decodeDomainName(reader)
+	return string(labels), nil
 }
@@ -3,7 +3,11 @@ package packet
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"strconv"
+	"strings"
 )
 
 // DNSType defines the type of data being requested/returned in a
@@ -12,49 +16,132 @@ type DNSType uint16
 
 // https://datatracker.ietf.org/doc/html/rfc1035#section-3.2.2
 const (
-	DNSTypeA     DNSType = 0x0001 // a host address
-	DNSTypeNS    DNSType = 0x0002 // an authoritative name server
-	DNSTypeMD    DNSType = 0x0003 // a mail destination (Obsolete - use MX)
-	DNSTypeMF    DNSType = 0x04   // a mail forwarder (Obsolete - use MX)
-	DNSTypeCNAME DNSType = 0x05   // the canonical name for an alias
-	DNSTypeSOA   DNSType = 0x06   // marks the start of a zone of authority
-	DNSTypeMB    DNSType = 0x07   // a mailbox domain name (EXPERIMENTAL)
-	DNSTypeMG    DNSType = 0x08   // a mail group member (EXPERIMENTAL)
-	DNSTypeMR    DNSType = 0x09   // a mail rename domain name (EXPERIMENTAL)
-	DNSTypeNULL  DNSType = 0x0A   // a null RR (EXPERIMENTAL)
-	DNSTypeWKS   DNSType = 0x0B   // a well known service description
-	DNSTypePTR   DNSType = 0x0C   // a domain name pointer
-	DNSTypeHINFO DNSType = 0x0D   // host information
-	DNSTypeMINFO DNSType = 0x0E   // mailbox or mail list information
-	DNSTypeMX    DNSType = 0x0F   // mail exchange
-	DNSTypeTXT   DNSType = 0x10   // text strings
-	DNSTypeAAAA  DNSType = 0x1C   // a ipv6 host address
-	DNSTypeSRV   DNSType = 0x21   // a service location
-	DNSTypeEDNS  DNSType = 0x29   // extensible dns
-	DNSTypeSPF   DNSType = 0x63   // a Sender Policy Framework record
-	DNSTypeAXFR  DNSType = 0xFC   // A request for a transfer of an entire zone
-	DNSTypeMAILB DNSType = 0xFD   // A request for mailbox-related records (MB, MG or MR)
-	DNSTypeMAILA DNSType = 0xFE   // A request for mail agent RRs (Obsolete - see MX)
-	DNSTypeAny   DNSType = 0xFF   // A request for all records
+	DNSTypeA          DNSType = 0x0001 // a host address
+	DNSTypeNS         DNSType = 0x0002 // an authoritative name server
+	DNSTypeMD         DNSType = 0x0003 // a mail destination (Obsolete - use MX)
+	DNSTypeMF         DNSType = 0x04   // a mail forwarder (Obsolete - use MX)
+	DNSTypeCNAME      DNSType = 0x05   // the canonical name for an alias
+	DNSTypeSOA        DNSType = 0x06   // marks the start of a zone of authority
+	DNSTypeMB         DNSType = 0x07   // a mailbox domain name (EXPERIMENTAL)
+	DNSTypeMG         DNSType = 0x08   // a mail group member (EXPERIMENTAL)
+	DNSTypeMR         DNSType = 0x09   // a mail rename domain name (EXPERIMENTAL)
+	DNSTypeNULL       DNSType = 0x0A   // a null RR (EXPERIMENTAL)
+	DNSTypeWKS        DNSType = 0x0B   // a well known service description
+	DNSTypePTR        DNSType = 0x0C   // a domain name pointer
+	DNSTypeHINFO      DNSType = 0x0D   // host information
+	DNSTypeMINFO      DNSType = 0x0E   // mailbox or mail list information
+	DNSTypeMX         DNSType = 0x0F   // mail exchange
+	DNSTypeTXT        DNSType = 0x10   // text strings
+	DNSTypeAAAA       DNSType = 0x1C   // a ipv6 host address
+	DNSTypeSRV        DNSType = 0x21   // a service location
+	DNSTypeNAPTR      DNSType = 0x23   // naming authority pointer
+	DNSTypeEDNS       DNSType = 0x29   // extensible dns
+	DNSTypeDS         DNSType = 0x2B   // delegation signer
+	DNSTypeSSHFP      DNSType = 0x2C   // SSH key fingerprint
+	DNSTypeRRSIG      DNSType = 0x2E   // DNSSEC signature
+	DNSTypeNSEC       DNSType = 0x2F   // next secure
+	DNSTypeDNSKEY     DNSType = 0x30   // DNSSEC public key
+	DNSTypeNSEC3      DNSType = 0x32   // hashed next secure
+	DNSTypeNSEC3PARAM DNSType = 0x33   // NSEC3 parameters
+	DNSTypeTLSA       DNSType = 0x34   // TLSA certificate association (DANE)
+	DNSTypeCDS        DNSType = 0x3B   // child copy of DS
+	DNSTypeCDNSKEY    DNSType = 0x3C   // child copy of DNSKEY
+	DNSTypeSVCB       DNSType = 0x40   // general-purpose service binding
+	DNSTypeHTTPS      DNSType = 0x41   // service binding for HTTPS
+	DNSTypeSPF        DNSType = 0x63   // a Sender Policy Framework record
+	DNSTypeTSIG       DNSType = 0xFA   // transaction signature
+	DNSTypeAXFR       DNSType = 0xFC   // A request for a transfer of an entire zone
+	DNSTypeMAILB      DNSType = 0xFD   // A request for mailbox-related records (MB, MG or MR)
+	DNSTypeMAILA      DNSType = 0xFE   // A request for mail agent RRs (Obsolete - see MX)
+	DNSTypeAny        DNSType = 0xFF   // A request for all records
+	DNSTypeCAA        DNSType = 0x101  // certification authority authorization
 )
 
+var dnsTypeNames = map[DNSType]string{
+	DNSTypeA:          "A",
+	DNSTypeNS:         "NS",
+	DNSTypeMD:         "MD",
+	DNSTypeMF:         "MF",
+	DNSTypeCNAME:      "CNAME",
+	DNSTypeSOA:        "SOA",
+	DNSTypeMB:         "MB",
+	DNSTypeMG:         "MG",
+	DNSTypeMR:         "MR",
+	DNSTypeNULL:       "NULL",
+	DNSTypeWKS:        "WKS",
+	DNSTypePTR:        "PTR",
+	DNSTypeHINFO:      "HINFO",
+	DNSTypeMINFO:      "MINFO",
+	DNSTypeMX:         "MX",
+	DNSTypeTXT:        "TXT",
+	DNSTypeAAAA:       "AAAA",
+	DNSTypeSRV:        "SRV",
+	DNSTypeNAPTR:      "NAPTR",
+	DNSTypeEDNS:       "OPT",
+	DNSTypeDS:         "DS",
+	DNSTypeSSHFP:      "SSHFP",
+	DNSTypeRRSIG:      "RRSIG",
+	DNSTypeNSEC:       "NSEC",
+	DNSTypeDNSKEY:     "DNSKEY",
+	DNSTypeNSEC3:      "NSEC3",
+	DNSTypeNSEC3PARAM: "NSEC3PARAM",
+	DNSTypeTLSA:       "TLSA",
+	DNSTypeCDS:        "CDS",
+	DNSTypeCDNSKEY:    "CDNSKEY",
+	DNSTypeSVCB:       "SVCB",
+	DNSTypeHTTPS:      "HTTPS",
+	DNSTypeSPF:        "SPF",
+	DNSTypeTSIG:       "TSIG",
+	DNSTypeAXFR:       "AXFR",
+	DNSTypeMAILB:      "MAILB",
+	DNSTypeMAILA:      "MAILA",
+	DNSTypeAny:        "ANY",
+	DNSTypeCAA:        "CAA",
+}
+
+// String returns the type mnemonic, or the TYPEnnn form of RFC 3597 §5
+// for types without one.
+func (t DNSType) String() string {
+	if name, ok := dnsTypeNames[t]; ok {
+		return name
+	}
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// ParseDNSType is the inverse of DNSType.String; it is case-insensitive.
+func ParseDNSType(s string) (DNSType, error) {
+	s = strings.ToUpper(s)
+	for t, name := range dnsTypeNames {
+		if name == s {
+			return t, nil
+		}
+	}
+	if strings.HasPrefix(s, "TYPE") {
+		if v, err := strconv.ParseUint(s[4:], 10, 16); err == nil {
+			return DNSType(v), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown DNS type %q", s)
+}
+
 // DNSClass defines the class associated with a request/response.  Different DNS
 // classes can be thought of as an array of parallel namespace trees.
 type DNSClass uint16
 
 // DNSClass known values.
 const (
-	DNSClassIN  DNSClass = 0x01 // Internet
-	DNSClassCS  DNSClass = 0x02 // the CSNET class (Obsolete)
-	DNSClassCH  DNSClass = 0x03 // the CHAOS class
-	DNSClassHS  DNSClass = 0x04 // Hesiod [Dyer 87]
-	DNSClassAny DNSClass = 0xFF // AnyClass
+	DNSClassIN   DNSClass = 0x01 // Internet
+	DNSClassCS   DNSClass = 0x02 // the CSNET class (Obsolete)
+	DNSClassCH   DNSClass = 0x03 // the CHAOS class
+	DNSClassHS   DNSClass = 0x04 // Hesiod [Dyer 87]
+	DNSClassNone DNSClass = 0xFE // QCLASS NONE [RFC2136]
+	DNSClassAny  DNSClass = 0xFF // AnyClass
 )
 
 func (dc DNSClass) String() string {
 	switch dc {
 	default:
-		return "Unknown"
+		return "CLASS" + strconv.Itoa(int(dc))
 	case DNSClassIN:
 		return "IN"
 	case DNSClassCS:
@@ -63,9 +150,28 @@ func (dc DNSClass) String() string {
 		return "CH"
 	case DNSClassHS:
 		return "HS"
+	case DNSClassNone:
+		return "NONE"
 	case DNSClassAny:
-		return "Any"
+		return "ANY"
+	}
+}
+
+// ParseDNSClass is the inverse of DNSClass.String. It is case-insensitive
+// and accepts the CLASSnnn form of RFC 3597 §5.
+func ParseDNSClass(s string) (DNSClass, error) {
+	s = strings.ToUpper(s)
+	for _, c := range []DNSClass{DNSClassIN, DNSClassCS, DNSClassCH, DNSClassHS, DNSClassNone, DNSClassAny} {
+		if strings.ToUpper(c.String()) == s {
+			return c, nil
+		}
+	}
+	if strings.HasPrefix(s, "CLASS") {
+		if v, err := strconv.ParseUint(s[5:], 10, 16); err == nil {
+			return DNSClass(v), nil
+		}
 	}
+	return 0, fmt.Errorf("unknown DNS class %q", s)
 }
 
 // DNSOpCode defines a set of different operation types.
@@ -83,27 +189,122 @@ const (
 func (code DNSOpCode) String() string {
 	switch code {
 	case DNSOpCodeQuery:
-		return "Query"
+		return "QUERY"
 	case DNSOpCodeIQuery:
-		return "Inverse Query"
+		return "IQUERY"
 	case DNSOpCodeStatus:
-		return "Status"
+		return "STATUS"
 	case DNSOpCodeNotify:
-		return "Notify"
+		return "NOTIFY"
 	case DNSOpCodeUpdate:
-		return "Update"
+		return "UPDATE"
 	default:
-		return "Unknown"
+		return "OPCODE" + strconv.Itoa(int(code))
 	}
 }
 
+// ParseDNSOpCode is the inverse of DNSOpCode.String; it is case-insensitive.
+func ParseDNSOpCode(s string) (DNSOpCode, error) {
+	s = strings.ToUpper(s)
+	for code := DNSOpCode(0); code < 16; code++ {
+		if code.String() == s {
+			return code, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown DNS opcode %q", s)
+}
+
+// DNSRCode is a response code. The header carries the low 4 bits; codes
+// from 16 up need the extended RCODE of an OPT record (RFC 6891 §6.1.3).
+type DNSRCode uint16
+
+// DNSRCode known values (IANA "DNS RCODEs").
+const (
+	DNSRCodeNoError   DNSRCode = 0  // No Error                       [RFC1035]
+	DNSRCodeFormErr   DNSRCode = 1  // Format Error                   [RFC1035]
+	DNSRCodeServFail  DNSRCode = 2  // Server Failure                 [RFC1035]
+	DNSRCodeNXDomain  DNSRCode = 3  // Non-Existent Domain            [RFC1035]
+	DNSRCodeNotImp    DNSRCode = 4  // Not Implemented                [RFC1035]
+	DNSRCodeRefused   DNSRCode = 5  // Query Refused                  [RFC1035]
+	DNSRCodeYXDomain  DNSRCode = 6  // Name Exists when it should not [RFC2136]
+	DNSRCodeYXRRSet   DNSRCode = 7  // RR Set Exists when it should not [RFC2136]
+	DNSRCodeNXRRSet   DNSRCode = 8  // RR Set that should exist does not [RFC2136]
+	DNSRCodeNotAuth   DNSRCode = 9  // Server Not Authoritative for zone [RFC2136]
+	DNSRCodeNotZone   DNSRCode = 10 // Name not contained in zone     [RFC2136]
+	DNSRCodeBadVers   DNSRCode = 16 // Bad OPT Version                [RFC6891]
+	DNSRCodeBadSig    DNSRCode = 16 // TSIG Signature Failure (BADVERS) [RFC8945]
+	DNSRCodeBadKey    DNSRCode = 17 // Key not recognized             [RFC8945]
+	DNSRCodeBadTime   DNSRCode = 18 // Signature out of time window   [RFC8945]
+	DNSRCodeBadMode   DNSRCode = 19 // Bad TKEY Mode                  [RFC2930]
+	DNSRCodeBadName   DNSRCode = 20 // Duplicate key name             [RFC2930]
+	DNSRCodeBadAlg    DNSRCode = 21 // Algorithm not supported        [RFC2930]
+	DNSRCodeBadTrunc  DNSRCode = 22 // Bad Truncation                 [RFC8945]
+	DNSRCodeBadCookie DNSRCode = 23 // Bad/missing Server Cookie      [RFC7873]
+)
+
+var dnsRCodeNames = map[DNSRCode]string{
+	DNSRCodeNoError:   "NOERROR",
+	DNSRCodeFormErr:   "FORMERR",
+	DNSRCodeServFail:  "SERVFAIL",
+	DNSRCodeNXDomain:  "NXDOMAIN",
+	DNSRCodeNotImp:    "NOTIMP",
+	DNSRCodeRefused:   "REFUSED",
+	DNSRCodeYXDomain:  "YXDOMAIN",
+	DNSRCodeYXRRSet:   "YXRRSET",
+	DNSRCodeNXRRSet:   "NXRRSET",
+	DNSRCodeNotAuth:   "NOTAUTH",
+	DNSRCodeNotZone:   "NOTZONE",
+	DNSRCodeBadVers:   "BADVERS",
+	DNSRCodeBadKey:    "BADKEY",
+	DNSRCodeBadTime:   "BADTIME",
+	DNSRCodeBadMode:   "BADMODE",
+	DNSRCodeBadName:   "BADNAME",
+	DNSRCodeBadAlg:    "BADALG",
+	DNSRCodeBadTrunc:  "BADTRUNC",
+	DNSRCodeBadCookie: "BADCOOKIE",
+}
+
+// String returns the mnemonic dig prints for the code, or RCODEnnn.
+func (rc DNSRCode) String() string {
+	if name, ok := dnsRCodeNames[rc]; ok {
+		return name
+	}
+	return "RCODE" + strconv.Itoa(int(rc))
+}
+
+// ParseDNSRCode is the inverse of DNSRCode.String; it is case-insensitive.
+func ParseDNSRCode(s string) (DNSRCode, error) {
+	s = strings.ToUpper(s)
+	for rc, name := range dnsRCodeNames {
+		if name == s {
+			return rc, nil
+		}
+	}
+	if strings.HasPrefix(s, "RCODE") {
+		if v, err := strconv.ParseUint(s[5:], 10, 12); err == nil {
+			return DNSRCode(v), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown DNS rcode %q", s)
+}
+
+// DNSResource is a single resource record. Decode is handed the reader
+// positioned at the start of RDATA (the reader spans the whole message so
+// compression pointers can be followed) and must consume exactly length
+// bytes; ParseResource rejects records that read past RDLENGTH or leave
+// part of it unread.
 type DNSResource interface {
 	Bytes() []byte
 	GetType() DNSType
+	GetHeader() *DNSResourceRecord
 	Encode() []byte
-	Decode(reader *bytes.Reader, length uint16)
+	Decode(reader *bytes.Reader, length uint16) error
 }
 
+// ErrRDataLength is returned when a record's RDATA does not match the
+// length announced by its RDLENGTH field.
+var ErrRDataLength = errors.New("RDATA length mismatch")
+
 // DNSResourceRecord
 // 0  1  2  3  4  5  6  7  8  9  0  1  2  3  4  5
 // +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
@@ -135,6 +336,12 @@ func (r *DNSResourceRecord) GetType() DNSType {
 	return r.Type
 }
 
+// GetHeader returns the owner name, type, class and TTL shared by every
+// record. Record types get it for free by embedding DNSResourceRecord.
+func (r *DNSResourceRecord) GetHeader() *DNSResourceRecord {
+	return r
+}
+
 func ParseResource(reader *bytes.Reader) (record DNSResource, err error) {
 	r := DNSResourceRecord{}
 	if r.Name, err = decodeDomainName(reader); err != nil {
@@ -149,6 +356,38 @@ func ParseResource(reader *bytes.Reader) (record DNSResource, err error) {
 	if err = binary.Read(reader, binary.BigEndian, &r.TTL); err != nil {
 		return
 	}
+	// Read RDLENGTH
+	var rdLength uint16
+	if err = binary.Read(reader, binary.BigEndian, &rdLength); err != nil {
+		return nil, err
+	}
+	record = newRDataResource(r, int(rdLength))
+	if int(rdLength) > reader.Len() {
+		return nil, fmt.Errorf("%w: RDLENGTH %d exceeds remaining %d bytes", ErrRDataLength, rdLength, reader.Len())
+	}
+	if err = decodeRData(record, reader, rdLength); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// DecodeRData builds the typed record for header from RDATA in wire form,
+// as found in the RFC 3597 generic \# syntax. Offsets of compression
+// pointers in rdata are relative to its first byte, as there is no message
+// around it.
+func DecodeRData(header DNSResourceRecord, rdata []byte) (DNSResource, error) {
+	if len(rdata) > 0xFFFF {
+		return nil, fmt.Errorf("%w: %d bytes of RDATA", ErrRDataLength, len(rdata))
+	}
+	record := newRDataResource(header, len(rdata))
+	if err := decodeRData(record, bytes.NewReader(rdata), uint16(len(rdata))); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// newResource returns the empty record type that decodes r.Type.
+func newResource(r DNSResourceRecord) (record DNSResource) {
 	switch r.Type {
 	case DNSTypeA:
 		record = &DNSResourceRecordA{
@@ -162,7 +401,7 @@ func ParseResource(reader *bytes.Reader) (record DNSResource, err error) {
 		record = &DNSResourceRecordSOA{
 			DNSResourceRecord: r,
 		}
-	case DNSTypeTXT:
+	case DNSTypeTXT, DNSTypeSPF:
 		record = &DNSResourceRecordTXT{
 			DNSResourceRecord: r,
 		}
@@ -190,42 +429,136 @@ func ParseResource(reader *bytes.Reader) (record DNSResource, err error) {
 		record = &DNSResourceRecordPTR{
 			DNSResourceRecord: r,
 		}
-	default:
-		// For unknown record types, read and store the RDATA
-		// This allows parsing to continue for other records
-		var rdLength uint16
-		if err = binary.Read(reader, binary.BigEndian, &rdLength); err != nil {
-			return
+	case DNSTypeCAA:
+		record = &DNSResourceRecordCAA{
+			DNSResourceRecord: r,
+		}
+	case DNSTypeTLSA:
+		record = &DNSResourceRecordTLSA{
+			DNSResourceRecord: r,
 		}
-		rdata := make([]byte, rdLength)
-		if _, err = io.ReadFull(reader, rdata); err != nil {
-			return
+	case DNSTypeSSHFP:
+		record = &DNSResourceRecordSSHFP{
+			DNSResourceRecord: r,
+		}
+	case DNSTypeNAPTR:
+		record = &DNSResourceRecordNAPTR{
+			DNSResourceRecord: r,
+		}
+	case DNSTypeDNSKEY, DNSTypeCDNSKEY:
+		record = &DNSResourceRecordDNSKEY{
+			DNSResourceRecord: r,
+		}
+	case DNSTypeDS, DNSTypeCDS:
+		record = &DNSResourceRecordDS{
+			DNSResourceRecord: r,
+		}
+	case DNSTypeRRSIG:
+		record = &DNSResourceRecordRRSIG{
+			DNSResourceRecord: r,
 		}
-		// Return the record with raw RDATA
+	case DNSTypeNSEC:
+		record = &DNSResourceRecordNSEC{
+			DNSResourceRecord: r,
+		}
+	case DNSTypeNSEC3:
+		record = &DNSResourceRecordNSEC3{
+			DNSResourceRecord: r,
+		}
+	case DNSTypeNSEC3PARAM:
+		record = &DNSResourceRecordNSEC3PARAM{
+			DNSResourceRecord: r,
+		}
+	case DNSTypeSVCB:
+		record = &DNSResourceRecordSVCB{
+			DNSResourceRecord: r,
+		}
+	case DNSTypeHTTPS:
+		record = &DNSResourceRecordHTTPS{
+			DNSResourceRecordSVCB: DNSResourceRecordSVCB{DNSResourceRecord: r},
+		}
+	case DNSTypeTSIG:
+		record = &DNSResourceRecordTSIG{
+			DNSResourceRecord: r,
+		}
+	default:
+		// For unknown record types, keep the raw RDATA so parsing can
+		// continue with the other records
 		record = &DNSResourceRecordUnknown{
 			DNSResourceRecord: r,
-			RData:             rdata,
 		}
-		return
 	}
-	// Read RDLENGTH
-	var rdLength uint16
-	if err = binary.Read(reader, binary.BigEndian, &rdLength); err != nil {
-		return
-	}
-	// // Read Data
-	// data := make([]byte, rdLength)
-	// if _, err = reader.Read(data); err != nil {
-	// 	return
-	// }
-	record.Decode(reader, rdLength)
 	return
 }
 
+// newRDataResource is newResource for a record whose RDATA is length
+// bytes long. UPDATE messages (RFC 2136 §2.4, §2.5) use empty RDATA with
+// class ANY or NONE to name an RRset rather than a record; those decode as
+// a DNSResourceRecordUnknown with no RData whatever their type.
+func newRDataResource(r DNSResourceRecord, length int) DNSResource {
+	if length == 0 && r.Type != DNSTypeEDNS && (r.Class == DNSClassAny || r.Class == DNSClassNone) {
+		return &DNSResourceRecordUnknown{DNSResourceRecord: r}
+	}
+	return newResource(r)
+}
+
+// decodeRData runs record.Decode over the next length bytes and checks that
+// it consumed exactly those.
+func decodeRData(record DNSResource, reader *bytes.Reader, length uint16) error {
+	h := record.GetHeader()
+	start := reader.Size() - int64(reader.Len())
+	if err := record.Decode(reader, length); err != nil {
+		return fmt.Errorf("%s record %q: %w", h.Type, h.Name, err)
+	}
+	if consumed := reader.Size() - int64(reader.Len()) - start; consumed != int64(length) {
+		return fmt.Errorf("%s record %q: %w: decoded %d of %d bytes", h.Type, h.Name, ErrRDataLength, consumed, length)
+	}
+	return nil
+}
+
+// decodeCharacterString reads one length-prefixed <character-string>
+// (RFC 1035 §3.3) that must fit in the remaining bytes of RDATA.
+func decodeCharacterString(reader *bytes.Reader, remaining int) (string, error) {
+	if remaining < 1 {
+		return "", fmt.Errorf("%w: missing character-string", ErrRDataLength)
+	}
+	n, err := reader.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if int(n) > remaining-1 {
+		return "", fmt.Errorf("%w: character-string of %d bytes exceeds RDATA", ErrRDataLength, n)
+	}
+	data := make([]byte, n)
+	if err := readFull(reader, data); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// encodeCharacterString writes s with its length prefix. Callers must keep
+// s within 255 bytes.
+func encodeCharacterString(buf *bytes.Buffer, s string) {
+	buf.WriteByte(byte(len(s)))
+	buf.WriteString(s)
+}
+
+// readFull fills buf from reader, reporting a short read as
+// io.ErrUnexpectedEOF.
+func readFull(reader *bytes.Reader, buf []byte) error {
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
 func (r *DNSResourceRecord) WrapData(rdData []byte) []byte {
 	var buf bytes.Buffer
 	// Encode domain name
-	encodeDomainName(&buf, r.Name, true)
+	encodeDomainName(&buf, r.Name, nil)
 	// Encode type
 	binary.Write(&buf, binary.BigEndian, uint16(r.Type))
 	// Encode class
@@ -2,6 +2,7 @@ package packet
 
 import (
 	"bytes"
+	"fmt"
 	"net"
 )
 
@@ -12,10 +13,16 @@ type DNSResourceRecordA struct {
 }
 
 // decode implements DNSResourceRecordData.
-func (a *DNSResourceRecordA) Decode(reader *bytes.Reader, length uint16) {
+func (a *DNSResourceRecordA) Decode(reader *bytes.Reader, length uint16) error {
+	if length != net.IPv4len {
+		return fmt.Errorf("%w: A address must be %d bytes, got %d", ErrRDataLength, net.IPv4len, length)
+	}
 	data := make([]byte, length)
-	reader.Read(data)
+	if err := readFull(reader, data); err != nil {
+		return err
+	}
 	a.Address = net.IP(data).String()
+	return nil
 }
 
 func (a *DNSResourceRecordA) Encode() []byte {
@@ -25,3 +32,14 @@ func (a *DNSResourceRecordA) Encode() []byte {
 func (a *DNSResourceRecordA) Bytes() []byte {
 	return a.WrapData(a.Encode())
 }
+
+// String returns the record in presentation format.
+func (a *DNSResourceRecordA) String() string {
+	return a.presentation(a.Address)
+}
+
+// Clone returns a copy of the record.
+func (a *DNSResourceRecordA) Clone() DNSResource {
+	cp := *a
+	return &cp
+}
@@ -2,6 +2,7 @@ package packet
 
 import (
 	"bytes"
+	"fmt"
 	"net"
 )
 
@@ -12,10 +13,16 @@ type DNSResourceRecordAAAA struct {
 }
 
 // Decode implements DNSResource.
-func (d *DNSResourceRecordAAAA) Decode(reader *bytes.Reader, length uint16) {
+func (d *DNSResourceRecordAAAA) Decode(reader *bytes.Reader, length uint16) error {
+	if length != net.IPv6len {
+		return fmt.Errorf("%w: AAAA address must be %d bytes, got %d", ErrRDataLength, net.IPv6len, length)
+	}
 	data := make([]byte, length)
-	reader.Read(data)
+	if err := readFull(reader, data); err != nil {
+		return err
+	}
 	d.Address = net.IP(data).String()
+	return nil
 }
 
 func (d *DNSResourceRecordAAAA) Encode() []byte {
@@ -25,3 +32,14 @@ func (d *DNSResourceRecordAAAA) Encode() []byte {
 func (a *DNSResourceRecordAAAA) Bytes() []byte {
 	return a.WrapData(a.Encode())
 }
+
+// String returns the record in presentation format.
+func (a *DNSResourceRecordAAAA) String() string {
+	return a.presentation(a.Address)
+}
+
+// Clone returns a copy of the record.
+func (a *DNSResourceRecordAAAA) Clone() DNSResource {
+	cp := *a
+	return &cp
+}
@@ -0,0 +1,76 @@
+package packet
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// CAA RDATA format (RFC 8659 §4.1)
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |         FLAGS         |      TAG LENGTH       |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                      TAG                      /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                     VALUE                     /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+
+// CAAFlagCritical is the Issuer Critical flag: a CA that does not understand
+// the tag must refuse to issue.
+const CAAFlagCritical uint8 = 0x80
+
+// DNSResourceRecordCAA represents the CAA (Certification Authority
+// Authorization) resource record, which restricts the CAs allowed to issue
+// certificates for a domain.
+type DNSResourceRecordCAA struct {
+	DNSResourceRecord
+
+	Flag  uint8  // Issuer Critical flag in the high bit
+	Tag   string // property tag, e.g. issue, issuewild, iodef
+	Value string // property value; its syntax depends on the tag
+}
+
+// Decode implements DNSResource.
+func (r *DNSResourceRecordCAA) Decode(reader *bytes.Reader, length uint16) (err error) {
+	if length < 2 {
+		return fmt.Errorf("%w: CAA RDATA of %d bytes", ErrRDataLength, length)
+	}
+	if r.Flag, err = reader.ReadByte(); err != nil {
+		return
+	}
+	if r.Tag, err = decodeCharacterString(reader, int(length)-1); err != nil {
+		return
+	}
+	if r.Tag == "" {
+		return fmt.Errorf("CAA tag must not be empty")
+	}
+	value := make([]byte, int(length)-2-len(r.Tag))
+	if err = readFull(reader, value); err != nil {
+		return
+	}
+	r.Value = string(value)
+	return nil
+}
+
+// Encode implements DNSResource.
+func (r *DNSResourceRecordCAA) Encode() []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(r.Flag)
+	encodeCharacterString(&buf, r.Tag)
+	buf.WriteString(r.Value)
+	return buf.Bytes()
+}
+
+func (r *DNSResourceRecordCAA) Bytes() []byte {
+	return r.WrapData(r.Encode())
+}
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordCAA) String() string {
+	return r.presentation(fmt.Sprintf("%d %s %s", r.Flag, r.Tag, quoteString(r.Value)))
+}
+
+// Clone returns a copy of the record.
+func (r *DNSResourceRecordCAA) Clone() DNSResource {
+	cp := *r
+	return &cp
+}
@@ -11,18 +11,35 @@ type DNSResourceRecordCNAME struct {
 }
 
 // Decode implements DNSResource.
-func (d *DNSResourceRecordCNAME) Decode(reader *bytes.Reader, length uint16) {
-	d.Domain, _ = decodeDomainName(reader)
+func (d *DNSResourceRecordCNAME) Decode(reader *bytes.Reader, length uint16) (err error) {
+	d.Domain, err = decodeDomainName(reader)
+	return
 }
 
 // Encode implements DNSResource.
 // Subtle: this method shadows the method (DNSResourceRecord).Encode of DNSResourceRecordCNAME.DNSResourceRecord.
 func (d *DNSResourceRecordCNAME) Encode() []byte {
 	var buf bytes.Buffer
-	encodeDomainName(&buf, d.Domain, true)
+	d.EncodeCompressed(&buf, nil)
 	return buf.Bytes()
 }
 
+// EncodeCompressed implements CompressibleResource.
+func (d *DNSResourceRecordCNAME) EncodeCompressed(msg *bytes.Buffer, c *Compressor) error {
+	return encodeDomainName(msg, d.Domain, c)
+}
+
 func (a *DNSResourceRecordCNAME) Bytes() []byte {
 	return a.WrapData(a.Encode())
 }
+
+// String returns the record in presentation format.
+func (a *DNSResourceRecordCNAME) String() string {
+	return a.presentation(fqdn(a.Domain))
+}
+
+// Clone returns a copy of the record.
+func (a *DNSResourceRecordCNAME) Clone() DNSResource {
+	cp := *a
+	return &cp
+}
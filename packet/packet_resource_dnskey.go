@@ -0,0 +1,116 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+)
+
+// DNSKEY RDATA format (RFC 4034 §2.1)
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                     FLAGS                     |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |       PROTOCOL        |       ALGORITHM       |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                  PUBLIC KEY                   /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+
+// DNSKEY flag bits (RFC 4034 §2.1.1, RFC 5011 §3).
+const (
+	DNSKEYFlagZone   uint16 = 0x0100 // key may sign zone data
+	DNSKEYFlagRevoke uint16 = 0x0080 // key is revoked
+	DNSKEYFlagSEP    uint16 = 0x0001 // secure entry point, i.e. a KSK
+)
+
+// DNSSEC algorithm numbers used by DNSKEY, DS and RRSIG
+// (IANA "DNS Security Algorithm Numbers").
+const (
+	DNSSECAlgorithmRSAMD5          uint8 = 1
+	DNSSECAlgorithmRSASHA1         uint8 = 5
+	DNSSECAlgorithmRSASHA256       uint8 = 8
+	DNSSECAlgorithmRSASHA512       uint8 = 10
+	DNSSECAlgorithmECDSAP256SHA256 uint8 = 13
+	DNSSECAlgorithmECDSAP384SHA384 uint8 = 14
+	DNSSECAlgorithmED25519         uint8 = 15
+	DNSSECAlgorithmED448           uint8 = 16
+)
+
+// DNSResourceRecordDNSKEY represents a DNSKEY record, or a CDNSKEY record
+// (type 60, RFC 7344) which shares its wire format.
+type DNSResourceRecordDNSKEY struct {
+	DNSResourceRecord
+
+	Flags     uint16
+	Protocol  uint8 // always 3
+	Algorithm uint8
+	PublicKey []byte
+}
+
+// Decode implements DNSResource.
+func (r *DNSResourceRecordDNSKEY) Decode(reader *bytes.Reader, length uint16) (err error) {
+	if length < 4 {
+		return fmt.Errorf("%w: DNSKEY RDATA of %d bytes", ErrRDataLength, length)
+	}
+	if err = binary.Read(reader, binary.BigEndian, &r.Flags); err != nil {
+		return
+	}
+	if r.Protocol, err = reader.ReadByte(); err != nil {
+		return
+	}
+	if r.Algorithm, err = reader.ReadByte(); err != nil {
+		return
+	}
+	r.PublicKey = make([]byte, length-4)
+	return readFull(reader, r.PublicKey)
+}
+
+// Encode implements DNSResource.
+func (r *DNSResourceRecordDNSKEY) Encode() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, r.Flags)
+	buf.WriteByte(r.Protocol)
+	buf.WriteByte(r.Algorithm)
+	buf.Write(r.PublicKey)
+	return buf.Bytes()
+}
+
+func (r *DNSResourceRecordDNSKEY) Bytes() []byte {
+	return r.WrapData(r.Encode())
+}
+
+// KeyTag computes the key tag that DS and RRSIG records use to refer to
+// this key (RFC 4034 Appendix B).
+func (r *DNSResourceRecordDNSKEY) KeyTag() uint16 {
+	if r.Algorithm == DNSSECAlgorithmRSAMD5 {
+		// B.1: the tag is the most significant 16 of the least
+		// significant 24 bits of the modulus
+		if n := len(r.PublicKey); n >= 3 {
+			return binary.BigEndian.Uint16(r.PublicKey[n-3:])
+		}
+		return 0
+	}
+	var ac uint32
+	for i, b := range r.Encode() {
+		if i&1 == 0 {
+			ac += uint32(b) << 8
+		} else {
+			ac += uint32(b)
+		}
+	}
+	ac += ac >> 16 & 0xFFFF
+	return uint16(ac)
+}
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordDNSKEY) String() string {
+	return r.presentation(fmt.Sprintf("%d %d %d %s", r.Flags, r.Protocol, r.Algorithm,
+		base64.StdEncoding.EncodeToString(r.PublicKey)))
+}
+
+// Clone returns a deep copy of the record.
+func (r *DNSResourceRecordDNSKEY) Clone() DNSResource {
+	cp := *r
+	cp.PublicKey = cloneBytes(r.PublicKey)
+	return &cp
+}
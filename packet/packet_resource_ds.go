@@ -0,0 +1,79 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// DS RDATA format (RFC 4034 §5.1)
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                    KEY TAG                    |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |       ALGORITHM       |      DIGEST TYPE      |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                    DIGEST                     /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+
+// DS digest types (IANA "Delegation Signer (DS) Resource Record Digest
+// Algorithms").
+const (
+	DSDigestSHA1   uint8 = 1
+	DSDigestSHA256 uint8 = 2
+	DSDigestSHA384 uint8 = 4
+)
+
+// DNSResourceRecordDS represents a DS record, or a CDS record (type 59,
+// RFC 7344) which shares its wire format.
+type DNSResourceRecordDS struct {
+	DNSResourceRecord
+
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     []byte
+}
+
+// Decode implements DNSResource.
+func (r *DNSResourceRecordDS) Decode(reader *bytes.Reader, length uint16) (err error) {
+	if length < 4 {
+		return fmt.Errorf("%w: DS RDATA of %d bytes", ErrRDataLength, length)
+	}
+	if err = binary.Read(reader, binary.BigEndian, &r.KeyTag); err != nil {
+		return
+	}
+	if r.Algorithm, err = reader.ReadByte(); err != nil {
+		return
+	}
+	if r.DigestType, err = reader.ReadByte(); err != nil {
+		return
+	}
+	r.Digest = make([]byte, length-4)
+	return readFull(reader, r.Digest)
+}
+
+// Encode implements DNSResource.
+func (r *DNSResourceRecordDS) Encode() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, r.KeyTag)
+	buf.WriteByte(r.Algorithm)
+	buf.WriteByte(r.DigestType)
+	buf.Write(r.Digest)
+	return buf.Bytes()
+}
+
+func (r *DNSResourceRecordDS) Bytes() []byte {
+	return r.WrapData(r.Encode())
+}
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordDS) String() string {
+	return r.presentation(fmt.Sprintf("%d %d %d %s", r.KeyTag, r.Algorithm, r.DigestType, hexString(r.Digest)))
+}
+
+// Clone returns a deep copy of the record.
+func (r *DNSResourceRecordDS) Clone() DNSResource {
+	cp := *r
+	cp.Digest = cloneBytes(r.Digest)
+	return &cp
+}
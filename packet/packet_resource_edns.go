@@ -3,27 +3,53 @@ package packet
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
+	"fmt"
 	"net"
+	"strings"
 )
 
 // EDNS Option Codes
 const (
-	EDNSOptionLLQ        uint16 = 1
-	EDNSOptionUL         uint16 = 2
-	EDNSOptionNSID       uint16 = 3
-	EDNSOptionDAU        uint16 = 5
-	EDNSOptionDHU        uint16 = 6
-	EDNSOptionN3U        uint16 = 7
-	EDNSOptionClientSubnet uint16 = 8
-	EDNSOptionExpire     uint16 = 9
-	EDNSOptionCookie     uint16 = 10
-	EDNSOptionTCPKeepalive uint16 = 11
-	EDNSOptionPadding    uint16 = 12
-	EDNSOptionChain      uint16 = 13
-	EDNSOptionKeyTag     uint16 = 14
-	EDNSOptionDeviceID   uint16 = 26
+	EDNSOptionLLQ           uint16 = 1
+	EDNSOptionUL            uint16 = 2
+	EDNSOptionNSID          uint16 = 3
+	EDNSOptionDAU           uint16 = 5
+	EDNSOptionDHU           uint16 = 6
+	EDNSOptionN3U           uint16 = 7
+	EDNSOptionClientSubnet  uint16 = 8
+	EDNSOptionExpire        uint16 = 9
+	EDNSOptionCookie        uint16 = 10
+	EDNSOptionTCPKeepalive  uint16 = 11
+	EDNSOptionPadding       uint16 = 12
+	EDNSOptionChain         uint16 = 13
+	EDNSOptionKeyTag        uint16 = 14
+	EDNSOptionExtendedError uint16 = 15
+	EDNSOptionDeviceID      uint16 = 26
 )
 
+var ednsOptionNames = map[uint16]string{
+	EDNSOptionLLQ:           "LLQ",
+	EDNSOptionUL:            "UL",
+	EDNSOptionNSID:          "NSID",
+	EDNSOptionDAU:           "DAU",
+	EDNSOptionDHU:           "DHU",
+	EDNSOptionN3U:           "N3U",
+	EDNSOptionClientSubnet:  "CLIENT-SUBNET",
+	EDNSOptionExpire:        "EXPIRE",
+	EDNSOptionCookie:        "COOKIE",
+	EDNSOptionTCPKeepalive:  "TCP-KEEPALIVE",
+	EDNSOptionPadding:       "PADDING",
+	EDNSOptionChain:         "CHAIN",
+	EDNSOptionKeyTag:        "KEY-TAG",
+	EDNSOptionExtendedError: "EDE",
+	EDNSOptionDeviceID:      "DEVICEID",
+}
+
+// DefaultEDNSUDPSize is the UDP payload size advertised by OPT records this
+// package creates on its own (the DNS Flag Day 2020 recommendation).
+const DefaultEDNSUDPSize uint16 = 1232
+
 type DNSResourceRecordEDNS struct {
 	DNSResourceRecord
 
@@ -40,21 +66,38 @@ type EDNSOption struct {
 }
 
 // Decode implements DNSResource.
-func (d *DNSResourceRecordEDNS) Decode(reader *bytes.Reader, length uint16) {
+func (d *DNSResourceRecordEDNS) Decode(reader *bytes.Reader, length uint16) error {
 	d.UDPSize = uint16(d.Class)
 	d.ExtRCode = uint8(d.TTL >> 24)
 	d.Version = uint8((d.TTL >> 16) & 0xFF)
 	d.Flags = uint16(d.TTL & 0xFFFF)
 
-	for reader.Len() > 0 {
+	// Options are bounded by RDLENGTH, not by the end of the message
+	remaining := int(length)
+	for remaining > 0 {
+		if remaining < 4 {
+			return fmt.Errorf("%w: %d trailing bytes after last EDNS option", ErrRDataLength, remaining)
+		}
 		var option EDNSOption
-		binary.Read(reader, binary.BigEndian, &option.Code)
 		var optionLength uint16
-		binary.Read(reader, binary.BigEndian, &optionLength)
+		if err := binary.Read(reader, binary.BigEndian, &option.Code); err != nil {
+			return err
+		}
+		if err := binary.Read(reader, binary.BigEndian, &optionLength); err != nil {
+			return err
+		}
+		remaining -= 4
+		if int(optionLength) > remaining {
+			return fmt.Errorf("%w: EDNS option %d length %d exceeds RDATA", ErrRDataLength, option.Code, optionLength)
+		}
 		option.Data = make([]byte, optionLength)
-		reader.Read(option.Data)
+		if err := readFull(reader, option.Data); err != nil {
+			return err
+		}
+		remaining -= int(optionLength)
 		d.Options = append(d.Options, option)
 	}
+	return nil
 }
 
 // Encode implements DNSResource.
@@ -77,6 +120,30 @@ func (r *DNSResourceRecordEDNS) Bytes() []byte {
 	return r.WrapData(r.Encode())
 }
 
+// String renders the OPT pseudo-record the way dig prints its OPT
+// PSEUDOSECTION, since OPT has no zone-file form: an "; EDNS:" line with
+// version, flags and UDP size, then one line per option.
+func (d *DNSResourceRecordEDNS) String() string {
+	var b strings.Builder
+	flags := ""
+	if d.GetDNSSECOK() {
+		flags = " do"
+	}
+	fmt.Fprintf(&b, "; EDNS: version: %d, flags:%s; udp: %d", d.Version, flags, d.UDPSize)
+	for _, option := range d.Options {
+		name, ok := ednsOptionNames[option.Code]
+		if !ok {
+			name = fmt.Sprintf("OPT=%d", option.Code)
+		}
+		value, ok := optionString(option)
+		if !ok {
+			value = strings.ToUpper(hex.EncodeToString(option.Data))
+		}
+		fmt.Fprintf(&b, "\n; %s: %s", name, value)
+	}
+	return b.String()
+}
+
 // AddEDNSOption adds an EDNS option to the EDNS record.
 func (d *DNSResourceRecordEDNS) AddEDNSOption(code uint16, data []byte) {
 	d.Options = append(d.Options, EDNSOption{
@@ -91,41 +158,30 @@ func (d *DNSResourceRecordEDNS) syncTTL() {
 }
 
 // AddEDNSOptionClientSubnet adds an EDNS Client Subnet option (RFC 7871).
-// address is the client IP address, and prefixLength is the subnet mask length.
+// address is the client IP address, and prefixLength is the source prefix
+// length; only the octets the prefix covers are sent, with the bits past it
+// zeroed.
 func (d *DNSResourceRecordEDNS) AddEDNSOptionClientSubnet(address net.IP, prefixLength uint8) {
-	family := uint16(1) // IPv4
-	if address.To4() == nil {
-		family = 2 // IPv6
+	family := ClientSubnetFamilyIPv4
+	ip := address.To4()
+	if ip == nil {
+		family = ClientSubnetFamilyIPv6
+		ip = address.To16()
 	}
-
-	// Determine address length based on family
-	addrLen := 4
-	if family == 2 {
-		addrLen = 8 // Use first 8 bytes for IPv6
+	if max := uint8(len(ip) * 8); prefixLength > max {
+		prefixLength = max
 	}
 
-	// Truncate address to prefix length
-	var truncatedIP net.IP
-	if family == 1 {
-		truncatedIP = address.To4()
-	} else {
-		truncatedIP = address.To16()
+	truncatedIP := make([]byte, (int(prefixLength)+7)/8)
+	copy(truncatedIP, ip)
+	if bits := prefixLength % 8; bits != 0 {
+		truncatedIP[len(truncatedIP)-1] &= 0xFF << (8 - bits)
 	}
-	// Calculate bytes needed based on prefix length
-	bytesNeeded := (int(prefixLength) + 7) / 8
-	if bytesNeeded > addrLen {
-		bytesNeeded = addrLen
-	}
-	truncatedIP = truncatedIP[:bytesNeeded]
 
 	var buf bytes.Buffer
 	binary.Write(&buf, binary.BigEndian, family)
-	buf.WriteByte(24) // Source netmask length (for IPv4)
-	if family == 2 {
-		buf.WriteByte(prefixLength) // For IPv6
-	}
-	buf.WriteByte(0) // Scope netmask length
-
+	buf.WriteByte(prefixLength) // Source prefix length
+	buf.WriteByte(0)            // Scope prefix length, always 0 in queries
 	buf.Write(truncatedIP)
 
 	d.AddEDNSOption(EDNSOptionClientSubnet, buf.Bytes())
@@ -178,3 +234,16 @@ func NewEDNSRecord(udpSize uint16) *DNSResourceRecordEDNS {
 		Options:  nil,
 	}
 }
+
+// Clone returns a deep copy of the record, so options can be edited
+// without touching the original.
+func (d *DNSResourceRecordEDNS) Clone() DNSResource {
+	cp := *d
+	if d.Options != nil {
+		cp.Options = make([]EDNSOption, len(d.Options))
+		for i, o := range d.Options {
+			cp.Options[i] = EDNSOption{Code: o.Code, Data: cloneBytes(o.Data)}
+		}
+	}
+	return &cp
+}
@@ -3,6 +3,7 @@ package packet
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 )
 
 // MX RDATA format
@@ -23,19 +24,38 @@ type DNSResourceRecordMX struct {
 }
 
 // Decode implements DNSResource.
-func (r *DNSResourceRecordMX) Decode(reader *bytes.Reader, length uint16) {
-	binary.Read(reader, binary.BigEndian, &r.Preference)
-	r.Exchange, _ = decodeDomainName(reader)
+func (r *DNSResourceRecordMX) Decode(reader *bytes.Reader, length uint16) (err error) {
+	if err = binary.Read(reader, binary.BigEndian, &r.Preference); err != nil {
+		return
+	}
+	r.Exchange, err = decodeDomainName(reader)
+	return
 }
 
 // Encode implements DNSResource.
 func (r *DNSResourceRecordMX) Encode() []byte {
 	var buf bytes.Buffer
-	binary.Write(&buf, binary.BigEndian, r.Preference)
-	encodeDomainName(&buf, r.Exchange, true)
+	r.EncodeCompressed(&buf, nil)
 	return buf.Bytes()
 }
 
+// EncodeCompressed implements CompressibleResource.
+func (r *DNSResourceRecordMX) EncodeCompressed(msg *bytes.Buffer, c *Compressor) error {
+	binary.Write(msg, binary.BigEndian, r.Preference)
+	return encodeDomainName(msg, r.Exchange, c)
+}
+
 func (r *DNSResourceRecordMX) Bytes() []byte {
 	return r.WrapData(r.Encode())
 }
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordMX) String() string {
+	return r.presentation(fmt.Sprintf("%d %s", r.Preference, fqdn(r.Exchange)))
+}
+
+// Clone returns a copy of the record.
+func (r *DNSResourceRecordMX) Clone() DNSResource {
+	cp := *r
+	return &cp
+}
@@ -0,0 +1,84 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// NAPTR RDATA format (RFC 3403 §4.1)
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                     ORDER                     |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                   PREFERENCE                  |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                     FLAGS                     /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                   SERVICES                    /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                    REGEXP                     /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                  REPLACEMENT                  /
+// /                                               /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+
+// DNSResourceRecordNAPTR represents the NAPTR (Naming Authority Pointer)
+// resource record, used by ENUM and SIP to rewrite a name into a URI or
+// another domain name.
+type DNSResourceRecordNAPTR struct {
+	DNSResourceRecord
+
+	Order       uint16 // records are processed in increasing order
+	Preference  uint16 // tie-breaker among records of equal order
+	Flags       string // e.g. "S", "A", "U", "P"
+	Services    string // e.g. "SIP+D2U", "E2U+sip"
+	Regexp      string // substitution expression, exclusive with Replacement
+	Replacement string // next domain name to query, "." when Regexp is used
+}
+
+// Decode implements DNSResource.
+func (r *DNSResourceRecordNAPTR) Decode(reader *bytes.Reader, length uint16) (err error) {
+	start := reader.Len()
+	if err = binary.Read(reader, binary.BigEndian, &r.Order); err != nil {
+		return
+	}
+	if err = binary.Read(reader, binary.BigEndian, &r.Preference); err != nil {
+		return
+	}
+	for _, field := range []*string{&r.Flags, &r.Services, &r.Regexp} {
+		if *field, err = decodeCharacterString(reader, int(length)-(start-reader.Len())); err != nil {
+			return
+		}
+	}
+	r.Replacement, err = decodeDomainName(reader)
+	return
+}
+
+// Encode implements DNSResource.
+func (r *DNSResourceRecordNAPTR) Encode() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, r.Order)
+	binary.Write(&buf, binary.BigEndian, r.Preference)
+	encodeCharacterString(&buf, r.Flags)
+	encodeCharacterString(&buf, r.Services)
+	encodeCharacterString(&buf, r.Regexp)
+	// RFC 3403 §4.1: the replacement is never compressed
+	encodeDomainName(&buf, r.Replacement, nil)
+	return buf.Bytes()
+}
+
+func (r *DNSResourceRecordNAPTR) Bytes() []byte {
+	return r.WrapData(r.Encode())
+}
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordNAPTR) String() string {
+	return r.presentation(fmt.Sprintf("%d %d %s %s %s %s", r.Order, r.Preference,
+		quoteString(r.Flags), quoteString(r.Services), quoteString(r.Regexp), fqdn(r.Replacement)))
+}
+
+// Clone returns a copy of the record.
+func (r *DNSResourceRecordNAPTR) Clone() DNSResource {
+	cp := *r
+	return &cp
+}
@@ -11,18 +11,35 @@ type DNSResourceRecordNS struct {
 }
 
 // Decode implements DNSResource.
-func (d *DNSResourceRecordNS) Decode(reader *bytes.Reader, length uint16) {
-	d.NameServer, _ = decodeDomainName(reader)
+func (d *DNSResourceRecordNS) Decode(reader *bytes.Reader, length uint16) (err error) {
+	d.NameServer, err = decodeDomainName(reader)
+	return
 }
 
 // Encode implements DNSResource.
 // Subtle: this method shadows the method (DNSResourceRecord).Encode of DNSResourceRecordNS.DNSResourceRecord.
 func (d *DNSResourceRecordNS) Encode() []byte {
 	var buf bytes.Buffer
-	encodeDomainName(&buf, d.NameServer, true)
+	d.EncodeCompressed(&buf, nil)
 	return buf.Bytes()
 }
 
+// EncodeCompressed implements CompressibleResource.
+func (d *DNSResourceRecordNS) EncodeCompressed(msg *bytes.Buffer, c *Compressor) error {
+	return encodeDomainName(msg, d.NameServer, c)
+}
+
 func (a *DNSResourceRecordNS) Bytes() []byte {
 	return a.WrapData(a.Encode())
 }
+
+// String returns the record in presentation format.
+func (a *DNSResourceRecordNS) String() string {
+	return a.presentation(fqdn(a.NameServer))
+}
+
+// Clone returns a copy of the record.
+func (a *DNSResourceRecordNS) Clone() DNSResource {
+	cp := *a
+	return &cp
+}
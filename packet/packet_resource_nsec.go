@@ -0,0 +1,126 @@
+package packet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NSEC RDATA format (RFC 4034 §4.1)
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /              NEXT DOMAIN NAME                 /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /               TYPE BIT MAPS                   /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+
+// ErrTypeBitMap is returned when an NSEC or NSEC3 type bitmap is malformed.
+var ErrTypeBitMap = errors.New("malformed type bitmap")
+
+// DNSResourceRecordNSEC represents an NSEC record, which proves that no
+// name exists between the owner and NextDomain, and lists the types
+// present at the owner.
+type DNSResourceRecordNSEC struct {
+	DNSResourceRecord
+
+	NextDomain string
+	Types      []DNSType // sorted, as decoded from the type bitmap
+}
+
+// Decode implements DNSResource.
+func (r *DNSResourceRecordNSEC) Decode(reader *bytes.Reader, length uint16) (err error) {
+	start := reader.Len()
+	if r.NextDomain, err = decodeDomainName(reader); err != nil {
+		return
+	}
+	remaining := int(length) - (start - reader.Len())
+	if remaining < 0 {
+		return fmt.Errorf("%w: NSEC next domain exceeds RDATA", ErrRDataLength)
+	}
+	r.Types, err = decodeTypeBitMap(reader, remaining)
+	return
+}
+
+// Encode implements DNSResource.
+func (r *DNSResourceRecordNSEC) Encode() []byte {
+	var buf bytes.Buffer
+	// RFC 4034 §4.1.1: the next domain name is never compressed
+	encodeDomainName(&buf, r.NextDomain, nil)
+	encodeTypeBitMap(&buf, r.Types)
+	return buf.Bytes()
+}
+
+func (r *DNSResourceRecordNSEC) Bytes() []byte {
+	return r.WrapData(r.Encode())
+}
+
+// encodeTypeBitMap writes types in the windowed bitmap format of RFC 4034
+// §4.1.2: for each 256-type window in use, the window number, the bitmap
+// length and the bitmap itself, omitting trailing zero octets.
+func encodeTypeBitMap(buf *bytes.Buffer, types []DNSType) {
+	sorted := append([]DNSType{}, types...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i := 0; i < len(sorted); {
+		window := byte(sorted[i] >> 8)
+		var bitmap [32]byte
+		n := 0
+		for ; i < len(sorted) && byte(sorted[i]>>8) == window; i++ {
+			low := byte(sorted[i])
+			bitmap[low/8] |= 0x80 >> (low % 8)
+			n = int(low/8) + 1
+		}
+		buf.WriteByte(window)
+		buf.WriteByte(byte(n))
+		buf.Write(bitmap[:n])
+	}
+}
+
+// decodeTypeBitMap reads a type bitmap occupying the next length bytes.
+// Windows must appear in increasing order with a bitmap of 1 to 32 bytes.
+func decodeTypeBitMap(reader *bytes.Reader, length int) ([]DNSType, error) {
+	var types []DNSType
+	last := -1
+	for length > 0 {
+		if length < 2 {
+			return nil, fmt.Errorf("%w: truncated window header", ErrTypeBitMap)
+		}
+		header := make([]byte, 2)
+		if err := readFull(reader, header); err != nil {
+			return nil, err
+		}
+		window, n := int(header[0]), int(header[1])
+		if window <= last {
+			return nil, fmt.Errorf("%w: window %d out of order", ErrTypeBitMap, window)
+		}
+		last = window
+		if n < 1 || n > 32 || n > length-2 {
+			return nil, fmt.Errorf("%w: window %d bitmap length %d", ErrTypeBitMap, window, n)
+		}
+		bitmap := make([]byte, n)
+		if err := readFull(reader, bitmap); err != nil {
+			return nil, err
+		}
+		for i, b := range bitmap {
+			for bit := 0; bit < 8; bit++ {
+				if b&(0x80>>bit) != 0 {
+					types = append(types, DNSType(window<<8|i*8+bit))
+				}
+			}
+		}
+		length -= 2 + n
+	}
+	return types, nil
+}
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordNSEC) String() string {
+	return r.presentation(strings.TrimSpace(fqdn(r.NextDomain) + " " + typeList(r.Types)))
+}
+
+// Clone returns a deep copy of the record.
+func (r *DNSResourceRecordNSEC) Clone() DNSResource {
+	cp := *r
+	cp.Types = append([]DNSType(nil), r.Types...)
+	return &cp
+}
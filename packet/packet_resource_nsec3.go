@@ -0,0 +1,161 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// NSEC3 RDATA format (RFC 5155 §3.2)
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |    HASH ALGORITHM     |         FLAGS         |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                  ITERATIONS                   |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |      SALT LENGTH      |         SALT          /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |      HASH LENGTH      |  NEXT HASHED OWNER    /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                 TYPE BIT MAPS                 /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//
+// NSEC3PARAM (RFC 5155 §4.2) carries only the fields up to the salt.
+
+// NSEC3FlagOptOut marks an NSEC3 span that may cover unsigned delegations.
+const NSEC3FlagOptOut uint8 = 0x01
+
+// NSEC3HashSHA1 is the only NSEC3 hash algorithm defined.
+const NSEC3HashSHA1 uint8 = 1
+
+// DNSResourceRecordNSEC3 represents an NSEC3 record, the hashed form of
+// NSEC used for authenticated denial without zone walking.
+type DNSResourceRecordNSEC3 struct {
+	DNSResourceRecord
+
+	HashAlgorithm uint8
+	Flags         uint8
+	Iterations    uint16
+	Salt          []byte
+	NextHashed    []byte    // raw hash, shown in base32hex in zone files
+	Types         []DNSType // sorted, as decoded from the type bitmap
+}
+
+// Decode implements DNSResource.
+func (r *DNSResourceRecordNSEC3) Decode(reader *bytes.Reader, length uint16) (err error) {
+	start := reader.Len()
+	if r.HashAlgorithm, r.Flags, r.Iterations, r.Salt, err = decodeNSEC3Params(reader, length); err != nil {
+		return
+	}
+	var hash string
+	if hash, err = decodeCharacterString(reader, int(length)-(start-reader.Len())); err != nil {
+		return
+	}
+	if hash == "" {
+		return fmt.Errorf("NSEC3 next hashed owner must not be empty")
+	}
+	r.NextHashed = []byte(hash)
+	r.Types, err = decodeTypeBitMap(reader, int(length)-(start-reader.Len()))
+	return
+}
+
+// Encode implements DNSResource.
+func (r *DNSResourceRecordNSEC3) Encode() []byte {
+	var buf bytes.Buffer
+	encodeNSEC3Params(&buf, r.HashAlgorithm, r.Flags, r.Iterations, r.Salt)
+	buf.WriteByte(byte(len(r.NextHashed)))
+	buf.Write(r.NextHashed)
+	encodeTypeBitMap(&buf, r.Types)
+	return buf.Bytes()
+}
+
+func (r *DNSResourceRecordNSEC3) Bytes() []byte {
+	return r.WrapData(r.Encode())
+}
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordNSEC3) String() string {
+	rdata := fmt.Sprintf("%d %d %d %s %s", r.HashAlgorithm, r.Flags, r.Iterations,
+		hexString(r.Salt), base32Hex.EncodeToString(r.NextHashed))
+	if len(r.Types) > 0 {
+		rdata += " " + typeList(r.Types)
+	}
+	return r.presentation(rdata)
+}
+
+// DNSResourceRecordNSEC3PARAM represents an NSEC3PARAM record, published at
+// the zone apex to tell authoritative servers which NSEC3 chain to use.
+type DNSResourceRecordNSEC3PARAM struct {
+	DNSResourceRecord
+
+	HashAlgorithm uint8
+	Flags         uint8
+	Iterations    uint16
+	Salt          []byte
+}
+
+// Decode implements DNSResource.
+func (r *DNSResourceRecordNSEC3PARAM) Decode(reader *bytes.Reader, length uint16) (err error) {
+	r.HashAlgorithm, r.Flags, r.Iterations, r.Salt, err = decodeNSEC3Params(reader, length)
+	return
+}
+
+// Encode implements DNSResource.
+func (r *DNSResourceRecordNSEC3PARAM) Encode() []byte {
+	var buf bytes.Buffer
+	encodeNSEC3Params(&buf, r.HashAlgorithm, r.Flags, r.Iterations, r.Salt)
+	return buf.Bytes()
+}
+
+func (r *DNSResourceRecordNSEC3PARAM) Bytes() []byte {
+	return r.WrapData(r.Encode())
+}
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordNSEC3PARAM) String() string {
+	return r.presentation(fmt.Sprintf("%d %d %d %s", r.HashAlgorithm, r.Flags, r.Iterations, hexString(r.Salt)))
+}
+
+// decodeNSEC3Params reads the fields NSEC3 and NSEC3PARAM have in common.
+func decodeNSEC3Params(reader *bytes.Reader, length uint16) (alg, flags uint8, iterations uint16, salt []byte, err error) {
+	if length < 5 {
+		err = fmt.Errorf("%w: NSEC3 RDATA of %d bytes", ErrRDataLength, length)
+		return
+	}
+	fixed := make([]byte, 4)
+	if err = readFull(reader, fixed); err != nil {
+		return
+	}
+	alg, flags, iterations = fixed[0], fixed[1], binary.BigEndian.Uint16(fixed[2:])
+	var s string
+	if s, err = decodeCharacterString(reader, int(length)-4); err != nil {
+		return
+	}
+	if s != "" {
+		salt = []byte(s)
+	}
+	return alg, flags, iterations, salt, nil
+}
+
+func encodeNSEC3Params(buf *bytes.Buffer, alg, flags uint8, iterations uint16, salt []byte) {
+	buf.WriteByte(alg)
+	buf.WriteByte(flags)
+	binary.Write(buf, binary.BigEndian, iterations)
+	buf.WriteByte(byte(len(salt)))
+	buf.Write(salt)
+}
+
+// Clone returns a deep copy of the record.
+func (r *DNSResourceRecordNSEC3) Clone() DNSResource {
+	cp := *r
+	cp.Salt = cloneBytes(r.Salt)
+	cp.NextHashed = cloneBytes(r.NextHashed)
+	cp.Types = append([]DNSType(nil), r.Types...)
+	return &cp
+}
+
+// Clone returns a deep copy of the record.
+func (r *DNSResourceRecordNSEC3PARAM) Clone() DNSResource {
+	cp := *r
+	cp.Salt = cloneBytes(r.Salt)
+	return &cp
+}
@@ -13,17 +13,34 @@ type DNSResourceRecordPTR struct {
 }
 
 // Decode implements DNSResource.
-func (r *DNSResourceRecordPTR) Decode(reader *bytes.Reader, length uint16) {
-	r.PtrDomainName, _ = decodeDomainName(reader)
+func (r *DNSResourceRecordPTR) Decode(reader *bytes.Reader, length uint16) (err error) {
+	r.PtrDomainName, err = decodeDomainName(reader)
+	return
 }
 
 // Encode implements DNSResource.
 func (r *DNSResourceRecordPTR) Encode() []byte {
 	var buf bytes.Buffer
-	encodeDomainName(&buf, r.PtrDomainName, true)
+	r.EncodeCompressed(&buf, nil)
 	return buf.Bytes()
 }
 
+// EncodeCompressed implements CompressibleResource.
+func (r *DNSResourceRecordPTR) EncodeCompressed(msg *bytes.Buffer, c *Compressor) error {
+	return encodeDomainName(msg, r.PtrDomainName, c)
+}
+
 func (r *DNSResourceRecordPTR) Bytes() []byte {
 	return r.WrapData(r.Encode())
 }
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordPTR) String() string {
+	return r.presentation(fqdn(r.PtrDomainName))
+}
+
+// Clone returns a copy of the record.
+func (r *DNSResourceRecordPTR) Clone() DNSResource {
+	cp := *r
+	return &cp
+}
@@ -0,0 +1,112 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+)
+
+// RRSIG RDATA format (RFC 4034 §3.1)
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                 TYPE COVERED                  |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |       ALGORITHM       |        LABELS         |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                 ORIGINAL TTL                  |
+// |                                               |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |             SIGNATURE EXPIRATION              |
+// |                                               |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |              SIGNATURE INCEPTION              |
+// |                                               |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                    KEY TAG                    |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                 SIGNER'S NAME                 /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                   SIGNATURE                   /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+
+// rrsigFixedLength is the size of the fields before the signer's name.
+const rrsigFixedLength = 18
+
+// DNSResourceRecordRRSIG represents an RRSIG record. Expiration and
+// Inception are seconds since the epoch in serial number arithmetic
+// (RFC 1982), as on the wire.
+type DNSResourceRecordRRSIG struct {
+	DNSResourceRecord
+
+	TypeCovered DNSType
+	Algorithm   uint8
+	Labels      uint8
+	OriginalTTL uint32
+	Expiration  uint32
+	Inception   uint32
+	KeyTag      uint16
+	SignerName  string
+	Signature   []byte
+}
+
+// Decode implements DNSResource.
+func (r *DNSResourceRecordRRSIG) Decode(reader *bytes.Reader, length uint16) (err error) {
+	if length < rrsigFixedLength+1 {
+		return fmt.Errorf("%w: RRSIG RDATA of %d bytes", ErrRDataLength, length)
+	}
+	start := reader.Len()
+	fixed := make([]byte, rrsigFixedLength)
+	if err = readFull(reader, fixed); err != nil {
+		return
+	}
+	r.TypeCovered = DNSType(binary.BigEndian.Uint16(fixed[0:]))
+	r.Algorithm = fixed[2]
+	r.Labels = fixed[3]
+	r.OriginalTTL = binary.BigEndian.Uint32(fixed[4:])
+	r.Expiration = binary.BigEndian.Uint32(fixed[8:])
+	r.Inception = binary.BigEndian.Uint32(fixed[12:])
+	r.KeyTag = binary.BigEndian.Uint16(fixed[16:])
+	if r.SignerName, err = decodeDomainName(reader); err != nil {
+		return
+	}
+	remaining := int(length) - (start - reader.Len())
+	if remaining < 0 {
+		return fmt.Errorf("%w: RRSIG signer name exceeds RDATA", ErrRDataLength)
+	}
+	r.Signature = make([]byte, remaining)
+	return readFull(reader, r.Signature)
+}
+
+// Encode implements DNSResource.
+func (r *DNSResourceRecordRRSIG) Encode() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(r.TypeCovered))
+	buf.WriteByte(r.Algorithm)
+	buf.WriteByte(r.Labels)
+	binary.Write(&buf, binary.BigEndian, r.OriginalTTL)
+	binary.Write(&buf, binary.BigEndian, r.Expiration)
+	binary.Write(&buf, binary.BigEndian, r.Inception)
+	binary.Write(&buf, binary.BigEndian, r.KeyTag)
+	// RFC 4034 §3.1.7: the signer's name is never compressed
+	encodeDomainName(&buf, r.SignerName, nil)
+	buf.Write(r.Signature)
+	return buf.Bytes()
+}
+
+func (r *DNSResourceRecordRRSIG) Bytes() []byte {
+	return r.WrapData(r.Encode())
+}
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordRRSIG) String() string {
+	return r.presentation(fmt.Sprintf("%s %d %d %d %s %s %d %s %s",
+		r.TypeCovered, r.Algorithm, r.Labels, r.OriginalTTL, sigTime(r.Expiration), sigTime(r.Inception),
+		r.KeyTag, fqdn(r.SignerName), base64.StdEncoding.EncodeToString(r.Signature)))
+}
+
+// Clone returns a deep copy of the record.
+func (r *DNSResourceRecordRRSIG) Clone() DNSResource {
+	cp := *r
+	cp.Signature = cloneBytes(r.Signature)
+	return &cp
+}
@@ -3,6 +3,7 @@ package packet
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 )
 
 // SOA RDATA format
@@ -40,33 +41,60 @@ type DNSResourceRecordSOA struct {
 	Minimum uint32
 }
 
-func (d *DNSResourceRecordSOA) Decode(reader *bytes.Reader, length uint16) {
-	d.MName, _ = decodeDomainName(reader)
-	d.RName, _ = decodeDomainName(reader)
-	binary.Read(reader, binary.BigEndian, &d.Serial)
-	binary.Read(reader, binary.BigEndian, &d.Refresh)
-	binary.Read(reader, binary.BigEndian, &d.Retry)
-	binary.Read(reader, binary.BigEndian, &d.Expire)
-	binary.Read(reader, binary.BigEndian, &d.Minimum)
+func (d *DNSResourceRecordSOA) Decode(reader *bytes.Reader, length uint16) (err error) {
+	if d.MName, err = decodeDomainName(reader); err != nil {
+		return
+	}
+	if d.RName, err = decodeDomainName(reader); err != nil {
+		return
+	}
+	for _, v := range []*uint32{&d.Serial, &d.Refresh, &d.Retry, &d.Expire, &d.Minimum} {
+		if err = binary.Read(reader, binary.BigEndian, v); err != nil {
+			return
+		}
+	}
+	return
 }
 
 func (d *DNSResourceRecordSOA) Encode() []byte {
 	var buf bytes.Buffer
-	encodeDomainName(&buf, d.MName, true)
-	encodeDomainName(&buf, d.RName, true)
+	d.EncodeCompressed(&buf, nil)
+	return buf.Bytes()
+}
+
+// EncodeCompressed implements CompressibleResource.
+func (d *DNSResourceRecordSOA) EncodeCompressed(msg *bytes.Buffer, c *Compressor) error {
+	if err := encodeDomainName(msg, d.MName, c); err != nil {
+		return err
+	}
+	if err := encodeDomainName(msg, d.RName, c); err != nil {
+		return err
+	}
 	// Serial
-	binary.Write(&buf, binary.BigEndian, d.Serial)
+	binary.Write(msg, binary.BigEndian, d.Serial)
 	// Refresh
-	binary.Write(&buf, binary.BigEndian, d.Refresh)
+	binary.Write(msg, binary.BigEndian, d.Refresh)
 	// Retry
-	binary.Write(&buf, binary.BigEndian, d.Retry)
+	binary.Write(msg, binary.BigEndian, d.Retry)
 	// Expire
-	binary.Write(&buf, binary.BigEndian, d.Expire)
+	binary.Write(msg, binary.BigEndian, d.Expire)
 	// Minimum
-	binary.Write(&buf, binary.BigEndian, d.Minimum)
-	return buf.Bytes()
+	binary.Write(msg, binary.BigEndian, d.Minimum)
+	return nil
 }
 
 func (a *DNSResourceRecordSOA) Bytes() []byte {
 	return a.WrapData(a.Encode())
 }
+
+// String returns the record in presentation format.
+func (a *DNSResourceRecordSOA) String() string {
+	return a.presentation(fmt.Sprintf("%s %s %d %d %d %d %d",
+		fqdn(a.MName), fqdn(a.RName), a.Serial, a.Refresh, a.Retry, a.Expire, a.Minimum))
+}
+
+// Clone returns a copy of the record.
+func (a *DNSResourceRecordSOA) Clone() DNSResource {
+	cp := *a
+	return &cp
+}
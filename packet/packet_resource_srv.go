@@ -3,6 +3,7 @@ package packet
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 )
 
 type DNSResourceRecordSRV struct {
@@ -15,11 +16,14 @@ type DNSResourceRecordSRV struct {
 }
 
 // Decode implements DNSResource.
-func (d *DNSResourceRecordSRV) Decode(reader *bytes.Reader, length uint16) {
-	binary.Read(reader, binary.BigEndian, &d.Priority)
-	binary.Read(reader, binary.BigEndian, &d.Weight)
-	binary.Read(reader, binary.BigEndian, &d.Port)
-	d.Target, _ = decodeDomainName(reader)
+func (d *DNSResourceRecordSRV) Decode(reader *bytes.Reader, length uint16) (err error) {
+	for _, v := range []*uint16{&d.Priority, &d.Weight, &d.Port} {
+		if err = binary.Read(reader, binary.BigEndian, v); err != nil {
+			return
+		}
+	}
+	d.Target, err = decodeDomainName(reader)
+	return
 }
 
 // Encode implements DNSResource.
@@ -29,10 +33,22 @@ func (d *DNSResourceRecordSRV) Encode() []byte {
 	binary.Write(&buf, binary.BigEndian, d.Priority)
 	binary.Write(&buf, binary.BigEndian, d.Weight)
 	binary.Write(&buf, binary.BigEndian, d.Port)
-	encodeDomainName(&buf, d.Target, true)
+	// RFC 2782: name compression is not to be used for the target
+	encodeDomainName(&buf, d.Target, nil)
 	return buf.Bytes()
 }
 
 func (a *DNSResourceRecordSRV) Bytes() []byte {
 	return a.WrapData(a.Encode())
 }
+
+// String returns the record in presentation format.
+func (a *DNSResourceRecordSRV) String() string {
+	return a.presentation(fmt.Sprintf("%d %d %d %s", a.Priority, a.Weight, a.Port, fqdn(a.Target)))
+}
+
+// Clone returns a copy of the record.
+func (a *DNSResourceRecordSRV) Clone() DNSResource {
+	cp := *a
+	return &cp
+}
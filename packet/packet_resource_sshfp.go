@@ -0,0 +1,61 @@
+package packet
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// SSHFP RDATA format (RFC 4255 §3.1)
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |       ALGORITHM       |        FP TYPE        |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                  FINGERPRINT                  /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+
+// DNSResourceRecordSSHFP represents the SSHFP resource record, which
+// publishes the fingerprint of an SSH host key.
+type DNSResourceRecordSSHFP struct {
+	DNSResourceRecord
+
+	Algorithm   uint8 // 1 RSA, 2 DSA, 3 ECDSA, 4 Ed25519
+	FPType      uint8 // fingerprint type: 1 SHA-1, 2 SHA-256
+	Fingerprint []byte
+}
+
+// Decode implements DNSResource.
+func (r *DNSResourceRecordSSHFP) Decode(reader *bytes.Reader, length uint16) error {
+	if length < 2 {
+		return fmt.Errorf("%w: SSHFP RDATA of %d bytes", ErrRDataLength, length)
+	}
+	fixed := make([]byte, 2)
+	if err := readFull(reader, fixed); err != nil {
+		return err
+	}
+	r.Algorithm, r.FPType = fixed[0], fixed[1]
+	r.Fingerprint = make([]byte, length-2)
+	return readFull(reader, r.Fingerprint)
+}
+
+// Encode implements DNSResource.
+func (r *DNSResourceRecordSSHFP) Encode() []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{r.Algorithm, r.FPType})
+	buf.Write(r.Fingerprint)
+	return buf.Bytes()
+}
+
+func (r *DNSResourceRecordSSHFP) Bytes() []byte {
+	return r.WrapData(r.Encode())
+}
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordSSHFP) String() string {
+	return r.presentation(fmt.Sprintf("%d %d %s", r.Algorithm, r.FPType, hexString(r.Fingerprint)))
+}
+
+// Clone returns a deep copy of the record.
+func (r *DNSResourceRecordSSHFP) Clone() DNSResource {
+	cp := *r
+	cp.Fingerprint = cloneBytes(r.Fingerprint)
+	return &cp
+}
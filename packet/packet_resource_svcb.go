@@ -0,0 +1,399 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SVCB RDATA format (RFC 9460 §2.2)
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                  SvcPriority                  |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                  TargetName                   /
+// /                                               /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                  SvcParams                    /
+// /                                               /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//
+// Each SvcParam is a 2-byte key, a 2-byte length and the value; keys
+// appear in strictly increasing order.
+
+// SvcParamKey identifies a service parameter in an SVCB or HTTPS record.
+type SvcParamKey uint16
+
+// SvcParamKey known values (RFC 9460 §14.3.2).
+const (
+	SvcParamMandatory     SvcParamKey = 0
+	SvcParamALPN          SvcParamKey = 1
+	SvcParamNoDefaultALPN SvcParamKey = 2
+	SvcParamPort          SvcParamKey = 3
+	SvcParamIPv4Hint      SvcParamKey = 4
+	SvcParamECH           SvcParamKey = 5
+	SvcParamIPv6Hint      SvcParamKey = 6
+)
+
+var svcParamKeyNames = map[SvcParamKey]string{
+	SvcParamMandatory:     "mandatory",
+	SvcParamALPN:          "alpn",
+	SvcParamNoDefaultALPN: "no-default-alpn",
+	SvcParamPort:          "port",
+	SvcParamIPv4Hint:      "ipv4hint",
+	SvcParamECH:           "ech",
+	SvcParamIPv6Hint:      "ipv6hint",
+}
+
+// String returns the presentation name of the key, or keyNNNNN for keys
+// without a registered name.
+func (k SvcParamKey) String() string {
+	if name, ok := svcParamKeyNames[k]; ok {
+		return name
+	}
+	return "key" + strconv.Itoa(int(k))
+}
+
+// ParseSvcParamKey is the inverse of SvcParamKey.String.
+func ParseSvcParamKey(s string) (SvcParamKey, error) {
+	s = strings.ToLower(s)
+	for k, name := range svcParamKeyNames {
+		if name == s {
+			return k, nil
+		}
+	}
+	if strings.HasPrefix(s, "key") {
+		v, err := strconv.ParseUint(s[3:], 10, 16)
+		if err == nil && v != 65535 {
+			return SvcParamKey(v), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown SvcParamKey %q", s)
+}
+
+// SvcParam is a service parameter kept in wire form, used for keys
+// SvcParams has no dedicated field for.
+type SvcParam struct {
+	Key   SvcParamKey
+	Value []byte
+}
+
+// SvcParams holds the service parameters of an SVCB or HTTPS record. Zero
+// values mean "absent": a nil slice, a false flag or a zero Port are not
+// encoded.
+type SvcParams struct {
+	Mandatory     []SvcParamKey
+	ALPN          []string
+	NoDefaultALPN bool
+	Port          uint16
+	IPv4Hint      []net.IP
+	ECH           []byte
+	IPv6Hint      []net.IP
+	Other         []SvcParam
+}
+
+// ErrSvcParam is returned when a service parameter is malformed.
+var ErrSvcParam = errors.New("malformed SvcParam")
+
+// DNSResourceRecordSVCB represents an SVCB record (type 64). Priority 0 is
+// AliasMode; a Target of "." means the owner name in ServiceMode.
+type DNSResourceRecordSVCB struct {
+	DNSResourceRecord
+
+	Priority uint16
+	Target   string
+	Params   SvcParams
+}
+
+// DNSResourceRecordHTTPS represents an HTTPS record (type 65), which shares
+// the SVCB wire format.
+type DNSResourceRecordHTTPS struct {
+	DNSResourceRecordSVCB
+}
+
+// Decode implements DNSResource.
+func (d *DNSResourceRecordSVCB) Decode(reader *bytes.Reader, length uint16) (err error) {
+	start := reader.Len()
+	if err = binary.Read(reader, binary.BigEndian, &d.Priority); err != nil {
+		return
+	}
+	if d.Target, err = decodeDomainName(reader); err != nil {
+		return
+	}
+	d.Params = SvcParams{}
+	last := -1
+	for remaining := int(length) - (start - reader.Len()); remaining > 0; {
+		if remaining < 4 {
+			return fmt.Errorf("%w: %d trailing bytes after last SvcParam", ErrRDataLength, remaining)
+		}
+		var key, size uint16
+		if err = binary.Read(reader, binary.BigEndian, &key); err != nil {
+			return
+		}
+		if err = binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return
+		}
+		remaining -= 4
+		if int(key) <= last {
+			return fmt.Errorf("%w: key %d out of order", ErrSvcParam, key)
+		}
+		last = int(key)
+		if int(size) > remaining {
+			return fmt.Errorf("%w: SvcParam %d length %d exceeds RDATA", ErrRDataLength, key, size)
+		}
+		value := make([]byte, size)
+		if err = readFull(reader, value); err != nil {
+			return
+		}
+		remaining -= int(size)
+		if err = d.Params.set(SvcParamKey(key), value); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// set stores a wire-format value under key.
+func (p *SvcParams) set(key SvcParamKey, value []byte) error {
+	switch key {
+	case SvcParamMandatory:
+		if len(value) == 0 || len(value)%2 != 0 {
+			return fmt.Errorf("%w: mandatory length %d", ErrSvcParam, len(value))
+		}
+		for i := 0; i < len(value); i += 2 {
+			p.Mandatory = append(p.Mandatory, SvcParamKey(binary.BigEndian.Uint16(value[i:])))
+		}
+	case SvcParamALPN:
+		if len(value) == 0 {
+			return fmt.Errorf("%w: empty alpn", ErrSvcParam)
+		}
+		for len(value) > 0 {
+			n := int(value[0])
+			if n == 0 || n+1 > len(value) {
+				return fmt.Errorf("%w: bad alpn-id length %d", ErrSvcParam, n)
+			}
+			p.ALPN = append(p.ALPN, string(value[1:n+1]))
+			value = value[n+1:]
+		}
+	case SvcParamNoDefaultALPN:
+		if len(value) != 0 {
+			return fmt.Errorf("%w: no-default-alpn must be empty", ErrSvcParam)
+		}
+		p.NoDefaultALPN = true
+	case SvcParamPort:
+		if len(value) != 2 {
+			return fmt.Errorf("%w: port length %d", ErrSvcParam, len(value))
+		}
+		p.Port = binary.BigEndian.Uint16(value)
+	case SvcParamIPv4Hint:
+		if len(value) == 0 || len(value)%net.IPv4len != 0 {
+			return fmt.Errorf("%w: ipv4hint length %d", ErrSvcParam, len(value))
+		}
+		for i := 0; i < len(value); i += net.IPv4len {
+			p.IPv4Hint = append(p.IPv4Hint, net.IP(append([]byte{}, value[i:i+net.IPv4len]...)))
+		}
+	case SvcParamECH:
+		p.ECH = value
+	case SvcParamIPv6Hint:
+		if len(value) == 0 || len(value)%net.IPv6len != 0 {
+			return fmt.Errorf("%w: ipv6hint length %d", ErrSvcParam, len(value))
+		}
+		for i := 0; i < len(value); i += net.IPv6len {
+			p.IPv6Hint = append(p.IPv6Hint, net.IP(append([]byte{}, value[i:i+net.IPv6len]...)))
+		}
+	default:
+		p.Other = append(p.Other, SvcParam{Key: key, Value: value})
+	}
+	return nil
+}
+
+// List returns the parameters in wire form, sorted by key as the wire
+// format requires.
+func (p *SvcParams) List() []SvcParam {
+	var out []SvcParam
+	if len(p.Mandatory) > 0 {
+		var buf bytes.Buffer
+		keys := append([]SvcParamKey{}, p.Mandatory...)
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		for _, k := range keys {
+			binary.Write(&buf, binary.BigEndian, uint16(k))
+		}
+		out = append(out, SvcParam{Key: SvcParamMandatory, Value: buf.Bytes()})
+	}
+	if len(p.ALPN) > 0 {
+		var buf bytes.Buffer
+		for _, id := range p.ALPN {
+			buf.WriteByte(byte(len(id)))
+			buf.WriteString(id)
+		}
+		out = append(out, SvcParam{Key: SvcParamALPN, Value: buf.Bytes()})
+	}
+	if p.NoDefaultALPN {
+		out = append(out, SvcParam{Key: SvcParamNoDefaultALPN, Value: []byte{}})
+	}
+	if p.Port != 0 {
+		value := make([]byte, 2)
+		binary.BigEndian.PutUint16(value, p.Port)
+		out = append(out, SvcParam{Key: SvcParamPort, Value: value})
+	}
+	if len(p.IPv4Hint) > 0 {
+		var buf bytes.Buffer
+		for _, ip := range p.IPv4Hint {
+			buf.Write(ip.To4())
+		}
+		out = append(out, SvcParam{Key: SvcParamIPv4Hint, Value: buf.Bytes()})
+	}
+	if p.ECH != nil {
+		out = append(out, SvcParam{Key: SvcParamECH, Value: p.ECH})
+	}
+	if len(p.IPv6Hint) > 0 {
+		var buf bytes.Buffer
+		for _, ip := range p.IPv6Hint {
+			buf.Write(ip.To16())
+		}
+		out = append(out, SvcParam{Key: SvcParamIPv6Hint, Value: buf.Bytes()})
+	}
+	out = append(out, p.Other...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// Encode implements DNSResource.
+func (d *DNSResourceRecordSVCB) Encode() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, d.Priority)
+	// RFC 9460 §2.2: TargetName is never compressed
+	encodeDomainName(&buf, d.Target, nil)
+	for _, param := range d.Params.List() {
+		binary.Write(&buf, binary.BigEndian, uint16(param.Key))
+		binary.Write(&buf, binary.BigEndian, uint16(len(param.Value)))
+		buf.Write(param.Value)
+	}
+	return buf.Bytes()
+}
+
+func (d *DNSResourceRecordSVCB) Bytes() []byte {
+	return d.WrapData(d.Encode())
+}
+
+// String returns the record in presentation format (RFC 9460 §2.1).
+func (d *DNSResourceRecordSVCB) String() string {
+	rdata := fmt.Sprintf("%d %s", d.Priority, fqdn(d.Target))
+	if params := d.Params.String(); params != "" {
+		rdata += " " + params
+	}
+	return d.presentation(rdata)
+}
+
+// String renders the parameters as space-separated key=value pairs in key
+// order, in the form the zone parser accepts.
+func (p *SvcParams) String() string {
+	var fields []string
+	for _, param := range p.List() {
+		var value string
+		switch param.Key {
+		case SvcParamMandatory:
+			keys := make([]string, 0, len(param.Value)/2)
+			for i := 0; i+1 < len(param.Value); i += 2 {
+				keys = append(keys, SvcParamKey(binary.BigEndian.Uint16(param.Value[i:])).String())
+			}
+			value = strings.Join(keys, ",")
+		case SvcParamALPN:
+			ids := make([]string, len(p.ALPN))
+			for i, id := range p.ALPN {
+				ids[i] = escapeALPN(id)
+			}
+			value = strings.Join(ids, ",")
+		case SvcParamNoDefaultALPN:
+			fields = append(fields, param.Key.String())
+			continue
+		case SvcParamPort:
+			value = strconv.Itoa(int(p.Port))
+		case SvcParamIPv4Hint, SvcParamIPv6Hint:
+			hints := p.IPv4Hint
+			if param.Key == SvcParamIPv6Hint {
+				hints = p.IPv6Hint
+			}
+			ips := make([]string, len(hints))
+			for i, ip := range hints {
+				ips[i] = ip.String()
+			}
+			value = strings.Join(ips, ",")
+		case SvcParamECH:
+			value = base64.StdEncoding.EncodeToString(param.Value)
+		default:
+			if len(param.Value) == 0 {
+				fields = append(fields, param.Key.String())
+				continue
+			}
+			value = quoteString(string(param.Value))
+		}
+		fields = append(fields, param.Key.String()+"="+value)
+	}
+	return strings.Join(fields, " ")
+}
+
+// escapeALPN escapes an alpn-id for use in a comma-separated value list
+// (RFC 9460 Appendix A.1).
+func escapeALPN(id string) string {
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c == ',' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c <= ' ' || c == '"' || c > 0x7e || c == ';' || c == '(' || c == ')':
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Clone returns a deep copy of the record.
+func (d *DNSResourceRecordSVCB) Clone() DNSResource {
+	cp := *d
+	cp.Params = d.Params.clone()
+	return &cp
+}
+
+// Clone returns a deep copy of the record.
+func (d *DNSResourceRecordHTTPS) Clone() DNSResource {
+	cp := *d
+	cp.Params = d.Params.clone()
+	return &cp
+}
+
+func (p SvcParams) clone() SvcParams {
+	cp := p
+	cp.Mandatory = append([]SvcParamKey(nil), p.Mandatory...)
+	cp.ALPN = append([]string(nil), p.ALPN...)
+	cp.IPv4Hint = cloneIPs(p.IPv4Hint)
+	cp.ECH = cloneBytes(p.ECH)
+	cp.IPv6Hint = cloneIPs(p.IPv6Hint)
+	if p.Other != nil {
+		cp.Other = make([]SvcParam, len(p.Other))
+		for i, o := range p.Other {
+			cp.Other[i] = SvcParam{Key: o.Key, Value: cloneBytes(o.Value)}
+		}
+	}
+	return cp
+}
+
+func cloneIPs(ips []net.IP) []net.IP {
+	if ips == nil {
+		return nil
+	}
+	out := make([]net.IP, len(ips))
+	for i, ip := range ips {
+		out[i] = net.IP(cloneBytes(ip))
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package packet
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// TLSA RDATA format (RFC 6698 §2.1)
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |      CERT USAGE       |       SELECTOR        |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |     MATCHING TYPE     |                       /
+// +--+--+--+--+--+--+--+--+                       /
+// /        CERTIFICATE ASSOCIATION DATA           /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+
+// DNSResourceRecordTLSA represents the TLSA resource record, which binds a
+// TLS server certificate or public key to a service name for DANE.
+type DNSResourceRecordTLSA struct {
+	DNSResourceRecord
+
+	Usage        uint8  // 0 PKIX-TA, 1 PKIX-EE, 2 DANE-TA, 3 DANE-EE
+	Selector     uint8  // 0 full certificate, 1 SubjectPublicKeyInfo
+	MatchingType uint8  // 0 exact, 1 SHA-256, 2 SHA-512
+	Certificate  []byte // certificate association data
+}
+
+// Decode implements DNSResource.
+func (r *DNSResourceRecordTLSA) Decode(reader *bytes.Reader, length uint16) error {
+	if length < 3 {
+		return fmt.Errorf("%w: TLSA RDATA of %d bytes", ErrRDataLength, length)
+	}
+	fixed := make([]byte, 3)
+	if err := readFull(reader, fixed); err != nil {
+		return err
+	}
+	r.Usage, r.Selector, r.MatchingType = fixed[0], fixed[1], fixed[2]
+	r.Certificate = make([]byte, length-3)
+	return readFull(reader, r.Certificate)
+}
+
+// Encode implements DNSResource.
+func (r *DNSResourceRecordTLSA) Encode() []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{r.Usage, r.Selector, r.MatchingType})
+	buf.Write(r.Certificate)
+	return buf.Bytes()
+}
+
+func (r *DNSResourceRecordTLSA) Bytes() []byte {
+	return r.WrapData(r.Encode())
+}
+
+// String returns the record in presentation format.
+func (r *DNSResourceRecordTLSA) String() string {
+	return r.presentation(fmt.Sprintf("%d %d %d %s", r.Usage, r.Selector, r.MatchingType, hexString(r.Certificate)))
+}
+
+// Clone returns a deep copy of the record.
+func (r *DNSResourceRecordTLSA) Clone() DNSResource {
+	cp := *r
+	cp.Certificate = cloneBytes(r.Certificate)
+	return &cp
+}
@@ -0,0 +1,122 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+)
+
+// TSIG RDATA format (RFC 8945 §4.2)
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                 ALGORITHM NAME                /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                  TIME SIGNED                  |
+// |                  (48 bits)                    |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                     FUDGE                     |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                  MAC SIZE                     |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                      MAC                      /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                  ORIGINAL ID                  |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                     ERROR                     |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// |                  OTHER LEN                    |
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+// /                  OTHER DATA                   /
+// +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+
+// DNSResourceRecordTSIG represents a TSIG meta-record. The owner name is the
+// key name, the class is ANY and the TTL is zero. TimeSigned is seconds
+// since the epoch and only its low 48 bits go on the wire. Error holds the
+// TSIG error (BADSIG, BADKEY, BADTIME or BADTRUNC), which is separate from
+// the message RCODE.
+type DNSResourceRecordTSIG struct {
+	DNSResourceRecord
+
+	Algorithm  string
+	TimeSigned uint64
+	Fudge      uint16
+	MAC        []byte
+	OriginalID uint16
+	Error      DNSRCode
+	OtherData  []byte
+}
+
+// Decode implements DNSResource.
+func (r *DNSResourceRecordTSIG) Decode(reader *bytes.Reader, length uint16) (err error) {
+	start := reader.Len()
+	if r.Algorithm, err = decodeDomainName(reader); err != nil {
+		return
+	}
+	fixed := make([]byte, 10)
+	if err = readFull(reader, fixed); err != nil {
+		return
+	}
+	r.TimeSigned = uint64(binary.BigEndian.Uint16(fixed[0:]))<<32 | uint64(binary.BigEndian.Uint32(fixed[2:]))
+	r.Fudge = binary.BigEndian.Uint16(fixed[6:])
+	macSize := int(binary.BigEndian.Uint16(fixed[8:]))
+	if macSize > int(length)-(start-reader.Len()) {
+		return fmt.Errorf("%w: TSIG MAC of %d bytes exceeds RDATA", ErrRDataLength, macSize)
+	}
+	r.MAC = make([]byte, macSize)
+	if err = readFull(reader, r.MAC); err != nil {
+		return
+	}
+	if err = readFull(reader, fixed[:6]); err != nil {
+		return
+	}
+	r.OriginalID = binary.BigEndian.Uint16(fixed[0:])
+	r.Error = DNSRCode(binary.BigEndian.Uint16(fixed[2:]))
+	otherLen := int(binary.BigEndian.Uint16(fixed[4:]))
+	if otherLen > int(length)-(start-reader.Len()) {
+		return fmt.Errorf("%w: TSIG other data of %d bytes exceeds RDATA", ErrRDataLength, otherLen)
+	}
+	r.OtherData = make([]byte, otherLen)
+	return readFull(reader, r.OtherData)
+}
+
+// Encode implements DNSResource.
+func (r *DNSResourceRecordTSIG) Encode() []byte {
+	var buf bytes.Buffer
+	// RFC 8945 §4.2: the algorithm name is never compressed
+	encodeDomainName(&buf, r.Algorithm, nil)
+	var fixed [10]byte
+	binary.BigEndian.PutUint16(fixed[0:], uint16(r.TimeSigned>>32))
+	binary.BigEndian.PutUint32(fixed[2:], uint32(r.TimeSigned))
+	binary.BigEndian.PutUint16(fixed[6:], r.Fudge)
+	binary.BigEndian.PutUint16(fixed[8:], uint16(len(r.MAC)))
+	buf.Write(fixed[:])
+	buf.Write(r.MAC)
+	binary.BigEndian.PutUint16(fixed[0:], r.OriginalID)
+	binary.BigEndian.PutUint16(fixed[2:], uint16(r.Error))
+	binary.BigEndian.PutUint16(fixed[4:], uint16(len(r.OtherData)))
+	buf.Write(fixed[:6])
+	buf.Write(r.OtherData)
+	return buf.Bytes()
+}
+
+func (r *DNSResourceRecordTSIG) Bytes() []byte {
+	return r.WrapData(r.Encode())
+}
+
+// String returns the record in the form dig prints it.
+func (r *DNSResourceRecordTSIG) String() string {
+	s := fmt.Sprintf("%s %d %d %d %s %d %s %d", fqdn(r.Algorithm), r.TimeSigned, r.Fudge,
+		len(r.MAC), base64.StdEncoding.EncodeToString(r.MAC), r.OriginalID, tsigErrorName(r.Error), len(r.OtherData))
+	if len(r.OtherData) > 0 {
+		s += " " + base64.StdEncoding.EncodeToString(r.OtherData)
+	}
+	return r.presentation(s)
+}
+
+// Clone returns a deep copy of the record.
+func (r *DNSResourceRecordTSIG) Clone() DNSResource {
+	cp := *r
+	cp.MAC = cloneBytes(r.MAC)
+	cp.OtherData = cloneBytes(r.OtherData)
+	return &cp
+}
@@ -2,29 +2,99 @@ package packet
 
 import (
 	"bytes"
+	"strings"
 )
 
+// maxCharacterString is the longest <character-string> RFC 1035 §3.3 allows:
+// a single length octet followed by up to 255 bytes.
+const maxCharacterString = 255
+
+// DNSResourceRecordTXT represents a TXT record, or an SPF record (type 99,
+// RFC 7208 §3.1) which shares its wire format. RDATA is one or more
+// <character-string>s; each element of Text is one of them.
 type DNSResourceRecordTXT struct {
 	DNSResourceRecord
 
-	Content string
+	Text []string
+}
+
+// Value returns the strings concatenated without separators, which is how
+// SPF and DKIM consumers interpret a record split across several strings.
+func (d *DNSResourceRecordTXT) Value() string {
+	return strings.Join(d.Text, "")
+}
+
+// SetValue replaces Text with value split into 255-byte character-strings.
+func (d *DNSResourceRecordTXT) SetValue(value string) {
+	d.Text = SplitTXT(value)
+}
+
+// SplitTXT splits value into chunks that each fit in one
+// <character-string>. An empty value yields a single empty string.
+func SplitTXT(value string) []string {
+	if len(value) <= maxCharacterString {
+		return []string{value}
+	}
+	var out []string
+	for len(value) > maxCharacterString {
+		out = append(out, value[:maxCharacterString])
+		value = value[maxCharacterString:]
+	}
+	if value != "" {
+		out = append(out, value)
+	}
+	return out
 }
 
 // Decode implements DNSResource.
-func (d *DNSResourceRecordTXT) Decode(reader *bytes.Reader, length uint16) {
-	data := make([]byte, length)
-	reader.Read(data)
-	d.Content = string(data)
+func (d *DNSResourceRecordTXT) Decode(reader *bytes.Reader, length uint16) error {
+	d.Text = nil
+	for remaining := int(length); remaining > 0; {
+		text, err := decodeCharacterString(reader, remaining)
+		if err != nil {
+			return err
+		}
+		remaining -= 1 + len(text)
+		d.Text = append(d.Text, text)
+	}
+	return nil
 }
 
-// Encode implements DNSResource.
-// Subtle: this method shadows the method (DNSResourceRecord).Encode of DNSResourceRecordTXT.DNSResourceRecord.
+// Encode implements DNSResource. Strings longer than 255 bytes are split
+// across consecutive character-strings; a record without any text encodes
+// as a single empty string, since RDATA must hold at least one.
 func (d *DNSResourceRecordTXT) Encode() []byte {
 	var buf bytes.Buffer
-	buf.Write([]byte(d.Content))
+	if len(d.Text) == 0 {
+		buf.WriteByte(0)
+	}
+	for _, text := range d.Text {
+		for _, s := range SplitTXT(text) {
+			encodeCharacterString(&buf, s)
+		}
+	}
 	return buf.Bytes()
 }
 
 func (a *DNSResourceRecordTXT) Bytes() []byte {
 	return a.WrapData(a.Encode())
 }
+
+// String returns the record in presentation format.
+func (a *DNSResourceRecordTXT) String() string {
+	quoted := make([]string, len(a.Text))
+	for i, s := range a.Text {
+		quoted[i] = quoteString(s)
+	}
+	if len(quoted) == 0 {
+		quoted = append(quoted, `""`)
+	}
+	return a.presentation(strings.Join(quoted, " "))
+}
+
+// Clone returns a deep copy of the record.
+func (a *DNSResourceRecordTXT) Clone() DNSResource {
+	cp := *a
+	cp.Text = append([]string(nil), a.Text...)
+	return &cp
+}
@@ -2,7 +2,8 @@ package packet
 
 import (
 	"bytes"
-	"io"
+	"encoding/hex"
+	"fmt"
 )
 
 // DNSResourceRecordUnknown represents an unknown or unsupported resource record type.
@@ -12,13 +13,10 @@ type DNSResourceRecordUnknown struct {
 	RData []byte
 }
 
-func (r *DNSResourceRecordUnknown) Decode(reader *bytes.Reader, length uint16) {
+func (r *DNSResourceRecordUnknown) Decode(reader *bytes.Reader, length uint16) error {
 	// Read RDATA bytes
 	r.RData = make([]byte, length)
-	_, err := io.ReadFull(reader, r.RData)
-	if err != nil {
-		// Ignore error - RDATA may be partially read
-	}
+	return readFull(reader, r.RData)
 }
 
 func (r *DNSResourceRecordUnknown) Encode() []byte {
@@ -28,3 +26,21 @@ func (r *DNSResourceRecordUnknown) Encode() []byte {
 func (r *DNSResourceRecordUnknown) Bytes() []byte {
 	return r.WrapData(r.RData)
 }
+
+// String formats the record, tab-separated, in the generic presentation
+// form of RFC 3597 §5 (example.com. 300 IN TYPE65280 \# 4 0a000001),
+// which the zone parser reads back.
+func (r *DNSResourceRecordUnknown) String() string {
+	rdata := fmt.Sprintf("\\# %d", len(r.RData))
+	if len(r.RData) > 0 {
+		rdata += " " + hex.EncodeToString(r.RData)
+	}
+	return r.presentation(rdata)
+}
+
+// Clone returns a deep copy of the record.
+func (r *DNSResourceRecordUnknown) Clone() DNSResource {
+	cp := *r
+	cp.RData = cloneBytes(r.RData)
+	return &cp
+}
@@ -2,9 +2,17 @@ package packet
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEncodeDecodeDNSHeader(t *testing.T) {
@@ -290,6 +298,108 @@ func TestEDNSOptionPadding(t *testing.T) {
 	}
 }
 
+func TestEDNSOptionClientSubnetPrefix(t *testing.T) {
+	tests := []struct {
+		addr   string
+		prefix uint8
+		data   []byte
+		want   string
+	}{
+		{"192.168.1.100", 20, []byte{0, 1, 20, 0, 192, 168, 0}, "192.168.0.0"},
+		{"192.168.1.100", 32, []byte{0, 1, 32, 0, 192, 168, 1, 100}, "192.168.1.100"},
+		{"2001:db8:1234:5678::1", 56, []byte{0, 2, 56, 0, 0x20, 0x01, 0x0d, 0xb8, 0x12, 0x34, 0x56}, "2001:db8:1234:5600::"},
+		{"2001:db8::1", 0, []byte{0, 2, 0, 0}, "::"},
+	}
+	for _, tt := range tests {
+		edns := NewEDNSRecord(4096)
+		edns.AddEDNSOptionClientSubnet(net.ParseIP(tt.addr), tt.prefix)
+		if !bytes.Equal(edns.Options[0].Data, tt.data) {
+			t.Errorf("%s/%d: expected %v, got %v", tt.addr, tt.prefix, tt.data, edns.Options[0].Data)
+		}
+		ecs, err := edns.ClientSubnet()
+		if err != nil {
+			t.Fatalf("%s/%d: %v", tt.addr, tt.prefix, err)
+		}
+		if ecs.SourcePrefix != tt.prefix || ecs.Address.String() != tt.want {
+			t.Errorf("%s/%d: decoded %s/%d", tt.addr, tt.prefix, ecs.Address, ecs.SourcePrefix)
+		}
+	}
+}
+
+func TestEDNSTypedOptions(t *testing.T) {
+	edns := NewEDNSRecord(1232)
+	if ecs, err := edns.ClientSubnet(); ecs != nil || err != nil {
+		t.Errorf("absent option should decode to nil, got %v, %v", ecs, err)
+	}
+	edns.AddEDNSOptionCookie([]byte("clientck"), []byte("server-cookie-16"))
+	edns.AddEDNSOptionNSID([]byte("ns1"))
+	edns.AddEDNSOptionTCPKeepalive(30 * time.Second)
+	edns.AddEDNSOption(EDNSOptionExpire, []byte{0, 0, 0x0e, 0x10})
+	edns.AddEDNSOptionPadding(12)
+	edns.AddEDNSOptionExtendedError(EDECodeBlocked, "blocked by policy")
+	edns.AddEDNSOptionExtendedError(EDECodeStaleAnswer, "")
+
+	pkt := NewPacket()
+	pkt.AddAdditional(edns)
+	decoded, err := FromBytes(pkt.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := decoded.Additionals[0].(*DNSResourceRecordEDNS)
+
+	cookie, err := opt.Cookie()
+	if err != nil || string(cookie.Client) != "clientck" || string(cookie.Server) != "server-cookie-16" {
+		t.Errorf("cookie: %+v, %v", cookie, err)
+	}
+	if nsid, ok := opt.NSID(); !ok || string(nsid) != "ns1" {
+		t.Errorf("nsid: %q, %v", nsid, ok)
+	}
+	if ka, err := opt.TCPKeepalive(); err != nil || !ka.HasTimeout || ka.Timeout != 30*time.Second {
+		t.Errorf("keepalive: %+v, %v", ka, err)
+	}
+	if exp, err := opt.Expire(); err != nil || !exp.HasExpire || exp.Expire != 3600 {
+		t.Errorf("expire: %+v, %v", exp, err)
+	}
+	if n, ok := opt.Padding(); !ok || n != 12 {
+		t.Errorf("padding: %d, %v", n, ok)
+	}
+	edes, err := opt.ExtendedErrors()
+	if err != nil || len(edes) != 2 {
+		t.Fatalf("extended errors: %v, %v", edes, err)
+	}
+	if edes[0].InfoCode != EDECodeBlocked || edes[0].ExtraText != "blocked by policy" || edes[1].InfoCode != EDECodeStaleAnswer {
+		t.Errorf("unexpected extended errors: %v %v", edes[0], edes[1])
+	}
+	if got := edes[0].String(); got != "15 (Blocked): blocked by policy" {
+		t.Errorf("unexpected EDE string %q", got)
+	}
+	if !strings.Contains(opt.String(), "; EDE: 15 (Blocked): blocked by policy") {
+		t.Errorf("EDE missing from OPT presentation:\n%s", opt)
+	}
+}
+
+func TestEDNSOptionMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) error
+		data  []byte
+	}{
+		{"subnet short", func(b []byte) error { _, err := ParseClientSubnet(b); return err }, []byte{0, 1, 24}},
+		{"subnet family", func(b []byte) error { _, err := ParseClientSubnet(b); return err }, []byte{0, 3, 0, 0}},
+		{"subnet prefix", func(b []byte) error { _, err := ParseClientSubnet(b); return err }, []byte{0, 1, 33, 0, 1, 2, 3, 4, 5}},
+		{"subnet address length", func(b []byte) error { _, err := ParseClientSubnet(b); return err }, []byte{0, 1, 24, 0, 10, 0, 0, 0}},
+		{"cookie", func(b []byte) error { _, err := ParseCookie(b); return err }, make([]byte, 12)},
+		{"keepalive", func(b []byte) error { _, err := ParseTCPKeepalive(b); return err }, []byte{1}},
+		{"expire", func(b []byte) error { _, err := ParseExpire(b); return err }, []byte{1, 2}},
+		{"extended error", func(b []byte) error { _, err := ParseExtendedError(b); return err }, []byte{15}},
+	}
+	for _, tt := range tests {
+		if err := tt.parse(tt.data); !errors.Is(err, ErrEDNSOption) {
+			t.Errorf("%s: expected ErrEDNSOption, got %v", tt.name, err)
+		}
+	}
+}
+
 func TestAddAdditionalEDNS(t *testing.T) {
 	pkt := NewPacket()
 	pkt.AddAdditionalEDNS(4096, 0, 0, true)
@@ -311,3 +421,1556 @@ func TestAddAdditionalEDNS(t *testing.T) {
 		t.Error("Expected DNSSEC OK flag to be set")
 	}
 }
+
+func TestPacketNameCompression(t *testing.T) {
+	pkt := NewPacket()
+	pkt.AddQuestionA("www.example.com")
+	pkt.AddAnswer(&DNSResourceRecordCNAME{
+		DNSResourceRecord: DNSResourceRecord{Name: "www.example.com", Type: DNSTypeCNAME, Class: DNSClassIN, TTL: 300},
+		Domain:            "cdn.example.com.",
+	})
+	pkt.AddAnswer(&DNSResourceRecordA{
+		DNSResourceRecord: DNSResourceRecord{Name: "cdn.example.com", Type: DNSTypeA, Class: DNSClassIN, TTL: 300},
+		Address:           "192.0.2.1",
+	})
+	pkt.AddAuthority(&DNSResourceRecordSOA{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeSOA, Class: DNSClassIN, TTL: 300},
+		MName:             "ns1.example.com",
+		RName:             "hostmaster.example.com",
+		Serial:            1,
+	})
+	pkt.AddAdditional(&DNSResourceRecordMX{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeMX, Class: DNSClassIN, TTL: 300},
+		Preference:        10,
+		Exchange:          "mail.example.com",
+	})
+
+	compressed := mustPack(t, pkt, true)
+	full := mustPack(t, pkt, false)
+	if len(compressed) >= len(full) {
+		t.Fatalf("expected compression to shrink the message: %d >= %d", len(compressed), len(full))
+	}
+	if !bytes.Equal(pkt.Bytes(), compressed) {
+		t.Error("Bytes() should compress by default")
+	}
+
+	for _, data := range [][]byte{compressed, full} {
+		decoded, err := FromBytes(data)
+		if err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if decoded.Questions[0].Name != "www.example.com" {
+			t.Errorf("question name mismatch: %q", decoded.Questions[0].Name)
+		}
+		cname := decoded.Answers[0].(*DNSResourceRecordCNAME)
+		if cname.Name != "www.example.com" || cname.Domain != "cdn.example.com" {
+			t.Errorf("CNAME mismatch: %s -> %s", cname.Name, cname.Domain)
+		}
+		if a := decoded.Answers[1].(*DNSResourceRecordA); a.Name != "cdn.example.com" {
+			t.Errorf("A owner mismatch: %q", a.Name)
+		}
+		soa := decoded.Authorities[0].(*DNSResourceRecordSOA)
+		if soa.MName != "ns1.example.com" || soa.RName != "hostmaster.example.com" || soa.Serial != 1 {
+			t.Errorf("SOA mismatch: %+v", soa)
+		}
+		mx := decoded.Additionals[0].(*DNSResourceRecordMX)
+		if mx.Preference != 10 || mx.Exchange != "mail.example.com" {
+			t.Errorf("MX mismatch: %+v", mx)
+		}
+	}
+}
+
+func TestPacketNameCompressionSkipsSRVTarget(t *testing.T) {
+	pkt := NewPacket()
+	pkt.AddQuestionSRV("_sip._tcp.example.com")
+	pkt.AddAnswer(&DNSResourceRecordSRV{
+		DNSResourceRecord: DNSResourceRecord{Name: "_sip._tcp.example.com", Type: DNSTypeSRV, Class: DNSClassIN, TTL: 300},
+		Port:              5060,
+		Target:            "sip.example.com",
+	})
+	data := pkt.Bytes()
+	if !bytes.Contains(data, []byte("\x03sip\x07example\x03com\x00")) {
+		t.Error("SRV target should be written uncompressed")
+	}
+	decoded, err := FromBytes(data)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if srv := decoded.Answers[0].(*DNSResourceRecordSRV); srv.Target != "sip.example.com" {
+		t.Errorf("SRV target mismatch: %q", srv.Target)
+	}
+}
+
+// withLastRDLength rewrites the RDLENGTH of the record whose RDATA ends at the
+// end of data (the last record in the message) and appends extra bytes.
+func withLastRDLength(data []byte, rdataLen int, extra []byte) []byte {
+	out := append([]byte{}, data...)
+	out = append(out, extra...)
+	at := len(data) - rdataLen - 2
+	binary.BigEndian.PutUint16(out[at:], uint16(rdataLen+len(extra)))
+	return out
+}
+
+// decoders runs a test case through both FromBytes and Unpack, which must
+// accept and reject the same messages.
+var decoders = []func([]byte) error{
+	func(data []byte) error { _, err := FromBytes(data); return err },
+	func(data []byte) error { return new(DNSPacket).Unpack(data) },
+}
+
+func TestFromBytesMalformedRecords(t *testing.T) {
+	a := NewPacket()
+	a.AddAnswer(&DNSResourceRecordA{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeA, Class: DNSClassIN, TTL: 300},
+		Address:           "192.0.2.1",
+	})
+	aData := a.Bytes()
+
+	mx := NewPacket()
+	mx.AddAuthority(&DNSResourceRecordMX{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeMX, Class: DNSClassIN, TTL: 300},
+		Preference:        10,
+		Exchange:          "mail.example.com",
+	})
+	mxData := mustPack(t, mx, false)
+
+	edns := NewPacket()
+	edns.AddAdditional(NewEDNSRecord(4096))
+	edns.AddAdditional(NewEDNSRecord(4096))
+	ednsData := edns.Bytes()
+	// first OPT announces a 4-byte option header claiming 8 bytes of data
+	badOpt := append([]byte{}, ednsData[:12+1+2+2+4]...)
+	badOpt = append(badOpt, 0, 4, 0, 10, 0, 8)
+	badOpt = append(badOpt, ednsData[12+11:]...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		section string
+		index   int
+	}{
+		{"truncated header", aData[:7], SectionHeader, 0},
+		{"truncated A", aData[:len(aData)-2], SectionAnswer, 0},
+		{"A with 5-byte RDATA", withLastRDLength(aData, 4, []byte{9}), SectionAnswer, 0},
+		{"MX with unread RDATA", withLastRDLength(mxData, 2+18, []byte{0, 0}), SectionAuthority, 0},
+		{"EDNS option past RDLENGTH", badOpt, SectionAdditional, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, decode := range decoders {
+				err := decode(tt.data)
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				var perr *ParseError
+				if !errors.As(err, &perr) {
+					t.Fatalf("expected *ParseError, got %T: %v", err, err)
+				}
+				if perr.Section != tt.section || perr.Index != tt.index {
+					t.Errorf("expected %s #%d, got %s #%d (%v)", tt.section, tt.index, perr.Section, perr.Index, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFromBytesRDataLengthMismatch(t *testing.T) {
+	pkt := NewPacket()
+	pkt.AddAnswer(&DNSResourceRecordA{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeA, Class: DNSClassIN, TTL: 300},
+		Address:           "192.0.2.1",
+	})
+	pkt.AddAnswer(&DNSResourceRecordNS{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeNS, Class: DNSClassIN, TTL: 300},
+		NameServer:        "ns1.example.com",
+	})
+	data := withLastRDLength(mustPack(t, pkt, false), 17, []byte{0xff})
+	_, err := FromBytes(data)
+	if !errors.Is(err, ErrRDataLength) {
+		t.Fatalf("expected ErrRDataLength, got %v", err)
+	}
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Section != SectionAnswer || perr.Index != 1 {
+		t.Errorf("expected answer #1 to be reported, got %v", err)
+	}
+}
+
+// questionWithName builds a one-question message whose QNAME is raw.
+func questionWithName(raw []byte) []byte {
+	data := []byte{0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	data = append(data, raw...)
+	return append(data, 0x00, 0x01, 0x00, 0x01)
+}
+
+func TestDecodeDomainNameHostilePointers(t *testing.T) {
+	long := bytes.Repeat([]byte("\x3f"+string(bytes.Repeat([]byte("a"), 63))), 4)
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"self pointer", questionWithName([]byte{0xc0, 0x0c}), ErrPointerForward},
+		{"forward pointer", questionWithName([]byte{0xc0, 0x20}), ErrPointerForward},
+		{"pointer loop", questionWithName([]byte{0x01, 'a', 0xc0, 0x0c}), ErrPointerForward},
+		{"name too long", questionWithName(append(long, 0x00)), ErrNameTooLong},
+		{"extended label", questionWithName([]byte{0x41, 0x00}), ErrLabelTypeUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, decode := range decoders {
+				if err := decode(tt.data); !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeDomainNamePointerChain(t *testing.T) {
+	// www -> example.com via two backward pointers
+	data := []byte{0x12, 0x34, 0x81, 0x80, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	data = append(data, "\x07example\x03com\x00\x00\x01\x00\x01"...) // offset 12
+	data = append(data, "\x03www\xc0\x0c\x00\x01\x00\x01"...)        // offset 29
+	pkt, err := FromBytes(data)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if pkt.Questions[1].Name != "www.example.com" {
+		t.Errorf("expected www.example.com, got %q", pkt.Questions[1].Name)
+	}
+	if pkt.Questions[1].Type != DNSTypeA {
+		t.Errorf("reader not restored after pointer, type=%d", pkt.Questions[1].Type)
+	}
+}
+
+func TestDomainNameEscapes(t *testing.T) {
+	raw := []byte("\x03a.b\x06sp ace\x02\x00\xff\x0aback\\slash\x03com\x00")
+	want := `a\.b.sp\032ace.\000\255.back\\slash.com`
+	data := questionWithName(raw)
+	for _, decode := range []func([]byte) (*DNSPacket, error){
+		FromBytes,
+		func(data []byte) (*DNSPacket, error) {
+			p := &DNSPacket{}
+			return p, p.Unpack(data)
+		},
+	} {
+		p, err := decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Questions[0].Name != want {
+			t.Errorf("expected %s, got %s", want, p.Questions[0].Name)
+		}
+		if got := mustPack(t, p, false); !bytes.Equal(got, data) {
+			t.Errorf("round trip changed the name:\n got % x\nwant % x", got, data)
+		}
+	}
+
+	// \X and \DDD escapes of ordinary bytes encode the same labels
+	var b bytes.Buffer
+	encodeDomainName(&b, `\097\.b.c\om.`, nil)
+	if got := b.String(); got != "\x03a.b\x03com\x00" {
+		t.Errorf("unexpected wire form %q", got)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	label63 := strings.Repeat("a", 63)
+	long := strings.Repeat(label63+".", 4)
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"", nil},
+		{".", nil},
+		{"example.com.", nil},
+		{`a\.b.example.com`, nil},
+		{label63 + ".com", nil},
+		{strings.Repeat(`\000`, 63), nil},
+		{strings.Repeat("a", 64) + ".com", ErrLabelTooLong},
+		{strings.Repeat(`\000`, 64), ErrLabelTooLong},
+		{long, ErrNameTooLong},
+		{"a..b", ErrEmptyLabel},
+		{".com", ErrEmptyLabel},
+		{"com..", ErrEmptyLabel},
+		{`a\25`, ErrNameEscape},
+		{`a\256`, ErrNameEscape},
+		{`a\`, ErrNameEscape},
+	}
+	for _, tt := range tests {
+		if err := ValidateName(tt.name); !errors.Is(err, tt.err) {
+			t.Errorf("ValidateName(%q) = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+// mustPack encodes p, failing the test if it can't be encoded.
+func mustPack(t testing.TB, p *DNSPacket, compress bool) []byte {
+	t.Helper()
+	data, err := p.Pack(compress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestPackRejectsInvalidNames(t *testing.T) {
+	long := strings.Repeat("a", 64) + ".com"
+	huge := strings.Repeat(strings.Repeat("a", 63)+".", 4) + "com"
+	tests := []struct {
+		name string
+		pkt  func(p *DNSPacket)
+		err  error
+	}{
+		{"question label", func(p *DNSPacket) { p.AddQuestionA(long) }, ErrLabelTooLong},
+		{"question name", func(p *DNSPacket) { p.AddQuestionA(huge) }, ErrNameTooLong},
+		{"owner", func(p *DNSPacket) {
+			p.AddAnswer(&DNSResourceRecordA{DNSResourceRecord: DNSResourceRecord{Name: "a..com", Type: DNSTypeA, Class: DNSClassIN}, Address: "192.0.2.1"})
+		}, ErrEmptyLabel},
+		{"uncompressed RDATA", func(p *DNSPacket) {
+			p.AddAnswer(&DNSResourceRecordSRV{DNSResourceRecord: DNSResourceRecord{Name: "_sip._udp.example.com", Type: DNSTypeSRV, Class: DNSClassIN}, Target: long})
+		}, ErrLabelTooLong},
+	}
+	for _, tt := range tests {
+		p := NewPacket()
+		tt.pkt(p)
+		for _, compress := range []bool{true, false} {
+			if data, err := p.Pack(compress); !errors.Is(err, tt.err) || data != nil {
+				t.Errorf("%s (compress=%v): got %d bytes, %v; want %v", tt.name, compress, len(data), err, tt.err)
+			}
+		}
+		if p.Bytes() != nil {
+			t.Errorf("%s: Bytes should be nil", tt.name)
+		}
+	}
+}
+
+func TestPackToRejectsInvalidNames(t *testing.T) {
+	p := NewPacket()
+	p.AddAnswer(&DNSResourceRecordCNAME{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeCNAME, Class: DNSClassIN},
+		Domain:            strings.Repeat("a", 64) + ".com",
+	})
+	var buf bytes.Buffer
+	if err := p.PackTo(&buf, true); !errors.Is(err, ErrLabelTooLong) {
+		t.Fatalf("expected ErrLabelTooLong, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("buffer should stay empty, got %d bytes", buf.Len())
+	}
+}
+
+func TestEqualName(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"WWW.Example.COM.", "www.example.com", true},
+		{".", "", true},
+		{`\065bc.com`, "abc.com", true},
+		{`\e\x.com`, "ex.com", true},
+		{`a\.b.com`, "a.b.com", false},
+		{"ÉTÉ.com", "été.com", false}, // only ASCII folds
+		{`\195\169t\195\169.com`, "été.com", true},
+	}
+	for _, tt := range tests {
+		if got := EqualName(tt.a, tt.b); got != tt.equal {
+			t.Errorf("EqualName(%q, %q) = %v", tt.a, tt.b, got)
+		}
+	}
+
+	if !IsSubDomain("Example.com", "www.example.COM.") || !IsSubDomain("example.com", "example.com") {
+		t.Error("expected www.example.com under example.com")
+	}
+	if IsSubDomain("example.com", `www\.example.com`) || IsSubDomain("example.com", "badexample.com") {
+		t.Error("suffix match must stop at label boundaries")
+	}
+	if got := SplitName(`a\.b.c.`); len(got) != 2 || got[0] != `a\.b` || got[1] != "c" {
+		t.Errorf("unexpected labels %q", got)
+	}
+}
+
+func TestEncodeDecodeTXTRecord(t *testing.T) {
+	long := string(bytes.Repeat([]byte("k"), 300))
+	txt := &DNSResourceRecordTXT{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeTXT, Class: DNSClassIN, TTL: 300},
+		Text:              []string{"v=spf1 -all", "", long},
+	}
+	rdata := txt.Encode()
+	if rdata[0] != 11 || string(rdata[1:12]) != "v=spf1 -all" || rdata[12] != 0 {
+		t.Fatalf("character-strings not length-prefixed: %q", rdata[:13])
+	}
+
+	pkt := NewPacket()
+	pkt.AddAnswer(txt)
+	decoded, err := FromBytes(pkt.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	got := decoded.Answers[0].(*DNSResourceRecordTXT)
+	expected := []string{"v=spf1 -all", "", long[:255], long[255:]}
+	if !reflect.DeepEqual(got.Text, expected) {
+		t.Errorf("Text mismatch: expected %q, got %q", expected, got.Text)
+	}
+	if got.Value() != "v=spf1 -all"+long {
+		t.Errorf("Value mismatch: %q", got.Value())
+	}
+}
+
+func TestDecodeSPFRecord(t *testing.T) {
+	spf := &DNSResourceRecordTXT{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeSPF, Class: DNSClassIN, TTL: 300},
+	}
+	spf.SetValue("v=spf1 include:_spf.example.com ~all")
+	pkt := NewPacket()
+	pkt.AddAnswer(spf)
+	decoded, err := FromBytes(pkt.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	got, ok := decoded.Answers[0].(*DNSResourceRecordTXT)
+	if !ok || got.Type != DNSTypeSPF {
+		t.Fatalf("Expected SPF record, got %T", decoded.Answers[0])
+	}
+	if got.Value() != "v=spf1 include:_spf.example.com ~all" {
+		t.Errorf("Value mismatch: %q", got.Value())
+	}
+}
+
+func TestDecodeTXTCharacterStringOverrun(t *testing.T) {
+	pkt := NewPacket()
+	pkt.AddAnswer(&DNSResourceRecordTXT{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeTXT, Class: DNSClassIN, TTL: 300},
+		Text:              []string{"abc"},
+	})
+	data := pkt.Bytes()
+	data[len(data)-4] = 9 // claims more bytes than RDLENGTH holds
+	if _, err := FromBytes(data); !errors.Is(err, ErrRDataLength) {
+		t.Fatalf("expected ErrRDataLength, got %v", err)
+	}
+}
+
+func TestEncodeDecodeHTTPSRecord(t *testing.T) {
+	https := &DNSResourceRecordHTTPS{
+		DNSResourceRecordSVCB: DNSResourceRecordSVCB{
+			DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeHTTPS, Class: DNSClassIN, TTL: 300},
+			Priority:          1,
+			Target:            ".",
+			Params: SvcParams{
+				Mandatory:     []SvcParamKey{SvcParamPort, SvcParamALPN},
+				ALPN:          []string{"h2", "h3"},
+				NoDefaultALPN: true,
+				Port:          8443,
+				IPv4Hint:      []net.IP{net.ParseIP("192.0.2.1").To4()},
+				ECH:           []byte{0x00, 0x45},
+				IPv6Hint:      []net.IP{net.ParseIP("2001:db8::1")},
+				Other:         []SvcParam{{Key: 65000, Value: []byte("x")}},
+			},
+		},
+	}
+	pkt := NewPacket()
+	pkt.AddAnswer(https)
+	decoded, err := FromBytes(pkt.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	got, ok := decoded.Answers[0].(*DNSResourceRecordHTTPS)
+	if !ok {
+		t.Fatalf("Expected HTTPS record, got %T", decoded.Answers[0])
+	}
+	if got.Priority != 1 || got.Target != "." {
+		t.Errorf("header mismatch: %+v", got.DNSResourceRecordSVCB)
+	}
+	want := https.Params
+	want.Mandatory = []SvcParamKey{SvcParamALPN, SvcParamPort} // sorted on the wire
+	if !reflect.DeepEqual(got.Params, want) {
+		t.Errorf("params mismatch:\nexpected %+v\ngot      %+v", want, got.Params)
+	}
+}
+
+func TestDecodeSVCBRejectsUnorderedKeys(t *testing.T) {
+	svcb := &DNSResourceRecordSVCB{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeSVCB, Class: DNSClassIN, TTL: 300},
+		Priority:          1,
+		Target:            "svc.example.com",
+		Params:            SvcParams{Other: []SvcParam{{Key: 9, Value: nil}, {Key: 8, Value: nil}}},
+	}
+	rdata := svcb.Encode()
+	// swap the two (already sorted) params back out of order
+	tail := len(rdata) - 8
+	reordered := append(append(append([]byte{}, rdata[:tail]...), rdata[tail+4:]...), rdata[tail:tail+4]...)
+	pkt := NewPacket()
+	pkt.AddAnswer(&DNSResourceRecordUnknown{
+		DNSResourceRecord: svcb.DNSResourceRecord,
+		RData:             reordered,
+	})
+	if _, err := FromBytes(pkt.Bytes()); !errors.Is(err, ErrSvcParam) {
+		t.Fatalf("expected ErrSvcParam, got %v", err)
+	}
+}
+
+func TestEncodeDecodeCertificateRecords(t *testing.T) {
+	header := func(rtype DNSType) DNSResourceRecord {
+		return DNSResourceRecord{Name: "example.com", Type: rtype, Class: DNSClassIN, TTL: 300}
+	}
+	records := []DNSResource{
+		&DNSResourceRecordCAA{DNSResourceRecord: header(DNSTypeCAA), Flag: CAAFlagCritical, Tag: "issue", Value: "letsencrypt.org; validationmethods=dns-01"},
+		&DNSResourceRecordTLSA{DNSResourceRecord: header(DNSTypeTLSA), Usage: 3, Selector: 1, MatchingType: 1, Certificate: []byte{0xde, 0xad, 0xbe, 0xef}},
+		&DNSResourceRecordSSHFP{DNSResourceRecord: header(DNSTypeSSHFP), Algorithm: 4, FPType: 2, Fingerprint: []byte{0x01, 0x02, 0x03}},
+		&DNSResourceRecordNAPTR{DNSResourceRecord: header(DNSTypeNAPTR), Order: 100, Preference: 10, Flags: "U", Services: "E2U+sip", Regexp: "!^.*$!sip:info@example.com!", Replacement: "."},
+		&DNSResourceRecordNAPTR{DNSResourceRecord: header(DNSTypeNAPTR), Order: 100, Preference: 20, Flags: "S", Services: "SIP+D2U", Replacement: "_sip._udp.example.com"},
+	}
+	pkt := NewPacket()
+	for _, rr := range records {
+		pkt.AddAnswer(rr)
+	}
+	decoded, err := FromBytes(pkt.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if len(decoded.Answers) != len(records) {
+		t.Fatalf("Expected %d answers, got %d", len(records), len(decoded.Answers))
+	}
+	for i, want := range records {
+		if !reflect.DeepEqual(decoded.Answers[i], want) {
+			t.Errorf("answer %d mismatch:\nexpected %+v\ngot      %+v", i, want, decoded.Answers[i])
+		}
+	}
+}
+
+func TestDecodeCAARejectsTagOverrun(t *testing.T) {
+	pkt := NewPacket()
+	pkt.AddAnswer(&DNSResourceRecordUnknown{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeCAA, Class: DNSClassIN, TTL: 300},
+		RData:             []byte{0, 10, 'i', 's', 's', 'u', 'e'},
+	})
+	if _, err := FromBytes(pkt.Bytes()); err == nil {
+		t.Fatal("expected error for CAA tag longer than RDATA")
+	}
+}
+
+func TestEncodeDecodeDNSSECRecords(t *testing.T) {
+	header := func(rtype DNSType) DNSResourceRecord {
+		return DNSResourceRecord{Name: "example.com", Type: rtype, Class: DNSClassIN, TTL: 3600}
+	}
+	records := []DNSResource{
+		&DNSResourceRecordDNSKEY{DNSResourceRecord: header(DNSTypeDNSKEY), Flags: DNSKEYFlagZone | DNSKEYFlagSEP, Protocol: 3, Algorithm: DNSSECAlgorithmED25519, PublicKey: []byte{1, 2, 3, 4}},
+		&DNSResourceRecordDNSKEY{DNSResourceRecord: header(DNSTypeCDNSKEY), Flags: DNSKEYFlagZone, Protocol: 3, Algorithm: DNSSECAlgorithmRSASHA256, PublicKey: []byte{5, 6}},
+		&DNSResourceRecordDS{DNSResourceRecord: header(DNSTypeDS), KeyTag: 60485, Algorithm: DNSSECAlgorithmRSASHA1, DigestType: DSDigestSHA1, Digest: []byte{0x2b, 0xb1, 0x83}},
+		&DNSResourceRecordDS{DNSResourceRecord: header(DNSTypeCDS), KeyTag: 1, Algorithm: DNSSECAlgorithmECDSAP256SHA256, DigestType: DSDigestSHA256, Digest: []byte{0xff}},
+		&DNSResourceRecordRRSIG{DNSResourceRecord: header(DNSTypeRRSIG), TypeCovered: DNSTypeA, Algorithm: DNSSECAlgorithmRSASHA256, Labels: 2, OriginalTTL: 300,
+			Expiration: 1700000000, Inception: 1690000000, KeyTag: 12345, SignerName: "example.com", Signature: []byte{0xaa, 0xbb}},
+		&DNSResourceRecordNSEC{DNSResourceRecord: header(DNSTypeNSEC), NextDomain: "host.example.com",
+			Types: []DNSType{DNSTypeA, DNSTypeMX, DNSTypeRRSIG, DNSTypeNSEC, DNSTypeCAA}},
+		&DNSResourceRecordNSEC3{DNSResourceRecord: header(DNSTypeNSEC3), HashAlgorithm: NSEC3HashSHA1, Flags: NSEC3FlagOptOut, Iterations: 10,
+			Salt: []byte{0xaa, 0xbb}, NextHashed: []byte("0123456789abcdefghij"), Types: []DNSType{DNSTypeNS, DNSTypeDS, DNSTypeRRSIG}},
+		&DNSResourceRecordNSEC3PARAM{DNSResourceRecord: header(DNSTypeNSEC3PARAM), HashAlgorithm: NSEC3HashSHA1, Iterations: 0},
+	}
+	pkt := NewPacket()
+	for _, rr := range records {
+		pkt.AddAnswer(rr)
+	}
+	decoded, err := FromBytes(pkt.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if len(decoded.Answers) != len(records) {
+		t.Fatalf("Expected %d answers, got %d", len(records), len(decoded.Answers))
+	}
+	for i, want := range records {
+		if !reflect.DeepEqual(decoded.Answers[i], want) {
+			t.Errorf("answer %d mismatch:\nexpected %+v\ngot      %+v", i, want, decoded.Answers[i])
+		}
+	}
+}
+
+func TestTypeBitMap(t *testing.T) {
+	var buf bytes.Buffer
+	// unsorted input with a duplicate; CAA (257) lands in window 1
+	encodeTypeBitMap(&buf, []DNSType{DNSTypeCAA, DNSTypeA, DNSTypeMX, DNSTypeRRSIG, DNSTypeA})
+	want := []byte{
+		0x00, 0x06, 0x40, 0x01, 0x00, 0x00, 0x00, 0x02, // A, MX, RRSIG
+		0x01, 0x01, 0x40, // CAA
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("bitmap mismatch:\nexpected %x\ngot      %x", want, buf.Bytes())
+	}
+	types, err := decodeTypeBitMap(bytes.NewReader(want), len(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(types, []DNSType{DNSTypeA, DNSTypeMX, DNSTypeRRSIG, DNSTypeCAA}) {
+		t.Errorf("unexpected types %v", types)
+	}
+
+	for _, bad := range [][]byte{
+		{0x01, 0x01, 0x40, 0x00, 0x01, 0x40}, // windows out of order
+		{0x00, 0x00},                         // empty bitmap
+		{0x00, 0x21},                         // bitmap longer than 32 bytes
+		{0x00, 0x02, 0x40},                   // bitmap overruns RDATA
+	} {
+		if _, err := decodeTypeBitMap(bytes.NewReader(bad), len(bad)); !errors.Is(err, ErrTypeBitMap) {
+			t.Errorf("expected ErrTypeBitMap for %x, got %v", bad, err)
+		}
+	}
+}
+
+func TestDNSKEYKeyTag(t *testing.T) {
+	// RFC 4034 §5.4 example key
+	key, err := base64.StdEncoding.DecodeString("AQOeiiR0GOMYkDshWoSKz9XzfwJr1AYtsmx3TGkJaNXVbfi/2pHm822aJ5iI9BMzNXxeYCmZDRD99WYwYqUSdjMmmAphXdvxegXd/M5+X7OrzKBaMbCVdFLUUh6DhweJBjEVv5f2wwjM9XzcnOf+EPbtG9DMBmADjFDc2w/rljwvFw==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dnskey := &DNSResourceRecordDNSKEY{Flags: 256, Protocol: 3, Algorithm: DNSSECAlgorithmRSASHA1, PublicKey: key}
+	if tag := dnskey.KeyTag(); tag != 60485 {
+		t.Errorf("expected key tag 60485, got %d", tag)
+	}
+}
+
+func TestParseDNSType(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want DNSType
+	}{
+		{"A", DNSTypeA},
+		{"nsec3param", DNSTypeNSEC3PARAM},
+		{"TYPE65534", 65534},
+		{"OPT", DNSTypeEDNS},
+	} {
+		got, err := ParseDNSType(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDNSType(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseDNSType("BOGUS"); err == nil {
+		t.Error("expected error for unknown mnemonic")
+	}
+	if s := DNSType(65534).String(); s != "TYPE65534" {
+		t.Errorf("expected TYPE65534, got %s", s)
+	}
+}
+
+func TestDecodeRData(t *testing.T) {
+	header := DNSResourceRecord{Name: "example.com", Type: DNSTypeMX, Class: DNSClassIN, TTL: 300}
+	rec, err := DecodeRData(header, []byte{0, 10, 4, 'm', 'a', 'i', 'l', 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mx, ok := rec.(*DNSResourceRecordMX)
+	if !ok || mx.Preference != 10 || mx.Exchange != "mail" {
+		t.Errorf("unexpected record %#v", rec)
+	}
+	// there is no message around rdata for a pointer to reach into
+	if _, err := DecodeRData(header, []byte{0, 10, 0xc0, 0x0c}); !errors.Is(err, ErrPointerForward) {
+		t.Errorf("expected ErrPointerForward, got %v", err)
+	}
+	if _, err := DecodeRData(header, []byte{0, 10, 0, 0xff}); !errors.Is(err, ErrRDataLength) {
+		t.Errorf("expected ErrRDataLength for trailing bytes, got %v", err)
+	}
+	header.Type = 65280
+	rec, err = DecodeRData(header, []byte{0x0a, 0, 0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := rec.(*DNSResourceRecordUnknown).String(); s != "example.com.\t300\tIN\tTYPE65280\t\\# 4 0a000001" {
+		t.Errorf("unexpected generic form %q", s)
+	}
+}
+
+func TestParseDNSClass(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want DNSClass
+	}{
+		{"IN", DNSClassIN},
+		{"ch", DNSClassCH},
+		{"CLASS32", 32},
+	} {
+		got, err := ParseDNSClass(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDNSClass(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseDNSClass("CLASSX"); err == nil {
+		t.Error("expected error for malformed class")
+	}
+	if s := DNSClass(32).String(); s != "CLASS32" {
+		t.Errorf("expected CLASS32, got %s", s)
+	}
+}
+
+func TestRecordString(t *testing.T) {
+	h := func(rtype DNSType) DNSResourceRecord {
+		return DNSResourceRecord{Name: "example.com", Type: rtype, Class: DNSClassIN, TTL: 300}
+	}
+	tests := []struct {
+		rr   DNSResource
+		want string
+	}{
+		{&DNSResourceRecordA{DNSResourceRecord: h(DNSTypeA), Address: "192.0.2.1"}, "example.com.\t300\tIN\tA\t192.0.2.1"},
+		{&DNSResourceRecordMX{DNSResourceRecord: h(DNSTypeMX), Preference: 10, Exchange: "mail.example.com"}, "example.com.\t300\tIN\tMX\t10 mail.example.com."},
+		{&DNSResourceRecordSOA{DNSResourceRecord: h(DNSTypeSOA), MName: "ns1.example.com", RName: "admin.example.com", Serial: 1, Refresh: 2, Retry: 3, Expire: 4, Minimum: 5},
+			"example.com.\t300\tIN\tSOA\tns1.example.com. admin.example.com. 1 2 3 4 5"},
+		{&DNSResourceRecordTXT{DNSResourceRecord: h(DNSTypeTXT), Text: []string{"v=spf1 -all", `say "hi"\`, "\x00"}},
+			"example.com.\t300\tIN\tTXT\t\"v=spf1 -all\" \"say \\\"hi\\\"\\\\\" \"\\000\""},
+		{&DNSResourceRecordCAA{DNSResourceRecord: h(DNSTypeCAA), Tag: "issue", Value: "ca.example"}, "example.com.\t300\tIN\tCAA\t0 issue \"ca.example\""},
+		{&DNSResourceRecordTLSA{DNSResourceRecord: h(DNSTypeTLSA), Usage: 3, Selector: 1, MatchingType: 1, Certificate: []byte{0xab, 0xcd}}, "example.com.\t300\tIN\tTLSA\t3 1 1 ABCD"},
+		{&DNSResourceRecordRRSIG{DNSResourceRecord: h(DNSTypeRRSIG), TypeCovered: DNSTypeA, Algorithm: 13, Labels: 2, OriginalTTL: 300,
+			Expiration: 1893456000, Inception: 1577836800, KeyTag: 42, SignerName: "example.com", Signature: []byte{1, 2, 3}},
+			"example.com.\t300\tIN\tRRSIG\tA 13 2 300 20300101000000 20200101000000 42 example.com. AQID"},
+		{&DNSResourceRecordNSEC{DNSResourceRecord: h(DNSTypeNSEC), NextDomain: "a.example.com", Types: []DNSType{DNSTypeA, 1234}},
+			"example.com.\t300\tIN\tNSEC\ta.example.com. A TYPE1234"},
+		{&DNSResourceRecordNSEC3PARAM{DNSResourceRecord: h(DNSTypeNSEC3PARAM), HashAlgorithm: 1}, "example.com.\t300\tIN\tNSEC3PARAM\t1 0 0 -"},
+		{&DNSResourceRecordHTTPS{DNSResourceRecordSVCB: DNSResourceRecordSVCB{DNSResourceRecord: h(DNSTypeHTTPS), Priority: 1, Target: ".",
+			Params: SvcParams{ALPN: []string{"h2", "a,b"}, Port: 443, Mandatory: []SvcParamKey{SvcParamPort}}}},
+			"example.com.\t300\tIN\tHTTPS\t1 . mandatory=port alpn=h2,a\\,b port=443"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(tt.rr); got != tt.want {
+			t.Errorf("unexpected presentation:\nexpected %q\ngot      %q", tt.want, got)
+		}
+	}
+}
+
+func TestPacketString(t *testing.T) {
+	pkt := NewPacket()
+	pkt.Header.ID = 4660
+	pkt.Header.QR = DNSResponse
+	pkt.Header.RD = 1
+	pkt.Header.RA = 1
+	pkt.Header.RCode = uint8(DNSRCodeNXDomain)
+	pkt.AddQuestionA("example.com")
+	pkt.AddAuthority(&DNSResourceRecordSOA{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeSOA, Class: DNSClassIN, TTL: 60},
+		MName:             "ns.example.com", RName: "host.example.com", Serial: 1, Refresh: 2, Retry: 3, Expire: 4, Minimum: 5,
+	})
+	pkt.AddAdditionalEDNS(1232, 0, 0, true)
+	want := `;; ->>HEADER<<- opcode: QUERY, status: NXDOMAIN, id: 4660
+;; flags: qr rd ra; QUERY: 1, ANSWER: 0, AUTHORITY: 1, ADDITIONAL: 1
+
+;; OPT PSEUDOSECTION:
+; EDNS: version: 0, flags: do; udp: 1232
+
+;; QUESTION SECTION:
+;example.com.	IN	A
+
+;; AUTHORITY SECTION:
+example.com.	60	IN	SOA	ns.example.com. host.example.com. 1 2 3 4 5
+`
+	if got := pkt.String(); got != want {
+		t.Errorf("unexpected dump:\nexpected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestParseMnemonics(t *testing.T) {
+	if rc, err := ParseDNSRCode("nxdomain"); err != nil || rc != DNSRCodeNXDomain {
+		t.Errorf("ParseDNSRCode(nxdomain) = %v, %v", rc, err)
+	}
+	if rc, err := ParseDNSRCode("RCODE3841"); err != nil || rc != 3841 {
+		t.Errorf("ParseDNSRCode(RCODE3841) = %v, %v", rc, err)
+	}
+	if s := DNSRCode(3841).String(); s != "RCODE3841" {
+		t.Errorf("expected RCODE3841, got %s", s)
+	}
+	if op, err := ParseDNSOpCode("notify"); err != nil || op != DNSOpCodeNotify {
+		t.Errorf("ParseDNSOpCode(notify) = %v, %v", op, err)
+	}
+	if op, err := ParseDNSOpCode("OPCODE3"); err != nil || op != 3 {
+		t.Errorf("ParseDNSOpCode(OPCODE3) = %v, %v", op, err)
+	}
+	if _, err := ParseDNSOpCode("BOGUS"); err == nil {
+		t.Error("expected error for unknown opcode")
+	}
+}
+
+func TestPacketJSONRoundTrip(t *testing.T) {
+	pkt := NewPacket()
+	pkt.Header.QR = DNSResponse
+	pkt.Header.RD = 1
+	pkt.Header.Z = headerZAD
+	pkt.AddQuestionMX("example.com")
+	pkt.AddAnswer(&DNSResourceRecordMX{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeMX, Class: DNSClassIN, TTL: 300},
+		Preference:        10,
+		Exchange:          "mail.example.com",
+	})
+	pkt.AddAnswer(&DNSResourceRecordTXT{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeTXT, Class: DNSClassIN, TTL: 300},
+		Text:              []string{"hello", "world"},
+	})
+	pkt.AddAuthority(&DNSResourceRecordUnknown{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: 65280, Class: DNSClassIN, TTL: 60},
+		RData:             []byte{0xde, 0xad},
+	})
+	pkt.AddAdditionalEDNS(1232, 0, 0, true)
+	want, err := FromBytes(pkt.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range []string{`"QNAME":"example.com."`, `"AD":1`, `"rdataMX":"10 mail.example.com."`, `"RDATAHEX":"DEAD"`, `"TYPEname":"TYPE65280"`} {
+		if !strings.Contains(string(data), member) {
+			t.Errorf("expected %s in %s", member, data)
+		}
+	}
+	var got DNSPacket
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("round trip mismatch:\nexpected %s\ngot      %s", want, &got)
+	}
+}
+
+func TestPacketUnmarshalJSONFixture(t *testing.T) {
+	// adapted from RFC 8427 §7.1, with an answer in presentation form
+	fixture := `{ "ID": 32784, "QR": 1, "Opcode": 0, "AA": 0, "TC": 0, "RD": 1, "RA": 1, "AD": 0, "CD": 0, "RCODE": 0,
+		"QDCOUNT": 1, "ANCOUNT": 2, "NSCOUNT": 0, "ARCOUNT": 0,
+		"QNAME": "example.com.", "QTYPE": 1, "QCLASS": 1,
+		"answerRRs": [
+			{ "NAME": "example.com.", "TYPE": 1, "CLASS": 1, "TTL": 3600, "rdataA": "192.0.2.1" },
+			{ "NAME": "example.com.", "TYPEname": "A", "TTL": 3600, "RDATAHEX": "C0000202" }
+		] }`
+	var pkt DNSPacket
+	if err := json.Unmarshal([]byte(fixture), &pkt); err != nil {
+		t.Fatal(err)
+	}
+	if pkt.Header.ID != 32784 || pkt.Header.RA != 1 || len(pkt.Questions) != 1 || pkt.Questions[0].Name != "example.com" {
+		t.Errorf("unexpected header or question: %s", &pkt)
+	}
+	for i, addr := range []string{"192.0.2.1", "192.0.2.2"} {
+		a, ok := pkt.Answers[i].(*DNSResourceRecordA)
+		if !ok || a.Address != addr || a.Class != DNSClassIN {
+			t.Errorf("answer %d: expected A %s, got %v", i, addr, pkt.Answers[i])
+		}
+	}
+
+	bad := `{ "answerRRs": [ { "NAME": "example.com.", "TYPE": 15, "CLASS": 1, "TTL": 1, "rdataMX": "ten mail.example.com." } ] }`
+	var perr *ParseError
+	if err := json.Unmarshal([]byte(bad), &pkt); !errors.As(err, &perr) || perr.Section != SectionAnswer {
+		t.Errorf("expected answer ParseError, got %v", err)
+	}
+}
+
+func TestPacketClone(t *testing.T) {
+	p := NewPacket()
+	p.AddQuestion(&DNSQuestion{Name: "example.com", Type: DNSTypeHTTPS, Class: DNSClassIN})
+	p.AddAnswer(&DNSResourceRecordHTTPS{DNSResourceRecordSVCB: DNSResourceRecordSVCB{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeHTTPS, Class: DNSClassIN, TTL: 300},
+		Priority:          1, Target: ".",
+		Params: SvcParams{ALPN: []string{"h2"}, IPv4Hint: []net.IP{net.IPv4(192, 0, 2, 1).To4()}},
+	}})
+	p.AddAnswer(&DNSResourceRecordTXT{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeTXT, Class: DNSClassIN, TTL: 300},
+		Text:              []string{"hello"},
+	})
+	p.AddAdditionalEDNS(1232, 0, 0, true)
+	p.Additionals[0].(*DNSResourceRecordEDNS).AddEDNSOption(EDNSOptionNSID, []byte("ns1"))
+	want := p.Bytes()
+
+	cp := p.Clone()
+	if !cp.Equal(p) {
+		t.Fatal("clone should equal the original")
+	}
+	cp.Header.ID++
+	cp.Questions[0].Name = "other.example"
+	https := cp.Answers[0].(*DNSResourceRecordHTTPS)
+	https.TTL = 1
+	https.Params.ALPN[0] = "h3"
+	https.Params.IPv4Hint[0][3] = 99
+	cp.Answers[1].(*DNSResourceRecordTXT).Text[0] = "bye"
+	opt := cp.Additionals[0].(*DNSResourceRecordEDNS)
+	opt.Options[0].Data[0] = 'x'
+	opt.Options = opt.Options[:0]
+
+	if got := p.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("modifying the clone changed the original:\nexpected %x\ngot      %x", want, got)
+	}
+	if cp.Equal(p) {
+		t.Error("modified clone should not equal the original")
+	}
+}
+
+func TestPacketEqual(t *testing.T) {
+	build := func(owner, exchange string, prefs ...uint16) *DNSPacket {
+		p := &DNSPacket{Header: &DNSHeader{ID: 7, QR: DNSResponse}}
+		p.AddQuestion(&DNSQuestion{Name: owner, Type: DNSTypeMX, Class: DNSClassIN})
+		for _, pref := range prefs {
+			p.AddAnswer(&DNSResourceRecordMX{
+				DNSResourceRecord: DNSResourceRecord{Name: owner, Type: DNSTypeMX, Class: DNSClassIN, TTL: 300},
+				Preference:        pref, Exchange: exchange,
+			})
+		}
+		return p
+	}
+	a := build("example.com", "mail.example.com", 10, 20)
+	if !a.Equal(build("EXAMPLE.com.", "Mail.Example.COM.", 20, 10)) {
+		t.Error("case, trailing dot and RRset order should not matter")
+	}
+	if a.Equal(build("example.com", "mail.example.com", 10, 30)) {
+		t.Error("different rdata should not be equal")
+	}
+	if a.Equal(build("example.com", "mail.example.com", 10)) {
+		t.Error("different answer count should not be equal")
+	}
+	b := build("example.com", "mail.example.com", 10, 20)
+	b.Header.RCode = 2
+	if a.Equal(b) {
+		t.Error("different rcode should not be equal")
+	}
+	if !EqualResource(a.Answers[0], b.Answers[0]) || EqualResource(a.Answers[0], a.Answers[1]) {
+		t.Error("unexpected EqualResource result")
+	}
+}
+
+func TestPacketExtendedRCode(t *testing.T) {
+	p := NewPacket()
+	p.SetRCode(DNSRCodeNXDomain)
+	if p.EDNS() != nil {
+		t.Error("4-bit rcode should not add an OPT record")
+	}
+
+	p.SetRCode(DNSRCodeBadCookie)
+	opt := p.EDNS()
+	if opt == nil {
+		t.Fatal("extended rcode should add an OPT record")
+	}
+	if p.Header.RCode != 7 || opt.ExtRCode != 1 {
+		t.Errorf("expected header 7 and ext 1, got %d and %d", p.Header.RCode, opt.ExtRCode)
+	}
+
+	decoded, err := FromBytes(p.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.RCode(); got != DNSRCodeBadCookie {
+		t.Errorf("expected BADCOOKIE after round trip, got %v", got)
+	}
+	if !strings.Contains(decoded.String(), "status: BADCOOKIE") {
+		t.Errorf("packet string should show the extended rcode:\n%s", decoded)
+	}
+
+	decoded.SetRCode(DNSRCodeBadVers)
+	if decoded.Header.RCode != 0 || decoded.EDNS().ExtRCode != 1 || decoded.RCode() != DNSRCodeBadVers {
+		t.Errorf("unexpected split for BADVERS: header %d, ext %d", decoded.Header.RCode, decoded.EDNS().ExtRCode)
+	}
+	decoded.SetRCode(DNSRCodeServFail)
+	if decoded.EDNS().ExtRCode != 0 || decoded.RCode() != DNSRCodeServFail {
+		t.Errorf("setting a 4-bit rcode should clear the extended bits, got %v", decoded.RCode())
+	}
+}
+
+// benchResponse is a typical recursive answer: a CNAME chain ending in two
+// addresses, with an OPT record.
+func benchResponse() *DNSPacket {
+	p := NewPacket()
+	p.Header.QR = DNSResponse
+	p.Header.RD, p.Header.RA = 1, 1
+	p.AddQuestionA("www.example.com")
+	p.AddAnswer(&DNSResourceRecordCNAME{
+		DNSResourceRecord: DNSResourceRecord{Name: "www.example.com", Type: DNSTypeCNAME, Class: DNSClassIN, TTL: 300},
+		Domain:            "cdn.example.net",
+	})
+	for _, addr := range []string{"192.0.2.1", "192.0.2.2"} {
+		p.AddAnswer(&DNSResourceRecordA{
+			DNSResourceRecord: DNSResourceRecord{Name: "cdn.example.net", Type: DNSTypeA, Class: DNSClassIN, TTL: 60},
+			Address:           addr,
+		})
+	}
+	p.AddAdditionalEDNS(1232, 0, 0, true)
+	return p
+}
+
+func TestUnpackMatchesFromBytes(t *testing.T) {
+	resp := benchResponse()
+	resp.AddAuthority(&DNSResourceRecordSOA{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.net", Type: DNSTypeSOA, Class: DNSClassIN, TTL: 300},
+		MName:             "ns1.example.net", RName: "hostmaster.example.net", Serial: 7,
+	})
+	resp.AddAdditional(&DNSResourceRecordAAAA{
+		DNSResourceRecord: DNSResourceRecord{Name: "cdn.example.net", Type: DNSTypeAAAA, Class: DNSClassIN, TTL: 60},
+		Address:           "2001:db8::1",
+	})
+	resp.AddAdditional(&DNSResourceRecordMX{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.net", Type: DNSTypeMX, Class: DNSClassIN, TTL: 60},
+		Preference:        10, Exchange: "mail.example.net",
+	})
+	resp.EDNS().AddEDNSOptionNSID([]byte("ns1"))
+
+	var p DNSPacket
+	for _, data := range [][]byte{mustPack(t, resp, true), mustPack(t, resp, false)} {
+		want, err := FromBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Unpack(data); err != nil {
+			t.Fatal(err)
+		}
+		if !p.Equal(want) || !reflect.DeepEqual(&p, want) {
+			t.Errorf("Unpack disagrees with FromBytes:\n%v\nvs\n%v", &p, want)
+		}
+		// the packet keeps nothing that points into data
+		for i := range data {
+			data[i] = 0xff
+		}
+		if !p.Equal(want) {
+			t.Error("Unpack result changed when the input was overwritten")
+		}
+	}
+
+	// reusing the packet for a smaller message drops the old records
+	query := NewPacket()
+	query.AddQuestionA("example.org")
+	if err := p.Unpack(query.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Answers)+len(p.Authorities)+len(p.Additionals) != 0 || p.Questions[0].Name != "example.org" {
+		t.Errorf("reused packet kept stale data: %v", &p)
+	}
+}
+
+func BenchmarkFromBytes(b *testing.B) {
+	data := benchResponse().Bytes()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := FromBytes(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnpack(b *testing.B) {
+	data := benchResponse().Bytes()
+	var p DNSPacket
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := p.Unpack(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnpackQuery(b *testing.B) {
+	query := NewPacket()
+	query.AddQuestionA("www.example.com")
+	data := query.Bytes()
+	var p DNSPacket
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := p.Unpack(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPack(b *testing.B) {
+	p := benchResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.Pack(true)
+	}
+}
+
+func BenchmarkPackTo(b *testing.B) {
+	p := benchResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := GetBuffer()
+		p.PackTo(buf, true)
+		PutBuffer(buf)
+	}
+}
+
+func TestIDNA(t *testing.T) {
+	tests := []struct {
+		unicode, ascii string
+	}{
+		{"例子.中国", "xn--fsqu00a.xn--fiqs8s"},
+		{"münchen.de.", "xn--mnchen-3ya.de."},
+		{"ＢÜＣＨＥＲ。example", "xn--bcher-kva.example"},
+		{"www.example.com", "www.example.com"},
+		{"日本語.jp", "xn--wgv71a119e.jp"},
+	}
+	for _, tt := range tests {
+		ascii, err := IDNAToASCII(tt.unicode)
+		if err != nil || ascii != tt.ascii {
+			t.Errorf("IDNAToASCII(%q) = %q, %v; want %q", tt.unicode, ascii, err, tt.ascii)
+		}
+		u, err := IDNAToUnicode(ascii)
+		if err != nil {
+			t.Errorf("IDNAToUnicode(%q): %v", ascii, err)
+		}
+		if back, _ := IDNAToASCII(u); back != ascii {
+			t.Errorf("%q does not round-trip: %q -> %q", ascii, u, back)
+		}
+	}
+	if got := DisplayName("xn--fsqu00a.xn--fiqs8s"); got != "xn--fsqu00a.xn--fiqs8s (例子.中国)" {
+		t.Errorf("unexpected display name %q", got)
+	}
+	if got := DisplayName("example.com"); got != "example.com" {
+		t.Errorf("unexpected display name %q", got)
+	}
+	for _, bad := range []string{"例 子.cn", "-例子.cn", "\u0301例子.cn", "例子!.cn"} {
+		if _, err := IDNAToASCII(bad); !errors.Is(err, ErrIDNA) {
+			t.Errorf("IDNAToASCII(%q): expected ErrIDNA, got %v", bad, err)
+		}
+	}
+	for _, bad := range []string{"xn--zz-.com", "xn--ab.com", "xn--9.com"} {
+		if _, err := IDNAToUnicode(bad); !errors.Is(err, ErrIDNA) {
+			t.Errorf("IDNAToUnicode(%q): expected ErrIDNA, got %v", bad, err)
+		}
+	}
+}
+
+// tsigVector is a query for example.com A signed with testTSIGKey at
+// 1700000000, assembled by hand from the layout of RFC 8945 §4.
+const tsigVector = "123401000001000000000001076578616d706c6503636f6d000001000108746573742d6b6579" +
+	"0000fa00ff00000000003d0b686d61632d7368613235360000006553f100012c0020c5d9dfa40dd46e9e" +
+	"0d75c1ea2bf57413f72dcdfe2416f0e825d63cc1ec7c87ec123400000000"
+
+var testTSIGKey = TSIGKey{
+	Name:      "test-key.",
+	Algorithm: TSIGHMACSHA256,
+	Secret:    []byte("0123456789abcdef0123456789abcdef"),
+}
+
+func setTSIGClock(t *testing.T, unix int64) {
+	t.Helper()
+	tsigNow = func() time.Time { return time.Unix(unix, 0) }
+	t.Cleanup(func() { tsigNow = time.Now })
+}
+
+func testKeyring(keys ...TSIGKey) TSIGKeyring {
+	kr := TSIGKeyring{}
+	for _, k := range keys {
+		kr.Add(k)
+	}
+	return kr
+}
+
+func TestTSIGKnownAnswer(t *testing.T) {
+	setTSIGClock(t, 1700000000)
+	want, _ := hex.DecodeString(tsigVector)
+
+	query := &DNSPacket{Header: &DNSHeader{ID: 0x1234, RD: 1}}
+	query.AddQuestion(&DNSQuestion{Name: "example.com", Type: DNSTypeA, Class: DNSClassIN})
+	if _, err := query.SignTSIG(testTSIGKey, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := query.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("signed query differs:\n got %x\nwant %x", got, want)
+	}
+
+	rr, err := VerifyTSIG(want, testKeyring(testTSIGKey), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.OriginalID != 0x1234 || rr.Fudge != DefaultTSIGFudge || len(rr.MAC) != 32 {
+		t.Errorf("unexpected TSIG record %s", rr)
+	}
+
+	decoded, err := FromBytes(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.TSIG() == nil || !EqualResource(decoded.TSIG(), rr) {
+		t.Errorf("FromBytes did not decode the TSIG record: %v", decoded.Additionals)
+	}
+}
+
+func TestTSIGRequestResponse(t *testing.T) {
+	setTSIGClock(t, 1700000000)
+	for _, alg := range []string{TSIGHMACSHA256, TSIGHMACSHA512} {
+		key := TSIGKey{Name: "xfr.example.", Algorithm: alg, Secret: []byte("secret")}
+		keyring := testKeyring(key)
+
+		query := NewPacket()
+		query.Header.ID = 0x4242
+		query.AddQuestionSOA("example.com")
+		qrr, err := query.SignTSIG(key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srr, err := VerifyTSIG(query.Bytes(), keyring, nil)
+		if err != nil {
+			t.Fatalf("%s: server verify: %v", alg, err)
+		}
+
+		resp := NewPacketFromRequest(query)
+		resp.Additionals = nil
+		resp.Header.QR = DNSResponse
+		if _, err := resp.SignTSIG(key, srr.MAC); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := VerifyTSIG(resp.Bytes(), keyring, qrr.MAC); err != nil {
+			t.Fatalf("%s: client verify: %v", alg, err)
+		}
+		// a response is bound to the request it answers
+		if _, err := VerifyTSIG(resp.Bytes(), keyring, nil); !errors.Is(err, ErrTSIGBadSig) {
+			t.Errorf("%s: expected BADSIG without the request MAC, got %v", alg, err)
+		}
+	}
+}
+
+func TestTSIGVerifyFailures(t *testing.T) {
+	setTSIGClock(t, 1700000000)
+	signed, _ := hex.DecodeString(tsigVector)
+	keyring := testKeyring(testTSIGKey)
+
+	tampered := append([]byte{}, signed...)
+	tampered[3] ^= 0x80 // flip RA
+	wrongSecret := testTSIGKey
+	wrongSecret.Secret = []byte("another secret")
+	wrongAlg := testTSIGKey
+	wrongAlg.Algorithm = TSIGHMACSHA512
+
+	unsigned := NewPacket()
+	unsigned.AddQuestionA("example.com")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		keyring TSIGKeyring
+		now     int64
+		err     error
+	}{
+		{"tampered", tampered, keyring, 1700000000, ErrTSIGBadSig},
+		{"wrong secret", signed, testKeyring(wrongSecret), 1700000000, ErrTSIGBadSig},
+		{"unknown key", signed, TSIGKeyring{}, 1700000000, ErrTSIGBadKey},
+		{"algorithm mismatch", signed, testKeyring(wrongAlg), 1700000000, ErrTSIGBadKey},
+		{"too late", signed, keyring, 1700000000 + DefaultTSIGFudge + 1, ErrTSIGBadTime},
+		{"too early", signed, keyring, 1700000000 - DefaultTSIGFudge - 1, ErrTSIGBadTime},
+		{"unsigned", unsigned.Bytes(), keyring, 1700000000, ErrTSIGUnsigned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTSIGClock(t, tt.now)
+			_, err := VerifyTSIG(tt.data, tt.keyring, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if code := TSIGErrorCode(err); tt.err != ErrTSIGUnsigned && code == DNSRCodeNoError {
+				t.Errorf("no TSIG error code for %v", err)
+			}
+		})
+	}
+
+	// TSIG must be the last record
+	p, _ := FromBytes(signed)
+	p.AddAdditional(&DNSResourceRecordA{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeA, Class: DNSClassIN},
+		Address:           "192.0.2.1",
+	})
+	if _, err := VerifyTSIG(p.Bytes(), keyring, nil); !errors.Is(err, ErrTSIGFormat) {
+		t.Errorf("expected ErrTSIGFormat, got %v", err)
+	}
+}
+
+func TestTSIGStream(t *testing.T) {
+	setTSIGClock(t, 1700000000)
+	keyring := testKeyring(testTSIGKey)
+	requestMAC := []byte("request mac, 32 bytes long......")
+
+	signer := NewTSIGSigner(testTSIGKey, requestMAC)
+	var stream [][]byte
+	for i := 0; i < 5; i++ {
+		msg := NewPacket()
+		msg.Header.ID = 7
+		msg.Header.QR = DNSResponse
+		msg.AddAnswer(&DNSResourceRecordA{
+			DNSResourceRecord: DNSResourceRecord{Name: fmt.Sprintf("h%d.example.com", i), Type: DNSTypeA, Class: DNSClassIN, TTL: 60},
+			Address:           fmt.Sprintf("192.0.2.%d", i),
+		})
+		// messages 1 and 2 go out unsigned
+		if i == 1 || i == 2 {
+			signer.Skip(msg.Bytes())
+		} else if _, err := signer.Sign(msg); err != nil {
+			t.Fatal(err)
+		}
+		stream = append(stream, msg.Bytes())
+	}
+
+	verifier := NewTSIGVerifier(keyring, requestMAC)
+	for i, data := range stream {
+		rr, err := verifier.Verify(data)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if signed := i != 1 && i != 2; signed != (rr != nil) {
+			t.Errorf("message %d: signed=%v, got record %v", i, signed, rr)
+		}
+	}
+	if err := verifier.Done(); err != nil {
+		t.Errorf("Done: %v", err)
+	}
+
+	// dropping an unsigned message breaks the chain
+	verifier = NewTSIGVerifier(keyring, requestMAC)
+	for i, data := range [][]byte{stream[0], stream[1], stream[3]} {
+		if _, err := verifier.Verify(data); err != nil {
+			if i != 2 || !errors.Is(err, ErrTSIGBadSig) {
+				t.Fatalf("message %d: unexpected %v", i, err)
+			}
+			return
+		}
+	}
+	t.Error("expected BADSIG after a dropped message")
+}
+
+func TestUpdateMessage(t *testing.T) {
+	a := &DNSResourceRecordA{
+		DNSResourceRecord: DNSResourceRecord{Name: "host.example.com", Type: DNSTypeA, Class: DNSClassIN, TTL: 300},
+		Address:           "192.0.2.7",
+	}
+	msg := NewUpdate("example.com")
+	msg.AddPrereqNameNotInUse("host.example.com")
+	msg.AddPrereqRRsetExists("example.com", DNSTypeNS)
+	msg.AddUpdateDeleteRRset("old.example.com", DNSTypeTXT)
+	msg.AddUpdateDelete(a)
+	msg.AddUpdateInsert(a)
+
+	if a.Class != DNSClassIN || a.TTL != 300 {
+		t.Fatal("builders must not modify the records passed in")
+	}
+	if err := msg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	data := mustPack(t, msg, true)
+	for name, decode := range map[string]func([]byte) (*DNSPacket, error){
+		"FromBytes": FromBytes,
+		"Unpack": func(data []byte) (*DNSPacket, error) {
+			p := &DNSPacket{}
+			return p, p.Unpack(data)
+		},
+	} {
+		got, err := decode(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if DNSOpCode(got.Header.OpCode) != DNSOpCodeUpdate || got.UpdateZone().Type != DNSTypeSOA {
+			t.Errorf("%s: bad zone section %v", name, got.Questions)
+		}
+		if !got.Equal(msg) {
+			t.Errorf("%s: round trip mismatch:\n%s\nwant\n%s", name, got, msg)
+		}
+		prereq, ok := got.Answers[0].(*DNSResourceRecordUnknown)
+		if !ok || prereq.Class != DNSClassNone || prereq.Type != DNSTypeAny || len(prereq.RData) != 0 {
+			t.Errorf("%s: name-not-in-use prerequisite decoded as %v", name, got.Answers[0])
+		}
+		if del := got.Authorities[1].GetHeader(); del.Class != DNSClassNone || del.TTL != 0 {
+			t.Errorf("%s: delete-record update has class %s TTL %d", name, del.Class, del.TTL)
+		}
+		if _, ok := got.Authorities[1].(*DNSResourceRecordA); !ok {
+			t.Errorf("%s: delete-record update should keep its RDATA, got %T", name, got.Authorities[1])
+		}
+	}
+}
+
+func TestEqualRData(t *testing.T) {
+	a := &DNSResourceRecordMX{
+		DNSResourceRecord: DNSResourceRecord{Name: "Example.com.", Type: DNSTypeMX, Class: DNSClassIN, TTL: 300},
+		Preference:        10,
+		Exchange:          "MAIL.example.com",
+	}
+	b := &DNSResourceRecordMX{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeMX, Class: DNSClassNone},
+		Preference:        10,
+		Exchange:          "mail.example.com.",
+	}
+	if !EqualRData(a, b) {
+		t.Error("records differing only in case, class and TTL should match")
+	}
+	b.Preference = 20
+	if EqualRData(a, b) {
+		t.Error("records with different RDATA should not match")
+	}
+}
+
+func truncTestA(name string, i int) *DNSResourceRecordA {
+	return &DNSResourceRecordA{
+		DNSResourceRecord: DNSResourceRecord{Name: name, Type: DNSTypeA, Class: DNSClassIN, TTL: 300},
+		Address:           fmt.Sprintf("192.0.2.%d", i),
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	build := func() *DNSPacket {
+		p := NewPacket()
+		p.Header.QR = DNSResponse
+		p.AddQuestionA("big.example.com")
+		for i := 0; i < 20; i++ {
+			p.AddAnswer(truncTestA("big.example.com", i))
+		}
+		for i := 0; i < 20; i++ {
+			p.AddAnswer(truncTestA("bigger.example.com", i))
+		}
+		p.AddAuthority(&DNSResourceRecordNS{
+			DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeNS, Class: DNSClassIN, TTL: 300},
+			NameServer:        "ns1.example.com",
+		})
+		p.AddAdditional(truncTestA("ns1.example.com", 1))
+		p.AddAdditionalEDNS(1232, 0, 0, false)
+		return p
+	}
+
+	p := build()
+	if p.Truncate(p.Len()) {
+		t.Error("a message that fits should be left alone")
+	}
+
+	p = build()
+	if !p.Truncate(512) {
+		t.Fatal("expected the message to be cut")
+	}
+	if p.Len() > 512 {
+		t.Errorf("truncated message is %d bytes", p.Len())
+	}
+	if p.Header.TC != 1 {
+		t.Error("TC should be set when answers are dropped")
+	}
+	if len(p.Answers) != 20 || p.Answers[19].GetHeader().Name != "big.example.com" {
+		t.Errorf("expected the first RRset whole and nothing of the second, got %d answers", len(p.Answers))
+	}
+	if len(p.Authorities) != 0 || len(p.Additionals) != 1 || p.EDNS() == nil {
+		t.Errorf("only the OPT record should follow a cut answer section, got %v %v", p.Authorities, p.Additionals)
+	}
+	q, err := FromBytes(p.Bytes())
+	if err != nil || q.Header.ANCount != 20 || q.Header.TC != 1 {
+		t.Errorf("truncated message doesn't round-trip: %v %v", q, err)
+	}
+
+	// additional data alone is dropped without TC
+	p = build()
+	size := p.Len() - 10
+	if !p.Truncate(size) || p.Header.TC != 0 {
+		t.Errorf("dropping additional data shouldn't set TC")
+	}
+	if len(p.Answers) != 40 || len(p.Authorities) != 1 || len(p.Additionals) != 1 || p.EDNS() == nil {
+		t.Errorf("expected only the glue to go, got %d/%d/%d", len(p.Answers), len(p.Authorities), len(p.Additionals))
+	}
+
+	// every cut is as long as it can be and no longer
+	full := build().Len()
+	for size := 100; size < full; size += 7 {
+		p = build()
+		p.Truncate(size)
+		kept := len(p.Answers) + len(p.Authorities) + len(p.Additionals)
+		if n := p.Len(); n > size {
+			t.Fatalf("Truncate(%d) left %d bytes", size, n)
+		}
+		if q := build(); q.Truncate(size+1) && len(q.Answers)+len(q.Authorities)+len(q.Additionals) < kept {
+			t.Fatalf("Truncate(%d) kept fewer records than Truncate(%d)", size+1, size)
+		}
+	}
+}
+
+func TestUDPSize(t *testing.T) {
+	p := NewPacket()
+	if p.UDPSize() != MinUDPSize {
+		t.Errorf("without OPT: %d", p.UDPSize())
+	}
+	p.AddAdditionalEDNS(256, 0, 0, false)
+	if p.UDPSize() != MinUDPSize {
+		t.Errorf("advertised sizes below 512 mean 512, got %d", p.UDPSize())
+	}
+	p = NewPacket()
+	p.AddAdditionalEDNS(4096, 0, 0, false)
+	if p.UDPSize() != 4096 {
+		t.Errorf("with OPT: %d", p.UDPSize())
+	}
+}
+
+func TestNewDNSJSONResponse(t *testing.T) {
+	pkt := NewPacket()
+	pkt.Header.QR = DNSResponse
+	pkt.Header.RD = 1
+	pkt.Header.RA = 1
+	pkt.Header.SetAuthenticData(true)
+	pkt.AddQuestionMX("example.com")
+	pkt.AddAnswer(&DNSResourceRecordMX{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: DNSTypeMX, Class: DNSClassIN, TTL: 300},
+		Preference:        10,
+		Exchange:          "mail.example.com",
+	})
+	pkt.AddAnswer(&DNSResourceRecordUnknown{
+		DNSResourceRecord: DNSResourceRecord{Name: "example.com", Type: 65280, Class: DNSClassIN, TTL: 60},
+		RData:             []byte{0xde, 0xad},
+	})
+	pkt.AddAdditionalEDNS(1232, 0, 0, false)
+	pkt.EDNS().AddEDNSOptionClientSubnet(net.IPv4(198, 51, 100, 7), 24)
+	res, err := FromBytes(pkt.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(NewDNSJSONResponse(res))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Status":0,"TC":false,"RD":true,"RA":true,"AD":true,"CD":false,` +
+		`"Question":[{"name":"example.com.","type":15}],` +
+		`"Answer":[{"name":"example.com.","type":15,"TTL":300,"data":"10 mail.example.com."},` +
+		`{"name":"example.com.","type":65280,"TTL":60,"data":"\\# 2 dead"}],` +
+		`"edns_client_subnet":"198.51.100.0/24"}`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+}
+
+func TestDNSJSONResponsePacket(t *testing.T) {
+	in := &DNSJSONResponse{
+		Status:   DNSRCodeNoError,
+		RD:       true,
+		RA:       true,
+		AD:       true,
+		Question: []DNSJSONQuestion{{Name: "example.com.", Type: DNSTypeMX}},
+		Answer: []DNSJSONRecord{
+			{Name: "example.com.", Type: DNSTypeMX, TTL: 300, Data: "10 mail.example.com."},
+			{Name: "example.com.", Type: DNSTypeTXT, TTL: 300, Data: `"v=spf1 -all" "two words"`},
+			{Name: "example.com.", Type: 65280, TTL: 60, Data: `\# 2 dead`},
+		},
+	}
+	p, err := in.Packet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Header.QR != DNSResponse || p.Header.RD != 1 || p.Header.RA != 1 || !p.Header.AuthenticData() || p.Header.ANCount != 3 {
+		t.Fatalf("unexpected header %+v", p.Header)
+	}
+	if q := p.Questions[0]; q.Name != "example.com" || q.Type != DNSTypeMX || q.Class != DNSClassIN {
+		t.Errorf("question %+v", q)
+	}
+	if mx, ok := p.Answers[0].(*DNSResourceRecordMX); !ok || mx.Name != "example.com" || mx.Preference != 10 || mx.Exchange != "mail.example.com" {
+		t.Errorf("MX %v", p.Answers[0])
+	}
+	if txt, ok := p.Answers[1].(*DNSResourceRecordTXT); !ok || len(txt.Text) != 2 || txt.Text[1] != "two words" {
+		t.Errorf("TXT %v", p.Answers[1])
+	}
+	if u, ok := p.Answers[2].(*DNSResourceRecordUnknown); !ok || !bytes.Equal(u.RData, []byte{0xde, 0xad}) {
+		t.Errorf("unknown %v", p.Answers[2])
+	}
+	if _, err := FromBytes(p.Bytes()); err != nil {
+		t.Errorf("re-decoding: %v", err)
+	}
+
+	bad := NewPacket()
+	bad.Header.QR = DNSResponse
+	bad.AddQuestionA("example.com")
+	bad.SetRCode(DNSRCodeBadCookie)
+	p, err = NewDNSJSONResponse(bad).Packet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.RCode() != DNSRCodeBadCookie || p.Header.ARCount != 1 {
+		t.Errorf("rcode %v, ARCount %d", p.RCode(), p.Header.ARCount)
+	}
+
+	in.Answer = []DNSJSONRecord{{Name: "example.com.", Type: DNSTypeMX, TTL: 300, Data: "ten mail.example.com."}}
+	if _, err := in.Packet(); err == nil {
+		t.Error("bad MX data: no error")
+	}
+}
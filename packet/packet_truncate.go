@@ -0,0 +1,121 @@
+package packet
+
+import "bytes"
+
+// MinUDPSize is the UDP payload every DNS client accepts (RFC 1035
+// §4.2.1); a larger one must be advertised with an OPT record.
+const MinUDPSize = 512
+
+// UDPSize returns the largest UDP response the sender of p accepts: the
+// size its OPT record advertises, but never less than MinUDPSize.
+func (p *DNSPacket) UDPSize() int {
+	if opt := p.EDNS(); opt != nil && int(opt.UDPSize) > MinUDPSize {
+		return int(opt.UDPSize)
+	}
+	return MinUDPSize
+}
+
+// Len returns the length of p encoded with name compression.
+func (p *DNSPacket) Len() int {
+	var buf bytes.Buffer
+	p.pack(&buf, true)
+	return buf.Len()
+}
+
+// Truncate cuts p down, if needed, so it encodes to at most size bytes,
+// and reports whether anything was removed. Records are dropped a whole
+// RRset at a time, from the end of the message (RFC 2181 §9): the
+// questions and the OPT record are always kept. TC is set when part of
+// the answer or authority section had to go, so the client asks again
+// over TCP; dropping additional records alone doesn't set it.
+func (p *DNSPacket) Truncate(size int) bool {
+	if p.Len() <= size {
+		return false
+	}
+	var opt DNSResource
+	var data []DNSResource
+	for _, rr := range p.Additionals {
+		if rr.GetType() == DNSTypeEDNS {
+			opt = rr
+		} else {
+			data = append(data, rr)
+		}
+	}
+	sections := [][][]DNSResource{rrsets(p.Answers), rrsets(p.Authorities), rrsets(data)}
+
+	// A record encodes the same whatever follows it, as compression only
+	// points back, so one pass over the RRsets in the order they'd be kept
+	// gives the length of the message cut after each of them. The OPT
+	// record goes last and is never compressed.
+	optLen := 0
+	if opt != nil {
+		var b bytes.Buffer
+		encodeResource(&b, opt, nil)
+		optLen = b.Len()
+	}
+	c := compressorPool.Get().(*Compressor)
+	defer func() {
+		c.Reset()
+		compressorPool.Put(c)
+	}()
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 12))
+	for _, q := range p.Questions {
+		q.encode(&buf, c)
+	}
+	kept := make([][]DNSResource, len(sections))
+	cut := -1
+	for s := 0; s < len(sections) && cut < 0; s++ {
+		for _, set := range sections[s] {
+			var err error
+			for _, rr := range set {
+				if err = encodeResource(&buf, rr, c); err != nil {
+					break
+				}
+			}
+			if err != nil || buf.Len()+optLen > size {
+				cut = s
+				break
+			}
+			kept[s] = append(kept[s], set...)
+		}
+	}
+
+	p.Answers, p.Authorities, p.Additionals = kept[0], kept[1], kept[2]
+	if opt != nil {
+		p.Additionals = append(p.Additionals, opt)
+	}
+	// TC tells the client part of the answer or authority section is
+	// missing; dropping additional data alone doesn't set it
+	if cut == 0 || cut == 1 {
+		p.Header.TC = 1
+	}
+	p.Header.ANCount = uint16(len(p.Answers))
+	p.Header.NSCount = uint16(len(p.Authorities))
+	p.Header.ARCount = uint16(len(p.Additionals))
+	return true
+}
+
+// rrsets groups records into RRsets, in order of first appearance.
+func rrsets(records []DNSResource) [][]DNSResource {
+	var sets [][]DNSResource
+	index := make(map[rrsetKey]int)
+	for _, rr := range records {
+		h := rr.GetHeader()
+		key := rrsetKey{CanonicalName(h.Name), h.Type, h.Class}
+		i, ok := index[key]
+		if !ok {
+			i = len(sets)
+			index[key] = i
+			sets = append(sets, nil)
+		}
+		sets[i] = append(sets[i], rr)
+	}
+	return sets
+}
+
+type rrsetKey struct {
+	name  string
+	rtype DNSType
+	class DNSClass
+}
@@ -0,0 +1,421 @@
+package packet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// TSIG algorithm names (RFC 8945 §6).
+const (
+	TSIGHMACSHA256 = "hmac-sha256."
+	TSIGHMACSHA512 = "hmac-sha512."
+)
+
+// DefaultTSIGFudge is the clock skew, in seconds, allowed between signer
+// and verifier (RFC 8945 §10).
+const DefaultTSIGFudge = 300
+
+// maxUnsignedTSIGMessages is how many messages of a TCP stream may go
+// unsigned between two signed ones (RFC 8945 §5.3.1).
+const maxUnsignedTSIGMessages = 99
+
+// Errors returned while verifying TSIG. ErrTSIGBadKey, ErrTSIGBadSig,
+// ErrTSIGBadTime and ErrTSIGBadTrunc correspond to the TSIG error codes of
+// the same name; TSIGErrorCode maps them back.
+var (
+	ErrTSIGUnsigned = errors.New("tsig: message is not signed")
+	ErrTSIGFormat   = errors.New("tsig: malformed TSIG record")
+	ErrTSIGBadKey   = errors.New("tsig: BADKEY")
+	ErrTSIGBadSig   = errors.New("tsig: BADSIG")
+	ErrTSIGBadTime  = errors.New("tsig: BADTIME")
+	ErrTSIGBadTrunc = errors.New("tsig: BADTRUNC")
+)
+
+// tsigNow is the clock used to stamp and check signatures.
+var tsigNow = time.Now
+
+// TSIGKey is a shared secret known to both ends of a transaction.
+type TSIGKey struct {
+	Name      string
+	Algorithm string // TSIGHMACSHA256 or TSIGHMACSHA512
+	Secret    []byte
+}
+
+func (k TSIGKey) newHash() (hash.Hash, error) {
+	switch CanonicalName(k.Algorithm) {
+	case CanonicalName(TSIGHMACSHA256):
+		return hmac.New(sha256.New, k.Secret), nil
+	case CanonicalName(TSIGHMACSHA512):
+		return hmac.New(sha512.New, k.Secret), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrTSIGBadKey, k.Algorithm)
+}
+
+// TSIGKeyring holds the keys a verifier accepts, indexed by key name.
+type TSIGKeyring map[string]TSIGKey
+
+// Add stores key under its name.
+func (kr TSIGKeyring) Add(key TSIGKey) {
+	kr[CanonicalName(key.Name)] = key
+}
+
+// Lookup returns the key called name, comparing names as EqualName does.
+func (kr TSIGKeyring) Lookup(name string) (TSIGKey, bool) {
+	key, ok := kr[CanonicalName(name)]
+	return key, ok
+}
+
+// TSIG returns the packet's TSIG record, which must be the last additional
+// record, or nil if the packet isn't signed.
+func (p *DNSPacket) TSIG() *DNSResourceRecordTSIG {
+	if n := len(p.Additionals); n > 0 {
+		if rr, ok := p.Additionals[n-1].(*DNSResourceRecordTSIG); ok {
+			return rr
+		}
+	}
+	return nil
+}
+
+// SignTSIG signs p with key and appends the TSIG record, replacing an
+// earlier one. requestMAC is the MAC of the signed request p answers, or
+// nil when p is itself a request. The signature covers p as Bytes encodes
+// it, so p must be sent compressed and unmodified.
+func (p *DNSPacket) SignTSIG(key TSIGKey, requestMAC []byte) (*DNSResourceRecordTSIG, error) {
+	return NewTSIGSigner(key, requestMAC).Sign(p)
+}
+
+// VerifyTSIG checks the TSIG record of the message in data against
+// keyring; requestMAC is the MAC of the request when data is a response.
+// The record is returned with ErrTSIGBadTime as well, since its MAC was
+// valid and the reply to it is signed.
+func VerifyTSIG(data []byte, keyring TSIGKeyring, requestMAC []byte) (*DNSResourceRecordTSIG, error) {
+	return NewTSIGVerifier(keyring, requestMAC).Verify(data)
+}
+
+// TSIGErrorCode returns the TSIG error code that reports err, or
+// DNSRCodeNoError if err is not a TSIG verification error.
+func TSIGErrorCode(err error) DNSRCode {
+	switch {
+	case errors.Is(err, ErrTSIGBadKey):
+		return DNSRCodeBadKey
+	case errors.Is(err, ErrTSIGBadSig):
+		return DNSRCodeBadSig
+	case errors.Is(err, ErrTSIGBadTime):
+		return DNSRCodeBadTime
+	case errors.Is(err, ErrTSIGBadTrunc):
+		return DNSRCodeBadTrunc
+	}
+	return DNSRCodeNoError
+}
+
+func tsigError(code DNSRCode) error {
+	switch code {
+	case DNSRCodeBadKey:
+		return ErrTSIGBadKey
+	case DNSRCodeBadSig:
+		return ErrTSIGBadSig
+	case DNSRCodeBadTime:
+		return ErrTSIGBadTime
+	case DNSRCodeBadTrunc:
+		return ErrTSIGBadTrunc
+	}
+	return fmt.Errorf("%w: error %d", ErrTSIGFormat, code)
+}
+
+// tsigErrorName names the TSIG error field, where 16 is BADSIG rather
+// than BADVERS.
+func tsigErrorName(code DNSRCode) string {
+	if code == DNSRCodeBadSig {
+		return "BADSIG"
+	}
+	return code.String()
+}
+
+// TSIGSigner signs the messages of a transaction. The first message is
+// signed over all TSIG variables; later ones, as in a zone transfer over
+// TCP, chain on the previous MAC and cover only the timers (RFC 8945
+// §5.3.1).
+type TSIGSigner struct {
+	Fudge uint16
+	// Error and OtherData are set on the first signature only, to sign
+	// an error reply such as BADTIME.
+	Error     DNSRCode
+	OtherData []byte
+
+	key     TSIGKey
+	prevMAC []byte
+	pending bytes.Buffer // messages sent unsigned since the last signature
+	signed  bool
+}
+
+// NewTSIGSigner returns a signer for key. requestMAC is the MAC of the
+// request being answered, or nil when signing a request.
+func NewTSIGSigner(key TSIGKey, requestMAC []byte) *TSIGSigner {
+	return &TSIGSigner{Fudge: DefaultTSIGFudge, key: key, prevMAC: requestMAC}
+}
+
+// Key returns the key the signer signs with.
+func (s *TSIGSigner) Key() TSIGKey {
+	return s.key
+}
+
+// Size returns how many bytes the TSIG record Sign appends takes at most,
+// so a message that has to fit a size limit can be truncated first.
+func (s *TSIGSigner) Size() int {
+	rr := &DNSResourceRecordTSIG{
+		DNSResourceRecord: DNSResourceRecord{Name: s.key.Name, Type: DNSTypeTSIG, Class: DNSClassAny},
+		Algorithm:         s.key.Algorithm,
+	}
+	if h, err := s.key.newHash(); err == nil {
+		rr.MAC = make([]byte, h.Size())
+	}
+	if !s.signed {
+		rr.OtherData = s.OtherData
+	}
+	var buf bytes.Buffer
+	encodeResource(&buf, rr, nil)
+	return buf.Len()
+}
+
+// Sign signs the next message of the transaction and appends its TSIG
+// record, as SignTSIG does.
+func (s *TSIGSigner) Sign(p *DNSPacket) (*DNSResourceRecordTSIG, error) {
+	h, err := s.key.newHash()
+	if err != nil {
+		return nil, err
+	}
+	if p.TSIG() != nil {
+		p.Additionals = p.Additionals[:len(p.Additionals)-1]
+	}
+	rr := &DNSResourceRecordTSIG{
+		DNSResourceRecord: DNSResourceRecord{Name: s.key.Name, Type: DNSTypeTSIG, Class: DNSClassAny},
+		Algorithm:         s.key.Algorithm,
+		TimeSigned:        uint64(tsigNow().Unix()),
+		Fudge:             s.Fudge,
+		OriginalID:        p.Header.ID,
+	}
+	if !s.signed {
+		rr.Error = s.Error
+		rr.OtherData = s.OtherData
+	}
+	var msg bytes.Buffer
+	if err := p.PackTo(&msg, true); err != nil {
+		return nil, err
+	}
+	if s.signed || s.prevMAC != nil {
+		writeTSIGMAC(h, s.prevMAC)
+	}
+	h.Write(s.pending.Bytes())
+	h.Write(msg.Bytes())
+	writeTSIGVariables(h, rr, s.signed)
+	rr.MAC = h.Sum(nil)
+	p.AddAdditional(rr)
+	s.prevMAC = rr.MAC
+	s.pending.Reset()
+	s.signed = true
+	return rr, nil
+}
+
+// Skip records a message of the transaction, in wire form, that is sent
+// without a TSIG record, so the next signature covers it. Only messages
+// after the first may be skipped.
+func (s *TSIGSigner) Skip(data []byte) {
+	s.pending.Write(data)
+}
+
+// TSIGVerifier verifies the messages of a transaction, the counterpart of
+// TSIGSigner. After the first message, which must be signed, up to 99
+// messages in a row may arrive unsigned; they are folded into the digest
+// of the next signed one.
+type TSIGVerifier struct {
+	keyring  TSIGKeyring
+	key      TSIGKey
+	prevMAC  []byte
+	pending  bytes.Buffer // unsigned messages since the last signed one
+	unsigned int
+	verified bool
+}
+
+// NewTSIGVerifier returns a verifier accepting the keys of keyring.
+// requestMAC is the MAC of the request the messages answer, or nil when
+// verifying a request.
+func NewTSIGVerifier(keyring TSIGKeyring, requestMAC []byte) *TSIGVerifier {
+	return &TSIGVerifier{keyring: keyring, prevMAC: requestMAC}
+}
+
+// Key returns the key that signed the transaction so far.
+func (v *TSIGVerifier) Key() TSIGKey {
+	return v.key
+}
+
+// Verify checks the next message of the transaction. It returns nil and
+// no error for an unsigned message that may still be covered by a later
+// signature.
+func (v *TSIGVerifier) Verify(data []byte) (*DNSResourceRecordTSIG, error) {
+	off, rr, err := findTSIG(data)
+	if err != nil {
+		return nil, err
+	}
+	if rr == nil {
+		if !v.verified {
+			return nil, ErrTSIGUnsigned
+		}
+		if v.unsigned++; v.unsigned > maxUnsignedTSIGMessages {
+			return nil, fmt.Errorf("%w: more than %d messages in a row", ErrTSIGUnsigned, maxUnsignedTSIGMessages)
+		}
+		v.pending.Write(data)
+		return nil, nil
+	}
+	if rr.Error != DNSRCodeNoError {
+		return rr, fmt.Errorf("peer reported %w", tsigError(rr.Error))
+	}
+
+	key, ok := v.keyring.Lookup(rr.Name)
+	if !ok || !EqualName(key.Algorithm, rr.Algorithm) || (v.verified && !EqualName(key.Name, v.key.Name)) {
+		return nil, fmt.Errorf("%w: %q", ErrTSIGBadKey, rr.Name)
+	}
+	h, err := key.newHash()
+	if err != nil {
+		return nil, err
+	}
+	if size := h.Size(); len(rr.MAC) > size || len(rr.MAC) < size/2 || len(rr.MAC) < 10 {
+		return nil, fmt.Errorf("%w: MAC of %d bytes", ErrTSIGFormat, len(rr.MAC))
+	}
+	if v.verified || v.prevMAC != nil {
+		writeTSIGMAC(h, v.prevMAC)
+	}
+	h.Write(v.pending.Bytes())
+	// the message as signed: original ID and without the TSIG record
+	var header [12]byte
+	copy(header[:], data)
+	binary.BigEndian.PutUint16(header[0:], rr.OriginalID)
+	binary.BigEndian.PutUint16(header[10:], binary.BigEndian.Uint16(header[10:])-1)
+	h.Write(header[:])
+	h.Write(data[12:off])
+	writeTSIGVariables(h, rr, v.verified)
+	if !hmac.Equal(h.Sum(nil)[:len(rr.MAC)], rr.MAC) {
+		return nil, ErrTSIGBadSig
+	}
+
+	v.key = key
+	v.prevMAC = rr.MAC
+	v.pending.Reset()
+	v.unsigned = 0
+	v.verified = true
+	now := tsigNow().Unix()
+	if skew := now - int64(rr.TimeSigned); skew > int64(rr.Fudge) || -skew > int64(rr.Fudge) {
+		return rr, fmt.Errorf("%w: signed at %d, now %d", ErrTSIGBadTime, rr.TimeSigned, now)
+	}
+	return rr, nil
+}
+
+// Done reports whether the transaction ended on a signed message.
+func (v *TSIGVerifier) Done() error {
+	if !v.verified || v.unsigned > 0 {
+		return fmt.Errorf("%w: transaction ended with unsigned messages", ErrTSIGUnsigned)
+	}
+	return nil
+}
+
+// writeTSIGMAC digests a previous MAC with its length prefix.
+func writeTSIGMAC(h hash.Hash, mac []byte) {
+	var size [2]byte
+	binary.BigEndian.PutUint16(size[:], uint16(len(mac)))
+	h.Write(size[:])
+	h.Write(mac)
+}
+
+// writeTSIGVariables digests the TSIG variables of RFC 8945 §4.3.3, or
+// only the timers for the later messages of a transaction.
+func writeTSIGVariables(h hash.Hash, rr *DNSResourceRecordTSIG, timersOnly bool) {
+	var buf bytes.Buffer
+	var fixed [8]byte
+	if !timersOnly {
+		encodeDomainName(&buf, CanonicalName(rr.Name), nil)
+		binary.BigEndian.PutUint16(fixed[0:], uint16(DNSClassAny))
+		binary.BigEndian.PutUint32(fixed[2:], 0) // TTL
+		buf.Write(fixed[:6])
+		encodeDomainName(&buf, CanonicalName(rr.Algorithm), nil)
+	}
+	binary.BigEndian.PutUint16(fixed[0:], uint16(rr.TimeSigned>>32))
+	binary.BigEndian.PutUint32(fixed[2:], uint32(rr.TimeSigned))
+	binary.BigEndian.PutUint16(fixed[6:], rr.Fudge)
+	buf.Write(fixed[:])
+	if !timersOnly {
+		binary.BigEndian.PutUint16(fixed[0:], uint16(rr.Error))
+		binary.BigEndian.PutUint16(fixed[2:], uint16(len(rr.OtherData)))
+		buf.Write(fixed[:4])
+		buf.Write(rr.OtherData)
+	}
+	h.Write(buf.Bytes())
+}
+
+// findTSIG locates the TSIG record of the message in data and returns its
+// offset, or a nil record if the message has none. A TSIG record anywhere
+// but last in the additional section is an error.
+func findTSIG(data []byte) (int, *DNSResourceRecordTSIG, error) {
+	if len(data) < 12 {
+		return 0, nil, &ParseError{Section: SectionHeader, Err: io.ErrUnexpectedEOF}
+	}
+	qdCount := int(binary.BigEndian.Uint16(data[4:]))
+	records := int(binary.BigEndian.Uint16(data[6:])) + int(binary.BigEndian.Uint16(data[8:]))
+	arCount := int(binary.BigEndian.Uint16(data[10:]))
+	records += arCount
+	off := 12
+	var err error
+	for i := 0; i < qdCount; i++ {
+		if off, err = skipName(data, off); err != nil {
+			return 0, nil, &ParseError{Section: SectionQuestion, Index: i, Err: err}
+		}
+		off += 4
+	}
+	for i := 0; i < records; i++ {
+		start := off
+		if off, err = skipName(data, off); err != nil {
+			return 0, nil, err
+		}
+		if len(data)-off < 10 {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		if DNSType(binary.BigEndian.Uint16(data[off:])) == DNSTypeTSIG {
+			if i != records-1 || arCount == 0 {
+				return 0, nil, fmt.Errorf("%w: not the last additional record", ErrTSIGFormat)
+			}
+			rr, _, err := unpackResource(data, start)
+			if err != nil {
+				return 0, nil, fmt.Errorf("%w: %v", ErrTSIGFormat, err)
+			}
+			return start, rr.(*DNSResourceRecordTSIG), nil
+		}
+		off += 10 + int(binary.BigEndian.Uint16(data[off+8:]))
+	}
+	return 0, nil, nil
+}
+
+// skipName returns the offset just past the name at off without decoding
+// it.
+func skipName(data []byte, off int) (int, error) {
+	for {
+		if off >= len(data) {
+			return off, io.ErrUnexpectedEOF
+		}
+		n := int(data[off])
+		switch {
+		case n == 0:
+			return off + 1, nil
+		case n&0xc0 == 0xc0:
+			return off + 2, nil
+		case n&0xc0 != 0:
+			return off, fmt.Errorf("%w: %#x", ErrLabelTypeUnknown, n&0xc0)
+		}
+		off += 1 + n
+	}
+}
@@ -0,0 +1,271 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Unpack decodes data into p. It accepts exactly the messages FromBytes
+// accepts, but walks data by offset instead of through a bytes.Reader:
+// fixed-size fields are read in place, names are assembled in a stack
+// buffer, and the common record types (A, AAAA, CNAME, NS, PTR, MX and OPT)
+// are decoded without an intermediate reader. p's header and section slices
+// are reused, so a packet can be recycled across messages.
+//
+// The decoded packet holds no references into data, which the caller may
+// overwrite as soon as Unpack returns. Malformed input is reported as a
+// *ParseError and leaves p in an unspecified state.
+func (p *DNSPacket) Unpack(data []byte) error {
+	if p.Header == nil {
+		p.Header = &DNSHeader{}
+	}
+	h := p.Header
+	if len(data) < 12 {
+		return &ParseError{Section: SectionHeader, Err: io.ErrUnexpectedEOF}
+	}
+	h.ID = binary.BigEndian.Uint16(data)
+	h.QR = data[2] >> 7
+	h.OpCode = (data[2] >> 3) & 0x0F
+	h.AA = (data[2] >> 2) & 0x01
+	h.TC = (data[2] >> 1) & 0x01
+	h.RD = data[2] & 0x01
+	h.RA = data[3] >> 7
+	h.Z = (data[3] >> 4) & 0x07
+	h.RCode = data[3] & 0x0F
+	h.QDCount = binary.BigEndian.Uint16(data[4:])
+	h.ANCount = binary.BigEndian.Uint16(data[6:])
+	h.NSCount = binary.BigEndian.Uint16(data[8:])
+	h.ARCount = binary.BigEndian.Uint16(data[10:])
+	off := 12
+
+	questions := p.Questions[:0]
+	for i := 0; i < int(h.QDCount); i++ {
+		var q *DNSQuestion
+		if i < len(p.Questions) && p.Questions[i] != nil {
+			q = p.Questions[i]
+		} else {
+			q = &DNSQuestion{}
+		}
+		var err error
+		if off, err = unpackQuestion(q, data, off); err != nil {
+			return &ParseError{Section: SectionQuestion, Index: i, Err: err}
+		}
+		questions = append(questions, q)
+	}
+	p.Questions = questions
+
+	var err error
+	if p.Answers, off, err = unpackSection(p.Answers[:0], data, off, int(h.ANCount), SectionAnswer); err != nil {
+		return err
+	}
+	if p.Authorities, off, err = unpackSection(p.Authorities[:0], data, off, int(h.NSCount), SectionAuthority); err != nil {
+		return err
+	}
+	if p.Additionals, _, err = unpackSection(p.Additionals[:0], data, off, int(h.ARCount), SectionAdditional); err != nil {
+		return err
+	}
+	return nil
+}
+
+func unpackQuestion(q *DNSQuestion, data []byte, off int) (int, error) {
+	name, off, err := unpackName(data, off)
+	if err != nil {
+		return off, err
+	}
+	if len(data)-off < 4 {
+		return off, io.ErrUnexpectedEOF
+	}
+	q.Name = name
+	q.Type = DNSType(binary.BigEndian.Uint16(data[off:]))
+	q.Class = DNSClass(binary.BigEndian.Uint16(data[off+2:]))
+	return off + 4, nil
+}
+
+func unpackSection(records []DNSResource, data []byte, off, count int, section string) ([]DNSResource, int, error) {
+	for i := 0; i < count; i++ {
+		rr, next, err := unpackResource(data, off)
+		if err != nil {
+			return records, off, &ParseError{Section: section, Index: i, Err: err}
+		}
+		records = append(records, rr)
+		off = next
+	}
+	return records, off, nil
+}
+
+// unpackResource is the offset-based counterpart of ParseResource.
+func unpackResource(data []byte, off int) (DNSResource, int, error) {
+	var r DNSResourceRecord
+	var err error
+	if r.Name, off, err = unpackName(data, off); err != nil {
+		return nil, off, err
+	}
+	if len(data)-off < 10 {
+		return nil, off, io.ErrUnexpectedEOF
+	}
+	r.Type = DNSType(binary.BigEndian.Uint16(data[off:]))
+	r.Class = DNSClass(binary.BigEndian.Uint16(data[off+2:]))
+	r.TTL = binary.BigEndian.Uint32(data[off+4:])
+	length := int(binary.BigEndian.Uint16(data[off+8:]))
+	off += 10
+	if length > len(data)-off {
+		return nil, off, fmt.Errorf("%w: RDLENGTH %d exceeds remaining %d bytes", ErrRDataLength, length, len(data)-off)
+	}
+	record := newRDataResource(r, length)
+	end := off + length
+	consumed, err := unpackRData(record, data, off, end)
+	if err != nil {
+		return nil, off, fmt.Errorf("%s record %q: %w", r.Type, r.Name, err)
+	}
+	if consumed != length {
+		return nil, off, fmt.Errorf("%s record %q: %w: decoded %d of %d bytes", r.Type, r.Name, ErrRDataLength, consumed, length)
+	}
+	return record, end, nil
+}
+
+// unpackRData decodes the RDATA at data[off:end] into record and returns
+// the number of bytes its decoder consumed. Like Decode, a name may run
+// past end; the caller rejects that through the returned count. Types
+// without a fast path fall back to their Decode method.
+func unpackRData(record DNSResource, data []byte, off, end int) (int, error) {
+	length := end - off
+	switch r := record.(type) {
+	case *DNSResourceRecordA:
+		if length != net.IPv4len {
+			return 0, fmt.Errorf("%w: A address must be %d bytes, got %d", ErrRDataLength, net.IPv4len, length)
+		}
+		r.Address = net.IP(data[off:end]).String()
+		return length, nil
+	case *DNSResourceRecordAAAA:
+		if length != net.IPv6len {
+			return 0, fmt.Errorf("%w: AAAA address must be %d bytes, got %d", ErrRDataLength, net.IPv6len, length)
+		}
+		r.Address = net.IP(data[off:end]).String()
+		return length, nil
+	case *DNSResourceRecordCNAME:
+		return unpackRDataName(&r.Domain, data, off)
+	case *DNSResourceRecordNS:
+		return unpackRDataName(&r.NameServer, data, off)
+	case *DNSResourceRecordPTR:
+		return unpackRDataName(&r.PtrDomainName, data, off)
+	case *DNSResourceRecordMX:
+		if len(data)-off < 2 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		r.Preference = binary.BigEndian.Uint16(data[off:])
+		n, err := unpackRDataName(&r.Exchange, data, off+2)
+		return 2 + n, err
+	case *DNSResourceRecordEDNS:
+		return unpackEDNS(r, data[off:end])
+	}
+	reader := bytes.NewReader(data)
+	reader.Seek(int64(off), io.SeekStart)
+	if err := record.Decode(reader, uint16(length)); err != nil {
+		return 0, err
+	}
+	return len(data) - reader.Len() - off, nil
+}
+
+func unpackRDataName(name *string, data []byte, off int) (int, error) {
+	s, next, err := unpackName(data, off)
+	if err != nil {
+		return 0, err
+	}
+	*name = s
+	return next - off, nil
+}
+
+// unpackEDNS mirrors (*DNSResourceRecordEDNS).Decode on an RDATA slice.
+// Option data is copied, since data belongs to the caller.
+func unpackEDNS(d *DNSResourceRecordEDNS, rdata []byte) (int, error) {
+	d.UDPSize = uint16(d.Class)
+	d.ExtRCode = uint8(d.TTL >> 24)
+	d.Version = uint8((d.TTL >> 16) & 0xFF)
+	d.Flags = uint16(d.TTL & 0xFFFF)
+	for off := 0; off < len(rdata); {
+		if len(rdata)-off < 4 {
+			return 0, fmt.Errorf("%w: %d trailing bytes after last EDNS option", ErrRDataLength, len(rdata)-off)
+		}
+		option := EDNSOption{Code: binary.BigEndian.Uint16(rdata[off:])}
+		optionLength := int(binary.BigEndian.Uint16(rdata[off+2:]))
+		off += 4
+		if optionLength > len(rdata)-off {
+			return 0, fmt.Errorf("%w: EDNS option %d length %d exceeds RDATA", ErrRDataLength, option.Code, optionLength)
+		}
+		option.Data = make([]byte, optionLength)
+		copy(option.Data, rdata[off:])
+		off += optionLength
+		d.Options = append(d.Options, option)
+	}
+	return len(rdata), nil
+}
+
+// unpackName is the offset-based counterpart of decodeDomainName and
+// enforces the same rules: pointers must point strictly before the labels
+// being read, and the jump count and wire length are capped. It returns
+// the escaped name and the offset just past it as it appeared at off.
+func unpackName(data []byte, off int) (string, int, error) {
+	// escaping can quadruple a label, so size the buffer for the worst case
+	var buf [4 * maxNameLength]byte
+	name := buf[:0]
+	wireLen := 1 // root label
+	jumps := 0
+	resume := -1 // where to continue once the first pointer is taken
+	start := off
+	for {
+		if off >= len(data) {
+			return "", off, fmt.Errorf("error reading label length: %v", io.EOF)
+		}
+		pos := off
+		labelLen := int(data[off])
+		off++
+		if labelLen == 0 {
+			break
+		}
+		switch labelLen & 0xc0 {
+		case 0xc0:
+			if off >= len(data) {
+				return "", off, fmt.Errorf("error reading pointer byte: %v", io.EOF)
+			}
+			pointer := (labelLen&0x3f)<<8 | int(data[off])
+			if pointer >= start {
+				return "", off, fmt.Errorf("%w: %d at offset %d", ErrPointerForward, pointer, pos)
+			}
+			start = pointer
+			if jumps++; jumps > maxPointerJumps {
+				return "", off, ErrPointerLoop
+			}
+			if resume < 0 {
+				resume = pos + 2
+			}
+			off = pointer
+			continue
+		case 0x00:
+		default:
+			return "", off, fmt.Errorf("%w: %#x", ErrLabelTypeUnknown, labelLen&0xc0)
+		}
+
+		wireLen += 1 + labelLen
+		if wireLen > maxNameLength {
+			return "", off, ErrNameTooLong
+		}
+		if labelLen > len(data)-off {
+			return "", off, fmt.Errorf("error reading label: %v", io.ErrUnexpectedEOF)
+		}
+		if len(name) > 0 {
+			name = append(name, '.')
+		}
+		name = appendEscapedLabel(name, data[off:off+labelLen])
+		off += labelLen
+	}
+	if resume >= 0 {
+		off = resume
+	}
+	if len(name) == 0 {
+		return ".", off, nil // Root domain (used in EDNS OPT records)
+	}
+	return string(name), off, nil
+}
@@ -0,0 +1,119 @@
+package packet
+
+// An UPDATE message (RFC 2136 §2) reuses the sections of a query under
+// other names: the question section holds the zone being updated, the
+// answer section the prerequisites, the authority section the updates and
+// the additional section anything else, such as a TSIG record. The class of
+// a prerequisite or update selects its meaning:
+//
+//	prerequisite  class ANY   RRset (or, with type ANY, the name) exists
+//	              class NONE  RRset (or, with type ANY, the name) does not exist
+//	              zone class  RRset exists with exactly these records
+//	update        zone class  add the record to its RRset
+//	              class ANY   delete the RRset (or, with type ANY, every RRset at the name)
+//	              class NONE  delete the record
+//
+// Prerequisites and deletions other than "delete the record" carry no
+// RDATA; they decode as DNSResourceRecordUnknown with an empty RData.
+
+// NewUpdate returns an UPDATE message for zone, in class IN.
+func NewUpdate(zone string) *DNSPacket {
+	p := NewPacket()
+	p.Header.OpCode = uint8(DNSOpCodeUpdate)
+	p.AddQuestion(&DNSQuestion{
+		Name:  zone,
+		Type:  DNSTypeSOA,
+		Class: DNSClassIN,
+	})
+	return p
+}
+
+// UpdateZone returns the zone an UPDATE message applies to, or nil if the
+// zone section doesn't hold exactly one entry.
+func (p *DNSPacket) UpdateZone() *DNSQuestion {
+	if len(p.Questions) != 1 {
+		return nil
+	}
+	return p.Questions[0]
+}
+
+// AddPrereqRRsetExists requires an RRset of type t to exist at name,
+// whatever its records (RFC 2136 §2.4.1).
+func (p *DNSPacket) AddPrereqRRsetExists(name string, t DNSType) {
+	p.AddAnswer(emptyRRset(name, t, DNSClassAny))
+}
+
+// AddPrereqRRsetEqual requires the RRsets of rrs to exist with exactly
+// these records (RFC 2136 §2.4.2). The records are copied; TTLs are not
+// compared and go out as zero.
+func (p *DNSPacket) AddPrereqRRsetEqual(rrs ...DNSResource) {
+	for _, rr := range rrs {
+		p.AddAnswer(updateCopy(rr, p.zoneClass(), 0))
+	}
+}
+
+// AddPrereqRRsetNotExists requires that there be no RRset of type t at name
+// (RFC 2136 §2.4.3).
+func (p *DNSPacket) AddPrereqRRsetNotExists(name string, t DNSType) {
+	p.AddAnswer(emptyRRset(name, t, DNSClassNone))
+}
+
+// AddPrereqNameInUse requires name to own at least one record (RFC 2136
+// §2.4.4).
+func (p *DNSPacket) AddPrereqNameInUse(name string) {
+	p.AddAnswer(emptyRRset(name, DNSTypeAny, DNSClassAny))
+}
+
+// AddPrereqNameNotInUse requires name to own no records (RFC 2136 §2.4.5).
+func (p *DNSPacket) AddPrereqNameNotInUse(name string) {
+	p.AddAnswer(emptyRRset(name, DNSTypeAny, DNSClassNone))
+}
+
+// AddUpdateInsert adds rrs to their RRsets (RFC 2136 §2.5.1). The records
+// are copied and put in the zone's class.
+func (p *DNSPacket) AddUpdateInsert(rrs ...DNSResource) {
+	for _, rr := range rrs {
+		p.AddAuthority(updateCopy(rr, p.zoneClass(), rr.GetHeader().TTL))
+	}
+}
+
+// AddUpdateDeleteRRset deletes the RRset of type t at name (RFC 2136
+// §2.5.2).
+func (p *DNSPacket) AddUpdateDeleteRRset(name string, t DNSType) {
+	p.AddAuthority(emptyRRset(name, t, DNSClassAny))
+}
+
+// AddUpdateDeleteName deletes every RRset at name (RFC 2136 §2.5.3).
+func (p *DNSPacket) AddUpdateDeleteName(name string) {
+	p.AddAuthority(emptyRRset(name, DNSTypeAny, DNSClassAny))
+}
+
+// AddUpdateDelete deletes the individual records rrs from their RRsets
+// (RFC 2136 §2.5.4). Records are matched on name, type and RDATA.
+func (p *DNSPacket) AddUpdateDelete(rrs ...DNSResource) {
+	for _, rr := range rrs {
+		p.AddAuthority(updateCopy(rr, DNSClassNone, 0))
+	}
+}
+
+// zoneClass returns the class of the zone being updated.
+func (p *DNSPacket) zoneClass() DNSClass {
+	if z := p.UpdateZone(); z != nil {
+		return z.Class
+	}
+	return DNSClassIN
+}
+
+func emptyRRset(name string, t DNSType, class DNSClass) DNSResource {
+	return &DNSResourceRecordUnknown{
+		DNSResourceRecord: DNSResourceRecord{Name: name, Type: t, Class: class},
+	}
+}
+
+func updateCopy(rr DNSResource, class DNSClass, ttl uint32) DNSResource {
+	cp := CloneResource(rr)
+	h := cp.GetHeader()
+	h.Class = class
+	h.TTL = ttl
+	return cp
+}
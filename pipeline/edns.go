@@ -27,23 +27,36 @@ func StripEDNSIfNeeded(req, res *packet.DNSPacket) {
 		res.Header.ARCount = uint16(len(filtered))
 		return
 	}
-	for _, add := range res.Additionals {
+	// res may share records with a cached packet, so replace the OPT record
+	// with an edited copy rather than filtering its options in place.
+	for i, add := range res.Additionals {
 		opt, ok := add.(*packet.DNSResourceRecordEDNS)
-		if !ok {
+		if !ok || !hasOption(opt, packet.EDNSOptionPadding) {
 			continue
 		}
-		if len(opt.Options) == 0 {
-			continue
-		}
-		kept := opt.Options[:0]
-		for _, o := range opt.Options {
+		stripped := opt.Clone().(*packet.DNSResourceRecordEDNS)
+		kept := stripped.Options[:0]
+		for _, o := range stripped.Options {
 			if o.Code == packet.EDNSOptionPadding {
 				continue
 			}
 			kept = append(kept, o)
 		}
-		opt.Options = kept
+		stripped.Options = kept
+		additionals := make([]packet.DNSResource, len(res.Additionals))
+		copy(additionals, res.Additionals)
+		additionals[i] = stripped
+		res.Additionals = additionals
+	}
+}
+
+func hasOption(opt *packet.DNSResourceRecordEDNS, code uint16) bool {
+	for _, o := range opt.Options {
+		if o.Code == code {
+			return true
+		}
 	}
+	return false
 }
 
 func hasEDNS(p *packet.DNSPacket) bool {
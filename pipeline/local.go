@@ -2,8 +2,12 @@ package pipeline
 
 import (
 	"fmt"
+	"net"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/lsongdev/dns-go/cache"
 	"github.com/lsongdev/dns-go/config"
 	"github.com/lsongdev/dns-go/packet"
 	"github.com/lsongdev/dns-go/zone"
@@ -18,25 +22,73 @@ type LocalSource interface {
 }
 
 // LocalIndex is the default LocalSource: an in-memory map populated from
-// `domains:` in config.yaml (inline records or BIND zone files). Lookups are
-// O(zones * records); the assumption is "domains" is a small static list.
+// `domains:` in config.yaml (inline records, BIND zone files or transfers
+// from a primary). Lookups are O(zones * records); the assumption is
+// "domains" is a small static list. Zones can be changed at runtime
+// through Update and transfers; a zone's slice is never modified in place,
+// only replaced, so records handed out stay valid.
 type LocalIndex struct {
-	zones map[string][]packet.DNSResource // origin (lower-cased, no trailing dot)
+	mu          sync.RWMutex
+	zones       map[string][]packet.DNSResource // origin (lower-cased, no trailing dot)
+	acls        map[string]*updateACL           // origin -> who may send UPDATE
+	notifyACLs  map[string]*updateACL           // origin -> who may send NOTIFY
+	xfrACLs     map[string]*updateACL           // origin -> who may transfer the zone
+	secondaries map[string]*secondaryZone       // origin -> primary to transfer from
+	notify      map[string][]string             // origin -> secondaries to NOTIFY
+	keys        map[string]*packet.TSIGKey      // origin -> tsig_key shared with its peers
+	cache       *cache.Cache                    // purged of a zone when it changes
+
+	notifyInterval time.Duration
 }
 
-func NewLocalIndex(domains []config.DomainSpec) (*LocalIndex, error) {
-	li := &LocalIndex{zones: make(map[string][]packet.DNSResource)}
+// NewLocalIndex loads the zones of domains. keys holds the tsig_keys the
+// domains' tsig_key entries name.
+func NewLocalIndex(domains []config.DomainSpec, keys packet.TSIGKeyring) (*LocalIndex, error) {
+	li := &LocalIndex{
+		zones:       make(map[string][]packet.DNSResource),
+		acls:        make(map[string]*updateACL),
+		notifyACLs:  make(map[string]*updateACL),
+		xfrACLs:     make(map[string]*updateACL),
+		secondaries: make(map[string]*secondaryZone),
+		notify:      make(map[string][]string),
+		keys:        make(map[string]*packet.TSIGKey),
+
+		notifyInterval: notifyInterval,
+	}
 	for i, d := range domains {
 		if d.Domain == "" {
 			return nil, fmt.Errorf("domains[%d]: domain required", i)
 		}
-		origin := strings.ToLower(strings.TrimSuffix(d.Domain, "."))
+		origin, err := packet.IDNAToASCII(d.Domain)
+		if err != nil {
+			return nil, fmt.Errorf("domains[%d] (%s): %w", i, d.Domain, err)
+		}
+		origin = packet.CanonicalName(origin)
+		if d.TSIGKey != "" {
+			key, ok := keys.Lookup(d.TSIGKey)
+			if !ok {
+				return nil, fmt.Errorf("domains[%d] (%s): tsig_key %q unknown", i, d.Domain, d.TSIGKey)
+			}
+			li.keys[origin] = &key
+		}
 
 		var z *zone.Zone
-		var err error
 		switch {
 		case d.ZoneFile != "" && len(d.Records) > 0:
 			return nil, fmt.Errorf("domains[%d] (%s): only one of records or zone_file allowed", i, d.Domain)
+		case d.Primary != "":
+			// filled in by the first transfer
+			z = &zone.Zone{Origin: origin}
+			allow := d.AllowNotify
+			if len(allow) == 0 {
+				if host, _, _ := net.SplitHostPort(d.Primary); net.ParseIP(host) != nil {
+					allow = []string{host}
+				}
+			}
+			if err := addACL(li.notifyACLs, origin, allow); err != nil {
+				return nil, fmt.Errorf("domains[%d] (%s): allow_notify: %w", i, d.Domain, err)
+			}
+			li.secondaries[origin] = newSecondaryZone(origin, d.Primary, li.keys[origin])
 		case d.ZoneFile != "":
 			z, err = zone.ParseFile(d.ZoneFile)
 		default:
@@ -46,10 +98,39 @@ func NewLocalIndex(domains []config.DomainSpec) (*LocalIndex, error) {
 			return nil, fmt.Errorf("domains[%d] (%s): %w", i, d.Domain, err)
 		}
 		li.zones[origin] = append(li.zones[origin], z.Records...)
+		if err := addACL(li.acls, origin, d.AllowUpdate); err != nil {
+			return nil, fmt.Errorf("domains[%d] (%s): allow_update: %w", i, d.Domain, err)
+		}
+		li.notify[origin] = append(li.notify[origin], d.Notify...)
+		allow := d.AllowTransfer
+		if len(allow) == 0 {
+			for _, addr := range d.Notify {
+				if host, _, _ := net.SplitHostPort(addr); net.ParseIP(host) != nil {
+					allow = append(allow, host)
+				}
+			}
+			if d.TSIGKey != "" {
+				allow = append(allow, d.TSIGKey)
+			}
+		}
+		if err := addACL(li.xfrACLs, origin, allow); err != nil {
+			return nil, fmt.Errorf("domains[%d] (%s): allow_transfer: %w", i, d.Domain, err)
+		}
 	}
 	return li, nil
 }
 
+// addACL adds entries to the ACL of origin in acls.
+func addACL(acls map[string]*updateACL, origin string, entries []string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if acls[origin] == nil {
+		acls[origin] = &updateACL{keys: make(map[string]bool)}
+	}
+	return acls[origin].add(entries)
+}
+
 func parseInline(origin string, records []string) (*zone.Zone, error) {
 	if len(records) == 0 {
 		return &zone.Zone{Origin: origin}, nil
@@ -66,14 +147,19 @@ func parseInline(origin string, records []string) (*zone.Zone, error) {
 }
 
 func (li *LocalIndex) Lookup(qname string, qtype packet.DNSType) []packet.DNSResource {
-	qname = strings.ToLower(strings.TrimSuffix(qname, "."))
+	if ascii, err := packet.IDNAToASCII(qname); err == nil {
+		qname = ascii
+	}
+	qname = packet.CanonicalName(qname)
+	li.mu.RLock()
+	defer li.mu.RUnlock()
 	origin := li.matchZone(qname)
 	if origin == "" {
 		return nil
 	}
 	var out []packet.DNSResource
 	for _, r := range li.zones[origin] {
-		name := strings.ToLower(recordName(r))
+		name := packet.CanonicalName(recordName(r))
 		if name != qname {
 			continue
 		}
@@ -88,7 +174,7 @@ func (li *LocalIndex) Lookup(qname string, qtype packet.DNSType) []packet.DNSRes
 func (li *LocalIndex) matchZone(qname string) string {
 	best := ""
 	for origin := range li.zones {
-		if origin == qname || strings.HasSuffix(qname, "."+origin) {
+		if packet.IsSubDomain(origin, qname) {
 			if len(origin) > len(best) {
 				best = origin
 			}
@@ -98,29 +184,5 @@ func (li *LocalIndex) matchZone(qname string) string {
 }
 
 func recordName(r packet.DNSResource) string {
-	switch x := r.(type) {
-	case *packet.DNSResourceRecordA:
-		return x.Name
-	case *packet.DNSResourceRecordAAAA:
-		return x.Name
-	case *packet.DNSResourceRecordCNAME:
-		return x.Name
-	case *packet.DNSResourceRecordMX:
-		return x.Name
-	case *packet.DNSResourceRecordNS:
-		return x.Name
-	case *packet.DNSResourceRecordTXT:
-		return x.Name
-	case *packet.DNSResourceRecordPTR:
-		return x.Name
-	case *packet.DNSResourceRecordSOA:
-		return x.Name
-	case *packet.DNSResourceRecordSRV:
-		return x.Name
-	case *packet.DNSResourceRecordEDNS:
-		return x.Name
-	case *packet.DNSResourceRecordUnknown:
-		return x.Name
-	}
-	return ""
+	return r.GetHeader().Name
 }
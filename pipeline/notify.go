@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/lsongdev/dns-go/packet"
+	"github.com/lsongdev/dns-go/server"
+)
+
+// NotifyReceiver handles NOTIFY messages (RFC 1996) and returns the RCODE
+// to answer with. LocalIndex implements it for its secondary zones.
+type NotifyReceiver interface {
+	Notify(req *packet.DNSPacket, client UpdateClient) packet.DNSRCode
+}
+
+// notifyInterval and notifyRetries are the defaults RFC 1996 §3.6
+// suggests: an unanswered NOTIFY is sent again every 60 seconds, at most
+// 5 more times.
+const (
+	notifyInterval = 60 * time.Second
+	notifyRetries  = 5
+)
+
+// Notify schedules a refresh of the secondary zone req names. Only the
+// zone's notifiers, by default its primary, may send NOTIFY, signed with
+// the zone's tsig_key if it has one; a zone that isn't a secondary here
+// answers NOTAUTH.
+func (li *LocalIndex) Notify(req *packet.DNSPacket, client UpdateClient) packet.DNSRCode {
+	if len(req.Questions) != 1 || req.Questions[0].Type != packet.DNSTypeSOA {
+		return packet.DNSRCodeFormErr
+	}
+	origin := packet.CanonicalName(req.Questions[0].Name)
+	li.mu.RLock()
+	sec, acl, key := li.secondaries[origin], li.notifyACLs[origin], li.keys[origin]
+	li.mu.RUnlock()
+	if sec == nil {
+		return packet.DNSRCodeNotAuth
+	}
+	if !acl.allows(client) || (key != nil && !packet.EqualName(client.Key, key.Name)) {
+		return packet.DNSRCodeRefused
+	}
+	sec.poke()
+	return packet.DNSRCodeNoError
+}
+
+// setZone replaces the records of origin, drops whatever the cache holds
+// at or below it and sends NOTIFY to the zone's secondaries in the
+// background. li.mu must be held.
+func (li *LocalIndex) setZone(origin string, records []packet.DNSResource) {
+	li.zones[origin] = records
+	if li.cache != nil {
+		li.cache.RemoveZone(origin)
+	}
+	var soa packet.DNSResource
+	if i := indexOfType(records, origin, packet.DNSTypeSOA); i >= 0 {
+		soa = records[i]
+	}
+	key, interval := li.keys[origin], li.notifyInterval
+	for _, addr := range li.notify[origin] {
+		go func(addr string) {
+			if err := sendNotify(addr, origin, soa, key, interval); err != nil {
+				log.Printf("notify: %v", err)
+			}
+		}(addr)
+	}
+}
+
+// sendNotify tells the secondary at addr that zone origin changed, over
+// UDP. soa, if not nil, goes in the answer section as a hint (RFC 1996
+// §3.7). With a key, the message is signed and only a reply signed with
+// the same key is accepted. The message is sent again every interval
+// until the secondary answers.
+func sendNotify(addr, origin string, soa packet.DNSResource, key *packet.TSIGKey, interval time.Duration) error {
+	msg := packet.NewPacket()
+	msg.Header.OpCode = uint8(packet.DNSOpCodeNotify)
+	msg.Header.AA = 1
+	msg.AddQuestionSOA(origin)
+	if soa != nil {
+		msg.AddAnswer(soa)
+	}
+	var keyring packet.TSIGKeyring
+	var mac []byte
+	if key != nil {
+		rr, err := msg.SignTSIG(*key, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %v", origin, err)
+		}
+		keyring, mac = packet.TSIGKeyring{}, rr.MAC
+		keyring.Add(*key)
+	}
+	var data bytes.Buffer
+	if err := msg.PackTo(&data, true); err != nil {
+		return fmt.Errorf("%s: %v", origin, err)
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	buf := make([]byte, 512)
+	for try := 0; try <= notifyRetries; try++ {
+		if _, err := conn.Write(data.Bytes()); err != nil {
+			return err
+		}
+		deadline := time.Now().Add(interval)
+		conn.SetReadDeadline(deadline)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				// a timeout, or an ICMP error from a secondary that
+				// isn't up yet: either way, try again later
+				time.Sleep(time.Until(deadline))
+				break
+			}
+			res, err := packet.FromBytes(buf[:n])
+			if err != nil || res.Header.ID != msg.Header.ID || res.Header.QR != packet.DNSResponse {
+				continue
+			}
+			if keyring != nil {
+				if _, err := packet.VerifyTSIG(buf[:n], keyring, mac); err != nil {
+					// not from the secondary, or it doesn't know the key
+					log.Printf("notify %s: reply from %s: %v", origin, addr, err)
+					continue
+				}
+			}
+			if rcode := res.RCode(); rcode != packet.DNSRCodeNoError {
+				return fmt.Errorf("%s: %s answered %s", origin, addr, rcode)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: no answer from %s after %d tries", origin, addr, notifyRetries+1)
+}
+
+// notify answers a NOTIFY for one of the local zones.
+func (h *Handler) notify(conn *server.PackConn) {
+	req := conn.Request
+	rcode := packet.DNSRCodeNotImp
+	if h.notifies != nil {
+		rcode = h.notifies.Notify(req, updateClientOf(conn))
+	}
+	if len(req.Questions) > 0 {
+		log.Printf("[%s] notify %s: %s", conn.RemoteAddr, req.Questions[0].Name, rcode)
+	}
+	res := opcodeResponse(req, rcode)
+	if rcode == packet.DNSRCodeNoError {
+		res.Header.AA = 1
+	}
+	if err := conn.WriteResponse(res); err != nil {
+		log.Printf("[%s] write error: %v", conn.RemoteAddr, err)
+	}
+}
@@ -37,9 +37,14 @@ type UpstreamPool interface {
 // Cache is held separately so the dispatcher can write fresh answers back
 // (resolver chain[0] is the cache itself; everything past it gets cached).
 type Handler struct {
-	chain []Resolver
-	cache *cache.Cache
-	pool  UpstreamPool // tracked so Close() can shut upstreams down
+	chain    []Resolver
+	cache    *cache.Cache
+	pool     UpstreamPool   // tracked so Close() can shut upstreams down
+	updater  Updater        // the local source, if it accepts DNS UPDATE
+	notifies NotifyReceiver // the local source, if it accepts NOTIFY
+	xfr      Transferer     // the local source, if it serves AXFR
+	stop     func()         // stops zone transfers
+	policy   config.PolicySpec
 }
 
 func New(cfg *config.Config) (*Handler, error) {
@@ -47,7 +52,11 @@ func New(cfg *config.Config) (*Handler, error) {
 		return nil, fmt.Errorf("pipeline: nil config")
 	}
 
-	local, err := NewLocalIndex(cfg.Domains)
+	keys, err := TSIGKeyring(cfg.TSIG)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline: tsig_keys: %w", err)
+	}
+	local, err := NewLocalIndex(cfg.Domains, keys)
 	if err != nil {
 		return nil, fmt.Errorf("pipeline: local zones: %w", err)
 	}
@@ -71,7 +80,10 @@ func New(cfg *config.Config) (*Handler, error) {
 		cc = cache.New(cfg.Cache)
 	}
 
-	return newHandler(cc, local, flt, pool), nil
+	h := newHandler(cc, local, flt, pool)
+	h.policy = cfg.Policy
+	h.stop = local.startTransfers()
+	return h, nil
 }
 
 // newHandler assembles a Handler from already-built components. Nil entries
@@ -91,10 +103,26 @@ func newHandler(cc *cache.Cache, local LocalSource, flt *filter.Filter, pool Ups
 	if pool != nil {
 		chain = append(chain, pool)
 	}
-	return &Handler{chain: chain, cache: cc, pool: pool}
+	h := &Handler{chain: chain, cache: cc, pool: pool}
+	if li, ok := local.(*LocalIndex); ok {
+		li.cache = cc
+	}
+	if u, ok := local.(Updater); ok {
+		h.updater = u
+	}
+	if n, ok := local.(NotifyReceiver); ok {
+		h.notifies = n
+	}
+	if t, ok := local.(Transferer); ok {
+		h.xfr = t
+	}
+	return h
 }
 
 func (h *Handler) Close() error {
+	if h.stop != nil {
+		h.stop()
+	}
 	if h.pool != nil {
 		return h.pool.Close()
 	}
@@ -106,7 +134,32 @@ func (h *Handler) Close() error {
 // per-request) so this runs synchronously.
 func (h *Handler) HandleQuery(conn *server.PackConn) {
 	req := conn.Request
-	if req == nil || len(req.Questions) == 0 {
+	if req == nil || req.Header == nil {
+		return
+	}
+	if res, ok := h.screen(req); !ok {
+		if res == nil {
+			return
+		}
+		if err := conn.WriteResponse(res); err != nil {
+			log.Printf("[%s] write error: %v", conn.RemoteAddr, err)
+		}
+		return
+	}
+	switch packet.DNSOpCode(req.Header.OpCode) {
+	case packet.DNSOpCodeUpdate:
+		h.update(conn)
+		return
+	case packet.DNSOpCodeNotify:
+		h.notify(conn)
+		return
+	}
+	if len(req.Questions) > 1 {
+		// policy.AllowMultiQuestion: answer the first one only
+		req.Questions = req.Questions[:1]
+	}
+	if req.Questions[0].Type == packet.DNSTypeAXFR {
+		h.transfer(conn)
 		return
 	}
 	resp := h.resolve(req)
@@ -132,7 +185,9 @@ func (h *Handler) resolve(req *packet.DNSPacket) *packet.DNSPacket {
 		if resp == nil {
 			continue
 		}
-		if i > 0 && h.cache != nil {
+		// a SERVFAIL says nothing about the name, so it isn't worth
+		// keeping (and a zone still loading would stay broken)
+		if i > 0 && h.cache != nil && resp.RCode() != packet.DNSRCodeServFail {
 			h.cache.Put(cache.KeyOf(req.Questions[0]), resp)
 		}
 		resp.Header.ID = req.Header.ID
@@ -141,6 +196,20 @@ func (h *Handler) resolve(req *packet.DNSPacket) *packet.DNSPacket {
 	return SynthSERVFAIL(req)
 }
 
+// TSIGKeyring returns the keys of tsig_keys, for verifying requests and
+// signing the queries and NOTIFY the local zones send.
+func TSIGKeyring(specs []config.TSIGKeySpec) (packet.TSIGKeyring, error) {
+	keyring := packet.TSIGKeyring{}
+	for _, k := range specs {
+		secret, err := k.DecodeSecret()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Name, err)
+		}
+		keyring.Add(packet.TSIGKey{Name: k.Name, Algorithm: k.Algorithm, Secret: secret})
+	}
+	return keyring, nil
+}
+
 func buildFilter(spec config.FiltersSpec) (*filter.Filter, error) {
 	f := filter.New()
 	for _, rule := range spec.Rules {
@@ -2,11 +2,18 @@ package pipeline
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/lsongdev/dns-go/cache"
+	"github.com/lsongdev/dns-go/client"
 	"github.com/lsongdev/dns-go/config"
 	"github.com/lsongdev/dns-go/filter"
 	"github.com/lsongdev/dns-go/packet"
@@ -61,9 +68,14 @@ func emptyLocal() *LocalIndex {
 }
 
 func dispatch(t *testing.T, h *Handler, req *packet.DNSPacket) *packet.DNSPacket {
+	t.Helper()
+	return dispatchConn(t, h, &server.PackConn{RemoteAddr: "test", Request: req})
+}
+
+func dispatchConn(t *testing.T, h *Handler, conn *server.PackConn) *packet.DNSPacket {
 	t.Helper()
 	var buf bytes.Buffer
-	conn := &server.PackConn{Writer: &buf, RemoteAddr: "test", Request: req}
+	conn.Writer = &buf
 	h.HandleQuery(conn)
 	if buf.Len() == 0 {
 		t.Fatal("no response written")
@@ -103,7 +115,7 @@ func TestHandlerLocalShortCircuits(t *testing.T) {
 	pool := &stubPool{resp: makeUpstreamA("nas.example.com", "9.9.9.9", 300)}
 	local, err := NewLocalIndex([]config.DomainSpec{
 		{Domain: "example.com", Records: []string{"nas IN A 192.168.1.10"}},
-	})
+	}, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -128,6 +140,24 @@ func TestHandlerLocalShortCircuits(t *testing.T) {
 	}
 }
 
+func TestHandlerLocalUnicodeDomain(t *testing.T) {
+	local, err := NewLocalIndex([]config.DomainSpec{
+		{Domain: "例子.中国", Records: []string{"www IN A 192.168.1.20"}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler(nil, local, filter.New(), &stubPool{err: errors.New("unused")})
+
+	resp := dispatch(t, h, makeRequest("www.xn--fsqu00a.xn--fiqs8s", packet.DNSTypeA))
+	if len(resp.Answers) != 1 {
+		t.Fatalf("expected 1 answer, got %d", len(resp.Answers))
+	}
+	if resp.Header.AA != 1 {
+		t.Errorf("local response should be authoritative")
+	}
+}
+
 func TestHandlerFilterBlock(t *testing.T) {
 	pool := &stubPool{resp: makeUpstreamA("ad.bad.com", "5.5.5.5", 300)}
 	flt := filter.New()
@@ -185,6 +215,54 @@ func TestHandlerNoPoolReturnsServfail(t *testing.T) {
 	}
 }
 
+func extendedErrors(t *testing.T, resp *packet.DNSPacket) []*packet.EDNSExtendedError {
+	t.Helper()
+	for _, add := range resp.Additionals {
+		if opt, ok := add.(*packet.DNSResourceRecordEDNS); ok {
+			edes, err := opt.ExtendedErrors()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return edes
+		}
+	}
+	return nil
+}
+
+func TestSynthesizedResponsesCarryExtendedError(t *testing.T) {
+	flt := filter.New()
+	if err := flt.AddRule("||bad.com^"); err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler(nil, emptyLocal(), flt, &stubPool{err: errors.New("upstream down")})
+
+	tests := []struct {
+		name  string
+		rcode uint8
+		code  packet.EDECode
+	}{
+		{"bad.com", 3, packet.EDECodeBlocked},
+		{"google.com", 2, packet.EDECodeNoReachableAuthority},
+	}
+	for _, tt := range tests {
+		req := makeRequest(tt.name, packet.DNSTypeMX)
+		req.AddAdditionalEDNS(4096, 0, 0, false)
+		resp := dispatch(t, h, req)
+		if resp.Header.RCode != tt.rcode {
+			t.Errorf("%s: expected rcode %d, got %d", tt.name, tt.rcode, resp.Header.RCode)
+		}
+		edes := extendedErrors(t, resp)
+		if len(edes) != 1 || edes[0].InfoCode != tt.code {
+			t.Errorf("%s: expected EDE %v, got %v", tt.name, tt.code, edes)
+		}
+
+		resp = dispatch(t, h, makeRequest(tt.name, packet.DNSTypeMX))
+		if len(resp.Additionals) != 0 {
+			t.Errorf("%s: client without EDNS got OPT in response", tt.name)
+		}
+	}
+}
+
 func TestStripEDNSWhenRequestHasNone(t *testing.T) {
 	upstreamResp := makeUpstreamA("google.com", "1.2.3.4", 300)
 	upstreamResp.AddAdditionalEDNS(4096, 0, 0, false)
@@ -254,6 +332,61 @@ func TestStripEDNSPaddingFromUpstream(t *testing.T) {
 	}
 }
 
+func TestStripEDNSPaddingKeepsCacheIntact(t *testing.T) {
+	upstreamResp := makeUpstreamA("google.com", "1.2.3.4", 300)
+	upstreamResp.AddAdditionalEDNS(4096, 0, 0, false)
+	for _, add := range upstreamResp.Additionals {
+		if opt, ok := add.(*packet.DNSResourceRecordEDNS); ok {
+			opt.AddEDNSOption(packet.EDNSOptionNSID, []byte("ns1"))
+			opt.AddEDNSOptionPadding(384)
+		}
+	}
+
+	c := newCache(t)
+	pool := &stubPool{resp: upstreamResp}
+	h := newHandler(c, emptyLocal(), filter.New(), pool)
+
+	req := makeRequest("google.com", packet.DNSTypeA)
+	req.AddAdditionalEDNS(4096, 0, 0, false)
+	dispatch(t, h, req)
+	dispatch(t, h, req)
+
+	cached, ok := c.Get(cache.KeyOf(req.Questions[0]))
+	if !ok {
+		t.Fatal("response not cached")
+	}
+	var codes []uint16
+	for _, add := range cached.Additionals {
+		if opt, ok := add.(*packet.DNSResourceRecordEDNS); ok {
+			for _, o := range opt.Options {
+				codes = append(codes, o.Code)
+			}
+		}
+	}
+	if len(codes) != 2 || codes[0] != packet.EDNSOptionNSID || codes[1] != packet.EDNSOptionPadding {
+		t.Errorf("cached OPT options changed by post-processing: %v", codes)
+	}
+}
+
+func TestStripEDNSLeavesSharedOPTAlone(t *testing.T) {
+	orig := makeUpstreamA("google.com", "1.2.3.4", 300)
+	orig.AddAdditionalEDNS(4096, 0, 0, false)
+	opt := orig.Additionals[0].(*packet.DNSResourceRecordEDNS)
+	opt.AddEDNSOptionPadding(384)
+
+	shared := &packet.DNSPacket{Header: orig.Header, Additionals: orig.Additionals}
+	req := makeRequest("google.com", packet.DNSTypeA)
+	req.AddAdditionalEDNS(4096, 0, 0, false)
+	StripEDNSIfNeeded(req, shared)
+
+	if len(opt.Options) != 1 || orig.Additionals[0] != opt {
+		t.Errorf("original OPT record was modified: %+v", orig.Additionals[0])
+	}
+	if got := shared.Additionals[0].(*packet.DNSResourceRecordEDNS); len(got.Options) != 0 {
+		t.Errorf("padding not stripped: %+v", got.Options)
+	}
+}
+
 // TestHandlerLocalIsCached covers a B′ semantic: local hits are written back
 // to cache too (the dispatcher caches everything past chain[0]). Previously
 // local was explicitly excluded from cache; now caching is uniform and
@@ -261,7 +394,7 @@ func TestStripEDNSPaddingFromUpstream(t *testing.T) {
 func TestHandlerLocalIsCached(t *testing.T) {
 	local, err := NewLocalIndex([]config.DomainSpec{
 		{Domain: "example.com", Records: []string{"nas IN A 10.0.0.1"}},
-	})
+	}, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -307,3 +440,616 @@ func TestNewFromConfig(t *testing.T) {
 		t.Errorf("expected chain [cache, local, filter, proxy], got len=%d", len(h.chain))
 	}
 }
+
+func TestHandlerLocalHTTPS(t *testing.T) {
+	local, err := NewLocalIndex([]config.DomainSpec{
+		{Domain: "example.com", Records: []string{`@ IN HTTPS 1 . alpn=h2,h3 ipv4hint=192.0.2.1`}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler(nil, local, filter.New(), nil)
+
+	resp := dispatch(t, h, makeRequest("example.com", packet.DNSTypeHTTPS))
+	if len(resp.Answers) != 1 {
+		t.Fatalf("expected 1 answer, got %d", len(resp.Answers))
+	}
+	https, ok := resp.Answers[0].(*packet.DNSResourceRecordHTTPS)
+	if !ok {
+		t.Fatalf("expected HTTPS record, got %T", resp.Answers[0])
+	}
+	if len(https.Params.ALPN) != 2 || https.Params.IPv4Hint[0].String() != "192.0.2.1" {
+		t.Errorf("unexpected params %+v", https.Params)
+	}
+}
+
+func updateZone(t *testing.T) *LocalIndex {
+	t.Helper()
+	local, err := NewLocalIndex([]config.DomainSpec{{
+		Domain: "example.com",
+		Records: []string{
+			"@ 3600 IN SOA ns1 hostmaster 2024010100 7200 3600 1209600 300",
+			"@ IN NS ns1",
+			"ns1 IN A 192.0.2.1",
+			"www IN A 192.0.2.80",
+		},
+		AllowUpdate: []string{"192.0.2.0/24", "ddns-key."},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return local
+}
+
+func zoneSerial(t *testing.T, local *LocalIndex) uint32 {
+	t.Helper()
+	soa := local.Lookup("example.com", packet.DNSTypeSOA)
+	if len(soa) != 1 {
+		t.Fatalf("expected one SOA, got %v", soa)
+	}
+	return soa[0].(*packet.DNSResourceRecordSOA).Serial
+}
+
+func hostA(name, addr string) *packet.DNSResourceRecordA {
+	return &packet.DNSResourceRecordA{
+		DNSResourceRecord: packet.DNSResourceRecord{Name: name, Type: packet.DNSTypeA, Class: packet.DNSClassIN, TTL: 60},
+		Address:           addr,
+	}
+}
+
+func TestHandlerUpdate(t *testing.T) {
+	local := updateZone(t)
+	h := newHandler(newCache(t), local, filter.New(), &stubPool{err: errors.New("unused")})
+	if resp := dispatch(t, h, makeRequest("www.example.com", packet.DNSTypeA)); len(resp.Answers) != 1 {
+		t.Fatalf("expected www to resolve before the update, got %v", resp.Answers)
+	}
+
+	upd := packet.NewUpdate("example.com")
+	upd.AddPrereqNameNotInUse("laptop.example.com")
+	upd.AddPrereqRRsetEqual(hostA("www.example.com", "192.0.2.80"))
+	upd.AddUpdateInsert(hostA("laptop.example.com", "192.0.2.23"))
+	upd.AddUpdateDeleteRRset("www.example.com", packet.DNSTypeA)
+	upd.AddUpdateDeleteName("example.com") // the apex SOA and NS survive
+	resp := dispatchConn(t, h, &server.PackConn{RemoteAddr: "192.0.2.9:5353", Request: upd})
+	if resp.RCode() != packet.DNSRCodeNoError {
+		t.Fatalf("update failed: %s", resp.RCode())
+	}
+	if packet.DNSOpCode(resp.Header.OpCode) != packet.DNSOpCodeUpdate || resp.Header.QR != packet.DNSResponse {
+		t.Errorf("unexpected response header %+v", resp.Header)
+	}
+	if got := zoneSerial(t, local); got != 2024010101 {
+		t.Errorf("serial = %d, want 2024010101", got)
+	}
+	if got := local.Lookup("example.com", packet.DNSTypeNS); len(got) != 1 {
+		t.Errorf("apex NS should survive deleting the apex name, got %v", got)
+	}
+	if resp := dispatch(t, h, makeRequest("laptop.example.com", packet.DNSTypeA)); len(resp.Answers) != 1 {
+		t.Errorf("inserted record not served: %v", resp)
+	}
+	if resp := dispatch(t, h, makeRequest("www.example.com", packet.DNSTypeA)); resp.RCode() != packet.DNSRCodeServFail {
+		t.Errorf("deleted record still served (from cache?): %v", resp)
+	}
+
+	// the same update signed with an allowed key from any address; the
+	// prerequisite now fails
+	key := packet.TSIGKey{Name: "ddns-key", Algorithm: packet.TSIGHMACSHA256, Secret: []byte("secret")}
+	conn := &server.PackConn{RemoteAddr: "198.51.100.1:5353", Request: upd, TSIG: packet.NewTSIGSigner(key, nil)}
+	if resp := dispatchConn(t, h, conn); resp.RCode() != packet.DNSRCodeYXDomain {
+		t.Errorf("expected YXDOMAIN for a name in use, got %s", resp.RCode())
+	}
+}
+
+func TestHandlerUpdateRejects(t *testing.T) {
+	local := updateZone(t)
+	h := newHandler(nil, local, filter.New(), nil)
+	www := hostA("www.example.com", "192.0.2.80")
+
+	cases := []struct {
+		name  string
+		addr  string
+		build func(*packet.DNSPacket)
+		want  packet.DNSRCode
+	}{
+		{"unlisted client", "203.0.113.5:53", func(u *packet.DNSPacket) {
+			u.AddUpdateInsert(hostA("evil.example.com", "203.0.113.5"))
+		}, packet.DNSRCodeRefused},
+		{"rrset exists", "192.0.2.9:53", func(u *packet.DNSPacket) {
+			u.AddPrereqRRsetNotExists("www.example.com", packet.DNSTypeA)
+		}, packet.DNSRCodeYXRRSet},
+		{"rrset missing", "192.0.2.9:53", func(u *packet.DNSPacket) {
+			u.AddPrereqRRsetExists("www.example.com", packet.DNSTypeAAAA)
+		}, packet.DNSRCodeNXRRSet},
+		{"rrset differs", "192.0.2.9:53", func(u *packet.DNSPacket) {
+			u.AddPrereqRRsetEqual(www, hostA("www.example.com", "192.0.2.81"))
+		}, packet.DNSRCodeNXRRSet},
+		{"name missing", "192.0.2.9:53", func(u *packet.DNSPacket) {
+			u.AddPrereqNameInUse("nobody.example.com")
+		}, packet.DNSRCodeNXDomain},
+		{"outside zone", "192.0.2.9:53", func(u *packet.DNSPacket) {
+			u.AddUpdateInsert(www)
+			u.AddUpdateInsert(hostA("www.example.org", "192.0.2.80"))
+		}, packet.DNSRCodeNotZone},
+		{"meta type", "192.0.2.9:53", func(u *packet.DNSPacket) {
+			u.AddUpdateDeleteRRset("www.example.com", packet.DNSTypeAXFR)
+		}, packet.DNSRCodeFormErr},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			upd := packet.NewUpdate("example.com")
+			tc.build(upd)
+			resp := dispatchConn(t, h, &server.PackConn{RemoteAddr: tc.addr, Request: upd})
+			if resp.RCode() != tc.want {
+				t.Errorf("got %s, want %s", resp.RCode(), tc.want)
+			}
+		})
+	}
+	if got := zoneSerial(t, local); got != 2024010100 {
+		t.Errorf("rejected updates changed the zone: serial %d", got)
+	}
+
+	upd := packet.NewUpdate("example.net")
+	upd.AddUpdateInsert(hostA("www.example.net", "192.0.2.80"))
+	if resp := dispatchConn(t, h, &server.PackConn{RemoteAddr: "192.0.2.9:53", Request: upd}); resp.RCode() != packet.DNSRCodeNotAuth {
+		t.Errorf("update for a zone we don't serve: got %s, want NOTAUTH", resp.RCode())
+	}
+
+	// deleting the SOA or the only NS record is ignored and re-adding an
+	// existing record is a no-op, so nothing changes
+	upd = packet.NewUpdate("example.com")
+	upd.AddUpdateDelete(local.Lookup("example.com", packet.DNSTypeSOA)...)
+	upd.AddUpdateDelete(local.Lookup("example.com", packet.DNSTypeNS)...)
+	upd.AddUpdateInsert(local.Lookup("www.example.com", packet.DNSTypeA)...)
+	if resp := dispatchConn(t, h, &server.PackConn{RemoteAddr: "192.0.2.9:53", Request: upd}); resp.RCode() != packet.DNSRCodeNoError {
+		t.Fatalf("got %s", resp.RCode())
+	}
+	if got := zoneSerial(t, local); got != 2024010100 {
+		t.Errorf("no-op update bumped the serial to %d", got)
+	}
+}
+
+// fakePrimary serves SOA queries and AXFR for one zone over TCP.
+type fakePrimary struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	records []packet.DNSResource // SOA first
+}
+
+func newFakePrimary(t *testing.T, records ...packet.DNSResource) *fakePrimary {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakePrimary{ln: ln, records: records}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *fakePrimary) set(records ...packet.DNSResource) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = records
+}
+
+func (p *fakePrimary) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		data := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return
+		}
+		req, err := packet.FromBytes(data)
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		records := p.records
+		p.mu.Unlock()
+		var replies [][]packet.DNSResource
+		if req.Questions[0].Type == packet.DNSTypeAXFR {
+			// split over two messages, ending with the SOA again
+			all := append(append([]packet.DNSResource{}, records...), records[0])
+			replies = [][]packet.DNSResource{all[:2], all[2:]}
+		} else {
+			replies = [][]packet.DNSResource{records[:1]}
+		}
+		for _, answers := range replies {
+			res := emptyResponse(req)
+			res.Header.AA = 1
+			res.Answers = answers
+			msg := res.Bytes()
+			binary.BigEndian.PutUint16(length[:], uint16(len(msg)))
+			if _, err := conn.Write(append(length[:], msg...)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func soaRecord(serial uint32) *packet.DNSResourceRecordSOA {
+	return &packet.DNSResourceRecordSOA{
+		DNSResourceRecord: packet.DNSResourceRecord{Name: "sec.example", Type: packet.DNSTypeSOA, Class: packet.DNSClassIN, TTL: 3600},
+		MName:             "ns1.sec.example",
+		RName:             "hostmaster.sec.example",
+		Serial:            serial,
+		Refresh:           3600,
+		Retry:             600,
+		Expire:            86400,
+		Minimum:           300,
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestHandlerNotifyRefreshesSecondary(t *testing.T) {
+	primary := newFakePrimary(t, soaRecord(1), hostA("a.sec.example", "192.0.2.1"), hostA("b.sec.example", "192.0.2.2"))
+	local, err := NewLocalIndex([]config.DomainSpec{
+		{Domain: "sec.example", Primary: primary.ln.Addr().String(), AllowUpdate: []string{"127.0.0.1"}},
+		{Domain: "example.com", Records: []string{"www IN A 192.0.2.80"}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := local.startTransfers()
+	defer stop()
+	waitFor(t, "the initial transfer", func() bool {
+		return len(local.Lookup("b.sec.example", packet.DNSTypeA)) == 1
+	})
+	if got := local.Lookup("sec.example", packet.DNSTypeSOA); len(got) != 1 {
+		t.Errorf("the SOA repeated at the end of the transfer should be stored once, got %d", len(got))
+	}
+	upd := packet.NewUpdate("sec.example")
+	upd.AddUpdateInsert(hostA("x.sec.example", "192.0.2.9"))
+	if rcode := local.Update(upd, UpdateClient{Addr: net.ParseIP("127.0.0.1")}); rcode != packet.DNSRCodeNotAuth {
+		t.Errorf("UPDATE of a secondary zone: got %s, want NOTAUTH", rcode)
+	}
+
+	primary.set(soaRecord(2), hostA("a.sec.example", "192.0.2.1"), hostA("c.sec.example", "192.0.2.3"))
+	h := newHandler(nil, local, filter.New(), nil)
+	notify := func(zone, addr string) *packet.DNSPacket {
+		msg := packet.NewPacket()
+		msg.Header.OpCode = uint8(packet.DNSOpCodeNotify)
+		msg.AddQuestionSOA(zone)
+		return dispatchConn(t, h, &server.PackConn{RemoteAddr: addr, Request: msg})
+	}
+	if resp := notify("sec.example", "198.51.100.7:53"); resp.RCode() != packet.DNSRCodeRefused {
+		t.Errorf("NOTIFY from outside allow_notify: got %s, want REFUSED", resp.RCode())
+	}
+	if resp := notify("example.com", "127.0.0.1:53"); resp.RCode() != packet.DNSRCodeNotAuth {
+		t.Errorf("NOTIFY for a primary zone: got %s, want NOTAUTH", resp.RCode())
+	}
+	resp := notify("sec.example", "127.0.0.1:53")
+	if resp.RCode() != packet.DNSRCodeNoError || resp.Header.AA != 1 || packet.DNSOpCode(resp.Header.OpCode) != packet.DNSOpCodeNotify {
+		t.Fatalf("unexpected NOTIFY response %v", resp)
+	}
+	waitFor(t, "the transfer after NOTIFY", func() bool {
+		return len(local.Lookup("c.sec.example", packet.DNSTypeA)) == 1
+	})
+	if got := local.Lookup("b.sec.example", packet.DNSTypeA); len(got) != 0 {
+		t.Errorf("records dropped by the primary should be gone, got %v", got)
+	}
+}
+
+func TestHandlerSecondaryNotLoaded(t *testing.T) {
+	primary := newFakePrimary(t, soaRecord(1), hostA("a.sec.example", "192.0.2.1"))
+	local, err := NewLocalIndex([]config.DomainSpec{{Domain: "sec.example", Primary: primary.ln.Addr().String()}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := newCache(t)
+	pool := &stubPool{resp: makeUpstreamA("a.sec.example", "203.0.113.1", 300)}
+	h := newHandler(cc, local, filter.New(), pool)
+
+	if resp := dispatch(t, h, makeRequest("a.sec.example", packet.DNSTypeA)); resp.RCode() != packet.DNSRCodeServFail {
+		t.Fatalf("before the first transfer: got %s, want SERVFAIL", resp.RCode())
+	}
+	if pool.calls != 0 || cc.Len() != 0 {
+		t.Fatalf("a zone still loading must not be resolved upstream or cached (calls=%d, cached=%d)", pool.calls, cc.Len())
+	}
+
+	// left over from before the zone was configured here
+	cc.Put(cache.KeyOf(makeRequest("a.sec.example", packet.DNSTypeA).Questions[0]), makeUpstreamA("a.sec.example", "203.0.113.1", 300))
+	stop := local.startTransfers()
+	defer stop()
+	answer := func() string {
+		resp := dispatch(t, h, makeRequest("a.sec.example", packet.DNSTypeA))
+		if len(resp.Answers) != 1 {
+			return resp.RCode().String()
+		}
+		return resp.Answers[0].(*packet.DNSResourceRecordA).Address
+	}
+	waitFor(t, "the initial transfer", func() bool { return !local.Loading("a.sec.example") })
+	if got := answer(); got != "192.0.2.1" {
+		t.Fatalf("after the transfer: got %s, want the zone's 192.0.2.1", got)
+	}
+
+	primary.set(soaRecord(2), hostA("a.sec.example", "192.0.2.2"))
+	local.secondaries["sec.example"].poke()
+	waitFor(t, "the refresh", func() bool { return local.soa("sec.example").Serial == 2 })
+	if got := answer(); got != "192.0.2.2" {
+		t.Errorf("after the refresh: got %s, the cache should have been purged", got)
+	}
+}
+
+func TestHandlerServesTransfer(t *testing.T) {
+	records := []string{"@ 3600 IN SOA ns1 hostmaster 7 3600 600 86400 300", "@ IN NS ns1"}
+	for i := 0; i < 1000; i++ {
+		// enough to take more than one message
+		records = append(records, fmt.Sprintf("host%d IN A 192.0.2.%d", i, i%256))
+	}
+	primary, err := NewLocalIndex([]config.DomainSpec{{Domain: "sec.example", Records: records, AllowTransfer: []string{"127.0.0.1"}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler(nil, primary, nil, nil)
+
+	axfr := func(zone, addr string, stream bool) packet.DNSRCode {
+		conn := &server.PackConn{RemoteAddr: addr, Request: makeRequest(zone, packet.DNSTypeAXFR), Stream: stream}
+		return dispatchConn(t, h, conn).RCode()
+	}
+	if got := axfr("sec.example", "198.51.100.7:53", true); got != packet.DNSRCodeRefused {
+		t.Errorf("AXFR from outside allow_transfer: got %s, want REFUSED", got)
+	}
+	if got := axfr("other.example", "127.0.0.1:53", true); got != packet.DNSRCodeNotAuth {
+		t.Errorf("AXFR of an unknown zone: got %s, want NOTAUTH", got)
+	}
+	if got := axfr("sec.example", "127.0.0.1:53", false); got != packet.DNSRCodeNotImp {
+		t.Errorf("AXFR over UDP: got %s, want NOTIMP", got)
+	}
+
+	srv := &server.Server{}
+	addr, err := srv.ListenTCP("127.0.0.1:0", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(context.Background())
+	defer srv.Shutdown(context.Background())
+
+	secondary, err := NewLocalIndex([]config.DomainSpec{{Domain: "sec.example", Primary: addr.String()}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := secondary.startTransfers()
+	defer stop()
+	waitFor(t, "the transfer from the local primary", func() bool { return !secondary.Loading("sec.example") })
+	if got, want := len(secondary.zones["sec.example"]), len(records); got != want {
+		t.Errorf("transferred %d records, want %d", got, want)
+	}
+	if got := secondary.Lookup("host999.sec.example", packet.DNSTypeA); len(got) != 1 {
+		t.Errorf("host999: got %v", got)
+	}
+}
+
+func TestSignedTransferAndNotify(t *testing.T) {
+	key := packet.TSIGKey{Name: "xfr-key", Algorithm: packet.TSIGHMACSHA256, Secret: []byte("secret")}
+	keys := packet.TSIGKeyring{}
+	keys.Add(key)
+	primary, err := NewLocalIndex([]config.DomainSpec{{
+		Domain:        "sec.example",
+		Records:       []string{"@ 3600 IN SOA ns1 hostmaster 7 3600 600 86400 300", "a IN A 192.0.2.1"},
+		AllowUpdate:   []string{"127.0.0.1"},
+		AllowTransfer: []string{"xfr-key"},
+		TSIGKey:       "xfr-key",
+	}}, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server.Server{}
+	defer srv.Shutdown(context.Background())
+	primaryAddr, err := srv.ListenTCP("127.0.0.1:0", &server.TSIGHandler{Handler: newHandler(nil, primary, nil, nil), Keyring: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondary, err := NewLocalIndex([]config.DomainSpec{{Domain: "sec.example", Primary: primaryAddr.String(), TSIGKey: "xfr-key"}}, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler(nil, secondary, nil, nil)
+	secondaryAddr, err := srv.ListenUDP("127.0.0.1:0", &server.TSIGHandler{Handler: h, Keyring: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary.notify["sec.example"] = []string{secondaryAddr.String()}
+	go srv.Serve(context.Background())
+
+	if _, err := client.NewTCPClient(primaryAddr.String()).Transfer("sec.example"); err == nil {
+		t.Error("an unsigned transfer should be refused")
+	}
+	stop := secondary.startTransfers()
+	defer stop()
+	waitFor(t, "the signed transfer", func() bool { return !secondary.Loading("sec.example") })
+
+	msg := packet.NewPacket()
+	msg.Header.OpCode = uint8(packet.DNSOpCodeNotify)
+	msg.AddQuestionSOA("sec.example")
+	if resp := dispatchConn(t, h, &server.PackConn{RemoteAddr: "127.0.0.1:53", Request: msg}); resp.RCode() != packet.DNSRCodeRefused {
+		t.Errorf("unsigned NOTIFY: got %s, want REFUSED", resp.RCode())
+	}
+
+	// the signed NOTIFY this sends is what makes the secondary refresh
+	upd := packet.NewUpdate("sec.example")
+	upd.AddUpdateInsert(hostA("b.sec.example", "192.0.2.2"))
+	if rcode := primary.Update(upd, UpdateClient{Addr: net.ParseIP("127.0.0.1")}); rcode != packet.DNSRCodeNoError {
+		t.Fatalf("update failed: %s", rcode)
+	}
+	waitFor(t, "the refresh after NOTIFY", func() bool {
+		return len(secondary.Lookup("b.sec.example", packet.DNSTypeA)) == 1
+	})
+}
+
+func TestUpdateSendsNotify(t *testing.T) {
+	secondary, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer secondary.Close()
+	got := make(chan *packet.DNSPacket, 1)
+	go func() {
+		buf := make([]byte, 512)
+		for try := 0; ; try++ {
+			n, addr, err := secondary.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if try == 0 {
+				continue // lost: the sender has to retransmit
+			}
+			msg, err := packet.FromBytes(buf[:n])
+			if err != nil {
+				return
+			}
+			res := opcodeResponse(msg, packet.DNSRCodeNoError)
+			secondary.WriteTo(res.Bytes(), addr)
+			got <- msg
+			return
+		}
+	}()
+
+	local, err := NewLocalIndex([]config.DomainSpec{{
+		Domain:      "example.com",
+		Records:     []string{"@ IN SOA ns1 hostmaster 7 3600 600 86400 300"},
+		AllowUpdate: []string{"127.0.0.1"},
+		Notify:      []string{secondary.LocalAddr().String()},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local.notifyInterval = 50 * time.Millisecond
+	upd := packet.NewUpdate("example.com")
+	upd.AddUpdateInsert(hostA("new.example.com", "192.0.2.9"))
+	if rcode := local.Update(upd, UpdateClient{Addr: net.ParseIP("127.0.0.1")}); rcode != packet.DNSRCodeNoError {
+		t.Fatalf("update failed: %s", rcode)
+	}
+
+	select {
+	case msg := <-got:
+		if packet.DNSOpCode(msg.Header.OpCode) != packet.DNSOpCodeNotify || msg.Questions[0].Type != packet.DNSTypeSOA {
+			t.Errorf("not a NOTIFY: %v", msg)
+		}
+		if len(msg.Answers) != 1 || msg.Answers[0].(*packet.DNSResourceRecordSOA).Serial != 8 {
+			t.Errorf("NOTIFY should carry the new SOA, got %v", msg.Answers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no NOTIFY received")
+	}
+}
+
+func TestHandlerPolicyStrict(t *testing.T) {
+	pool := &stubPool{resp: makeUpstreamA("google.com", "1.2.3.4", 300)}
+	h := newHandler(nil, emptyLocal(), filter.New(), pool)
+
+	status := makeRequest("google.com", packet.DNSTypeA)
+	status.Header.OpCode = uint8(packet.DNSOpCodeStatus)
+	if rcode := dispatch(t, h, status).RCode(); rcode != packet.DNSRCodeNotImp {
+		t.Errorf("STATUS: got %s, want NOTIMP", rcode)
+	}
+
+	none := makeRequest("google.com", packet.DNSTypeA)
+	none.Questions = nil
+	if rcode := dispatch(t, h, none).RCode(); rcode != packet.DNSRCodeFormErr {
+		t.Errorf("no question: got %s, want FORMERR", rcode)
+	}
+
+	two := makeRequest("google.com", packet.DNSTypeA)
+	two.AddQuestionA("example.com")
+	if rcode := dispatch(t, h, two).RCode(); rcode != packet.DNSRCodeFormErr {
+		t.Errorf("two questions: got %s, want FORMERR", rcode)
+	}
+
+	twoOPT := makeRequest("google.com", packet.DNSTypeA)
+	twoOPT.AddAdditionalEDNS(4096, 0, 0, false)
+	twoOPT.AddAdditionalEDNS(4096, 0, 0, false)
+	if rcode := dispatch(t, h, twoOPT).RCode(); rcode != packet.DNSRCodeFormErr {
+		t.Errorf("two OPT records: got %s, want FORMERR", rcode)
+	}
+
+	v1 := makeRequest("google.com", packet.DNSTypeA)
+	v1.AddAdditionalEDNS(4096, 0, 1, false)
+	resp := dispatch(t, h, v1)
+	if rcode := resp.RCode(); rcode != packet.DNSRCodeBadVers {
+		t.Errorf("EDNS version 1: got %s, want BADVERS", rcode)
+	}
+	if opt := resp.EDNS(); opt == nil || opt.Version != 0 {
+		t.Errorf("BADVERS should carry an OPT with version 0, got %v", opt)
+	}
+	if len(resp.Answers) != 0 {
+		t.Errorf("BADVERS should have no answers, got %v", resp.Answers)
+	}
+
+	if pool.calls != 0 {
+		t.Errorf("rejected queries reached upstream %d times", pool.calls)
+	}
+}
+
+func TestHandlerPolicyDropsResponses(t *testing.T) {
+	h := newHandler(nil, emptyLocal(), filter.New(), &stubPool{})
+	req := makeRequest("google.com", packet.DNSTypeA)
+	req.Header.QR = packet.DNSResponse
+	var buf bytes.Buffer
+	h.HandleQuery(&server.PackConn{RemoteAddr: "test", Request: req, Writer: &buf})
+	if buf.Len() != 0 {
+		t.Error("a response should never be answered")
+	}
+}
+
+func TestHandlerPolicyRelaxed(t *testing.T) {
+	pool := &stubPool{resp: makeUpstreamA("google.com", "1.2.3.4", 300)}
+	h := newHandler(nil, emptyLocal(), filter.New(), pool)
+	h.policy = config.PolicySpec{
+		DropUnknownOpcodes: true,
+		AllowMultiQuestion: true,
+		IgnoreEDNSVersion:  true,
+	}
+
+	status := makeRequest("google.com", packet.DNSTypeA)
+	status.Header.OpCode = uint8(packet.DNSOpCodeStatus)
+	var buf bytes.Buffer
+	h.HandleQuery(&server.PackConn{RemoteAddr: "test", Request: status, Writer: &buf})
+	if buf.Len() != 0 {
+		t.Error("unknown opcode should be dropped")
+	}
+
+	two := makeRequest("google.com", packet.DNSTypeA)
+	two.AddQuestionA("example.com")
+	resp := dispatch(t, h, two)
+	if resp.RCode() != packet.DNSRCodeNoError || len(resp.Answers) != 1 {
+		t.Fatalf("two questions: got %s with %d answers", resp.RCode(), len(resp.Answers))
+	}
+	if len(resp.Questions) != 1 || resp.Questions[0].Name != "google.com" {
+		t.Errorf("only the first question should be answered, got %v", resp.Questions)
+	}
+
+	v1 := makeRequest("google.com", packet.DNSTypeA)
+	v1.AddAdditionalEDNS(4096, 0, 1, false)
+	if rcode := dispatch(t, h, v1).RCode(); rcode != packet.DNSRCodeNoError {
+		t.Errorf("EDNS version 1: got %s, want NOERROR", rcode)
+	}
+
+	// zero questions stay malformed whatever the policy
+	none := makeRequest("google.com", packet.DNSTypeA)
+	none.Questions = nil
+	if rcode := dispatch(t, h, none).RCode(); rcode != packet.DNSRCodeFormErr {
+		t.Errorf("no question: got %s, want FORMERR", rcode)
+	}
+}
@@ -0,0 +1,44 @@
+package pipeline
+
+import "github.com/lsongdev/dns-go/packet"
+
+// screen decides whether req can be dispatched. When it can't, screen
+// returns false and the error response to send, or nil when req is to be
+// dropped unanswered. What gets rejected follows h.policy; responses
+// sent to us by mistake are always dropped, so two servers can't bounce
+// errors back and forth.
+func (h *Handler) screen(req *packet.DNSPacket) (*packet.DNSPacket, bool) {
+	if req.Header.QR == packet.DNSResponse {
+		return nil, false
+	}
+	var opt *packet.DNSResourceRecordEDNS
+	for _, rr := range req.Additionals {
+		if o, ok := rr.(*packet.DNSResourceRecordEDNS); ok {
+			// RFC 6891 §6.1.1: at most one OPT record
+			if opt != nil {
+				return opcodeResponse(req, packet.DNSRCodeFormErr), false
+			}
+			opt = o
+		}
+	}
+	// RFC 6891 §6.1.3: answer an unsupported version with BADVERS and
+	// an OPT record carrying the version we do support
+	if opt != nil && opt.Version != 0 && !h.policy.IgnoreEDNSVersion {
+		return opcodeResponse(req, packet.DNSRCodeBadVers), false
+	}
+
+	switch packet.DNSOpCode(req.Header.OpCode) {
+	case packet.DNSOpCodeQuery:
+		if len(req.Questions) == 0 || (len(req.Questions) > 1 && !h.policy.AllowMultiQuestion) {
+			return opcodeResponse(req, packet.DNSRCodeFormErr), false
+		}
+	case packet.DNSOpCodeUpdate, packet.DNSOpCodeNotify:
+		// these check their zone section themselves
+	default:
+		if h.policy.DropUnknownOpcodes {
+			return nil, false
+		}
+		return opcodeResponse(req, packet.DNSRCodeNotImp), false
+	}
+	return nil, true
+}
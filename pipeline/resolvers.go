@@ -1,8 +1,6 @@
 package pipeline
 
 import (
-	"strings"
-
 	"github.com/lsongdev/dns-go/cache"
 	"github.com/lsongdev/dns-go/filter"
 	"github.com/lsongdev/dns-go/packet"
@@ -27,17 +25,27 @@ func (r *CacheResolver) Query(req *packet.DNSPacket) (*packet.DNSPacket, error)
 }
 
 // LocalResolver answers from zones the server is authoritative for. Returns
-// (nil, nil) (passes through) for any name outside the configured zones.
+// (nil, nil) (passes through) for any name outside the configured zones,
+// and SERVFAIL for names in zones the source is still loading.
 type LocalResolver struct {
 	local LocalSource
 }
 
+// loadingSource is implemented by local sources whose zones may not be
+// available yet, such as LocalIndex with secondary zones.
+type loadingSource interface {
+	Loading(qname string) bool
+}
+
 func (r *LocalResolver) Query(req *packet.DNSPacket) (*packet.DNSPacket, error) {
 	if r.local == nil || len(req.Questions) == 0 {
 		return nil, nil
 	}
 	q := req.Questions[0]
-	qname := strings.ToLower(strings.TrimSuffix(q.Name, "."))
+	qname := packet.CanonicalName(q.Name)
+	if l, ok := r.local.(loadingSource); ok && l.Loading(qname) {
+		return SynthNotReady(req), nil
+	}
 	records := r.local.Lookup(qname, q.Type)
 	if len(records) == 0 {
 		return nil, nil
@@ -56,7 +64,7 @@ func (r *FilterResolver) Query(req *packet.DNSPacket) (*packet.DNSPacket, error)
 	if r.filter == nil || len(req.Questions) == 0 {
 		return nil, nil
 	}
-	qname := strings.ToLower(strings.TrimSuffix(req.Questions[0].Name, "."))
+	qname := packet.CanonicalName(req.Questions[0].Name)
 	if r.filter.Decide(qname) != filter.Block {
 		return nil, nil
 	}
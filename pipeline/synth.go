@@ -2,13 +2,7 @@ package pipeline
 
 import "github.com/lsongdev/dns-go/packet"
 
-const (
-	rcodeNoError  = 0
-	rcodeServFail = 2
-	rcodeNXDOMAIN = 3
-
-	syntheticTTL = 60
-)
+const syntheticTTL = 60
 
 func cloneHeader(h *packet.DNSHeader) *packet.DNSHeader {
 	cp := *h
@@ -26,11 +20,23 @@ func emptyResponse(req *packet.DNSPacket) *packet.DNSPacket {
 	return &packet.DNSPacket{Header: h, Questions: req.Questions}
 }
 
+// opcodeResponse answers req with rcode alone: the header and question
+// (or zone) section of the request and no records. RA is only set in
+// answer to a QUERY; it means nothing to UPDATE and NOTIFY.
+func opcodeResponse(req *packet.DNSPacket, rcode packet.DNSRCode) *packet.DNSPacket {
+	res := emptyResponse(req)
+	if packet.DNSOpCode(req.Header.OpCode) != packet.DNSOpCodeQuery {
+		res.Header.RA = 0
+	}
+	res.SetRCode(rcode)
+	return res
+}
+
 // SynthBlock builds a "blocked" response: A→0.0.0.0, AAAA→::, others→NXDOMAIN.
 func SynthBlock(req *packet.DNSPacket) *packet.DNSPacket {
 	res := emptyResponse(req)
 	if len(req.Questions) == 0 {
-		res.Header.RCode = rcodeNXDOMAIN
+		res.SetRCode(packet.DNSRCodeNXDomain)
 		return res
 	}
 	q := req.Questions[0]
@@ -46,8 +52,9 @@ func SynthBlock(req *packet.DNSPacket) *packet.DNSPacket {
 			Address:           "::",
 		})
 	default:
-		res.Header.RCode = rcodeNXDOMAIN
+		res.SetRCode(packet.DNSRCodeNXDomain)
 	}
+	addExtendedError(req, res, packet.EDECodeBlocked, "blocked by filter")
 	return res
 }
 
@@ -55,16 +62,42 @@ func SynthBlock(req *packet.DNSPacket) *packet.DNSPacket {
 // unavailable or all upstreams errored.
 func SynthSERVFAIL(req *packet.DNSPacket) *packet.DNSPacket {
 	res := emptyResponse(req)
-	res.Header.RCode = rcodeServFail
+	res.SetRCode(packet.DNSRCodeServFail)
+	addExtendedError(req, res, packet.EDECodeNoReachableAuthority, "no upstream answered")
+	return res
+}
+
+// SynthNotReady builds the SERVFAIL answered for a zone that hasn't been
+// loaded yet.
+func SynthNotReady(req *packet.DNSPacket) *packet.DNSPacket {
+	res := emptyResponse(req)
+	res.SetRCode(packet.DNSRCodeServFail)
+	addExtendedError(req, res, packet.EDECodeNotReady, "zone not loaded yet")
 	return res
 }
 
+// addExtendedError explains a synthesized response with an Extended DNS
+// Error (RFC 8914). Clients that didn't send OPT can't receive one, so the
+// response is left alone for them.
+func addExtendedError(req, res *packet.DNSPacket, code packet.EDECode, text string) {
+	if !hasEDNS(req) {
+		return
+	}
+	opt := packet.NewEDNSRecord(packet.DefaultEDNSUDPSize)
+	opt.AddEDNSOptionExtendedError(code, text)
+	res.AddAdditional(opt)
+}
+
 // buildLocalResponse wraps zone records as an authoritative answer for the
 // caller's question.
 func buildLocalResponse(req *packet.DNSPacket, records []packet.DNSResource) *packet.DNSPacket {
 	res := emptyResponse(req)
 	res.Header.AA = 1
-	res.Header.RCode = rcodeNoError
-	res.Answers = append(res.Answers, records...)
+	res.SetRCode(packet.DNSRCodeNoError)
+	// records belong to the local index; hand out copies so the response
+	// can be edited downstream
+	for _, rr := range records {
+		res.Answers = append(res.Answers, packet.CloneResource(rr))
+	}
 	return res
 }
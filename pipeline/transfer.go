@@ -0,0 +1,227 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lsongdev/dns-go/client"
+	"github.com/lsongdev/dns-go/packet"
+	"github.com/lsongdev/dns-go/server"
+)
+
+// Refresh intervals used until a secondary zone has an SOA of its own,
+// and the timeout of each query to the primary.
+var (
+	defaultRefresh  = time.Hour
+	defaultRetry    = time.Minute
+	transferTimeout = 30 * time.Second
+)
+
+// secondaryZone is a zone copied from a primary server. It is transferred
+// at startup, when the primary sends NOTIFY and when the SOA refresh
+// interval runs out (RFC 1034 §4.3.5).
+type secondaryZone struct {
+	origin   string
+	primary  string
+	key      *packet.TSIGKey // signs the queries to the primary, if set
+	notified chan struct{}
+}
+
+func newSecondaryZone(origin, primary string, key *packet.TSIGKey) *secondaryZone {
+	return &secondaryZone{origin: origin, primary: primary, key: key, notified: make(chan struct{}, 1)}
+}
+
+// poke schedules a refresh; a refresh already pending absorbs it.
+func (s *secondaryZone) poke() {
+	select {
+	case s.notified <- struct{}{}:
+	default:
+	}
+}
+
+// startTransfers keeps the secondary zones in step with their primaries
+// until the returned function is called.
+func (li *LocalIndex) startTransfers() (stop func()) {
+	done := make(chan struct{})
+	for _, s := range li.secondaries {
+		go li.follow(s, done)
+	}
+	return func() { close(done) }
+}
+
+func (li *LocalIndex) follow(s *secondaryZone, done <-chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		case <-s.notified:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+		refresh, retry := li.refreshTimers(s.origin)
+		if err := li.refresh(s); err != nil {
+			log.Printf("transfer %s from %s: %v", s.origin, s.primary, err)
+			timer.Reset(retry)
+			continue
+		}
+		timer.Reset(refresh)
+	}
+}
+
+// refresh transfers the zone from the primary if the primary's SOA serial
+// is newer than ours.
+func (li *LocalIndex) refresh(s *secondaryZone) error {
+	c := client.NewTCPClient(s.primary)
+	c.Timeout = transferTimeout
+	c.TSIG = s.key
+	defer c.Close()
+
+	query := packet.NewPacket()
+	query.AddQuestionSOA(s.origin)
+	res, err := c.Query(query)
+	if err != nil {
+		return err
+	}
+	i := indexOfType(res.Answers, s.origin, packet.DNSTypeSOA)
+	if i < 0 {
+		return fmt.Errorf("primary has no SOA for the zone")
+	}
+	remote, _ := zoneSOA(res.Answers, i)
+	if local := li.soa(s.origin); local != nil && !serialAfter(remote.Serial, local.Serial) {
+		return nil
+	}
+
+	records, err := c.Transfer(s.origin)
+	if err != nil {
+		return err
+	}
+	for _, rr := range records {
+		if !packet.IsSubDomain(s.origin, rr.GetHeader().Name) {
+			return fmt.Errorf("transfer holds %q, outside the zone", rr.GetHeader().Name)
+		}
+	}
+	li.mu.Lock()
+	li.setZone(s.origin, records)
+	li.mu.Unlock()
+	log.Printf("transfer %s from %s: %d records", s.origin, s.primary, len(records))
+	return nil
+}
+
+// Loading reports whether qname falls in a secondary zone that hasn't been
+// transferred yet. Such names can't be answered: not from here, and not
+// from upstream either, which may know a different version of the zone.
+func (li *LocalIndex) Loading(qname string) bool {
+	if ascii, err := packet.IDNAToASCII(qname); err == nil {
+		qname = ascii
+	}
+	qname = packet.CanonicalName(qname)
+	li.mu.RLock()
+	defer li.mu.RUnlock()
+	origin := li.matchZone(qname)
+	return li.secondaries[origin] != nil && len(li.zones[origin]) == 0
+}
+
+// soa returns the SOA record of zone origin, or nil.
+func (li *LocalIndex) soa(origin string) *packet.DNSResourceRecordSOA {
+	li.mu.RLock()
+	defer li.mu.RUnlock()
+	records := li.zones[origin]
+	soa, _ := zoneSOA(records, indexOfType(records, origin, packet.DNSTypeSOA))
+	return soa
+}
+
+// refreshTimers returns the refresh and retry intervals of the zone's SOA.
+func (li *LocalIndex) refreshTimers(origin string) (refresh, retry time.Duration) {
+	refresh, retry = defaultRefresh, defaultRetry
+	if soa := li.soa(origin); soa != nil {
+		if soa.Refresh > 0 {
+			refresh = time.Duration(soa.Refresh) * time.Second
+		}
+		if soa.Retry > 0 {
+			retry = time.Duration(soa.Retry) * time.Second
+		}
+	}
+	return refresh, retry
+}
+
+// Transferer hands out whole zones for AXFR (RFC 5936) and returns the
+// RCODE to answer with. LocalIndex implements it for the zones in
+// config.yaml.
+type Transferer interface {
+	Transfer(origin string, client UpdateClient) ([]packet.DNSResource, packet.DNSRCode)
+}
+
+// axfrMessageSize caps the records packed into one AXFR message, counted
+// without name compression so the message stays well under the 64KiB a
+// TCP message can carry.
+const axfrMessageSize = 16 * 1024
+
+// Transfer returns the records of zone origin in transfer order: the SOA,
+// the rest of the zone, and the SOA again. The client must be on the
+// zone's allow_transfer list; a secondary zone not transferred yet, or a
+// zone without an SOA, can't be handed out and answers SERVFAIL.
+func (li *LocalIndex) Transfer(origin string, client UpdateClient) ([]packet.DNSResource, packet.DNSRCode) {
+	origin = packet.CanonicalName(origin)
+	li.mu.RLock()
+	defer li.mu.RUnlock()
+	records, ok := li.zones[origin]
+	if !ok {
+		return nil, packet.DNSRCodeNotAuth
+	}
+	if !li.xfrACLs[origin].allows(client) {
+		return nil, packet.DNSRCodeRefused
+	}
+	i := indexOfType(records, origin, packet.DNSTypeSOA)
+	if i < 0 {
+		return nil, packet.DNSRCodeServFail
+	}
+	out := make([]packet.DNSResource, 0, len(records)+1)
+	out = append(out, records[i])
+	out = append(out, records[:i]...)
+	out = append(out, records[i+1:]...)
+	return append(out, records[i]), packet.DNSRCodeNoError
+}
+
+// transfer answers an AXFR for one of the local zones, over as many
+// messages as the zone takes. Only stream transports can carry them.
+func (h *Handler) transfer(conn *server.PackConn) {
+	req := conn.Request
+	q := req.Questions[0]
+	var records []packet.DNSResource
+	rcode := packet.DNSRCodeNotImp
+	if h.xfr != nil && conn.Stream && q.Class == packet.DNSClassIN {
+		records, rcode = h.xfr.Transfer(q.Name, updateClientOf(conn))
+	}
+	log.Printf("[%s] transfer %s: %s", conn.RemoteAddr, q.Name, rcode)
+	if rcode != packet.DNSRCodeNoError {
+		if err := conn.WriteResponse(opcodeResponse(req, rcode)); err != nil {
+			log.Printf("[%s] write error: %v", conn.RemoteAddr, err)
+		}
+		return
+	}
+	for len(records) > 0 {
+		res := opcodeResponse(req, packet.DNSRCodeNoError)
+		res.Header.AA = 1
+		size := 0
+		for len(records) > 0 {
+			rr := records[0]
+			// the name, type, class, TTL, RDLENGTH and RDATA
+			n := len(rr.GetHeader().Name) + 2 + 10 + len(rr.Encode())
+			if size > 0 && size+n > axfrMessageSize {
+				break
+			}
+			res.AddAnswer(rr)
+			size += n
+			records = records[1:]
+		}
+		if err := conn.WriteResponse(res); err != nil {
+			log.Printf("[%s] write error: %v", conn.RemoteAddr, err)
+			return
+		}
+	}
+}
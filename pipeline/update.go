@@ -0,0 +1,390 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/lsongdev/dns-go/packet"
+	"github.com/lsongdev/dns-go/server"
+)
+
+// Updater applies DNS UPDATE messages (RFC 2136) and returns the RCODE to
+// answer with. LocalIndex implements it for the zones in config.yaml.
+type Updater interface {
+	Update(req *packet.DNSPacket, client UpdateClient) packet.DNSRCode
+}
+
+// UpdateClient identifies the sender of an UPDATE, a NOTIFY or an AXFR: the
+// name of the TSIG key that signed it, if it was signed, and its address.
+type UpdateClient struct {
+	Key  string
+	Addr net.IP
+}
+
+func updateClientOf(conn *server.PackConn) UpdateClient {
+	var c UpdateClient
+	if conn.TSIG != nil {
+		c.Key = conn.TSIG.Key().Name
+	}
+	host, _, err := net.SplitHostPort(conn.RemoteAddr)
+	if err != nil {
+		host = conn.RemoteAddr
+	}
+	c.Addr = net.ParseIP(host)
+	return c
+}
+
+// updateACL is a zone's allow_update list; allow_notify and
+// allow_transfer lists take the same form.
+type updateACL struct {
+	keys map[string]bool // packet.CanonicalName of the key name
+	nets []*net.IPNet
+}
+
+func (a *updateACL) add(entries []string) error {
+	for _, e := range entries {
+		if ip := net.ParseIP(e); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			a.nets = append(a.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		if _, n, err := net.ParseCIDR(e); err == nil {
+			a.nets = append(a.nets, n)
+			continue
+		}
+		if err := packet.ValidateName(e); err != nil {
+			return fmt.Errorf("%q: %w", e, err)
+		}
+		a.keys[packet.CanonicalName(e)] = true
+	}
+	return nil
+}
+
+func (a *updateACL) allows(c UpdateClient) bool {
+	if a == nil {
+		return false
+	}
+	if c.Key != "" && a.keys[packet.CanonicalName(c.Key)] {
+		return true
+	}
+	for _, n := range a.nets {
+		if c.Addr != nil && n.Contains(c.Addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Update applies req to the zone it names, following RFC 2136 §3: the
+// prerequisites are checked against the zone as it stands, the updates
+// are checked as a whole before any is applied, and then applied in order.
+// When anything changed the SOA serial is incremented, unless the update
+// set it. The sender must be on the zone's allow_update list, and the
+// zone must not be a secondary.
+func (li *LocalIndex) Update(req *packet.DNSPacket, client UpdateClient) packet.DNSRCode {
+	zone := req.UpdateZone()
+	if zone == nil || zone.Type != packet.DNSTypeSOA {
+		return packet.DNSRCodeFormErr
+	}
+	origin := packet.CanonicalName(zone.Name)
+
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	records, ok := li.zones[origin]
+	// a secondary zone only changes by transfer from its primary
+	if !ok || zone.Class != packet.DNSClassIN || li.secondaries[origin] != nil {
+		return packet.DNSRCodeNotAuth
+	}
+	if !li.acls[origin].allows(client) {
+		return packet.DNSRCodeRefused
+	}
+	if rcode := checkPrerequisites(origin, records, req.Answers); rcode != packet.DNSRCodeNoError {
+		return rcode
+	}
+	if rcode := prescanUpdates(origin, req.Authorities); rcode != packet.DNSRCodeNoError {
+		return rcode
+	}
+	if updated, changed := applyUpdates(origin, records, req.Authorities); changed {
+		li.setZone(origin, updated)
+	}
+	return packet.DNSRCodeNoError
+}
+
+// checkPrerequisites implements RFC 2136 §3.2.
+func checkPrerequisites(origin string, records, prereqs []packet.DNSResource) packet.DNSRCode {
+	var values []packet.DNSResource
+	for _, rr := range prereqs {
+		h := rr.GetHeader()
+		if h.TTL != 0 {
+			return packet.DNSRCodeFormErr
+		}
+		if !packet.IsSubDomain(origin, h.Name) {
+			return packet.DNSRCodeNotZone
+		}
+		switch h.Class {
+		case packet.DNSClassAny:
+			if !emptyRData(rr) {
+				return packet.DNSRCodeFormErr
+			}
+			if h.Type == packet.DNSTypeAny {
+				if len(recordsAt(records, h.Name)) == 0 {
+					return packet.DNSRCodeNXDomain
+				}
+			} else if len(rrset(records, h.Name, h.Type)) == 0 {
+				return packet.DNSRCodeNXRRSet
+			}
+		case packet.DNSClassNone:
+			if !emptyRData(rr) {
+				return packet.DNSRCodeFormErr
+			}
+			if h.Type == packet.DNSTypeAny {
+				if len(recordsAt(records, h.Name)) > 0 {
+					return packet.DNSRCodeYXDomain
+				}
+			} else if len(rrset(records, h.Name, h.Type)) > 0 {
+				return packet.DNSRCodeYXRRSet
+			}
+		case packet.DNSClassIN:
+			values = append(values, rr)
+		default:
+			return packet.DNSRCodeFormErr
+		}
+	}
+	// value-dependent prerequisites name whole RRsets, which must match
+	// the zone's exactly
+	for _, rr := range values {
+		h := rr.GetHeader()
+		if !sameRRset(rrset(records, h.Name, h.Type), rrset(values, h.Name, h.Type)) {
+			return packet.DNSRCodeNXRRSet
+		}
+	}
+	return packet.DNSRCodeNoError
+}
+
+// prescanUpdates implements RFC 2136 §3.4.1: a malformed update rejects
+// the whole message before anything is applied.
+func prescanUpdates(origin string, updates []packet.DNSResource) packet.DNSRCode {
+	for _, rr := range updates {
+		h := rr.GetHeader()
+		if !packet.IsSubDomain(origin, h.Name) {
+			return packet.DNSRCodeNotZone
+		}
+		switch h.Class {
+		case packet.DNSClassIN:
+			if metaType(h.Type) {
+				return packet.DNSRCodeFormErr
+			}
+		case packet.DNSClassAny:
+			if h.TTL != 0 || !emptyRData(rr) || (metaType(h.Type) && h.Type != packet.DNSTypeAny) {
+				return packet.DNSRCodeFormErr
+			}
+		case packet.DNSClassNone:
+			if h.TTL != 0 || metaType(h.Type) {
+				return packet.DNSRCodeFormErr
+			}
+		default:
+			return packet.DNSRCodeFormErr
+		}
+	}
+	return packet.DNSRCodeNoError
+}
+
+// applyUpdates implements RFC 2136 §3.4.2 on a copy of records and
+// reports whether anything changed. Updates the RFC says to ignore, such
+// as deleting the SOA or the apex's last NS record, are skipped silently.
+func applyUpdates(origin string, records, updates []packet.DNSResource) ([]packet.DNSResource, bool) {
+	zone := append([]packet.DNSResource(nil), records...)
+	changed, serialSet := false, false
+	for _, rr := range updates {
+		h := rr.GetHeader()
+		apex := packet.EqualName(h.Name, origin)
+		switch h.Class {
+		case packet.DNSClassIN:
+			if h.Type == packet.DNSTypeSOA {
+				soa, ok := rr.(*packet.DNSResourceRecordSOA)
+				if !apex || !ok {
+					continue
+				}
+				// the SOA is only replaced by one with a newer serial
+				i := indexOfType(zone, h.Name, packet.DNSTypeSOA)
+				if old, ok := zoneSOA(zone, i); ok && !serialAfter(soa.Serial, old.Serial) {
+					continue
+				}
+				zone = replaceAt(zone, i, rr)
+				changed, serialSet = true, true
+				continue
+			}
+			if i := indexOfRecord(zone, rr); i >= 0 && zone[i].GetHeader().TTL == h.TTL {
+				continue
+			}
+			cnames := len(rrset(zone, h.Name, packet.DNSTypeCNAME))
+			if h.Type == packet.DNSTypeCNAME {
+				if len(recordsAt(zone, h.Name)) > cnames {
+					continue
+				}
+				zone = replaceAt(zone, indexOfType(zone, h.Name, packet.DNSTypeCNAME), rr)
+				changed = true
+				continue
+			}
+			if cnames > 0 {
+				continue
+			}
+			// adding a record that's already there only refreshes its TTL
+			zone = replaceAt(zone, indexOfRecord(zone, rr), rr)
+			changed = true
+		case packet.DNSClassAny:
+			var removed bool
+			zone, removed = removeRecords(zone, func(z packet.DNSResource) bool {
+				t := z.GetType()
+				if apex && (t == packet.DNSTypeSOA || t == packet.DNSTypeNS) {
+					return false
+				}
+				return (h.Type == packet.DNSTypeAny || t == h.Type) && packet.EqualName(z.GetHeader().Name, h.Name)
+			})
+			changed = changed || removed
+		case packet.DNSClassNone:
+			if h.Type == packet.DNSTypeSOA {
+				continue
+			}
+			if apex && h.Type == packet.DNSTypeNS && len(rrset(zone, h.Name, packet.DNSTypeNS)) == 1 {
+				continue
+			}
+			var removed bool
+			zone, removed = removeRecords(zone, func(z packet.DNSResource) bool {
+				return packet.EqualRData(z, rr)
+			})
+			changed = changed || removed
+		}
+	}
+	if changed && !serialSet {
+		i := indexOfType(zone, origin, packet.DNSTypeSOA)
+		if old, ok := zoneSOA(zone, i); ok {
+			soa := old.Clone().(*packet.DNSResourceRecordSOA)
+			soa.Serial++
+			zone[i] = soa
+		}
+	}
+	return zone, changed
+}
+
+// replaceAt stores a copy of rr at zone[i], or appends it if i is -1.
+func replaceAt(zone []packet.DNSResource, i int, rr packet.DNSResource) []packet.DNSResource {
+	cp := packet.CloneResource(rr)
+	if i < 0 {
+		return append(zone, cp)
+	}
+	zone[i] = cp
+	return zone
+}
+
+func zoneSOA(zone []packet.DNSResource, i int) (*packet.DNSResourceRecordSOA, bool) {
+	if i < 0 {
+		return nil, false
+	}
+	soa, ok := zone[i].(*packet.DNSResourceRecordSOA)
+	return soa, ok
+}
+
+func removeRecords(zone []packet.DNSResource, match func(packet.DNSResource) bool) ([]packet.DNSResource, bool) {
+	kept := zone[:0]
+	for _, z := range zone {
+		if !match(z) {
+			kept = append(kept, z)
+		}
+	}
+	return kept, len(kept) < len(zone)
+}
+
+func recordsAt(records []packet.DNSResource, name string) []packet.DNSResource {
+	var out []packet.DNSResource
+	for _, r := range records {
+		if packet.EqualName(recordName(r), name) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func rrset(records []packet.DNSResource, name string, t packet.DNSType) []packet.DNSResource {
+	var out []packet.DNSResource
+	for _, r := range records {
+		if r.GetType() == t && packet.EqualName(recordName(r), name) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func indexOfType(records []packet.DNSResource, name string, t packet.DNSType) int {
+	for i, r := range records {
+		if r.GetType() == t && packet.EqualName(recordName(r), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexOfRecord(records []packet.DNSResource, rr packet.DNSResource) int {
+	for i, r := range records {
+		if packet.EqualRData(r, rr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameRRset reports whether a and b hold the same records, ignoring TTLs
+// and duplicates.
+func sameRRset(a, b []packet.DNSResource) bool {
+	return subset(a, b) && subset(b, a)
+}
+
+func subset(a, b []packet.DNSResource) bool {
+	for _, x := range a {
+		if indexOfRecord(b, x) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// emptyRData reports whether rr carries no RDATA, as the RRset-level
+// prerequisites and deletions of RFC 2136 do.
+func emptyRData(rr packet.DNSResource) bool {
+	u, ok := rr.(*packet.DNSResourceRecordUnknown)
+	return ok && len(u.RData) == 0
+}
+
+// metaType reports whether t is a query or meta type (RFC 6895 §3.1),
+// which can't be stored in a zone.
+func metaType(t packet.DNSType) bool {
+	return t == packet.DNSTypeEDNS || (t >= 128 && t <= 255)
+}
+
+// serialAfter reports whether SOA serial a is newer than b in the serial
+// number arithmetic of RFC 1982.
+func serialAfter(a, b uint32) bool {
+	return a != b && int32(a-b) > 0
+}
+
+// update answers a DNS UPDATE. Updates are never cached or forwarded: the
+// updater applies them, and cached answers for the names they touch are
+// dropped so the change is visible at once.
+func (h *Handler) update(conn *server.PackConn) {
+	req := conn.Request
+	rcode := packet.DNSRCodeNotImp
+	if h.updater != nil {
+		rcode = h.updater.Update(req, updateClientOf(conn))
+	}
+	if zone := req.UpdateZone(); zone != nil && rcode != packet.DNSRCodeNoError {
+		log.Printf("[%s] update %s: %s", conn.RemoteAddr, zone.Name, rcode)
+	}
+	res := opcodeResponse(req, rcode)
+	if err := conn.WriteResponse(res); err != nil {
+		log.Printf("[%s] write error: %v", conn.RemoteAddr, err)
+	}
+}
@@ -1,30 +1,140 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
 
 	"github.com/lsongdev/dns-go/packet"
 )
 
+// DefaultDoHPath is the path DoH is served at unless configured otherwise,
+// the one RFC 8484 uses in its examples.
+const DefaultDoHPath = "/dns-query"
+
+// dnsMessageType is the media type of a DNS message in wire format.
+const dnsMessageType = "application/dns-message"
+
+// maxDNSMessage is the largest DNS message, the most a TCP length prefix
+// can announce.
+const maxDNSMessage = 65535
+
+// ListenHTTP serves DNS over HTTP at addr, on every path, until the
+// process exits. Use Server to be able to stop it.
 func ListenHTTP(addr string, handler DNSHandler) error {
-	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// log.Println(r.RemoteAddr)
-		d := r.URL.Query().Get("dns")
-		data, err := base64.RawURLEncoding.DecodeString(d)
+	s := &Server{}
+	if _, err := s.ListenHTTP(addr, &DoHHandler{Handler: handler}); err != nil {
+		return err
+	}
+	return s.Serve(context.Background())
+}
+
+// DoHHandler serves DNS over HTTPS (RFC 8484) on whatever path it is
+// mounted at: GET with the query in the dns parameter, base64url-encoded,
+// or POST with the query as the body. Answers are application/dns-message
+// and may be cached by HTTP caches for as long as their records live.
+// Requests that carry no usable query get 400, POST bodies of another
+// type 415 and oversized queries 413.
+type DoHHandler struct {
+	Handler DNSHandler
+}
+
+func (d *DoHHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, status, err := readDoHQuery(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	req, err := packet.FromBytes(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("malformed DNS message: %v", err), http.StatusBadRequest)
+		return
+	}
+	var buf bytes.Buffer
+	d.Handler.HandleQuery(newPackConn(&buf, r.RemoteAddr, req, data))
+	if buf.Len() == 0 {
+		// the handler chose not to answer, as it does for responses
+		http.Error(w, "query not answered", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", dnsMessageType)
+	if res, err := packet.FromBytes(buf.Bytes()); err == nil {
+		if ttl, ok := cacheTTL(res); ok {
+			w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", ttl))
+		}
+	}
+	w.Write(buf.Bytes())
+}
+
+// readDoHQuery returns the DNS message of a DoH request, or the status to
+// answer with and why.
+func readDoHQuery(w http.ResponseWriter, r *http.Request) ([]byte, int, error) {
+	switch r.Method {
+	case http.MethodGet:
+		param := r.URL.Query().Get("dns")
+		if param == "" {
+			return nil, http.StatusBadRequest, errors.New("missing dns parameter")
+		}
+		if base64.RawURLEncoding.DecodedLen(len(param)) > maxDNSMessage {
+			return nil, http.StatusRequestEntityTooLarge, errors.New("query too large")
+		}
+		// RFC 8484 §6 leaves out the padding, but some clients send it
+		data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(param, "="))
 		if err != nil {
-			return
+			return nil, http.StatusBadRequest, fmt.Errorf("dns parameter is not base64url: %v", err)
 		}
-		req, err := packet.FromBytes(data)
+		return data, 0, nil
+	case http.MethodPost:
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != dnsMessageType {
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("content type must be %s", dnsMessageType)
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDNSMessage))
 		if err != nil {
-			return
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, http.StatusRequestEntityTooLarge, errors.New("query too large")
+			}
+			return nil, http.StatusBadRequest, err
+		}
+		return data, 0, nil
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		return nil, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)
+	}
+}
+
+// cacheTTL returns how long an HTTP cache may keep res (RFC 8484 §5.1):
+// the smallest TTL in the answer section or, for a negative answer, the
+// negative caching TTL of the SOA record in the authority section
+// (RFC 2308 §5).
+func cacheTTL(res *packet.DNSPacket) (uint32, bool) {
+	records := res.Answers
+	if len(records) == 0 {
+		records = res.Authorities
+	}
+	var ttl uint32
+	found := false
+	for _, rr := range records {
+		t := rr.GetHeader().TTL
+		if len(res.Answers) == 0 {
+			soa, ok := rr.(*packet.DNSResourceRecordSOA)
+			if !ok {
+				continue
+			}
+			if soa.Minimum < t {
+				t = soa.Minimum
+			}
 		}
-		conn := &PackConn{
-			Writer:     w,
-			Request:    req,
-			RemoteAddr: r.RemoteAddr,
+		if !found || t < ttl {
+			ttl, found = t, true
 		}
-		handler.HandleQuery(conn)
-	})
-	return http.ListenAndServe(addr, h)
+	}
+	return ttl, found
 }
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lsongdev/dns-go/client"
+	"github.com/lsongdev/dns-go/packet"
+)
+
+// zoneHandler answers example.com with two A records of different TTLs
+// and anything else with NXDOMAIN and an SOA record.
+type zoneHandler struct{}
+
+func (zoneHandler) HandleQuery(conn *PackConn) {
+	req := conn.Request
+	res := &packet.DNSPacket{Header: &packet.DNSHeader{ID: req.Header.ID}, Questions: req.Questions}
+	if req.Questions[0].Name == "example.com" {
+		for i, ttl := range []uint32{300, 60} {
+			res.AddAnswer(&packet.DNSResourceRecordA{
+				DNSResourceRecord: packet.DNSResourceRecord{Name: "example.com", Type: packet.DNSTypeA, Class: packet.DNSClassIN, TTL: ttl},
+				Address:           net.IPv4(192, 0, 2, byte(i+1)).String(),
+			})
+		}
+	} else {
+		res.SetRCode(packet.DNSRCodeNXDomain)
+		res.AddAuthority(&packet.DNSResourceRecordSOA{
+			DNSResourceRecord: packet.DNSResourceRecord{Name: "com", Type: packet.DNSTypeSOA, Class: packet.DNSClassIN, TTL: 3600},
+			MName:             "ns.com",
+			RName:             "hostmaster.com",
+			Serial:            1,
+			Minimum:           120,
+		})
+	}
+	conn.WriteResponse(res)
+}
+
+func dohQuery(name string) []byte {
+	query := packet.NewPacket()
+	query.Header.ID = 0x4242
+	query.AddQuestionA(name)
+	return query.Bytes()
+}
+
+func TestDoHHandler(t *testing.T) {
+	h := &DoHHandler{Handler: zoneHandler{}}
+	get := func(name string) *http.Request {
+		return httptest.NewRequest(http.MethodGet, "/dns-query?dns="+base64.RawURLEncoding.EncodeToString(dohQuery(name)), nil)
+	}
+	post := func(contentType string, body []byte) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/dns-query", bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return r
+	}
+
+	cases := []struct {
+		name   string
+		req    *http.Request
+		status int
+		maxAge string
+	}{
+		{"get", get("example.com"), http.StatusOK, "max-age=60"},
+		{"post", post("application/dns-message", dohQuery("example.com")), http.StatusOK, "max-age=60"},
+		{"negative", get("nope.com"), http.StatusOK, "max-age=120"},
+		{"padded base64", httptest.NewRequest(http.MethodGet, "/dns-query?dns="+base64.URLEncoding.EncodeToString(dohQuery("example.com")), nil), http.StatusOK, "max-age=60"},
+		{"no dns parameter", httptest.NewRequest(http.MethodGet, "/dns-query", nil), http.StatusBadRequest, ""},
+		{"bad base64", httptest.NewRequest(http.MethodGet, "/dns-query?dns=***", nil), http.StatusBadRequest, ""},
+		{"malformed message", post("application/dns-message", []byte{1, 2, 3}), http.StatusBadRequest, ""},
+		{"wrong content type", post("application/json", dohQuery("example.com")), http.StatusUnsupportedMediaType, ""},
+		{"too large", post("application/dns-message", make([]byte, 70000)), http.StatusRequestEntityTooLarge, ""},
+		{"method", httptest.NewRequest(http.MethodPut, "/dns-query", nil), http.StatusMethodNotAllowed, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, c.req)
+			if w.Code != c.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, c.status, w.Body)
+			}
+			if c.status != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/dns-message" {
+				t.Errorf("Content-Type %q", ct)
+			}
+			if cc := w.Header().Get("Cache-Control"); cc != c.maxAge {
+				t.Errorf("Cache-Control %q, want %q", cc, c.maxAge)
+			}
+			res, err := packet.FromBytes(w.Body.Bytes())
+			if err != nil || res.Header.ID != 0x4242 {
+				t.Errorf("bad answer: %v %v", res, err)
+			}
+		})
+	}
+}
+
+// selfSigned returns a TLS config serving a certificate for 127.0.0.1,
+// and a pool trusting it.
+func selfSigned(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dns-go test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+func TestServerHTTPS(t *testing.T) {
+	config, pool := selfSigned(t)
+	mux := http.NewServeMux()
+	mux.Handle(DefaultDoHPath, &DoHHandler{Handler: zoneHandler{}})
+	srv := &Server{}
+	addr, err := srv.ListenHTTPS("127.0.0.1:0", config, mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(context.Background())
+	defer srv.Shutdown(context.Background())
+
+	c := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool},
+		ForceAttemptHTTP2: true,
+	}}
+	url := "https://" + addr.String() + DefaultDoHPath
+	resp, err := c.Post(url, "application/dns-message", bytes.NewReader(dohQuery("example.com")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Errorf("got %s over %s", resp.Status, resp.Proto)
+	}
+	if res, err := packet.FromBytes(body); err != nil || len(res.Answers) != 2 {
+		t.Errorf("bad answer: %v %v", res, err)
+	}
+
+	resp, err = c.Get(strings.TrimSuffix(url, DefaultDoHPath) + "/elsewhere")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("other paths should be 404, got %s", resp.Status)
+	}
+}
+
+func TestServerHTTPClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle(DefaultDoHPath, &DoHHandler{Handler: zoneHandler{}})
+	srv := &Server{}
+	addr, err := srv.ListenHTTP("127.0.0.1:0", mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(context.Background())
+	defer srv.Shutdown(context.Background())
+
+	for _, c := range []*client.HTTPClient{
+		client.NewHTTPClient("http://" + addr.String() + DefaultDoHPath),
+		client.NewHTTPClientPost("http://" + addr.String() + DefaultDoHPath),
+	} {
+		query := packet.NewPacket()
+		query.AddQuestionA("example.com")
+		res, err := c.Query(query)
+		if err != nil {
+			t.Fatalf("post=%v: %v", c.UsePost, err)
+		}
+		if len(res.Answers) != 2 {
+			t.Errorf("post=%v: got %d answers", c.UsePost, len(res.Answers))
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lsongdev/dns-go/packet"
+)
+
+// DefaultJSONPath is the path the JSON API is served at unless configured
+// otherwise, the one Google Public DNS uses.
+const DefaultJSONPath = "/resolve"
+
+// dnsJSONType is the media type of the DNS JSON API.
+const dnsJSONType = "application/dns-json"
+
+// JSONHandler serves the DNS JSON API of Google Public DNS and Cloudflare
+// (GET ?name=example.com&type=AAAA) on whatever path it is mounted at.
+// The query is built from the parameters and answered by Handler like any
+// other; the answer is a packet.DNSJSONResponse. Supported parameters are
+// name, type (a mnemonic or a number, A by default), cd, do and
+// edns_client_subnet (an address or a CIDR prefix).
+type JSONHandler struct {
+	Handler DNSHandler
+}
+
+func (j *JSONHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		jsonError(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	query, subnet, err := jsonQuery(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var data bytes.Buffer
+	if err := query.PackTo(&data, true); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var buf bytes.Buffer
+	j.Handler.HandleQuery(newPackConn(&buf, r.RemoteAddr, query, data.Bytes()))
+	if buf.Len() == 0 {
+		jsonError(w, "query not answered", http.StatusBadRequest)
+		return
+	}
+	res, err := packet.FromBytes(buf.Bytes())
+	if err != nil {
+		jsonError(w, fmt.Sprintf("malformed answer: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	out := packet.NewDNSJSONResponse(res)
+	if out.EDNSClientSubnet == "" {
+		out.EDNSClientSubnet = subnet
+	}
+	w.Header().Set("Content-Type", dnsJSONType)
+	if ttl, ok := cacheTTL(res); ok {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", ttl))
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+// jsonError answers with status and a JSON body {"error": msg}, the form
+// Cloudflare's API reports bad requests in.
+func jsonError(w http.ResponseWriter, msg string, status int) {
+	w.Header().Set("Content-Type", dnsJSONType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{msg})
+}
+
+// jsonQuery builds the DNS query a JSON API request asks for, and returns
+// the client subnet it carries, if any, as address/prefix.
+func jsonQuery(r *http.Request) (*packet.DNSPacket, string, error) {
+	params := r.URL.Query()
+	name := params.Get("name")
+	if name == "" {
+		return nil, "", fmt.Errorf("missing name parameter")
+	}
+	name = strings.TrimSuffix(name, ".")
+	if err := packet.ValidateName(name); err != nil {
+		return nil, "", fmt.Errorf("invalid name %q: %v", params.Get("name"), err)
+	}
+	qtype := packet.DNSTypeA
+	if s := params.Get("type"); s != "" {
+		if v, err := strconv.ParseUint(s, 10, 16); err == nil {
+			qtype = packet.DNSType(v)
+		} else if qtype, err = packet.ParseDNSType(s); err != nil {
+			return nil, "", err
+		}
+	}
+	cd, err := jsonFlag(params.Get("cd"))
+	if err != nil {
+		return nil, "", fmt.Errorf("cd: %v", err)
+	}
+	do, err := jsonFlag(params.Get("do"))
+	if err != nil {
+		return nil, "", fmt.Errorf("do: %v", err)
+	}
+
+	query := packet.NewPacket()
+	query.Header.RD = 1
+	query.Header.SetCheckingDisabled(cd)
+	query.AddQuestion(&packet.DNSQuestion{Name: name, Type: qtype, Class: packet.DNSClassIN})
+
+	var subnet string
+	if s := params.Get("edns_client_subnet"); s != "" || do {
+		opt := packet.NewEDNSRecord(packet.DefaultEDNSUDPSize)
+		opt.SetDNSSECOK(do)
+		if s != "" {
+			ip, prefix, err := parseSubnet(s)
+			if err != nil {
+				return nil, "", err
+			}
+			opt.AddEDNSOptionClientSubnet(ip, prefix)
+			subnet = fmt.Sprintf("%s/%d", ip.Mask(net.CIDRMask(int(prefix), len(ip)*8)), prefix)
+		}
+		query.AddAdditional(opt)
+	}
+	return query, subnet, nil
+}
+
+// jsonFlag parses a boolean parameter, which the public APIs accept as
+// 1/0 or true/false and treat as false when left out.
+func jsonFlag(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "", "0", "false":
+		return false, nil
+	case "1", "true":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid value %q", s)
+}
+
+// parseSubnet parses an address or a CIDR prefix; a bare address stands
+// for the whole address.
+func parseSubnet(s string) (net.IP, uint8, error) {
+	if ip, ipnet, err := net.ParseCIDR(s); err == nil {
+		ones, _ := ipnet.Mask.Size()
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		return ip, uint8(ones), nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, 0, fmt.Errorf("invalid edns_client_subnet %q", s)
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return ip, uint8(len(ip) * 8), nil
+}
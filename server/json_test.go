@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lsongdev/dns-go/client"
+	"github.com/lsongdev/dns-go/packet"
+)
+
+// queryHandler records the query it was given and answers with zoneHandler.
+type queryHandler struct {
+	query *packet.DNSPacket
+}
+
+func (q *queryHandler) HandleQuery(conn *PackConn) {
+	q.query = conn.Request
+	zoneHandler{}.HandleQuery(conn)
+}
+
+func TestJSONHandler(t *testing.T) {
+	cases := []struct {
+		name   string
+		url    string
+		status int
+		check  func(t *testing.T, q *packet.DNSPacket, res *packet.DNSJSONResponse)
+	}{
+		{"default type", "/resolve?name=example.com.", http.StatusOK, func(t *testing.T, q *packet.DNSPacket, res *packet.DNSJSONResponse) {
+			if qq := q.Questions[0]; qq.Name != "example.com" || qq.Type != packet.DNSTypeA || q.Header.RD != 1 || q.EDNS() != nil {
+				t.Errorf("unexpected query %s", q)
+			}
+			if res.Status != packet.DNSRCodeNoError || len(res.Answer) != 2 || res.Answer[1].Data != "192.0.2.2" || res.Answer[1].TTL != 60 {
+				t.Errorf("unexpected answer %+v", res)
+			}
+		}},
+		{"flags and subnet", "/resolve?name=example.com&type=aaaa&cd=1&do=true&edns_client_subnet=198.51.100.7/24", http.StatusOK, func(t *testing.T, q *packet.DNSPacket, res *packet.DNSJSONResponse) {
+			opt := q.EDNS()
+			if q.Questions[0].Type != packet.DNSTypeAAAA || !q.Header.CheckingDisabled() || opt == nil || !opt.GetDNSSECOK() {
+				t.Fatalf("unexpected query %s", q)
+			}
+			if ecs, err := opt.ClientSubnet(); err != nil || ecs == nil || ecs.SourcePrefix != 24 {
+				t.Errorf("client subnet: %+v %v", ecs, err)
+			}
+			if res.EDNSClientSubnet != "198.51.100.0/24" {
+				t.Errorf("edns_client_subnet %q", res.EDNSClientSubnet)
+			}
+		}},
+		{"numeric type", "/resolve?name=example.com&type=28", http.StatusOK, func(t *testing.T, q *packet.DNSPacket, res *packet.DNSJSONResponse) {
+			if q.Questions[0].Type != packet.DNSTypeAAAA || res.Question[0].Type != packet.DNSTypeAAAA {
+				t.Errorf("unexpected query %s", q)
+			}
+		}},
+		{"negative", "/resolve?name=nope.com", http.StatusOK, func(t *testing.T, q *packet.DNSPacket, res *packet.DNSJSONResponse) {
+			if res.Status != packet.DNSRCodeNXDomain || len(res.Authority) != 1 || res.Authority[0].Name != "com." {
+				t.Errorf("unexpected answer %+v", res)
+			}
+		}},
+		{"no name", "/resolve?type=A", http.StatusBadRequest, nil},
+		{"long label", "/resolve?name=" + strings.Repeat("a", 64) + ".com", http.StatusBadRequest, nil},
+		{"empty label", "/resolve?name=a..com", http.StatusBadRequest, nil},
+		{"bad type", "/resolve?name=example.com&type=BOGUS", http.StatusBadRequest, nil},
+		{"bad flag", "/resolve?name=example.com&do=maybe", http.StatusBadRequest, nil},
+		{"bad subnet", "/resolve?name=example.com&edns_client_subnet=nowhere", http.StatusBadRequest, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			qh := &queryHandler{}
+			w := httptest.NewRecorder()
+			(&JSONHandler{Handler: qh}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.url, nil))
+			if w.Code != c.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, c.status, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/dns-json" {
+				t.Errorf("Content-Type %q", ct)
+			}
+			if c.status != http.StatusOK {
+				var e struct{ Error string }
+				if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil || e.Error == "" {
+					t.Errorf("error body %q: %v", w.Body, err)
+				}
+				if qh.query != nil {
+					t.Errorf("bad request reached the handler: %s", qh.query)
+				}
+				return
+			}
+			var res packet.DNSJSONResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			c.check(t, qh.query, &res)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	(&JSONHandler{Handler: zoneHandler{}}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/resolve?name=example.com", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET" {
+		t.Errorf("POST: status %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestServerJSONClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle(DefaultJSONPath, &JSONHandler{Handler: zoneHandler{}})
+	srv := &Server{}
+	addr, err := srv.ListenHTTP("127.0.0.1:0", mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(context.Background())
+	defer srv.Shutdown(context.Background())
+
+	c := client.NewHTTPClientJSON("http://" + addr.String() + DefaultJSONPath)
+	query := packet.NewPacket()
+	query.AddQuestionA("example.com")
+	res, err := c.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Header.ID != query.Header.ID || len(res.Answers) != 2 {
+		t.Fatalf("unexpected answer %s", res)
+	}
+	if a, ok := res.Answers[0].(*packet.DNSResourceRecordA); !ok || a.Name != "example.com" || a.Address != "192.0.2.1" || a.TTL != 300 {
+		t.Errorf("first answer %v", res.Answers[0])
+	}
+
+	query = packet.NewPacket()
+	query.AddQuestionA("nope.com")
+	if res, err = c.Query(query); err != nil {
+		t.Fatal(err)
+	}
+	if res.RCode() != packet.DNSRCodeNXDomain || len(res.Authorities) != 1 {
+		t.Errorf("unexpected answer %s", res)
+	}
+	if soa, ok := res.Authorities[0].(*packet.DNSResourceRecordSOA); !ok || soa.Minimum != 120 {
+		t.Errorf("authority %v", res.Authorities[0])
+	}
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed is returned by Serve once the server has been shut down.
+var ErrServerClosed = errors.New("server: closed")
+
+// Defaults for the Server fields left zero.
+const (
+	DefaultDrainTimeout   = 5 * time.Second
+	DefaultTCPIdleTimeout = 10 * time.Second
+	DefaultTCPReadTimeout = 5 * time.Second
+	DefaultMaxTCPQueries  = 16
+	DefaultMaxTCPConns    = 512
+)
+
+// refusedLogInterval is the least time between two logs of connections
+// refused over MaxTCPConns, so a flood of connections doesn't flood the
+// log as well; those in between are counted.
+var refusedLogInterval = time.Minute
+
+// Server owns a set of listeners, each with its own handler, and can stop
+// them gracefully. Listeners are bound by the Listen methods, which return
+// the address actually bound (so ":0" can be used in tests), and served
+// by Serve:
+//
+//	srv := &server.Server{}
+//	srv.ListenUDP(":53", h)
+//	srv.ListenTCP(":53", h)
+//	srv.Serve(ctx)
+//
+// Shutdown, or cancelling the context passed to Serve, stops accepting
+// new queries, waits for those in flight to be answered and then closes
+// every connection.
+type Server struct {
+	// DrainTimeout bounds the wait for in-flight queries when Serve's
+	// context is cancelled. Zero means DefaultDrainTimeout.
+	DrainTimeout time.Duration
+	// MaxUDPSize caps UDP responses, whatever the client advertises. It
+	// is raised to 512 or lowered to 65535 if out of that range; zero
+	// means packet.DefaultEDNSUDPSize.
+	MaxUDPSize int
+
+	// TCP and DoT connections are closed after TCPIdleTimeout without a
+	// query. TCPReadTimeout bounds reading the rest of a query once it has
+	// started to arrive, and writing each response. A connection has at
+	// most MaxTCPQueries queries handled at once, and at most MaxTCPConns
+	// connections are open; more are closed as soon as they are accepted.
+	TCPIdleTimeout time.Duration
+	TCPReadTimeout time.Duration
+	MaxTCPQueries  int
+	MaxTCPConns    int
+
+	mu        sync.Mutex
+	udp       []*udpListener
+	tcp       []*tcpListener
+	http      []*httpListener
+	conns     map[net.Conn]struct{} // open TCP and DoT connections
+	refused   int                   // connections refused over MaxTCPConns, not logged yet
+	refusedAt time.Time             // when refused connections were last logged
+	serving   bool
+	closing   chan struct{} // closed by Shutdown
+
+	// active counts the serve loops, open connections and UDP queries
+	// being handled. The loops are counted from the start, so the counter
+	// can't drop to zero while they may still add to it.
+	active sync.WaitGroup
+}
+
+type udpListener struct {
+	conn    net.PacketConn
+	handler DNSHandler
+}
+
+type tcpListener struct {
+	ln      net.Listener
+	handler DNSHandler
+}
+
+type httpListener struct {
+	ln  net.Listener
+	srv *http.Server
+}
+
+// ListenUDP binds a UDP socket at addr for handler to answer.
+func (s *Server) ListenUDP(addr string, handler DNSHandler) (net.Addr, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.udp = append(s.udp, &udpListener{conn: conn, handler: handler})
+	return conn.LocalAddr(), nil
+}
+
+// ListenTCP binds a TCP listener at addr for handler to answer.
+func (s *Server) ListenTCP(addr string, handler DNSHandler) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s.addTCP(ln, handler)
+	return ln.Addr(), nil
+}
+
+// ListenTLS binds a DNS over TLS (RFC 7858) listener at addr.
+func (s *Server) ListenTLS(addr string, config *tls.Config, handler DNSHandler) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s.addTCP(tls.NewListener(ln, config), handler)
+	return ln.Addr(), nil
+}
+
+func (s *Server) addTCP(ln net.Listener, handler DNSHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tcp = append(s.tcp, &tcpListener{ln: ln, handler: handler})
+}
+
+// ListenHTTP binds an HTTP listener at addr for handler, usually a
+// DoHHandler or a mux it is mounted in.
+func (s *Server) ListenHTTP(addr string, handler http.Handler) (net.Addr, error) {
+	return s.listenHTTP(addr, nil, handler)
+}
+
+// ListenHTTPS binds an HTTPS listener at addr for handler. HTTP/2 is
+// offered unless config.NextProtos says otherwise.
+func (s *Server) ListenHTTPS(addr string, config *tls.Config, handler http.Handler) (net.Addr, error) {
+	return s.listenHTTP(addr, config, handler)
+}
+
+func (s *Server) listenHTTP(addr string, config *tls.Config, handler http.Handler) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.http = append(s.http, &httpListener{ln: ln, srv: &http.Server{Handler: handler, TLSConfig: config}})
+	return ln.Addr(), nil
+}
+
+func (s *Server) init() {
+	if s.closing == nil {
+		s.closing = make(chan struct{})
+		s.conns = make(map[net.Conn]struct{})
+	}
+}
+
+// Serve answers queries on every bound listener until Shutdown is called
+// or ctx is done, in which case Serve shuts the server down itself and
+// returns once it has drained. Serve always returns a non-nil error:
+// ErrServerClosed after a shutdown, or the error that stopped a listener.
+func (s *Server) Serve(ctx context.Context) error {
+	s.mu.Lock()
+	s.init()
+	if s.serving {
+		s.mu.Unlock()
+		return errors.New("server: already serving")
+	}
+	select {
+	case <-s.closing:
+		s.mu.Unlock()
+		return ErrServerClosed
+	default:
+	}
+	if len(s.udp)+len(s.tcp)+len(s.http) == 0 {
+		s.mu.Unlock()
+		return errors.New("server: no listeners")
+	}
+	s.serving = true
+	s.active.Add(len(s.udp) + len(s.tcp))
+	for _, l := range s.udp {
+		go s.serveUDP(l)
+	}
+	for _, l := range s.tcp {
+		go s.serveTCP(l)
+	}
+	errc := make(chan error, len(s.http))
+	for _, l := range s.http {
+		go func(l *httpListener) {
+			var err error
+			if l.srv.TLSConfig != nil {
+				// certificates come from TLSConfig; ServeTLS sets up HTTP/2
+				err = l.srv.ServeTLS(l.ln, "", "")
+			} else {
+				err = l.srv.Serve(l.ln)
+			}
+			if err != http.ErrServerClosed {
+				errc <- err
+			}
+		}(l)
+	}
+	closing := s.closing
+	s.mu.Unlock()
+
+	select {
+	case <-closing:
+		return ErrServerClosed
+	case err := <-errc:
+		s.drain()
+		return err
+	case <-ctx.Done():
+		if err := s.drain(); err != nil {
+			log.Printf("server: shutdown: %v", err)
+		}
+		return ErrServerClosed
+	}
+}
+
+// drain shuts the server down, waiting at most s.DrainTimeout.
+func (s *Server) drain() error {
+	timeout := s.DrainTimeout
+	if timeout == 0 {
+		timeout = DefaultDrainTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// Shutdown stops the server from accepting new queries, waits for the
+// ones in flight to be answered and closes every listener and
+// connection. If ctx is done first, the remaining connections are closed
+// straight away and ctx's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.init()
+	select {
+	case <-s.closing:
+	default:
+		close(s.closing)
+	}
+	// Wake the serve loops: they see s.closing and return. UDP sockets
+	// stay open until the queries read from them have been answered.
+	now := time.Now()
+	for _, l := range s.udp {
+		l.conn.SetReadDeadline(now)
+	}
+	for _, l := range s.tcp {
+		l.ln.Close()
+	}
+	for conn := range s.conns {
+		conn.SetReadDeadline(now)
+	}
+	https := s.http
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		for _, l := range https {
+			l.srv.Shutdown(ctx)
+		}
+		s.active.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range s.udp {
+		l.conn.Close()
+	}
+	for _, l := range s.http {
+		l.srv.Close()
+		l.ln.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
+
+func (s *Server) shuttingDown() bool {
+	select {
+	case <-s.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Server) serveUDP(l *udpListener) {
+	defer s.active.Done()
+	buf := make([]byte, 65535)
+	for {
+		n, remote, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if s.shuttingDown() {
+				return
+			}
+			log.Printf("Error reading packet: %v", err)
+			continue
+		}
+		req, ok := decodeUDP(buf[:n])
+		if !ok {
+			replyFormErr(&UdpWritter{l.conn, remote}, buf[:n])
+			continue
+		}
+		pc := newPackConn(&UdpWritter{l.conn, remote}, remote.String(), req, buf[:n])
+		pc.MaxSize = udpSize(req, s.MaxUDPSize)
+		s.active.Add(1)
+		go func() {
+			defer s.active.Done()
+			l.handler.HandleQuery(pc)
+		}()
+	}
+}
+
+func (s *Server) serveTCP(l *tcpListener) {
+	defer s.active.Done()
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if s.shuttingDown() {
+				return
+			}
+			log.Printf("Error accepting connection: %v", err)
+			// don't spin on a persistent error such as EMFILE
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		if !s.track(conn) {
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer s.untrack(conn)
+			s.serveConn(conn, l.handler)
+		}()
+	}
+}
+
+// track registers a new connection, unless the server is shutting down
+// or already has s.MaxTCPConns open.
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shuttingDown() {
+		return false
+	}
+	max := s.MaxTCPConns
+	if max <= 0 {
+		max = DefaultMaxTCPConns
+	}
+	if len(s.conns) >= max {
+		s.refused++
+		if now := time.Now(); now.Sub(s.refusedAt) >= refusedLogInterval {
+			log.Printf("[%s] refusing connection: %d already open (%d refused since the last message)", conn.RemoteAddr(), len(s.conns), s.refused)
+			s.refused, s.refusedAt = 0, now
+		}
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.active.Add(1)
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.active.Done()
+}
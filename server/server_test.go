@@ -0,0 +1,558 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lsongdev/dns-go/packet"
+)
+
+// gateHandler holds every query until release is closed.
+type gateHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newGateHandler() *gateHandler {
+	return &gateHandler{started: make(chan struct{}, 8), release: make(chan struct{})}
+}
+
+func (g *gateHandler) HandleQuery(conn *PackConn) {
+	g.started <- struct{}{}
+	<-g.release
+	res := &packet.DNSPacket{Header: &packet.DNSHeader{ID: conn.Request.Header.ID}, Questions: conn.Request.Questions}
+	conn.WriteResponse(res)
+}
+
+func (g *gateHandler) wait(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-g.started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d queries reached the handler", i, n)
+		}
+	}
+}
+
+func testQuery(id uint16) []byte {
+	query := packet.NewPacket()
+	query.Header.ID = id
+	query.AddQuestionA("example.com")
+	return query.Bytes()
+}
+
+func sendTCP(t *testing.T, addr net.Addr, id uint16) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := testQuery(id)
+	msg := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(msg, uint16(len(data)))
+	copy(msg[2:], data)
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func readTCP(t *testing.T, conn net.Conn) *packet.DNSPacket {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		t.Fatalf("read length: %v", err)
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	res, err := packet.FromBytes(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestServerShutdownDrains(t *testing.T) {
+	h := newGateHandler()
+	srv := &Server{}
+	udpAddr, err := srv.ListenUDP("127.0.0.1:0", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcpAddr, err := srv.ListenTCP("127.0.0.1:0", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tcpAddr.(*net.TCPAddr).Port == 0 || udpAddr.(*net.UDPAddr).Port == 0 {
+		t.Fatalf("bound addresses should carry the real port: %v %v", udpAddr, tcpAddr)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(context.Background()) }()
+
+	tcp := sendTCP(t, tcpAddr, 1)
+	defer tcp.Close()
+	udp, err := net.Dial("udp", udpAddr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	if _, err := udp.Write(testQuery(2)); err != nil {
+		t.Fatal(err)
+	}
+	h.wait(t, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shut := make(chan error, 1)
+	go func() { shut <- srv.Shutdown(ctx) }()
+
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve returned %v, want ErrServerClosed", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", tcpAddr.String())
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("still accepting connections while shutting down")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-shut:
+		t.Fatalf("Shutdown returned %v with queries in flight", err)
+	default:
+	}
+
+	close(h.release)
+	if res := readTCP(t, tcp); res.Header.ID != 1 {
+		t.Errorf("TCP answer has ID %d", res.Header.ID)
+	}
+	udp.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 512)
+	n, err := udp.Read(buf)
+	if err != nil {
+		t.Fatalf("UDP answer: %v", err)
+	}
+	if res, err := packet.FromBytes(buf[:n]); err != nil || res.Header.ID != 2 {
+		t.Errorf("UDP answer: %v %v", res, err)
+	}
+	if err := <-shut; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if _, err := tcp.Read(buf); err == nil {
+		t.Error("connection should be closed after shutdown")
+	}
+}
+
+func TestServerShutdownDeadline(t *testing.T) {
+	h := newGateHandler()
+	defer close(h.release)
+	srv := &Server{}
+	addr, err := srv.ListenTCP("127.0.0.1:0", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(context.Background())
+
+	tcp := sendTCP(t, addr, 1)
+	defer tcp.Close()
+	h.wait(t, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown returned %v, want a deadline error", err)
+	}
+	tcp.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := tcp.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("connection should have been closed, got %v", err)
+	}
+}
+
+func TestServerServeContext(t *testing.T) {
+	srv := &Server{}
+	udpAddr, err := srv.ListenUDP("127.0.0.1:0", &echoHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpAddr, err := srv.ListenHTTP("127.0.0.1:0", &DoHHandler{Handler: &echoHandler{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx) }()
+
+	udp, err := net.Dial("udp", udpAddr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	udp.Write(testQuery(7))
+	udp.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := udp.Read(make([]byte, 512)); err != nil {
+		t.Fatalf("no answer before cancel: %v", err)
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return after its context was cancelled")
+	}
+	if conn, err := net.Dial("tcp", httpAddr.String()); err == nil {
+		conn.Close()
+		t.Error("HTTP listener should be closed")
+	}
+}
+
+// bigHandler answers with 100 A records, more than fit in 512 bytes.
+type bigHandler struct{}
+
+func (bigHandler) HandleQuery(conn *PackConn) {
+	res := &packet.DNSPacket{Header: &packet.DNSHeader{ID: conn.Request.Header.ID}, Questions: conn.Request.Questions}
+	for i := 0; i < 100; i++ {
+		res.AddAnswer(&packet.DNSResourceRecordA{
+			DNSResourceRecord: packet.DNSResourceRecord{Name: "example.com", Type: packet.DNSTypeA, Class: packet.DNSClassIN, TTL: 60},
+			Address:           fmt.Sprintf("192.0.2.%d", i),
+		})
+	}
+	if conn.Request.EDNS() != nil {
+		res.AddAdditionalEDNS(packet.DefaultEDNSUDPSize, 0, 0, false)
+	}
+	conn.WriteResponse(res)
+}
+
+func TestServerTruncatesUDP(t *testing.T) {
+	srv := &Server{MaxUDPSize: 1000}
+	addr, err := srv.ListenUDP("127.0.0.1:0", bigHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(context.Background())
+	defer srv.Shutdown(context.Background())
+
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	exchange := func(query *packet.DNSPacket) (*packet.DNSPacket, int) {
+		t.Helper()
+		conn.Write(query.Bytes())
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		buf := make([]byte, 65535)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := packet.FromBytes(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, n
+	}
+
+	query := packet.NewPacket()
+	query.AddQuestionA("example.com")
+	res, n := exchange(query)
+	if n > 512 || res.Header.TC != 1 || len(res.Answers) != 0 {
+		t.Errorf("without OPT: %d bytes, TC=%d, %d answers", n, res.Header.TC, len(res.Answers))
+	}
+
+	// 4096 advertised, capped by the server at 1000
+	query = packet.NewPacket()
+	query.AddQuestionA("example.com")
+	query.AddAdditionalEDNS(4096, 0, 0, false)
+	res, n = exchange(query)
+	if n > 1000 || res.Header.TC != 1 || res.EDNS() == nil {
+		t.Errorf("with OPT: %d bytes, TC=%d, OPT %v", n, res.Header.TC, res.EDNS())
+	}
+}
+
+func TestWriteResponseTruncatesSigned(t *testing.T) {
+	h, _ := tsigHandler(true)
+	h.Handler = bigHandler{}
+	data, mac := signedQuery(t, testKey)
+	req, err := packet.FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	conn := newPackConn(&buf, "test", req, data)
+	conn.MaxSize = udpSize(req, 0)
+	h.HandleQuery(conn)
+
+	if buf.Len() > 512 {
+		t.Errorf("signed response is %d bytes", buf.Len())
+	}
+	if _, err := packet.VerifyTSIG(buf.Bytes(), h.Keyring, mac); err != nil {
+		t.Fatalf("truncated response signature: %v", err)
+	}
+	res, _ := packet.FromBytes(buf.Bytes())
+	if res.Header.TC != 1 || res.TSIG() == nil {
+		t.Errorf("expected a signed TC response, got %v", res)
+	}
+}
+
+// holdHandler holds queries with ID 1 until release is closed and answers
+// the others straight away.
+type holdHandler struct {
+	release chan struct{}
+}
+
+func (h *holdHandler) HandleQuery(conn *PackConn) {
+	if conn.Request.Header.ID == 1 {
+		<-h.release
+	}
+	conn.WriteResponse(&packet.DNSPacket{Header: &packet.DNSHeader{ID: conn.Request.Header.ID}, Questions: conn.Request.Questions})
+}
+
+func writeTCP(t *testing.T, conn net.Conn, id uint16) {
+	t.Helper()
+	data := testQuery(id)
+	msg := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(msg, uint16(len(data)))
+	copy(msg[2:], data)
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerTCPPipelining(t *testing.T) {
+	h := &holdHandler{release: make(chan struct{})}
+	srv := &Server{}
+	addr, err := srv.ListenTCP("127.0.0.1:0", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(context.Background())
+	defer srv.Shutdown(context.Background())
+
+	conn := sendTCP(t, addr, 1)
+	defer conn.Close()
+	writeTCP(t, conn, 2)
+	writeTCP(t, conn, 3)
+	// 2 and 3 may come in either order, but both ahead of 1
+	for i := 0; i < 2; i++ {
+		if res := readTCP(t, conn); res.Header.ID == 1 {
+			close(h.release)
+			t.Fatal("the held query was answered first")
+		}
+	}
+	close(h.release)
+	if res := readTCP(t, conn); res.Header.ID != 1 {
+		t.Errorf("got answer %d, want 1", res.Header.ID)
+	}
+}
+
+func TestServerTCPQueryLimit(t *testing.T) {
+	h := newGateHandler()
+	srv := &Server{MaxTCPQueries: 2}
+	addr, err := srv.ListenTCP("127.0.0.1:0", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(context.Background())
+	defer srv.Shutdown(context.Background())
+
+	conn := sendTCP(t, addr, 1)
+	defer conn.Close()
+	writeTCP(t, conn, 2)
+	writeTCP(t, conn, 3)
+	h.wait(t, 2)
+	select {
+	case <-h.started:
+		t.Fatal("a third query started while two were in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(h.release)
+	h.wait(t, 1)
+	for i := 0; i < 3; i++ {
+		readTCP(t, conn)
+	}
+}
+
+func TestServerTCPIdleTimeout(t *testing.T) {
+	srv := &Server{TCPIdleTimeout: 50 * time.Millisecond}
+	addr, err := srv.ListenTCP("127.0.0.1:0", &echoHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(context.Background())
+	defer srv.Shutdown(context.Background())
+
+	conn := sendTCP(t, addr, 1)
+	defer conn.Close()
+	readTCP(t, conn)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("idle connection should be closed, got %v", err)
+	}
+}
+
+func TestServerMaxTCPConns(t *testing.T) {
+	srv := &Server{MaxTCPConns: 1}
+	addr, err := srv.ListenTCP("127.0.0.1:0", &echoHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(context.Background())
+	defer srv.Shutdown(context.Background())
+
+	first := sendTCP(t, addr, 1)
+	defer first.Close()
+	readTCP(t, first)
+
+	second := sendTCP(t, addr, 2)
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("connection over the limit should be closed, got %v", err)
+	}
+	// the first refusal is logged, the next ones only counted
+	third := sendTCP(t, addr, 3)
+	defer third.Close()
+	third.SetReadDeadline(time.Now().Add(5 * time.Second))
+	third.Read(make([]byte, 1))
+	srv.mu.Lock()
+	refused := srv.refused
+	srv.mu.Unlock()
+	if refused != 1 {
+		t.Errorf("%d refusals waiting to be logged, want 1", refused)
+	}
+	writeTCP(t, first, 3)
+	if res := readTCP(t, first); res.Header.ID != 3 {
+		t.Errorf("first connection should still be served, got %d", res.Header.ID)
+	}
+}
+
+func TestServerFormErr(t *testing.T) {
+	h := &holdHandler{}
+	srv := &Server{}
+	udpAddr, err := srv.ListenUDP("127.0.0.1:0", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcpAddr, err := srv.ListenTCP("127.0.0.1:0", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(context.Background())
+	defer srv.Shutdown(context.Background())
+
+	// the header is intact but the question is cut short
+	query := packet.NewPacket()
+	query.Header.ID = 7
+	query.Header.OpCode = uint8(packet.DNSOpCodeNotify)
+	query.AddQuestionSOA("example.com")
+	malformed := query.Bytes()
+	malformed = malformed[:len(malformed)-3]
+	check := func(t *testing.T, res *packet.DNSPacket) {
+		t.Helper()
+		h := res.Header
+		if h.ID != 7 || h.QR != packet.DNSResponse || packet.DNSOpCode(h.OpCode) != packet.DNSOpCodeNotify ||
+			h.RA != 0 || res.RCode() != packet.DNSRCodeFormErr || len(res.Questions) != 0 {
+			t.Errorf("unexpected answer %s", res)
+		}
+	}
+
+	t.Run("udp", func(t *testing.T) {
+		conn, err := net.Dial("udp", udpAddr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		buf := make([]byte, 512)
+		conn.Write(malformed)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := packet.FromBytes(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, res)
+
+		// no header, or a response: dropped
+		conn.Write(malformed[:10])
+		resp := append([]byte(nil), malformed...)
+		resp[2] |= 0x80
+		conn.Write(resp)
+		conn.Write(testQuery(8))
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if n, err = conn.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+		if res, err := packet.FromBytes(buf[:n]); err != nil || res.Header.ID != 8 {
+			t.Errorf("got %v %v, want the answer to 8", res, err)
+		}
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		conn, err := net.Dial("tcp", tcpAddr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		msg := make([]byte, 2+len(malformed))
+		binary.BigEndian.PutUint16(msg, uint16(len(malformed)))
+		copy(msg[2:], malformed)
+		if _, err := conn.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+		check(t, readTCP(t, conn))
+		// the connection stays usable
+		writeTCP(t, conn, 9)
+		if res := readTCP(t, conn); res.Header.ID != 9 {
+			t.Errorf("got answer %d, want 9", res.Header.ID)
+		}
+	})
+}
+
+func TestUDPSize(t *testing.T) {
+	withOPT := func(size uint16) *packet.DNSPacket {
+		p := packet.NewPacket()
+		p.AddAdditionalEDNS(size, 0, 0, false)
+		return p
+	}
+	cases := []struct {
+		req  *packet.DNSPacket
+		max  int
+		want int
+	}{
+		{packet.NewPacket(), 0, 512},
+		{withOPT(4096), 0, int(packet.DefaultEDNSUDPSize)},
+		{withOPT(4096), 1000, 1000},
+		{withOPT(100), 1000, 512},
+		{withOPT(4096), 100, 512},
+		{withOPT(4096), -1, 512},
+		{withOPT(65535), 100000, 65535},
+	}
+	for _, c := range cases {
+		if got := udpSize(c.req, c.max); got != c.want {
+			t.Errorf("udpSize(%d advertised, max %d) = %d, want %d", c.req.UDPSize(), c.max, got, c.want)
+		}
+	}
+}
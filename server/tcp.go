@@ -1,24 +1,29 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/binary"
+	"errors"
 	"io"
 	"log"
 	"net"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/lsongdev/dns-go/packet"
 )
 
-// ListenTCP starts a DNS server over TCP at the given address.
+// ListenTCP serves DNS over TCP at addr until the process exits. Use
+// Server to be able to stop it.
 func ListenTCP(addr string, handler DNSHandler) error {
-	ln, err := net.Listen("tcp", addr)
-	if err != nil {
+	s := &Server{}
+	if _, err := s.ListenTCP(addr, handler); err != nil {
 		return err
 	}
-	defer ln.Close()
 	log.Printf("TCP server listening on %s", addr)
-	return serveTCP(ln, handler)
+	return s.Serve(context.Background())
 }
 
 // ListenDoT starts a DNS over TLS (RFC 7858) server at the given address.
@@ -42,40 +47,72 @@ func ListenTLS(addr string, certFile, keyFile string, handler DNSHandler) error
 // ListenDoTWithTLS starts a DNS over TLS server with custom TLS config.
 // This allows more control over TLS settings (e.g., custom certificates, client auth).
 func ListenTLSWithConfig(addr string, tlsConfig *tls.Config, handler DNSHandler) error {
-	ln, err := net.Listen("tcp", addr)
-	if err != nil {
+	s := &Server{}
+	if _, err := s.ListenTLS(addr, tlsConfig, handler); err != nil {
 		return err
 	}
-	defer ln.Close()
-
-	tlsLn := tls.NewListener(ln, tlsConfig)
-	defer tlsLn.Close()
-
 	log.Printf("DoT server listening on %s", addr)
-	return serveTCP(tlsLn, handler)
+	return s.Serve(context.Background())
 }
 
-// serveTCP handles DNS messages over a TCP listener (plain TCP or TLS).
-func serveTCP(ln net.Listener, h DNSHandler) error {
-	for {
-		conn, err := ln.Accept()
-		if err != nil {
-			log.Printf("Error accepting connection: %v", err)
-			continue
-		}
-		go handleTCPConn(conn, h)
+// tcpWriter frames every message written with its 2-byte length
+// (RFC 1035 §4.2.2). WriteResponse writes a message in one call, and the
+// queries of a connection are answered concurrently, so writes are
+// serialized; each one must finish within timeout.
+type tcpWriter struct {
+	conn    net.Conn
+	timeout time.Duration
+	mu      sync.Mutex
+}
+
+func (w *tcpWriter) Write(data []byte) (int, error) {
+	msg := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(msg, uint16(len(data)))
+	copy(msg[2:], data)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	if _, err := w.conn.Write(msg); err != nil {
+		return 0, err
 	}
+	return len(data), nil
 }
 
-func handleTCPConn(conn net.Conn, h DNSHandler) {
-	defer conn.Close()
+// serveConn reads the queries of a TCP or DoT connection and handles them
+// concurrently, up to s.MaxTCPQueries at a time, answering each as soon
+// as it is ready rather than in order (RFC 7766 §6.2.1.1). The connection
+// is closed once it has been idle for s.TCPIdleTimeout, or when a message
+// takes longer than s.TCPReadTimeout to arrive, after the queries already
+// read have been answered.
+func (s *Server) serveConn(conn net.Conn, h DNSHandler) {
+	idle := orDefault(s.TCPIdleTimeout, DefaultTCPIdleTimeout)
+	timeout := orDefault(s.TCPReadTimeout, DefaultTCPReadTimeout)
+	limit := s.MaxTCPQueries
+	if limit <= 0 {
+		limit = DefaultMaxTCPQueries
+	}
+	w := &tcpWriter{conn: conn, timeout: timeout}
+	inflight := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		conn.Close()
+	}()
 
 	for {
+		// Shutdown sets a deadline in the past to wake this read; check
+		// for it after setting ours so ours can't override it.
+		conn.SetReadDeadline(time.Now().Add(idle))
+		if s.shuttingDown() {
+			return
+		}
 		// Read 2-byte length prefix
 		lengthBuf := make([]byte, 2)
 		_, err := io.ReadFull(conn, lengthBuf)
 		if err != nil {
-			if err != io.EOF && err != io.ErrUnexpectedEOF {
+			// EOF: the client is done; a timeout: the connection was idle
+			// or the server is shutting down
+			if err != io.EOF && err != io.ErrUnexpectedEOF && !errors.Is(err, os.ErrDeadlineExceeded) {
 				log.Printf("Error reading length prefix from %s: %v", conn.RemoteAddr(), err)
 			}
 			return
@@ -83,6 +120,10 @@ func handleTCPConn(conn net.Conn, h DNSHandler) {
 		msgLen := binary.BigEndian.Uint16(lengthBuf)
 
 		// Read DNS message
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		if s.shuttingDown() {
+			return
+		}
 		buf := make([]byte, msgLen)
 		_, err = io.ReadFull(conn, buf)
 		if err != nil {
@@ -94,17 +135,30 @@ func handleTCPConn(conn net.Conn, h DNSHandler) {
 		req, err := packet.FromBytes(buf)
 		if err != nil {
 			log.Printf("Error decoding packet from %s: %v", conn.RemoteAddr(), err)
+			replyFormErr(w, buf)
 			continue
 		}
 
 		// Create connection wrapper
-		pc := &PackConn{
-			Writer:     conn,
-			RemoteAddr: conn.RemoteAddr().String(),
-			Request:    req,
-		}
+		pc := newPackConn(w, conn.RemoteAddr().String(), req, buf)
+		pc.Stream = true
+
+		// Handle query; at the limit, stop reading until one is answered
+		inflight <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-inflight
+				wg.Done()
+			}()
+			h.HandleQuery(pc)
+		}()
+	}
+}
 
-		// Handle query
-		h.HandleQuery(pc)
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
 	}
+	return d
 }
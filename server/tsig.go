@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/binary"
+	"log"
+	"time"
+
+	"github.com/lsongdev/dns-go/packet"
+)
+
+// TSIGHandler authenticates requests with TSIG (RFC 8945) before passing
+// them to Handler. A signed request is verified against Keyring, stripped
+// of its TSIG record and handed on; the response is then signed with the
+// same key. A request that fails verification is answered with NOTAUTH
+// and the TSIG error, and with Require set an unsigned request is
+// refused.
+type TSIGHandler struct {
+	Handler DNSHandler
+	Keyring packet.TSIGKeyring
+	Require bool
+}
+
+func (t *TSIGHandler) HandleQuery(conn *PackConn) {
+	req := conn.Request
+	if req == nil || req.Header == nil {
+		return
+	}
+	sig := req.TSIG()
+	if sig == nil {
+		if t.Require {
+			log.Printf("[%s] tsig: refusing unsigned request", conn.RemoteAddr)
+			res := tsigErrorResponse(req)
+			res.SetRCode(packet.DNSRCodeRefused)
+			t.write(conn, res)
+			return
+		}
+		t.Handler.HandleQuery(conn)
+		return
+	}
+
+	rr, err := packet.VerifyTSIG(conn.Raw, t.Keyring, nil)
+	if err != nil {
+		log.Printf("[%s] tsig: %v", conn.RemoteAddr, err)
+		t.reject(conn, sig, rr, err)
+		return
+	}
+	key, _ := t.Keyring.Lookup(rr.Name)
+	conn.TSIG = packet.NewTSIGSigner(key, rr.MAC)
+	req.Additionals = req.Additionals[:len(req.Additionals)-1]
+	t.Handler.HandleQuery(conn)
+}
+
+// reject answers a request whose signature didn't verify (RFC 8945
+// §5.2). sig is the request's TSIG record and rr the verified one, which
+// is only set for BADTIME: that reply is signed and carries the server's
+// clock, while BADKEY and BADSIG replies carry an unsigned TSIG record.
+func (t *TSIGHandler) reject(conn *PackConn, sig, rr *packet.DNSResourceRecordTSIG, err error) {
+	res := tsigErrorResponse(conn.Request)
+	code := packet.TSIGErrorCode(err)
+	switch {
+	case code == packet.DNSRCodeNoError:
+		res.SetRCode(packet.DNSRCodeFormErr)
+	case code == packet.DNSRCodeBadTime && rr != nil:
+		res.SetRCode(packet.DNSRCodeNotAuth)
+		key, _ := t.Keyring.Lookup(rr.Name)
+		signer := packet.NewTSIGSigner(key, rr.MAC)
+		signer.Error = code
+		signer.OtherData = make([]byte, 6)
+		now := uint64(time.Now().Unix())
+		binary.BigEndian.PutUint16(signer.OtherData, uint16(now>>32))
+		binary.BigEndian.PutUint32(signer.OtherData[2:], uint32(now))
+		conn.TSIG = signer
+	default:
+		res.SetRCode(packet.DNSRCodeNotAuth)
+		res.AddAdditional(&packet.DNSResourceRecordTSIG{
+			DNSResourceRecord: packet.DNSResourceRecord{Name: sig.Name, Type: packet.DNSTypeTSIG, Class: packet.DNSClassAny},
+			Algorithm:         sig.Algorithm,
+			TimeSigned:        uint64(time.Now().Unix()),
+			Fudge:             sig.Fudge,
+			OriginalID:        conn.Request.Header.ID,
+			Error:             code,
+		})
+	}
+	t.write(conn, res)
+}
+
+func (t *TSIGHandler) write(conn *PackConn, res *packet.DNSPacket) {
+	if err := conn.WriteResponse(res); err != nil {
+		log.Printf("[%s] write error: %v", conn.RemoteAddr, err)
+	}
+}
+
+// tsigErrorResponse starts an empty reply to req.
+func tsigErrorResponse(req *packet.DNSPacket) *packet.DNSPacket {
+	return &packet.DNSPacket{
+		Header: &packet.DNSHeader{
+			ID:     req.Header.ID,
+			OpCode: req.Header.OpCode,
+			RD:     req.Header.RD,
+		},
+		Questions: req.Questions,
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/lsongdev/dns-go/packet"
+)
+
+var testKey = packet.TSIGKey{
+	Name:      "xfr.example.",
+	Algorithm: packet.TSIGHMACSHA256,
+	Secret:    []byte("0123456789abcdef0123456789abcdef"),
+}
+
+// echoHandler answers every query with NOERROR and records what it saw.
+type echoHandler struct {
+	seen *packet.DNSPacket
+}
+
+func (e *echoHandler) HandleQuery(conn *PackConn) {
+	e.seen = conn.Request
+	res := &packet.DNSPacket{Header: &packet.DNSHeader{ID: conn.Request.Header.ID}, Questions: conn.Request.Questions}
+	conn.WriteResponse(res)
+}
+
+func tsigHandler(require bool) (*TSIGHandler, *echoHandler) {
+	echo := &echoHandler{}
+	keyring := packet.TSIGKeyring{}
+	keyring.Add(testKey)
+	return &TSIGHandler{Handler: echo, Keyring: keyring, Require: require}, echo
+}
+
+// serve runs data through h as a transport would and returns the raw reply.
+func serve(t *testing.T, h DNSHandler, data []byte) []byte {
+	t.Helper()
+	req, err := packet.FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	h.HandleQuery(newPackConn(&buf, "test", req, data))
+	if buf.Len() == 0 {
+		t.Fatal("no response written")
+	}
+	return buf.Bytes()
+}
+
+func signedQuery(t *testing.T, key packet.TSIGKey) ([]byte, []byte) {
+	t.Helper()
+	query := packet.NewPacket()
+	query.Header.ID = 0x5151
+	query.AddQuestionSOA("example.com")
+	rr, err := query.SignTSIG(key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return query.Bytes(), rr.MAC
+}
+
+func TestTSIGHandlerSignsResponse(t *testing.T) {
+	h, echo := tsigHandler(true)
+	data, mac := signedQuery(t, testKey)
+
+	reply := serve(t, h, data)
+	if echo.seen == nil || echo.seen.TSIG() != nil {
+		t.Fatal("handler should see the request without its TSIG record")
+	}
+	if _, err := packet.VerifyTSIG(reply, h.Keyring, mac); err != nil {
+		t.Fatalf("response signature: %v", err)
+	}
+}
+
+func TestTSIGHandlerRejects(t *testing.T) {
+	wrongKey := testKey
+	wrongKey.Secret = []byte("not the shared secret")
+	badSig, mac := signedQuery(t, wrongKey)
+
+	h, echo := tsigHandler(false)
+	reply := serve(t, h, badSig)
+	if echo.seen != nil {
+		t.Error("handler must not see a request with a bad signature")
+	}
+	res, err := packet.FromBytes(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RCode() != packet.DNSRCodeNotAuth {
+		t.Errorf("expected NOTAUTH, got %s", res.RCode())
+	}
+	if rr := res.TSIG(); rr == nil || rr.Error != packet.DNSRCodeBadSig || len(rr.MAC) != 0 {
+		t.Errorf("expected an unsigned BADSIG TSIG record, got %v", res.Additionals)
+	}
+	if _, err := packet.VerifyTSIG(reply, h.Keyring, mac); !errors.Is(err, packet.ErrTSIGBadSig) {
+		t.Errorf("client should see BADSIG, got %v", err)
+	}
+
+	unsigned := packet.NewPacket()
+	unsigned.AddQuestionA("example.com")
+	res, err = packet.FromBytes(serve(t, h, unsigned.Bytes()))
+	if err != nil || res.RCode() != packet.DNSRCodeNoError {
+		t.Errorf("unsigned request should pass when TSIG isn't required: %v %v", res, err)
+	}
+
+	h, echo = tsigHandler(true)
+	res, err = packet.FromBytes(serve(t, h, unsigned.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RCode() != packet.DNSRCodeRefused || echo.seen != nil {
+		t.Errorf("unsigned request should be refused, got %s", res.RCode())
+	}
+}
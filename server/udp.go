@@ -1,6 +1,8 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"io"
 	"log"
 	"net"
@@ -12,11 +14,49 @@ type PackConn struct {
 	io.Writer
 	RemoteAddr string
 	Request    *packet.DNSPacket
+	// Raw is the request as received. Transports keep it only for
+	// requests carrying a TSIG record, for TSIGHandler to verify.
+	Raw []byte
+	// TSIG, when set, signs every response written to the connection.
+	TSIG *packet.TSIGSigner
+	// MaxSize, when set, is the most a response may take; longer ones are
+	// truncated and flagged TC. UDP transports set it.
+	MaxSize int
+	// Stream is set by the transports that frame every message, TCP and
+	// TLS, over which one request may be answered with several messages,
+	// as a zone transfer is.
+	Stream bool
+}
+
+func newPackConn(w io.Writer, remoteAddr string, req *packet.DNSPacket, data []byte) *PackConn {
+	pc := &PackConn{Writer: w, RemoteAddr: remoteAddr, Request: req}
+	if req.TSIG() != nil {
+		pc.Raw = append([]byte(nil), data...)
+	}
+	return pc
 }
 
 func (p *PackConn) WriteResponse(res *packet.DNSPacket) error {
 	res.Header.QR = packet.DNSResponse
-	_, err := p.Write(res.Bytes())
+	if p.MaxSize > 0 {
+		size := p.MaxSize
+		if p.TSIG != nil {
+			// the truncated message is what gets signed (RFC 8945 §5.3)
+			size -= p.TSIG.Size()
+		}
+		res.Truncate(size)
+	}
+	if p.TSIG != nil {
+		if _, err := p.TSIG.Sign(res); err != nil {
+			return err
+		}
+	}
+	buf := packet.GetBuffer()
+	defer packet.PutBuffer(buf)
+	if err := res.PackTo(buf, true); err != nil {
+		return err
+	}
+	_, err := p.Write(buf.Bytes())
 	return err
 }
 
@@ -24,13 +64,36 @@ type DNSHandler interface {
 	HandleQuery(conn *PackConn)
 }
 
+// ListenUDP serves DNS over UDP at addr until the process exits. Use
+// Server to be able to stop it.
 func ListenUDP(addr string, handler DNSHandler) error {
-	conn, err := net.ListenPacket("udp", addr)
-	if err != nil {
+	s := &Server{}
+	if _, err := s.ListenUDP(addr, handler); err != nil {
 		return err
 	}
-	defer conn.Close()
-	return serveUDP(conn, handler)
+	return s.Serve(context.Background())
+}
+
+// udpSize returns the largest response to send to the client of req:
+// what it advertises, but never less than 512 (RFC 6891 §6.2.3, §6.2.5),
+// capped at max, which is itself kept within 512 to 65535.
+func udpSize(req *packet.DNSPacket, max int) int {
+	switch {
+	case max == 0:
+		max = int(packet.DefaultEDNSUDPSize)
+	case max < packet.MinUDPSize:
+		max = packet.MinUDPSize
+	case max > 65535:
+		max = 65535
+	}
+	size := req.UDPSize()
+	if size < packet.MinUDPSize {
+		size = packet.MinUDPSize
+	}
+	if size < max {
+		return size
+	}
+	return max
 }
 
 type UdpWritter struct {
@@ -42,35 +105,35 @@ func (w *UdpWritter) Write(data []byte) (int, error) {
 	return w.WriteTo(data, w.addr)
 }
 
-func serveUDP(conn net.PacketConn, h DNSHandler) error {
-	buf := make([]byte, 4096)
-	for {
-		n, remote, err := conn.ReadFrom(buf)
-		if err != nil {
-			log.Printf("Error reading packet: %v", err)
-			continue
-		}
-
-		// Copy off the shared read buffer before handing to a goroutine —
-		// FromBytes may keep slices into the input (name-compression pointers
-		// chase back through the original byte stream via reader.Seek), and
-		// the next ReadFrom will overwrite buf in place.
-		data := make([]byte, n)
-		copy(data, buf[:n])
+// decodeUDP decodes a datagram. Unpack keeps no references into data, so
+// the datagram can be decoded straight out of the shared read buffer
+// before the next ReadFrom overwrites it.
+func decodeUDP(data []byte) (*packet.DNSPacket, bool) {
+	req := &packet.DNSPacket{}
+	if err := req.Unpack(data); err != nil {
+		log.Printf("Error decoding packet: %v", err)
+		return nil, false
+	}
+	return req, true
+}
 
-		req, err := packet.FromBytes(data)
-		if err != nil {
-			log.Printf("Error decoding packet: %v", err)
-			continue
-		}
-		pc := &PackConn{
-			Writer: &UdpWritter{
-				conn,
-				remote,
-			},
-			RemoteAddr: remote.String(),
-			Request:    req,
-		}
-		go h.HandleQuery(pc)
+// replyFormErr answers data, a message whose body could not be decoded,
+// with FORMERR as pipeline's opcodeResponse would: the request's ID,
+// opcode, RD and CD, RA only for a QUERY, and no records (RFC 1035
+// §4.1.1). Responses, and messages too short to hold a header, get no
+// answer.
+func replyFormErr(w io.Writer, data []byte) {
+	h := &packet.DNSHeader{}
+	if err := h.Parse(bytes.NewReader(data)); err != nil || h.QR == packet.DNSResponse {
+		return
+	}
+	res := &packet.DNSPacket{Header: &packet.DNSHeader{ID: h.ID, OpCode: h.OpCode, RD: h.RD}}
+	if packet.DNSOpCode(h.OpCode) == packet.DNSOpCodeQuery {
+		res.Header.RA = 1
+	}
+	res.Header.SetCheckingDisabled(h.CheckingDisabled())
+	res.SetRCode(packet.DNSRCodeFormErr)
+	if err := (&PackConn{Writer: w}).WriteResponse(res); err != nil {
+		log.Printf("Error writing FORMERR: %v", err)
 	}
 }
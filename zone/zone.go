@@ -2,9 +2,7 @@ package zone
 
 import (
 	"fmt"
-	"net"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/lsongdev/dns-go/packet"
@@ -46,12 +44,40 @@ func tokenize(data string) []lineToken {
 	var lines []lineToken
 	current := ""
 	inParen := false
+	inQuote := false
 	lineno := 0
 
 	for i := 0; i < len(data); i++ {
 		ch := data[i]
 
+		// Inside a quoted string ';', '#' and parentheses are literal
+		// (DKIM and SPF values are full of semicolons); escapes are kept
+		// for splitFields and the record builders to interpret.
+		if inQuote && ch != '\n' {
+			if ch == '\\' && i+1 < len(data) {
+				current += data[i : i+2]
+				i++
+				continue
+			}
+			if ch == '"' {
+				inQuote = false
+			}
+			current += data[i : i+1]
+			continue
+		}
+		if ch == '"' {
+			inQuote = true
+			current += data[i : i+1]
+			continue
+		}
+		if ch == '\\' && i+1 < len(data) && data[i+1] != '\n' {
+			current += data[i : i+2]
+			i++
+			continue
+		}
+
 		if ch == '\n' {
+			inQuote = false
 			lineno++
 			if inParen {
 				current += " "
@@ -82,7 +108,7 @@ func tokenize(data string) []lineToken {
 			continue
 		}
 
-		current += string(ch)
+		current += data[i : i+1]
 	}
 
 	if trimmed := strings.TrimSpace(current); trimmed != "" && !isComment(trimmed) {
@@ -99,7 +125,7 @@ func isComment(s string) bool {
 func parseLines(z *Zone, lines []lineToken) error {
 	currentTTL := z.TTL
 	for _, line := range lines {
-		fields := splitFields(line.text)
+		fields := packet.SplitFields(line.text)
 		if len(fields) == 0 {
 			continue
 		}
@@ -107,12 +133,19 @@ func parseLines(z *Zone, lines []lineToken) error {
 		switch {
 		case strings.HasPrefix(fields[0], "$ORIGIN"):
 			if len(fields) >= 2 {
-				z.Origin = absDomain(fields[1])
+				origin, err := packet.IDNAToASCII(fields[1])
+				if err == nil {
+					err = packet.ValidateName(origin)
+				}
+				if err != nil {
+					return fmt.Errorf("line %d: bad $ORIGIN: %v", line.lineno, err)
+				}
+				z.Origin = absDomain(origin)
 			}
 			continue
 		case strings.HasPrefix(fields[0], "$TTL"):
 			if len(fields) >= 2 {
-				ttl, err := parseTTL(fields[1])
+				ttl, err := packet.ParseTTL(fields[1])
 				if err != nil {
 					return fmt.Errorf("line %d: bad $TTL: %v", line.lineno, err)
 				}
@@ -138,39 +171,6 @@ func parseLines(z *Zone, lines []lineToken) error {
 	return nil
 }
 
-
-func splitFields(s string) []string {
-	var fields []string
-	current := ""
-	inQuote := false
-
-	for i := 0; i < len(s); i++ {
-		ch := s[i]
-		if ch == '"' {
-			inQuote = !inQuote
-			current += string(ch)
-			continue
-		}
-		if ch == '\\' && i+1 < len(s) {
-			i++
-			current += string(s[i])
-			continue
-		}
-		if (ch == ' ' || ch == '\t') && !inQuote {
-			if current != "" {
-				fields = append(fields, current)
-				current = ""
-			}
-			continue
-		}
-		current += string(ch)
-	}
-	if current != "" {
-		fields = append(fields, current)
-	}
-	return fields
-}
-
 func parseRecordLine(fields []string, z *Zone, defaultTTL uint32, lineno int) (packet.DNSResource, uint32, error) {
 	if len(fields) < 2 {
 		return nil, 0, nil
@@ -184,13 +184,13 @@ func parseRecordLine(fields []string, z *Zone, defaultTTL uint32, lineno int) (p
 	ttl := defaultTTL
 	class := packet.DNSClassIN
 
-	if t, err := parseTTL(fields[idx]); err == nil {
+	if t, err := packet.ParseTTL(fields[idx]); err == nil {
 		ttl = t
 		idx++
 	}
 
-	if fields[idx] == "IN" || fields[idx] == "CH" || fields[idx] == "CS" || fields[idx] == "HS" {
-		class = classFromString(fields[idx])
+	if c, err := packet.ParseDNSClass(fields[idx]); err == nil && c != packet.DNSClassAny {
+		class = c
 		idx++
 	}
 
@@ -203,17 +203,62 @@ func parseRecordLine(fields []string, z *Zone, defaultTTL uint32, lineno int) (p
 
 	rdata := fields[idx:]
 
-	dname := resolveDomain(name, z.Origin)
+	dname, err := packet.IDNAToASCII(resolveDomain(name, z.Origin))
+	if err == nil {
+		err = packet.ValidateName(dname)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("line %d: %v", lineno, err)
+	}
 	rec, err := buildRecord(dname, rtype, class, ttl, rdata, lineno)
-	return rec, ttl, err
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := asciiTargets(rec); err != nil {
+		return nil, 0, fmt.Errorf("line %d: %v", lineno, err)
+	}
+	return rec, ttl, nil
+}
+
+// asciiTargets converts Unicode domain names in rec's RDATA to their
+// A-label form and validates them, as parseRecordLine does for owner names.
+func asciiTargets(rec packet.DNSResource) (err error) {
+	var names []*string
+	switch r := rec.(type) {
+	case *packet.DNSResourceRecordCNAME:
+		names = []*string{&r.Domain}
+	case *packet.DNSResourceRecordNS:
+		names = []*string{&r.NameServer}
+	case *packet.DNSResourceRecordPTR:
+		names = []*string{&r.PtrDomainName}
+	case *packet.DNSResourceRecordMX:
+		names = []*string{&r.Exchange}
+	case *packet.DNSResourceRecordSOA:
+		names = []*string{&r.MName, &r.RName}
+	case *packet.DNSResourceRecordSRV:
+		names = []*string{&r.Target}
+	case *packet.DNSResourceRecordSVCB:
+		names = []*string{&r.Target}
+	case *packet.DNSResourceRecordHTTPS:
+		names = []*string{&r.Target}
+	}
+	for _, name := range names {
+		if *name, err = packet.IDNAToASCII(*name); err != nil {
+			return err
+		}
+		if err := packet.ValidateName(*name); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func resolveDomain(name, origin string) string {
 	if name == "@" {
 		return origin
 	}
-	if strings.HasSuffix(name, ".") {
-		return strings.TrimSuffix(name, ".")
+	if packet.IsFQDN(name) {
+		return name[:len(name)-1]
 	}
 	if name == "" || name == "." {
 		return origin
@@ -222,7 +267,9 @@ func resolveDomain(name, origin string) string {
 }
 
 func absDomain(s string) string {
-	s = strings.TrimSuffix(s, ".")
+	if packet.IsFQDN(s) {
+		s = s[:len(s)-1]
+	}
 	if s == "" {
 		return "."
 	}
@@ -230,264 +277,14 @@ func absDomain(s string) string {
 }
 
 func buildRecord(name, rtype string, class packet.DNSClass, ttl uint32, rdata []string, lineno int) (packet.DNSResource, error) {
-	switch strings.ToUpper(rtype) {
-	case "A":
-		return buildA(name, class, ttl, rdata, lineno)
-	case "AAAA":
-		return buildAAAA(name, class, ttl, rdata, lineno)
-	case "CNAME":
-		return buildCNAME(name, class, ttl, rdata, lineno)
-	case "NS":
-		return buildNS(name, class, ttl, rdata, lineno)
-	case "MX":
-		return buildMX(name, class, ttl, rdata, lineno)
-	case "TXT":
-		return buildTXT(name, class, ttl, rdata, lineno)
-	case "PTR":
-		return buildPTR(name, class, ttl, rdata, lineno)
-	case "SOA":
-		return buildSOA(name, class, ttl, rdata, lineno)
-	case "SRV":
-		return buildSRV(name, class, ttl, rdata, lineno)
-	default:
-		return nil, fmt.Errorf("line %d: unsupported record type %q", lineno, rtype)
-	}
-}
-
-func classFromString(s string) packet.DNSClass {
-	switch s {
-	case "IN":
-		return packet.DNSClassIN
-	case "CH":
-		return packet.DNSClassCH
-	case "CS":
-		return packet.DNSClassCS
-	case "HS":
-		return packet.DNSClassHS
-	default:
-		return packet.DNSClassIN
-	}
-}
-
-func parseTTL(s string) (uint32, error) {
-	s = strings.ToUpper(strings.TrimSpace(s))
-	if s == "" {
-		return 0, fmt.Errorf("empty TTL")
-	}
-	if s[len(s)-1] == 'S' {
-		s = s[:len(s)-1]
-	}
-	multiplier := uint32(1)
-	switch {
-	case strings.HasSuffix(s, "W"):
-		multiplier = 604800
-		s = s[:len(s)-1]
-	case strings.HasSuffix(s, "D"):
-		multiplier = 86400
-		s = s[:len(s)-1]
-	case strings.HasSuffix(s, "H"):
-		multiplier = 3600
-		s = s[:len(s)-1]
-	case strings.HasSuffix(s, "M"):
-		multiplier = 60
-		s = s[:len(s)-1]
-	}
-	v, err := strconv.ParseUint(s, 10, 32)
-	if err != nil {
-		return 0, fmt.Errorf("bad TTL value %q: %v", s, err)
-	}
-	return uint32(v) * multiplier, nil
-}
-
-func buildA(name string, class packet.DNSClass, ttl uint32, rdata []string, lineno int) (packet.DNSResource, error) {
-	if len(rdata) < 1 {
-		return nil, fmt.Errorf("line %d: A record requires an IP address", lineno)
-	}
-	ip := net.ParseIP(rdata[0])
-	if ip == nil || ip.To4() == nil {
-		return nil, fmt.Errorf("line %d: invalid A record IP %q", lineno, rdata[0])
-	}
-	return &packet.DNSResourceRecordA{
-		DNSResourceRecord: packet.DNSResourceRecord{
-			Name:  name,
-			Type:  packet.DNSTypeA,
-			Class: class,
-			TTL:   ttl,
-		},
-		Address: rdata[0],
-	}, nil
-}
-
-func buildAAAA(name string, class packet.DNSClass, ttl uint32, rdata []string, lineno int) (packet.DNSResource, error) {
-	if len(rdata) < 1 {
-		return nil, fmt.Errorf("line %d: AAAA record requires an IPv6 address", lineno)
-	}
-	ip := net.ParseIP(rdata[0])
-	if ip == nil || ip.To16() == nil {
-		return nil, fmt.Errorf("line %d: invalid AAAA record IP %q", lineno, rdata[0])
-	}
-	return &packet.DNSResourceRecordAAAA{
-		DNSResourceRecord: packet.DNSResourceRecord{
-			Name:  name,
-			Type:  packet.DNSTypeAAAA,
-			Class: class,
-			TTL:   ttl,
-		},
-		Address: rdata[0],
-	}, nil
-}
-
-func buildCNAME(name string, class packet.DNSClass, ttl uint32, rdata []string, _ int) (packet.DNSResource, error) {
-	if len(rdata) < 1 {
-		return nil, fmt.Errorf("CNAME record requires a target domain")
-	}
-	return &packet.DNSResourceRecordCNAME{
-		DNSResourceRecord: packet.DNSResourceRecord{
-			Name:  name,
-			Type:  packet.DNSTypeCNAME,
-			Class: class,
-			TTL:   ttl,
-		},
-		Domain: rdata[0],
-	}, nil
-}
-
-func buildNS(name string, class packet.DNSClass, ttl uint32, rdata []string, _ int) (packet.DNSResource, error) {
-	if len(rdata) < 1 {
-		return nil, fmt.Errorf("NS record requires a nameserver domain")
-	}
-	return &packet.DNSResourceRecordNS{
-		DNSResourceRecord: packet.DNSResourceRecord{
-			Name:  name,
-			Type:  packet.DNSTypeNS,
-			Class: class,
-			TTL:   ttl,
-		},
-		NameServer: rdata[0],
-	}, nil
-}
-
-func buildMX(name string, class packet.DNSClass, ttl uint32, rdata []string, lineno int) (packet.DNSResource, error) {
-	if len(rdata) < 2 {
-		return nil, fmt.Errorf("line %d: MX record requires preference and exchange", lineno)
-	}
-	pref, err := strconv.ParseUint(rdata[0], 10, 16)
+	// RFC 3597 §5: TYPEnnn of a known type means that type
+	t, err := packet.ParseDNSType(rtype)
 	if err != nil {
-		return nil, fmt.Errorf("line %d: invalid MX preference %q: %v", lineno, rdata[0], err)
-	}
-	return &packet.DNSResourceRecordMX{
-		DNSResourceRecord: packet.DNSResourceRecord{
-			Name:  name,
-			Type:  packet.DNSTypeMX,
-			Class: class,
-			TTL:   ttl,
-		},
-		Preference: uint16(pref),
-		Exchange:   rdata[1],
-	}, nil
-}
-
-func buildTXT(name string, class packet.DNSClass, ttl uint32, rdata []string, _ int) (packet.DNSResource, error) {
-	if len(rdata) < 1 {
-		return nil, fmt.Errorf("TXT record requires text content")
-	}
-	content := strings.Join(rdata, " ")
-	if strings.HasPrefix(content, "\"") && strings.HasSuffix(content, "\"") {
-		content = content[1 : len(content)-1]
-	}
-	return &packet.DNSResourceRecordTXT{
-		DNSResourceRecord: packet.DNSResourceRecord{
-			Name:  name,
-			Type:  packet.DNSTypeTXT,
-			Class: class,
-			TTL:   ttl,
-		},
-		Content: content,
-	}, nil
-}
-
-func buildPTR(name string, class packet.DNSClass, ttl uint32, rdata []string, _ int) (packet.DNSResource, error) {
-	if len(rdata) < 1 {
-		return nil, fmt.Errorf("PTR record requires a target domain")
-	}
-	return &packet.DNSResourceRecordPTR{
-		DNSResourceRecord: packet.DNSResourceRecord{
-			Name:  name,
-			Type:  packet.DNSTypePTR,
-			Class: class,
-			TTL:   ttl,
-		},
-		PtrDomainName: rdata[0],
-	}, nil
-}
-
-func buildSOA(name string, class packet.DNSClass, ttl uint32, rdata []string, lineno int) (packet.DNSResource, error) {
-	if len(rdata) < 7 {
-		return nil, fmt.Errorf("line %d: SOA requires MNAME RNAME SERIAL REFRESH RETRY EXPIRE MINIMUM", lineno)
-	}
-	serial, err := strconv.ParseUint(rdata[2], 10, 32)
-	if err != nil {
-		return nil, fmt.Errorf("line %d: invalid SOA serial: %v", lineno, err)
-	}
-	refresh, err := strconv.ParseUint(rdata[3], 10, 32)
-	if err != nil {
-		return nil, fmt.Errorf("line %d: invalid SOA refresh: %v", lineno, err)
-	}
-	retry, err := strconv.ParseUint(rdata[4], 10, 32)
-	if err != nil {
-		return nil, fmt.Errorf("line %d: invalid SOA retry: %v", lineno, err)
-	}
-	expire, err := strconv.ParseUint(rdata[5], 10, 32)
-	if err != nil {
-		return nil, fmt.Errorf("line %d: invalid SOA expire: %v", lineno, err)
-	}
-	minimum, err := strconv.ParseUint(rdata[6], 10, 32)
-	if err != nil {
-		return nil, fmt.Errorf("line %d: invalid SOA minimum: %v", lineno, err)
-	}
-	return &packet.DNSResourceRecordSOA{
-		DNSResourceRecord: packet.DNSResourceRecord{
-			Name:  name,
-			Type:  packet.DNSTypeSOA,
-			Class: class,
-			TTL:   ttl,
-		},
-		MName:   rdata[0],
-		RName:   rdata[1],
-		Serial:  uint32(serial),
-		Refresh: uint32(refresh),
-		Retry:   uint32(retry),
-		Expire:  uint32(expire),
-		Minimum: uint32(minimum),
-	}, nil
-}
-
-func buildSRV(name string, class packet.DNSClass, ttl uint32, rdata []string, lineno int) (packet.DNSResource, error) {
-	if len(rdata) < 4 {
-		return nil, fmt.Errorf("line %d: SRV requires priority weight port target", lineno)
-	}
-	priority, err := strconv.ParseUint(rdata[0], 10, 16)
-	if err != nil {
-		return nil, fmt.Errorf("line %d: invalid SRV priority: %v", lineno, err)
-	}
-	weight, err := strconv.ParseUint(rdata[1], 10, 16)
-	if err != nil {
-		return nil, fmt.Errorf("line %d: invalid SRV weight: %v", lineno, err)
+		return nil, fmt.Errorf("line %d: unsupported record type %q", lineno, rtype)
 	}
-	port, err := strconv.ParseUint(rdata[2], 10, 16)
+	rec, err := packet.ParseRData(packet.DNSResourceRecord{Name: name, Type: t, Class: class, TTL: ttl}, rdata)
 	if err != nil {
-		return nil, fmt.Errorf("line %d: invalid SRV port: %v", lineno, err)
+		return nil, fmt.Errorf("line %d: %v", lineno, err)
 	}
-	return &packet.DNSResourceRecordSRV{
-		DNSResourceRecord: packet.DNSResourceRecord{
-			Name:  name,
-			Type:  packet.DNSTypeSRV,
-			Class: class,
-			TTL:   ttl,
-		},
-		Priority: uint16(priority),
-		Weight:   uint16(weight),
-		Port:     uint16(port),
-		Target:   rdata[3],
-	}, nil
+	return rec, nil
 }
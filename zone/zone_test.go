@@ -1,6 +1,9 @@
 package zone
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/lsongdev/dns-go/packet"
@@ -107,8 +110,59 @@ func TestParseTXT(t *testing.T) {
 		t.Fatalf("expected TXT record, got %T", z.Records[0])
 	}
 	expected := "v=spf1 include:_spf.example.com ~all"
-	if txt.Content != expected {
-		t.Errorf("expected %q, got %q", expected, txt.Content)
+	if len(txt.Text) != 1 || txt.Text[0] != expected {
+		t.Errorf("expected [%q], got %q", expected, txt.Text)
+	}
+}
+
+func TestParseTXTMultipleStrings(t *testing.T) {
+	data := []byte(`mail._domainkey.example.com. 3600 IN TXT ( "v=DKIM1; k=rsa; "
+	"p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQ" ) ; key split across strings
+example.com. 3600 IN TXT "say \"hi\"" plain \065\066 "semi;colon (paren)"
+`)
+	z, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(z.Records))
+	}
+	dkim := z.Records[0].(*packet.DNSResourceRecordTXT)
+	if len(dkim.Text) != 2 {
+		t.Fatalf("expected 2 strings, got %q", dkim.Text)
+	}
+	if got := dkim.Value(); got != "v=DKIM1; k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQ" {
+		t.Errorf("unexpected DKIM value %q", got)
+	}
+	txt := z.Records[1].(*packet.DNSResourceRecordTXT)
+	expected := []string{`say "hi"`, "plain", "AB", "semi;colon (paren)"}
+	if len(txt.Text) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, txt.Text)
+	}
+	for i := range expected {
+		if txt.Text[i] != expected[i] {
+			t.Errorf("string %d: expected %q, got %q", i, expected[i], txt.Text[i])
+		}
+	}
+}
+
+func TestParseSPF(t *testing.T) {
+	z, err := Parse([]byte("example.com. 3600 IN SPF \"v=spf1 -all\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spf, ok := z.Records[0].(*packet.DNSResourceRecordTXT)
+	if !ok {
+		t.Fatalf("expected TXT-shaped record, got %T", z.Records[0])
+	}
+	if spf.Type != packet.DNSTypeSPF || spf.Value() != "v=spf1 -all" {
+		t.Errorf("unexpected SPF record %+v", spf)
+	}
+}
+
+func TestParseTXTUnterminatedQuote(t *testing.T) {
+	if _, err := Parse([]byte("example.com. 3600 IN TXT \"oops\n")); err == nil {
+		t.Fatal("expected error for unterminated quoted string")
 	}
 }
 
@@ -240,6 +294,78 @@ func TestParseWithOrigin(t *testing.T) {
 	}
 }
 
+func TestParseUnicodeNames(t *testing.T) {
+	data := []byte(
+		"$ORIGIN 例子.中国.\n" +
+			"www 3600 IN CNAME 主页.例子.中国.\n" +
+			"主页 3600 IN TXT \"你好, 世界\"\n",
+	)
+	z, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if z.Origin != "xn--fsqu00a.xn--fiqs8s" {
+		t.Errorf("expected A-label origin, got %q", z.Origin)
+	}
+	cname, ok := z.Records[0].(*packet.DNSResourceRecordCNAME)
+	if !ok {
+		t.Fatalf("expected CNAME record, got %T", z.Records[0])
+	}
+	if cname.Name != "www.xn--fsqu00a.xn--fiqs8s" {
+		t.Errorf("unexpected owner %q", cname.Name)
+	}
+	if cname.Domain != "xn--tiqp32p.xn--fsqu00a.xn--fiqs8s." {
+		t.Errorf("unexpected target %q", cname.Domain)
+	}
+	txt, ok := z.Records[1].(*packet.DNSResourceRecordTXT)
+	if !ok {
+		t.Fatalf("expected TXT record, got %T", z.Records[1])
+	}
+	if txt.Name != "xn--tiqp32p.xn--fsqu00a.xn--fiqs8s" {
+		t.Errorf("unexpected owner %q", txt.Name)
+	}
+	if len(txt.Text) != 1 || txt.Text[0] != "你好, 世界" {
+		t.Errorf("unicode TXT mangled: %q", txt.Text)
+	}
+
+	if _, err := Parse([]byte("$ORIGIN -例子.cn.\n")); err == nil {
+		t.Error("expected error for invalid unicode origin")
+	}
+}
+
+func TestParseEscapedNames(t *testing.T) {
+	data := []byte(
+		"$ORIGIN example.com.\n" +
+			"a\\.b 3600 IN A 192.0.2.1\n" +
+			"c 3600 IN CNAME sp\\032ace.example.com\\.\n",
+	)
+	z, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := z.Records[0].GetHeader().Name; name != `a\.b.example.com` {
+		t.Errorf("unexpected owner %q", name)
+	}
+	cname, ok := z.Records[1].(*packet.DNSResourceRecordCNAME)
+	if !ok {
+		t.Fatalf("expected CNAME record, got %T", z.Records[1])
+	}
+	if cname.Domain != `sp\032ace.example.com\.` {
+		t.Errorf("unexpected target %q", cname.Domain)
+	}
+
+	long := strings.Repeat("a", 64)
+	for _, bad := range []string{
+		long + " 3600 IN A 192.0.2.1\n",
+		"www 3600 IN CNAME " + long + ".example.com.\n",
+		"$ORIGIN " + long + ".com.\n",
+	} {
+		if _, err := Parse([]byte("$ORIGIN example.com.\n" + bad)); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
 func TestParseWithTTLDirective(t *testing.T) {
 	data := []byte(
 		"$TTL 7200\n" +
@@ -356,3 +482,338 @@ func TestParseFileNotExist(t *testing.T) {
 		t.Fatal("expected error for nonexistent file")
 	}
 }
+
+func TestParseHTTPS(t *testing.T) {
+	data := []byte(`$ORIGIN example.com.
+@ 300 IN HTTPS 1 . alpn="h2,h3" port=8443 ipv4hint=192.0.2.1,192.0.2.2 ipv6hint=2001:db8::1 ech=AEX+DQ== mandatory=alpn,port key65000="x y"
+_dns 300 IN SVCB 0 svc.example.net.
+`)
+	z, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(z.Records))
+	}
+	https, ok := z.Records[0].(*packet.DNSResourceRecordHTTPS)
+	if !ok {
+		t.Fatalf("expected HTTPS record, got %T", z.Records[0])
+	}
+	if https.Type != packet.DNSTypeHTTPS || https.Priority != 1 || https.Target != "." {
+		t.Errorf("unexpected HTTPS header %+v", https.DNSResourceRecordSVCB)
+	}
+	p := https.Params
+	if len(p.ALPN) != 2 || p.ALPN[0] != "h2" || p.ALPN[1] != "h3" {
+		t.Errorf("unexpected alpn %q", p.ALPN)
+	}
+	if p.Port != 8443 {
+		t.Errorf("expected port 8443, got %d", p.Port)
+	}
+	if len(p.IPv4Hint) != 2 || p.IPv4Hint[1].String() != "192.0.2.2" {
+		t.Errorf("unexpected ipv4hint %v", p.IPv4Hint)
+	}
+	if len(p.IPv6Hint) != 1 || p.IPv6Hint[0].String() != "2001:db8::1" {
+		t.Errorf("unexpected ipv6hint %v", p.IPv6Hint)
+	}
+	if len(p.ECH) != 4 {
+		t.Errorf("unexpected ech %x", p.ECH)
+	}
+	if len(p.Mandatory) != 2 {
+		t.Errorf("unexpected mandatory %v", p.Mandatory)
+	}
+	if len(p.Other) != 1 || p.Other[0].Key != 65000 || string(p.Other[0].Value) != "x y" {
+		t.Errorf("unexpected other params %+v", p.Other)
+	}
+
+	svcb, ok := z.Records[1].(*packet.DNSResourceRecordSVCB)
+	if !ok {
+		t.Fatalf("expected SVCB record, got %T", z.Records[1])
+	}
+	if svcb.Priority != 0 || svcb.Target != "svc.example.net." {
+		t.Errorf("unexpected AliasMode SVCB %+v", svcb)
+	}
+}
+
+func TestParseHTTPSErrors(t *testing.T) {
+	tests := []string{
+		"example.com. 300 IN HTTPS 1 . port=99999\n",
+		"example.com. 300 IN HTTPS 1 . alpn=h2 alpn=h3\n",
+		"example.com. 300 IN HTTPS 1 . mandatory=port alpn=h2\n",
+		"example.com. 300 IN HTTPS 1 . ipv4hint=2001:db8::1\n",
+		"example.com. 300 IN HTTPS 1 . bogus=1\n",
+		"example.com. 300 IN HTTPS 1 . port\n",
+	}
+	for _, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParseCertificateRecords(t *testing.T) {
+	data := []byte(`$ORIGIN example.com.
+@ 300 IN CAA 0 issue "letsencrypt.org; validationmethods=dns-01"
+@ 300 IN CAA 128 iodef "mailto:security@example.com"
+_443._tcp 300 IN TLSA 3 1 1 ( 0123456789abcdef
+                              0123456789ABCDEF )
+host 300 IN SSHFP 4 2 0102030405
+@ 300 IN NAPTR 100 10 "U" "E2U+sip" "!^.*$!sip:info@example.com!" .
+`)
+	z, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z.Records) != 5 {
+		t.Fatalf("expected 5 records, got %d", len(z.Records))
+	}
+	caa, ok := z.Records[0].(*packet.DNSResourceRecordCAA)
+	if !ok {
+		t.Fatalf("expected CAA record, got %T", z.Records[0])
+	}
+	if caa.Flag != 0 || caa.Tag != "issue" || caa.Value != "letsencrypt.org; validationmethods=dns-01" {
+		t.Errorf("unexpected CAA %+v", caa)
+	}
+	if iodef := z.Records[1].(*packet.DNSResourceRecordCAA); iodef.Flag != packet.CAAFlagCritical {
+		t.Errorf("expected critical flag, got %d", iodef.Flag)
+	}
+	tlsa, ok := z.Records[2].(*packet.DNSResourceRecordTLSA)
+	if !ok {
+		t.Fatalf("expected TLSA record, got %T", z.Records[2])
+	}
+	if tlsa.Usage != 3 || tlsa.Selector != 1 || tlsa.MatchingType != 1 || len(tlsa.Certificate) != 16 {
+		t.Errorf("unexpected TLSA %+v", tlsa)
+	}
+	sshfp, ok := z.Records[3].(*packet.DNSResourceRecordSSHFP)
+	if !ok {
+		t.Fatalf("expected SSHFP record, got %T", z.Records[3])
+	}
+	if sshfp.Algorithm != 4 || sshfp.FPType != 2 || len(sshfp.Fingerprint) != 5 {
+		t.Errorf("unexpected SSHFP %+v", sshfp)
+	}
+	naptr, ok := z.Records[4].(*packet.DNSResourceRecordNAPTR)
+	if !ok {
+		t.Fatalf("expected NAPTR record, got %T", z.Records[4])
+	}
+	if naptr.Order != 100 || naptr.Preference != 10 || naptr.Flags != "U" || naptr.Services != "E2U+sip" ||
+		naptr.Regexp != "!^.*$!sip:info@example.com!" || naptr.Replacement != "." {
+		t.Errorf("unexpected NAPTR %+v", naptr)
+	}
+}
+
+func TestParseCertificateRecordErrors(t *testing.T) {
+	tests := []string{
+		"example.com. 300 IN CAA 256 issue \"ca.example\"\n",
+		"example.com. 300 IN CAA 0 is-sue \"ca.example\"\n",
+		"example.com. 300 IN TLSA 3 1 1 xyz\n",
+		"example.com. 300 IN SSHFP 4 2 abc\n",
+		"example.com. 300 IN NAPTR 100 10 \"U\" \"E2U+sip\" .\n",
+	}
+	for _, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParseDNSSECRecords(t *testing.T) {
+	data := []byte(`$ORIGIN example.com.
+dskey 86400 IN DNSKEY 256 3 5 ( AQOeiiR0GOMYkDshWoSKz9Xz
+                                fwJr1AYtsmx3TGkJaNXVbfi/2pHm822aJ5iI9BMzNXxeYCmZDRD99WYwYqUSdjMmmAphXdvxegXd/M5+X7OrzKBaMbCVdFLUUh6DhweJBjEVv5f2wwjM9XzcnOf+EPbtG9DMBmADjFDc2w/rljwvFw== )
+dskey 86400 IN DS 60485 5 1 ( 2BB183AF5F22588179A5
+                              3B0A98631FAD1A292118 )
+@ 86400 IN CDS 0 0 0 00
+@ 86400 IN CDNSKEY 0 3 0 AA==
+host 3600 IN RRSIG A 5 3 86400 20300101000000 ( 20200101000000 2642 example.com. oJB1W6WNGv+ldvQ3WDG0MQkg5IEhjRip8WTr
+                                              PYGv07h108dUKGMeDPKijVCHX3DDKdfb+v6o B9wfuh3DTJXUAfI= )
+host 3600 IN NSEC www.example.com. A MX RRSIG NSEC TYPE1234
+0p9mhaveqvm6t7vbl5lop2u3t2rp3tom 3600 IN NSEC3 1 1 12 aabbccdd ( 2t7b4g4vsa5smi47k61mv5bv1a22bojr NS SOA MX RRSIG DNSKEY NSEC3PARAM )
+@ 3600 IN NSEC3PARAM 1 0 0 -
+`)
+	z, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z.Records) != 8 {
+		t.Fatalf("expected 8 records, got %d", len(z.Records))
+	}
+	dnskey, ok := z.Records[0].(*packet.DNSResourceRecordDNSKEY)
+	if !ok {
+		t.Fatalf("expected DNSKEY record, got %T", z.Records[0])
+	}
+	if dnskey.Flags != 256 || dnskey.Protocol != 3 || dnskey.Algorithm != 5 {
+		t.Errorf("unexpected DNSKEY %+v", dnskey)
+	}
+	if tag := dnskey.KeyTag(); tag != 60485 {
+		t.Errorf("expected key tag 60485, got %d", tag)
+	}
+	ds, ok := z.Records[1].(*packet.DNSResourceRecordDS)
+	if !ok {
+		t.Fatalf("expected DS record, got %T", z.Records[1])
+	}
+	if ds.KeyTag != 60485 || ds.Algorithm != 5 || ds.DigestType != 1 || len(ds.Digest) != 20 {
+		t.Errorf("unexpected DS %+v", ds)
+	}
+	if cds := z.Records[2].(*packet.DNSResourceRecordDS); cds.Type != packet.DNSTypeCDS {
+		t.Errorf("expected CDS type, got %d", cds.Type)
+	}
+	if cdnskey := z.Records[3].(*packet.DNSResourceRecordDNSKEY); cdnskey.Type != packet.DNSTypeCDNSKEY {
+		t.Errorf("expected CDNSKEY type, got %d", cdnskey.Type)
+	}
+	rrsig, ok := z.Records[4].(*packet.DNSResourceRecordRRSIG)
+	if !ok {
+		t.Fatalf("expected RRSIG record, got %T", z.Records[4])
+	}
+	if rrsig.TypeCovered != packet.DNSTypeA || rrsig.Labels != 3 || rrsig.OriginalTTL != 86400 ||
+		rrsig.Expiration != 1893456000 || rrsig.Inception != 1577836800 || rrsig.KeyTag != 2642 ||
+		rrsig.SignerName != "example.com." || len(rrsig.Signature) == 0 {
+		t.Errorf("unexpected RRSIG %+v", rrsig)
+	}
+	nsec, ok := z.Records[5].(*packet.DNSResourceRecordNSEC)
+	if !ok {
+		t.Fatalf("expected NSEC record, got %T", z.Records[5])
+	}
+	wantTypes := []packet.DNSType{packet.DNSTypeA, packet.DNSTypeMX, packet.DNSTypeRRSIG, packet.DNSTypeNSEC, 1234}
+	if nsec.NextDomain != "www.example.com." || !reflect.DeepEqual(nsec.Types, wantTypes) {
+		t.Errorf("unexpected NSEC %+v", nsec)
+	}
+	nsec3, ok := z.Records[6].(*packet.DNSResourceRecordNSEC3)
+	if !ok {
+		t.Fatalf("expected NSEC3 record, got %T", z.Records[6])
+	}
+	if nsec3.Name != "0p9mhaveqvm6t7vbl5lop2u3t2rp3tom.example.com" || nsec3.Flags != 1 || nsec3.Iterations != 12 ||
+		len(nsec3.Salt) != 4 || len(nsec3.NextHashed) != 20 || len(nsec3.Types) != 6 {
+		t.Errorf("unexpected NSEC3 %+v", nsec3)
+	}
+	param, ok := z.Records[7].(*packet.DNSResourceRecordNSEC3PARAM)
+	if !ok {
+		t.Fatalf("expected NSEC3PARAM record, got %T", z.Records[7])
+	}
+	if param.HashAlgorithm != 1 || param.Salt != nil {
+		t.Errorf("unexpected NSEC3PARAM %+v", param)
+	}
+}
+
+func TestParseDNSSECRecordErrors(t *testing.T) {
+	tests := []string{
+		"example.com. 300 IN DNSKEY 256 3 8 not-base64!\n",
+		"example.com. 300 IN DS 70000 8 2 abcd\n",
+		"example.com. 300 IN RRSIG BOGUS 8 2 300 20300101000000 20200101000000 1 example.com. AA==\n",
+		"example.com. 300 IN RRSIG A 8 2 300 20301301000000 20200101000000 1 example.com. AA==\n",
+		"example.com. 300 IN NSEC next.example.com. A BOGUS\n",
+		"example.com. 300 IN NSEC3 1 0 0 - !!!! A\n",
+		"example.com. 300 IN NSEC3PARAM 1 0 0 xyz\n",
+	}
+	for _, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParseGenericRecords(t *testing.T) {
+	data := []byte(`$ORIGIN example.com.
+exp 300 IN TYPE65280 \# 4 0A000001
+exp 300 CLASS32 TYPE65281 \# 0
+raw 300 IN A \# 4 ( C0A8
+                    0101 )
+alias 300 IN TYPE1 192.0.2.1
+`)
+	z, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z.Records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(z.Records))
+	}
+	unknown, ok := z.Records[0].(*packet.DNSResourceRecordUnknown)
+	if !ok {
+		t.Fatalf("expected unknown record, got %T", z.Records[0])
+	}
+	if unknown.Type != 65280 || !reflect.DeepEqual(unknown.RData, []byte{0x0a, 0, 0, 1}) {
+		t.Errorf("unexpected record %+v", unknown)
+	}
+	if s := unknown.String(); s != "exp.example.com.\t300\tIN\tTYPE65280\t\\# 4 0a000001" {
+		t.Errorf("unexpected generic form %q", s)
+	}
+	empty := z.Records[1].(*packet.DNSResourceRecordUnknown)
+	if empty.Class != 32 || empty.Type != 65281 || len(empty.RData) != 0 {
+		t.Errorf("unexpected record %+v", empty)
+	}
+	if a, ok := z.Records[2].(*packet.DNSResourceRecordA); !ok || a.Address != "192.168.1.1" {
+		t.Errorf("expected A 192.168.1.1 from generic RDATA, got %+v", z.Records[2])
+	}
+	if a, ok := z.Records[3].(*packet.DNSResourceRecordA); !ok || a.Address != "192.0.2.1" {
+		t.Errorf("expected TYPE1 to parse as A, got %+v", z.Records[3])
+	}
+
+	// the printed form parses back to the same record
+	again, err := Parse([]byte(unknown.String() + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.Records[0], unknown) {
+		t.Errorf("round trip mismatch: %+v", again.Records[0])
+	}
+}
+
+func TestParseGenericRecordErrors(t *testing.T) {
+	tests := []string{
+		"example.com. 300 IN TYPE65280 \\# 5 0A000001\n",
+		"example.com. 300 IN TYPE65280 \\# 4 0A0000ZZ\n",
+		"example.com. 300 IN TYPE65280 \\#\n",
+		"example.com. 300 IN A \\# 3 0A0000\n",
+		"example.com. 300 IN TYPE99999 \\# 0\n",
+		"example.com. 300 IN TYPE65280 0A000001\n",
+	}
+	for _, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParsePresentationRoundTrip(t *testing.T) {
+	data := []byte(`$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. admin.example.com. 2024010101 7200 3600 1209600 300
+@ 3600 IN NS ns1.example.com.
+@ 3600 IN MX 10 mail.example.com.
+www 300 IN A 192.0.2.1
+www 300 IN AAAA 2001:db8::1
+alias 300 IN CNAME www.example.com.
+@ 300 IN TXT "v=spf1 include:_spf.example.com ~all" "say \"hi\"\\" "\000"
+_sip._tcp 300 IN SRV 10 60 5060 sip.example.com.
+1.2.0.192.in-addr.arpa. 300 IN PTR www.example.com.
+@ 300 IN HTTPS 1 . mandatory=alpn alpn=h2,h\,3 port=8443 ipv4hint=192.0.2.1 ech=AEX+DQ== ipv6hint=2001:db8::1 key65000="x y"
+@ 300 IN CAA 0 issue "letsencrypt.org"
+_443._tcp 300 IN TLSA 3 1 1 0123456789ABCDEF
+host 300 IN SSHFP 4 2 0102030405
+@ 300 IN NAPTR 100 10 "U" "E2U+sip" "!^.*$!sip:info@example.com!" .
+@ 300 IN DNSKEY 257 3 13 AQID
+@ 300 IN DS 12345 13 2 ABCDEF
+@ 300 IN RRSIG A 13 2 300 20300101000000 20200101000000 42 example.com. AQID
+@ 300 IN NSEC www.example.com. A NS SOA MX TXT AAAA RRSIG NSEC DNSKEY TYPE1234
+@ 300 IN NSEC3 1 1 12 AABBCCDD 2T7B4G4VSA5SMI47K61MV5BV1A22BOJR NS SOA RRSIG
+@ 300 IN NSEC3PARAM 1 0 0 -
+@ 300 IN TYPE65280 \# 4 0a000001
+`)
+	z, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var printed []byte
+	for _, rr := range z.Records {
+		printed = append(printed, fmt.Sprintf("%v\n", rr)...)
+	}
+	again, err := Parse(printed)
+	if err != nil {
+		t.Fatalf("reparsing printed zone: %v\n%s", err, printed)
+	}
+	if len(again.Records) != len(z.Records) {
+		t.Fatalf("expected %d records, got %d", len(z.Records), len(again.Records))
+	}
+	for i := range z.Records {
+		if !reflect.DeepEqual(again.Records[i], z.Records[i]) {
+			t.Errorf("record %d changed in round trip:\nprinted %s\nexpected %+v\ngot      %+v", i, fmt.Sprint(z.Records[i]), z.Records[i], again.Records[i])
+		}
+	}
+}